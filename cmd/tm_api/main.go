@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/stefanovazzocell/TuringMachine/src/api"
+	"github.com/stefanovazzocell/TuringMachine/src/token"
 )
 
 var (
@@ -22,6 +23,20 @@ var (
 
 	gamesDbFile    string
 	dbForceRefresh bool
+
+	sessionTTL      time.Duration
+	sessionStore    string
+	sessionSnapshot time.Duration
+	roomForfeit     time.Duration
+
+	tokenKeys string
+	tokenTTL  time.Duration
+	idSecret  string
+
+	dailySecret string
+	dailyWindow int
+
+	leaderboardFile string
 )
 
 func init() {
@@ -32,9 +47,24 @@ func init() {
 	flag.DurationVar(&writeTimeout, "write_timeout", 10*time.Second, "timeout for the request read+write")
 	flag.DurationVar(&idleTimeout, "idle_timeout", 2*time.Minute, "the keepalive timeout between requests")
 
-	flag.StringVar(&gamesDbFile, "db", "./games", "the location of the games DB file")
+	flag.StringVar(&gamesDbFile, "db", "./games", "the location of the games DB file or an http(s) URL serving it, .jsonl and .csv exports are loaded in memory")
 	flag.BoolVar(&dbForceRefresh, "db_force_refresh", false, "if set, forces the database refresh at startup")
 
+	flag.DurationVar(&sessionTTL, "session_ttl", api.DefaultSessionTTL, "the time after which an idle play session (or an empty room) is dropped")
+	flag.StringVar(&sessionStore, "sessions", "./sessions.kv", "where sessions and rooms are kept across restarts: a file or a redis:// URL, empty to disable")
+	flag.DurationVar(&sessionSnapshot, "session_snapshot", api.DefaultSessionSnapshot, "how often sessions and rooms are saved while running, in case of a crash")
+	flag.DurationVar(&roomForfeit, "room_forfeit", api.DefaultRoomForfeit, "the time after which a player disconnected from a room forfeits, 0 to never forfeit")
+
+	flag.StringVar(&tokenKeys, "token_keys", os.Getenv("TM_TOKEN_KEYS"), "the keys game tokens are signed with as id:secret,id:secret, the first one signs (defaults to $TM_TOKEN_KEYS, random if empty)")
+	flag.DurationVar(&tokenTTL, "token_ttl", api.DefaultTokenTTL, "the lifetime of game tokens, 0 for no expiry")
+
+	flag.StringVar(&idSecret, "id_secret", os.Getenv("TM_ID_SECRET"), "the secret game ids are keyed with (defaults to $TM_ID_SECRET, legacy ids and no signed games if empty)")
+
+	flag.StringVar(&dailySecret, "daily_secret", os.Getenv("TM_DAILY_SECRET"), "the secret daily puzzles are picked with (defaults to $TM_DAILY_SECRET)")
+	flag.IntVar(&dailyWindow, "daily_window", api.DefaultDailyWindow, "the minimum number of days between two daily puzzles with the same game")
+
+	flag.StringVar(&leaderboardFile, "leaderboard", "./leaderboard.log", "the file players, their results and ratings are kept in, empty to keep them in memory")
+
 	flag.TextVar(&logLevel, "log_level", slog.LevelInfo, "sets the log level")
 
 	flag.Parse()
@@ -47,14 +77,34 @@ func init() {
 func main() {
 	config := api.NewAPIConfig(gamesDbFile, corsOrigins)
 	config.StoreForceCreate = dbForceRefresh
+	config.SessionTTL = sessionTTL
+	config.SessionStore = sessionStore
+	config.SessionSnapshot = sessionSnapshot
+	config.RoomForfeit = roomForfeit
+	config.TokenTTL = tokenTTL
+	config.IdSecret = idSecret
+	config.DailySecret = dailySecret
+	config.DailyWindow = dailyWindow
+	config.LeaderboardFile = leaderboardFile
+	if tokenKeys != "" {
+		keys, err := token.ParseKeys(tokenKeys)
+		if err != nil {
+			panic(err)
+		}
+		config.TokenKeys = keys
+	}
 
+	source, err := api.OpenGameSource(config)
+	if err != nil {
+		panic(err)
+	}
 	a, err := api.NewApi(&http.Server{
 		Addr: serverAddr,
 
 		ReadTimeout:  readTimeout,
 		WriteTimeout: writeTimeout,
 		IdleTimeout:  idleTimeout,
-	}, config)
+	}, source, config)
 	if err != nil {
 		panic(err)
 	}
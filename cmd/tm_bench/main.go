@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/bot"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/store"
+)
+
+const (
+	usage = `Usage: tm_bench <command> [flags] <store file or URL>
+
+Commands:
+  strategies   plays games sampled from the store with every bot strategy
+
+Run "tm_bench <command> -h" for the flags of each command.
+`
+)
+
+var (
+	logLevel slog.Level
+)
+
+func init() {
+	flag.TextVar(&logLevel, "log_level", slog.LevelInfo, "sets the log level")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+		Level: logLevel,
+	})))
+}
+
+func main() {
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch flag.Arg(0) {
+	case "strategies":
+		err = strategiesCmd(flag.Args()[1:])
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		slog.Error("command failed", "command", flag.Arg(0), "err", err)
+		os.Exit(1)
+	}
+}
+
+// Handles: tm_bench strategies [-games 20] [-seed 1] [-json file] <store file>
+func strategiesCmd(args []string) error {
+	defaults := bot.DefaultTournamentOptions()
+	cmd := flag.NewFlagSet("strategies", flag.ExitOnError)
+	games := cmd.Int("games", defaults.Games, "the number of games sampled per difficulty and number of choices")
+	seed := cmd.Uint64("seed", defaults.Seed, "the seed used to sample the games")
+	choices := cmd.String("choices", "4,5,6", "the numbers of choices of the games sampled, comma separated")
+	strategies := cmd.String("strategies", strings.Join(defaults.Strategies, ","), "the strategies playing, comma separated")
+	workers := cmd.Int("workers", defaults.Workers, "the number of games played in parallel")
+	jsonOut := cmd.String("json", "", "if set, writes the report as JSON to this file (- for stdout instead of the table)")
+	_ = cmd.Parse(args)
+	if cmd.NArg() != 1 {
+		cmd.Usage()
+		os.Exit(2)
+	}
+
+	options := defaults
+	options.Games = *games
+	options.Seed = *seed
+	options.Strategies = strings.Split(*strategies, ",")
+	options.Workers = *workers
+	options.Choices = nil
+	for _, value := range strings.Split(*choices, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || n < 4 || n > 6 {
+			return fmt.Errorf("invalid number of choices %q", value)
+		}
+		options.Choices = append(options.Choices, n)
+	}
+
+	s, err := openStore(cmd.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	start := time.Now()
+	report, err := bot.RunTournament(ctx, s, options)
+	if err != nil {
+		return err
+	}
+	slog.Info("tournament done",
+		"games", report.Games,
+		"strategies", len(report.Strategies),
+		"duration", time.Since(start))
+
+	switch *jsonOut {
+	case "":
+	case "-":
+		return writeJSON(os.Stdout, report)
+	default:
+		file, err := os.Create(*jsonOut)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		if err = writeJSON(file, report); err != nil {
+			return err
+		}
+	}
+	return writeTable(os.Stdout, report)
+}
+
+// Opens a store file, or a remote store for http(s) URLs
+func openStore(name string) (*store.Store, error) {
+	if strings.HasPrefix(name, "http://") || strings.HasPrefix(name, "https://") {
+		return store.OpenRemoteStore(name, store.NewRemoteOptions())
+	}
+	return store.OpenStore(name)
+}
+
+// Writes a report as indented JSON
+func writeJSON(w io.Writer, report bot.Report) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// Writes a report as tables: the strategies, then the strategies by kind of
+// game
+func writeTable(w io.Writer, report bot.Report) error {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(table, "seed %d, %d games\n\n", report.Seed, report.Games)
+	fmt.Fprintln(table, "STRATEGY\tSOLVED\tWINS\tROUNDS mean/p50/p90/p99\tCHECKS mean/p50/p90/p99\tWEAK SPOTS (criteria: rounds vs mean)")
+	for _, strategy := range report.Strategies {
+		spots := []string{}
+		for _, spot := range strategy.WeakSpots {
+			spots = append(spots, fmt.Sprintf("%d: %.2fx", spot.Criteria, spot.Ratio))
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\t%s\n", strategy.Strategy,
+			percent(strategy.SolveRate), percent(strategy.WinRate),
+			summary(strategy.Rounds), summary(strategy.Checks), strings.Join(spots, ", "))
+	}
+	fmt.Fprintln(table)
+	fmt.Fprintln(table, "STRATEGY\tCHOICES\tDIFFICULTY\tGAMES\tSOLVED\tWINS\tROUNDS mean/p50/p90/p99\tCHECKS mean/p50/p90/p99")
+	for _, strategy := range report.Strategies {
+		for _, bucket := range strategy.Buckets {
+			fmt.Fprintf(table, "%s\t%d\t%s\t%d\t%s\t%s\t%s\t%s\n", strategy.Strategy,
+				bucket.Choices, bucket.Difficulty, bucket.Games,
+				percent(bucket.SolveRate), percent(bucket.WinRate),
+				summary(bucket.Rounds), summary(bucket.Checks))
+		}
+	}
+	return table.Flush()
+}
+
+// Formats a rate as a percentage
+func percent(rate float64) string {
+	return fmt.Sprintf("%.1f%%", rate*100)
+}
+
+// Formats a summary as mean/p50/p90/p99
+func summary(summary bot.Summary) string {
+	return fmt.Sprintf("%.2f/%d/%d/%d", summary.Mean, summary.P50, summary.P90, summary.P99)
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/store"
+)
+
+const (
+	usage = `Usage: tm_store <command> [flags] <store file or URL>
+
+Commands:
+  export   writes the games in the store as JSONL or CSV
+  import   creates a store from a JSONL or CSV file
+  verify   checks the integrity of a store
+
+Run "tm_store <command> -h" for the flags of each command.
+`
+)
+
+var (
+	logLevel slog.Level
+)
+
+func init() {
+	flag.TextVar(&logLevel, "log_level", slog.LevelInfo, "sets the log level")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+		Level: logLevel,
+	})))
+}
+
+func main() {
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch flag.Arg(0) {
+	case "export":
+		err = exportCmd(flag.Args()[1:])
+	case "import":
+		err = importCmd(flag.Args()[1:])
+	case "verify":
+		err = verifyCmd(flag.Args()[1:])
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		slog.Error("command failed", "command", flag.Arg(0), "err", err)
+		os.Exit(1)
+	}
+}
+
+// Handles: tm_store export [-format jsonl] [-choices 6] [-out file] <store file>
+// The -choices flag cannot be combined with -start or -end.
+func exportCmd(args []string) error {
+	cmd := flag.NewFlagSet("export", flag.ExitOnError)
+	formatName := cmd.String("format", "", "the output format (jsonl or csv), defaults to the output file extension or jsonl")
+	choices := cmd.Int("choices", 0, "if set, only export games with this number of choices (cannot be combined with -start or -end)")
+	start := cmd.Int64("start", 0, "the index of the first game to export")
+	end := cmd.Int64("end", -1, "the index after the last game to export, -1 exports until the end")
+	out := cmd.String("out", "", "the output file, defaults to stdout")
+	_ = cmd.Parse(args)
+	if cmd.NArg() != 1 {
+		cmd.Usage()
+		os.Exit(2)
+	}
+	if *choices != 0 && (isFlagSet(cmd, "start") || isFlagSet(cmd, "end")) {
+		return errors.New("-choices cannot be combined with -start or -end")
+	}
+
+	format, err := formatFor(*formatName, *out)
+	if err != nil {
+		return err
+	}
+	s, err := openStore(cmd.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	if *end == -1 {
+		*end = s.NumberOfGames()
+	}
+	if *choices != 0 {
+		*start, *end = s.GameRangeByChoices(*choices)
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		file, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+	slog.Info("exporting games",
+		"format", format.String(),
+		"start", *start,
+		"end", *end)
+	return s.Export(w, format, *start, *end)
+}
+
+// Handles: tm_store import [-format jsonl] [-in file] <store file>
+func importCmd(args []string) error {
+	cmd := flag.NewFlagSet("import", flag.ExitOnError)
+	formatName := cmd.String("format", "", "the input format (jsonl or csv), defaults to the input file extension or jsonl")
+	in := cmd.String("in", "", "the input file, defaults to stdin")
+	_ = cmd.Parse(args)
+	if cmd.NArg() != 1 {
+		cmd.Usage()
+		os.Exit(2)
+	}
+
+	format, err := formatFor(*formatName, *in)
+	if err != nil {
+		return err
+	}
+	var r io.Reader = os.Stdin
+	if *in != "" {
+		file, err := os.Open(*in)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		r = file
+	}
+
+	s, err := store.Import(r, format, cmd.Arg(0))
+	if err != nil {
+		return err
+	}
+	slog.Info("imported games", "games", s.NumberOfGames())
+	return s.Close()
+}
+
+// Handles: tm_store verify [-max_issues 10] [-regenerate] <store file>
+// Exits with status 1 if any issue is found.
+func verifyCmd(args []string) error {
+	cmd := flag.NewFlagSet("verify", flag.ExitOnError)
+	maxIssues := cmd.Int("max_issues", store.DefaultVerifyMaxIssues, "the number of issues to report before stopping, 0 reports all")
+	regenerate := cmd.Bool("regenerate", false, "if set, also compares the store with a freshly generated one")
+	_ = cmd.Parse(args)
+	if cmd.NArg() != 1 {
+		cmd.Usage()
+		os.Exit(2)
+	}
+
+	s, err := openStore(cmd.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	issues, err := s.Verify(ctx, store.VerifyOptions{
+		MaxIssues:  *maxIssues,
+		Regenerate: *regenerate,
+	})
+	if err != nil {
+		return err
+	}
+	for _, issue := range issues {
+		fmt.Println(issue.String())
+	}
+	if len(issues) > 0 {
+		s.Close()
+		os.Exit(1)
+	}
+	fmt.Printf("%s: %d games, no issues found\n", cmd.Arg(0), s.NumberOfGames())
+	return nil
+}
+
+// Returns true if a flag was set on the command line
+func isFlagSet(cmd *flag.FlagSet, name string) (set bool) {
+	cmd.Visit(func(f *flag.Flag) {
+		set = set || f.Name == name
+	})
+	return
+}
+
+// Opens a store file, or a remote store for http(s) URLs
+func openStore(name string) (*store.Store, error) {
+	if strings.HasPrefix(name, "http://") || strings.HasPrefix(name, "https://") {
+		return store.OpenRemoteStore(name, store.NewRemoteOptions())
+	}
+	return store.OpenStore(name)
+}
+
+// Returns the format with the given name or, if not set, the one matching the
+// file extension (defaulting to JSONL).
+func formatFor(name string, filename string) (store.Format, error) {
+	if name != "" {
+		return store.FormatFromString(name)
+	}
+	if strings.EqualFold(filepath.Ext(filename), ".csv") {
+		return store.FormatCSV, nil
+	}
+	return store.FormatJSONL, nil
+}
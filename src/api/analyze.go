@@ -0,0 +1,93 @@
+package api
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+)
+
+type AnalyzeResponse struct {
+	GameId string `json:"game_id"`
+	Won    bool   `json:"won"`
+	// The round after which the code was uniquely determined (0 if it was
+	// from the start), -1 if it never was
+	Determined int `json:"determined"`
+	// The number of queries with a result already known
+	Wasted int `json:"wasted"`
+	// The rounds with queries
+	Rounds []AnalyzedRound `json:"rounds"`
+}
+
+// How a round compares with the most informative queries of that round,
+// information is in bits over the combinations of laws still possible
+type AnalyzedRound struct {
+	// The round, starting from 1
+	Round     int    `json:"round"`
+	Proposal  string `json:"proposal"`
+	Verifiers []int  `json:"verifier_slots"`
+	// The combinations of laws still possible before and after the round
+	Before int `json:"before"`
+	After  int `json:"after"`
+	// The codes still possible before and after the round
+	SolutionsBefore int `json:"solutions_before"`
+	SolutionsAfter  int `json:"solutions_after"`
+	// The information the round gave, and was expected to give
+	Gained   float64 `json:"gained"`
+	Expected float64 `json:"expected"`
+	// The most informative single round of queries and its expected
+	// information, not set once the code is determined. It is picked one
+	// round at a time (see bot.Greedy), it is not an optimal plan for the
+	// rest of the game.
+	Best          float64 `json:"best"`
+	BestProposal  string  `json:"best_proposal,omitempty"`
+	BestVerifiers []int   `json:"best_verifier_slots,omitempty"`
+	// The verifier slots queried whose result was already known
+	Wasted []int `json:"wasted"`
+}
+
+// Handles POST /api/analyze {version: 1, game_id: "XXXXX", ...}
+func (a *api) handleAnalyze(w http.ResponseWriter, r *http.Request) {
+	upload, g, player, ok := a.readReplay(w, r)
+	if !ok {
+		return
+	}
+	analysis, err := upload.Analyze(g)
+	if err != nil {
+		writeInvalidReplay(w, err)
+		return
+	}
+
+	response := AnalyzeResponse{
+		GameId:     a.gameId(g),
+		Won:        player.Solved,
+		Determined: analysis.Determined,
+		Wasted:     analysis.Wasted,
+		Rounds:     []AnalyzedRound{},
+	}
+	for _, round := range analysis.Rounds {
+		analyzed := AnalyzedRound{
+			Round:           round.Round,
+			Proposal:        round.Proposal.String(),
+			Verifiers:       round.Verifiers,
+			Before:          round.CombinationsBefore,
+			After:           round.CombinationsAfter,
+			SolutionsBefore: round.SolutionsBefore,
+			SolutionsAfter:  round.SolutionsAfter,
+			Gained:          bits(round.Gained),
+			Expected:        bits(round.Expected),
+			Best:            bits(round.Best),
+			BestVerifiers:   round.BestVerifiers,
+			Wasted:          round.Wasted,
+		}
+		if len(round.BestVerifiers) > 0 {
+			analyzed.BestProposal = round.BestProposal.String()
+		}
+		response.Rounds = append(response.Rounds, analyzed)
+	}
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// Returns an amount of information rounded to keep the report compact
+func bits(information float64) float64 {
+	return math.Round(information*1000) / 1000
+}
@@ -0,0 +1,72 @@
+package api_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stefanovazzocell/TuringMachine/src/api"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/replay"
+)
+
+func TestAnalyze(t *testing.T) {
+	t.Parallel()
+
+	games := randomGames(t, 10)
+	ts := newTestServer(t, games)
+	g, par := games[0], game.Par{}
+	// A game that needs some queries
+	for _, g = range games {
+		var err error
+		if par, err = game.PlayPar(g); err != nil {
+			t.Fatalf("PlayPar failed: %v", err)
+		}
+		if par.Checks() > 0 {
+			break
+		}
+	}
+
+	// The replay of the par bot, with a repeated query
+	now := time.Now()
+	played, err := replay.New(g, g.String(), replay.ModeSession, game.LozengeSymbol, now)
+	if err != nil {
+		t.Fatalf("Failed to create replay: %v", err)
+	}
+	for i, round := range par.Rounds {
+		if i > 0 {
+			played.NextRound(now)
+		}
+		for _, query := range round.Queries {
+			played.Query(query.Proposal, query.Verifier, query.Result, now)
+		}
+	}
+	first := par.Rounds[0].Queries[0]
+	played.NextRound(now)
+	played.Query(first.Proposal, first.Verifier, first.Result, now)
+	played.MakeGuess(par.Guess, true, now)
+
+	analysis := api.AnalyzeResponse{}
+	if status := doRequest(t, "POST", ts.URL+"/api/analyze", played, &analysis); status != http.StatusOK {
+		t.Fatalf("Expected %d for the analysis, got %d", http.StatusOK, status)
+	}
+	if !analysis.Won || analysis.GameId != g.String() || analysis.Wasted != 1 ||
+		len(analysis.Rounds) != par.RoundsPlayed()+1 ||
+		analysis.Determined < 1 || analysis.Determined > par.RoundsPlayed() {
+		t.Fatalf("Unexpected analysis %+v", analysis)
+	}
+	start, last := analysis.Rounds[0], analysis.Rounds[len(analysis.Rounds)-1]
+	if start.Proposal != first.Proposal.String() || start.BestProposal == "" || len(start.BestVerifiers) == 0 ||
+		start.Before <= start.After || start.Gained <= 0 || start.Expected > start.Best || len(start.Wasted) != 0 {
+		t.Fatalf("Unexpected first round %+v", start)
+	}
+	if last.SolutionsAfter != 1 || last.Gained != 0 || last.BestProposal != "" || len(last.Wasted) != 1 {
+		t.Fatalf("Unexpected last round %+v", last)
+	}
+
+	// Invalid replays are rejected
+	played.Rounds[0].Queries[0].Result = !played.Rounds[0].Queries[0].Result
+	if status := doRequest(t, "POST", ts.URL+"/api/analyze", played, nil); status != http.StatusBadRequest {
+		t.Fatalf("Expected %d for a tampered replay, got %d", http.StatusBadRequest, status)
+	}
+}
@@ -2,19 +2,54 @@ package api
 
 import (
 	"context"
+	"crypto/rand"
 	"errors"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
+	"time"
 
+	"github.com/stefanovazzocell/TuringMachine/src/kv"
+	"github.com/stefanovazzocell/TuringMachine/src/token"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/daily"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/leaderboard"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/matchmaking"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/rating"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/session"
 	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/store"
 )
 
 // An API for turingmachine
 type api struct {
-	store *store.Store
+	store    store.GameSource
+	similar  *lazyCriteriaIndex
+	sessions *session.Manager
+	rooms    *session.RoomManager
+	pars     *parCache
+	// Nil unless sessions are persisted across restarts
+	backend kv.Backend
+	// Closed once the sessions are no longer snapshotted, nil unless they are
+	// persisted
+	snapshotted chan struct{}
+	tokens      *token.Signer
+	// Nil unless game ids are keyed
+	ids *game.IdCipher
+	// Keys the games of the tokens, nil unless game ids are keyed (signed
+	// games are disabled without it)
+	tokenIds *game.IdCipher
+	daily    *daily.Schedule
+	// The leaderboard and ratings, and the log they are stored in
+	leaderboard    *leaderboard.Board
+	ratings        *rating.Ratings
+	leaderboardLog *kv.LogBackend
+	lobby          *matchmaking.Lobby
+	// Closed to stop matchmaking
+	done chan struct{}
 
 	server *http.Server
 	mux    *http.ServeMux
@@ -22,21 +57,108 @@ type api struct {
 	config apiConfig
 }
 
-// Sets up a new API and registers itself as the server handler.
-// The provided server should not be modified by other entities going forward.
-func NewApi(server *http.Server, config apiConfig) (a *api, err error) {
-	// Make sure the server is non-nil
+// Sets up a new API serving games from source and registers itself as the
+// server handler.
+// The provided server should not be modified by other entities going forward,
+// the source is closed when the API is.
+func NewApi(server *http.Server, source store.GameSource, config apiConfig) (a *api, err error) {
+	// Make sure the server and source are non-nil
 	if server == nil {
 		panic("the server cannot be nil")
 	}
+	if source == nil {
+		panic("the source cannot be nil")
+	}
 
 	a = &api{
+		store:    source,
+		similar:  &lazyCriteriaIndex{},
+		sessions: session.NewManager(config.SessionTTL),
+		rooms:    session.NewRoomManager(config.SessionTTL, config.RoomForfeit),
+		pars:     newParCache(),
+		lobby:    matchmaking.NewLobby(config.Matchmaking, matchmaking.SystemClock{}),
+		done:     make(chan struct{}),
+
 		server: server,
 		mux:    http.NewServeMux(),
 
 		config: config,
 	}
 
+	// Key the game ids, and the games in the tokens with another key: the
+	// token claims are readable, their game must not be usable as an id
+	if config.IdSecret != "" {
+		a.ids = game.NewIdCipher([]byte(config.IdSecret))
+		a.tokenIds = game.NewIdCipher([]byte("token:" + config.IdSecret))
+	} else {
+		slog.Warn("no id secret configured, signed games are disabled")
+	}
+
+	// Set up the daily puzzles
+	if config.DailySecret == "" {
+		slog.Warn("no daily secret configured, daily puzzles can be predicted")
+	}
+	a.daily = daily.NewSchedule(source, []byte(config.DailySecret), config.DailyWindow, config.DailyRotation)
+
+	// Set up the game tokens signer
+	keys := config.TokenKeys
+	if len(keys) == 0 {
+		slog.Warn("no token keys configured, using a random key")
+		secret := make([]byte, 32)
+		if _, err = rand.Read(secret); err != nil {
+			return nil, err
+		}
+		keys = []token.Key{{Id: "random", Secret: secret}}
+	}
+	if a.tokens, err = token.NewSigner(config.TokenTTL, keys...); err != nil {
+		return nil, err
+	}
+
+	// Open the leaderboard and ratings
+	if a.leaderboardLog, err = kv.OpenLog(config.LeaderboardFile); err != nil {
+		return nil, err
+	}
+	a.leaderboard = leaderboard.New(a.leaderboardLog)
+	a.ratings = rating.New(a.leaderboardLog)
+	a.rooms.OnRated(a.rateRoom)
+
+	// Restore the sessions and rooms from the last run
+	if config.SessionStore != "" {
+		if a.backend, err = kv.Open(config.SessionStore); err != nil {
+			return nil, err
+		}
+		a.restoreSessions()
+		a.snapshotted = make(chan struct{})
+		go a.snapshotSessions()
+	}
+
+	// Register routes and set http handler
+	a.registerRoutes()
+	server.Handler = a.mux
+	go a.matchmake()
+	return
+}
+
+// Opens the games source described by the config:
+// - an http(s) URL is opened as a remote store (see store.OpenRemoteStore)
+// - a JSONL or CSV file is loaded into a memory store (see store.Import)
+// - any other file is opened as a store, creating it if needed
+func OpenGameSource(config apiConfig) (source store.GameSource, err error) {
+	// Read remote stores with range requests
+	if isRemoteStore(config.StoreFileName) {
+		return store.OpenRemoteStore(config.StoreFileName, store.NewRemoteOptions())
+	}
+
+	// Load exported games in memory
+	if format, ok := exportFormat(config.StoreFileName); ok {
+		file, err := os.Open(config.StoreFileName)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		return store.LoadMemoryStore(file, format)
+	}
+
 	// Check if we need to create the store
 	createStore := config.StoreForceCreate
 	if !createStore {
@@ -45,18 +167,21 @@ func NewApi(server *http.Server, config apiConfig) (a *api, err error) {
 	}
 	// Create or open the store
 	if createStore {
-		a.store, err = store.CreateStore(config.StoreFileName)
-	} else {
-		a.store, err = store.OpenStore(config.StoreFileName)
-	}
-	if err != nil {
-		return
+		return store.CreateStore(config.StoreFileName)
 	}
+	return store.OpenStore(config.StoreFileName)
+}
 
-	// Register routes and set http handler
-	a.registerRoutes()
-	server.Handler = a.mux
-	return
+// Returns true if the store file name is an http(s) URL
+func isRemoteStore(filename string) bool {
+	return strings.HasPrefix(filename, "http://") || strings.HasPrefix(filename, "https://")
+}
+
+// Returns the export format for a file name and true, if it has the
+// extension of an export format
+func exportFormat(filename string) (store.Format, bool) {
+	format, err := store.FormatFromString(strings.TrimPrefix(filepath.Ext(filename), "."))
+	return format, err == nil
 }
 
 // Start listening for incoming connections
@@ -103,9 +228,11 @@ func (a api) AwaitInterrupt() {
 		"interrupt", (<-signalChan).String())
 }
 
-// Shuts down the http server and closes the store
+// Shuts down the http server, stops matchmaking, saves the sessions (if
+// persisted) and closes the leaderboard and the games source
 func (a api) Close() {
 	var err error
+	close(a.done)
 	// Shutdown http server
 	gracefullCtx, cancelShutdown := context.WithTimeout(context.Background(), a.config.ShutdownTimeout)
 	{
@@ -116,6 +243,16 @@ func (a api) Close() {
 		slog.Error("got error during server shutdown",
 			"err", err)
 	}
+	// Persist the sessions and rooms for the next run
+	if a.backend != nil {
+		<-a.snapshotted
+		a.saveSessions()
+	}
+	// Closes the leaderboard
+	if err = a.leaderboardLog.Close(); err != nil {
+		slog.Error("got error while closing the leaderboard",
+			"err", err)
+	}
 	// Closes store
 	err = a.store.Close()
 	if err != nil {
@@ -123,3 +260,82 @@ func (a api) Close() {
 			"err", err)
 	}
 }
+
+// Loads the sessions and rooms from the backend, the ones that cannot be
+// restored are skipped
+func (a *api) restoreSessions() {
+	start := time.Now()
+	sessions, err := a.sessions.Restore(a.backend)
+	if err != nil {
+		slog.Warn("some sessions could not be restored",
+			"err", err)
+	}
+	rooms, err := a.rooms.Restore(a.backend)
+	if err != nil {
+		slog.Warn("some rooms could not be restored",
+			"err", err)
+	}
+	slog.Info("sessions restored",
+		"sessions", sessions,
+		"rooms", rooms,
+		"duration", time.Since(start))
+}
+
+// Writes the sessions and rooms to the backend and closes it
+func (a api) saveSessions() {
+	start := time.Now()
+	sessions, rooms := a.writeSessions()
+	if err := a.backend.Close(); err != nil {
+		slog.Error("got error while closing the sessions backend",
+			"err", err)
+	}
+	slog.Info("sessions saved",
+		"sessions", sessions,
+		"rooms", rooms,
+		"duration", time.Since(start))
+}
+
+// Writes the sessions and rooms to the backend every snapshot interval,
+// until the api is closed
+func (a *api) snapshotSessions() {
+	defer close(a.snapshotted)
+	if a.config.SessionSnapshot <= 0 {
+		return
+	}
+	ticker := time.NewTicker(a.config.SessionSnapshot)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.done:
+			return
+		case <-ticker.C:
+		}
+		start := time.Now()
+		sessions, rooms := a.writeSessions()
+		slog.Debug("sessions snapshotted",
+			"sessions", sessions,
+			"rooms", rooms,
+			"duration", time.Since(start))
+	}
+}
+
+// Writes the sessions and rooms to the backend and flushes it (if needed),
+// returns the number of sessions and rooms written
+func (a api) writeSessions() (sessions int, rooms int) {
+	var err error
+	if sessions, err = a.sessions.Save(a.backend); err != nil {
+		slog.Error("got error while saving sessions",
+			"err", err)
+	}
+	if rooms, err = a.rooms.Save(a.backend); err != nil {
+		slog.Error("got error while saving rooms",
+			"err", err)
+	}
+	if flusher, ok := a.backend.(interface{ Flush() error }); ok {
+		if err = flusher.Flush(); err != nil {
+			slog.Error("got error while flushing the sessions backend",
+				"err", err)
+		}
+	}
+	return
+}
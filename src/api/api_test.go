@@ -0,0 +1,439 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stefanovazzocell/TuringMachine/src/api"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/store"
+)
+
+// Returns n random valid games with 4 to 6 choices
+func randomGames(t testing.TB, n int) []game.Game {
+	games := make([]game.Game, n)
+	difficulties := []game.Difficulty{game.EasyDifficulty, game.StandardDifficulty, game.HardDifficulty}
+	var err error
+	for i := range n {
+		games[i], err = game.RandomSolvableGame(4+i%3, difficulties[i%len(difficulties)])
+		if err != nil {
+			t.Fatalf("Failed to generate random game: %v", err)
+		}
+	}
+	return games
+}
+
+// Returns a test server for an API serving the given games
+func newTestServer(t testing.TB, games []game.Game) *httptest.Server {
+	server := &http.Server{}
+	a, err := api.NewApi(server, memorySource(t, games), api.NewAPIConfig("", "*"))
+	if err != nil {
+		t.Fatalf("Failed to create api: %v", err)
+	}
+	return serveApi(t, server, a)
+}
+
+// Returns a test server with keyed game ids, so it issues signed games
+func newSignedTestServer(t testing.TB, games []game.Game) *httptest.Server {
+	config := api.NewAPIConfig("", "*")
+	config.IdSecret = "secret"
+	server := &http.Server{}
+	a, err := api.NewApi(server, memorySource(t, games), config)
+	if err != nil {
+		t.Fatalf("Failed to create api: %v", err)
+	}
+	return serveApi(t, server, a)
+}
+
+// Returns a memory store for the given games
+func memorySource(t testing.TB, games []game.Game) store.GameSource {
+	source, err := store.NewMemoryStore(games)
+	if err != nil {
+		t.Fatalf("Failed to create memory store: %v", err)
+	}
+	return source
+}
+
+// Returns a test server for an API created on server, both are closed at the
+// end of the test
+func serveApi(t testing.TB, server *http.Server, a interface{ Close() }) *httptest.Server {
+	ts := httptest.NewServer(server.Handler)
+	t.Cleanup(func() {
+		ts.Close()
+		a.Close()
+	})
+	return ts
+}
+
+// Performs a request and decodes the JSON response into v (if not nil).
+// Returns the response status code.
+func doRequest(t testing.TB, method, url string, body any, v any) int {
+	var reqBody *bytes.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("Failed to marshal body: %v", err)
+		}
+		reqBody = bytes.NewReader(raw)
+	} else {
+		reqBody = bytes.NewReader(nil)
+	}
+	req, err := http.NewRequest(method, url, reqBody)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s failed: %v", method, url, err)
+	}
+	defer resp.Body.Close()
+	if v != nil && resp.StatusCode == http.StatusOK {
+		if err = json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatalf("Failed to decode response of %s %s: %v", method, url, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestGetGame(t *testing.T) {
+	t.Parallel()
+
+	games := randomGames(t, 60)
+	ts := newTestServer(t, games)
+
+	for _, g := range games[:10] {
+		response := api.GameResponse{}
+		status := doRequest(t, "GET", ts.URL+"/api/game?id="+g.String(), nil, &response)
+		code, _ := g.Solve()
+		if status != http.StatusOK || response.Id != g.String() || response.Code != code.String() {
+			t.Fatalf("GET /api/game?id=%s returned %d %+v", g.String(), status, response)
+		}
+	}
+
+	for choices := 4; choices <= 6; choices++ {
+		response := api.GameResponse{}
+		status := doRequest(t, "GET", ts.URL+"/api/game?choices="+strconv.Itoa(choices), nil, &response)
+		if status != http.StatusOK || len(response.Criterias) != choices {
+			t.Fatalf("GET /api/game?choices=%d returned %d %+v", choices, status, response)
+		}
+	}
+
+	for _, query := range []string{"id=" + game.Game{}.String(), "id=123", "choices=9"} {
+		if status := doRequest(t, "GET", ts.URL+"/api/game?"+query, nil, nil); status != http.StatusBadRequest {
+			t.Errorf("GET /api/game?%s returned %d, expected %d", query, status, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestSolveAndVerify(t *testing.T) {
+	t.Parallel()
+
+	games := randomGames(t, 10)
+	ts := newTestServer(t, games)
+
+	for _, g := range games {
+		criterias, _, laws := g.GetCards()
+		code, _ := g.Solve()
+
+		response := api.SolverResponse{}
+		status := doRequest(t, "POST", ts.URL+"/api/solve", api.SolverRequest{
+			Criterias: criterias,
+			Verifiers: laws,
+		}, &response)
+		if status != http.StatusOK || !slices.Equal(response.Solutions, []string{code.String()}) {
+			t.Fatalf("POST /api/solve for %s returned %d %+v", g.Debug(), status, response)
+		}
+
+		for _, law := range laws {
+			verify := api.VerifyResponse{}
+			status = doRequest(t, "GET", ts.URL+"/api/verify?"+url.Values{
+				"law":      {strconv.Itoa(law)},
+				"proposal": {code.String()},
+			}.Encode(), nil, &verify)
+			if status != http.StatusOK || !verify.Check {
+				t.Fatalf("GET /api/verify?law=%d&proposal=%s returned %d %+v", law, code, status, verify)
+			}
+		}
+	}
+}
+
+func TestGetSimilarGames(t *testing.T) {
+	t.Parallel()
+
+	games := randomGames(t, 200)
+	ts := newTestServer(t, games)
+
+	response := api.SimilarResponse{}
+	status := doRequest(t, "GET", ts.URL+"/api/game/similar?shared=1&limit=5&id="+games[0].String(), nil, &response)
+	if status != http.StatusOK || response.Id != games[0].String() || len(response.Similar) == 0 || len(response.Similar) > 5 {
+		t.Fatalf("GET /api/game/similar returned %d %+v", status, response)
+	}
+	for _, similar := range response.Similar {
+		if similar.Id == games[0].String() || similar.Shared < 1 && !similar.SameCode && !similar.Swap {
+			t.Errorf("Unexpected similar game %+v", similar)
+		}
+	}
+	if status = doRequest(t, "GET", ts.URL+"/api/game/similar?limit=-1&id="+games[0].String(), nil, nil); status != http.StatusBadRequest {
+		t.Errorf("Expected %d for a negative limit, got %d", http.StatusBadRequest, status)
+	}
+}
+
+// A game source that fails to read games once
+type flakySource struct {
+	store.GameSource
+	failed atomic.Bool
+}
+
+func (source *flakySource) GetGame(idx int64) (game.Game, error) {
+	if source.failed.CompareAndSwap(false, true) {
+		return game.Game{}, errors.New("transient read error")
+	}
+	return source.GameSource.GetGame(idx)
+}
+
+func TestGetSimilarGamesRetry(t *testing.T) {
+	t.Parallel()
+
+	games := randomGames(t, 20)
+	server := &http.Server{}
+	a, err := api.NewApi(server, &flakySource{GameSource: memorySource(t, games)}, api.NewAPIConfig("", "*"))
+	if err != nil {
+		t.Fatalf("Failed to create api: %v", err)
+	}
+	ts := serveApi(t, server, a)
+
+	// The index is built again after a failure
+	url := ts.URL + "/api/game/similar?shared=1&id=" + games[0].String()
+	if status := doRequest(t, "GET", url, nil, nil); status != http.StatusInternalServerError {
+		t.Fatalf("Expected %d for a failed index, got %d", http.StatusInternalServerError, status)
+	}
+	if status := doRequest(t, "GET", url, nil, nil); status != http.StatusOK {
+		t.Fatalf("Expected %d after a failed index, got %d", http.StatusOK, status)
+	}
+}
+
+func TestVerifySlotAndGuess(t *testing.T) {
+	t.Parallel()
+
+	games := randomGames(t, 10)
+	ts := newTestServer(t, games)
+
+	for _, g := range games {
+		code, _ := g.Solve()
+		for slot := range g.NumberOfChoices() {
+			verify := api.VerifyResponse{}
+			status := doRequest(t, "GET", ts.URL+"/api/verify?"+url.Values{
+				"id":       {g.String()},
+				"slot":     {strconv.Itoa(slot)},
+				"proposal": {code.String()},
+			}.Encode(), nil, &verify)
+			if status != http.StatusOK || !verify.Check {
+				t.Fatalf("GET /api/verify?id=%s&slot=%d&proposal=%s returned %d %+v", g, slot, code, status, verify)
+			}
+		}
+		// Some verifier must reject any other code
+		wrong := game.CodeFromNumbers(1, 1, 1)
+		if wrong == code {
+			wrong = game.MaxCode
+		}
+		rejected := false
+		for slot := range g.NumberOfChoices() {
+			verify := api.VerifyResponse{}
+			doRequest(t, "GET", ts.URL+"/api/verify?"+url.Values{
+				"id":       {g.String()},
+				"slot":     {strconv.Itoa(slot)},
+				"proposal": {wrong.String()},
+			}.Encode(), nil, &verify)
+			rejected = rejected || !verify.Check
+		}
+		if !rejected {
+			t.Fatalf("Expected %s to be rejected by a verifier of %s", wrong, g.Debug())
+		}
+
+		for _, guess := range []game.Code{code, wrong} {
+			response := api.GuessResponse{}
+			status := doRequest(t, "POST", ts.URL+"/api/guess", api.GuessRequest{
+				Id:   g.String(),
+				Code: guess.String(),
+			}, &response)
+			if status != http.StatusOK || response.Correct != (guess == code) {
+				t.Fatalf("POST /api/guess with %s returned %d %+v for %s", guess, status, response, g.Debug())
+			}
+		}
+	}
+
+	id := games[0].String()
+	testCases := []struct {
+		method   string
+		url      string
+		body     any
+		expected int
+	}{
+		{"GET", "/api/verify?id=" + id + "&slot=9&proposal=111", nil, http.StatusBadRequest},
+		{"GET", "/api/verify?id=" + id + "&slot=-1&proposal=111", nil, http.StatusBadRequest},
+		{"GET", "/api/verify?id=" + id + "&proposal=111", nil, http.StatusBadRequest},
+		{"GET", "/api/verify?id=" + id + "&slot=0&proposal=999", nil, http.StatusBadRequest},
+		{"GET", "/api/verify?id=XXX&slot=0&proposal=111", nil, http.StatusBadRequest},
+		{"POST", "/api/guess", api.GuessRequest{Id: "XXX", Code: "111"}, http.StatusBadRequest},
+		{"POST", "/api/guess", api.GuessRequest{Id: id, Code: "611"}, http.StatusBadRequest},
+	}
+	for _, testCase := range testCases {
+		if status := doRequest(t, testCase.method, ts.URL+testCase.url, testCase.body, nil); status != testCase.expected {
+			t.Errorf("%s %s returned %d, expected %d", testCase.method, testCase.url, status, testCase.expected)
+		}
+	}
+}
+
+func TestKeyedGameIds(t *testing.T) {
+	t.Parallel()
+
+	games := randomGames(t, 10)
+	config := api.NewAPIConfig("", "*")
+	config.IdSecret = "secret"
+	server := &http.Server{}
+	a, err := api.NewApi(server, memorySource(t, games), config)
+	if err != nil {
+		t.Fatalf("Failed to create api: %v", err)
+	}
+	ts := serveApi(t, server, a)
+	legacyServer := newTestServer(t, games)
+	g := games[0]
+	code, _ := g.Solve()
+
+	// Legacy ids are accepted, keyed ids are returned
+	response := api.GameResponse{}
+	status := doRequest(t, "GET", ts.URL+"/api/game?id="+g.String(), nil, &response)
+	if status != http.StatusOK || !game.IsKeyedId(response.Id) || response.Code != code.String() {
+		t.Fatalf("GET /api/game?id=%s returned %d %+v", g, status, response)
+	}
+	keyed := api.GameResponse{}
+	status = doRequest(t, "GET", ts.URL+"/api/game?id="+response.Id, nil, &keyed)
+	if status != http.StatusOK || keyed.Id != response.Id || keyed.Code != response.Code {
+		t.Fatalf("GET /api/game?id=%s returned %d %+v", response.Id, status, keyed)
+	}
+	verify := api.VerifyResponse{}
+	status = doRequest(t, "GET", ts.URL+"/api/verify?"+url.Values{
+		"id":       {response.Id},
+		"slot":     {"0"},
+		"proposal": {code.String()},
+	}.Encode(), nil, &verify)
+	if status != http.StatusOK || !verify.Check {
+		t.Fatalf("GET /api/verify?id=%s returned %d %+v", response.Id, status, verify)
+	}
+
+	// Keyed ids cannot be decoded without the key
+	if status = doRequest(t, "GET", legacyServer.URL+"/api/game?id="+response.Id, nil, nil); status != http.StatusBadRequest {
+		t.Errorf("GET /api/game?id=%s without the key returned %d, expected %d", response.Id, status, http.StatusBadRequest)
+	}
+}
+
+func TestGameIdCorrections(t *testing.T) {
+	t.Parallel()
+
+	games := randomGames(t, 10)
+	ts := newTestServer(t, games)
+	g := games[0]
+	displayId := game.FormatId(g.String())
+
+	response := api.GameResponse{}
+	status := doRequest(t, "GET", ts.URL+"/api/game?id="+displayId, nil, &response)
+	if status != http.StatusOK || response.Id != g.String() || response.DisplayId != displayId {
+		t.Fatalf("GET /api/game?id=%s returned %d %+v", displayId, status, response)
+	}
+
+	// A swapped character is caught by the check symbol
+	typo := displayId[:1] + displayId[2:3] + displayId[1:2] + displayId[3:]
+	if typo == displayId {
+		typo = displayId[:4] + displayId[5:6] + displayId[4:5] + displayId[6:]
+	}
+	for _, id := range []string{typo, displayId[:5] + "!" + displayId[6:]} {
+		resp, err := http.Get(ts.URL + "/api/game?" + url.Values{"id": {id}}.Encode())
+		if err != nil {
+			t.Fatalf("GET /api/game?id=%s failed: %v", id, err)
+		}
+		invalid := api.InvalidGameResponse{}
+		err = json.NewDecoder(resp.Body).Decode(&invalid)
+		resp.Body.Close()
+		if err != nil || resp.StatusCode != http.StatusBadRequest || invalid.Position == nil ||
+			resp.Header.Get("TM-Invalid-Game-Reason") != invalid.Error {
+			t.Fatalf("GET /api/game?id=%s returned %d %+v (%v)", id, resp.StatusCode, invalid, err)
+		}
+		if !slices.Contains(invalid.Suggestions, displayId) {
+			t.Fatalf("Expected %s in the suggestions for %s, got %v", displayId, id, invalid.Suggestions)
+		}
+	}
+}
+
+func TestGamePhrase(t *testing.T) {
+	t.Parallel()
+
+	games := randomGames(t, 10)
+	ts := newTestServer(t, games)
+
+	for _, g := range games {
+		response := api.GameResponse{}
+		status := doRequest(t, "GET", ts.URL+"/api/game?id="+g.String(), nil, &response)
+		if status != http.StatusOK || response.Phrase != g.Phrase() {
+			t.Fatalf("GET /api/game?id=%s returned %d %+v", g, status, response)
+		}
+		byPhrase := api.GameResponse{}
+		status = doRequest(t, "GET", ts.URL+"/api/game?"+url.Values{"phrase": {response.Phrase}}.Encode(), nil, &byPhrase)
+		if status != http.StatusOK || byPhrase.Id != response.Id || byPhrase.Code != response.Code {
+			t.Fatalf("GET /api/game?phrase=%s returned %d %+v", response.Phrase, status, byPhrase)
+		}
+	}
+	for _, phrase := range []string{"amber-falcon", "amber-falcon-river-seven-quokka"} {
+		if status := doRequest(t, "GET", ts.URL+"/api/game?"+url.Values{"phrase": {phrase}}.Encode(), nil, nil); status != http.StatusBadRequest {
+			t.Errorf("GET /api/game?phrase=%s returned %d, expected %d", phrase, status, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestPuzzleNumbers(t *testing.T) {
+	t.Parallel()
+
+	games := randomGames(t, 30)
+	source := memorySource(t, games)
+	ts := newTestServer(t, games)
+
+	for choices := 4; choices <= 6; choices++ {
+		start, end := source.GameRangeByChoices(choices)
+		// Walk through the puzzles with the same number of choices
+		number, previous := start+1, int64(0)
+		for number != 0 {
+			expected, err := source.GetGame(number - 1)
+			if err != nil {
+				t.Fatalf("Failed to get game %d: %v", number-1, err)
+			}
+			response := api.GameResponse{}
+			status := doRequest(t, "GET", ts.URL+"/api/game?number="+strconv.FormatInt(number, 10), nil, &response)
+			if status != http.StatusOK || response.Id != expected.String() || response.Number != number || response.PreviousNumber != previous {
+				t.Fatalf("GET /api/game?number=%d returned %d %+v, expected %s", number, status, response, expected)
+			}
+			// The number is also returned for requests by id
+			byId := api.GameResponse{}
+			doRequest(t, "GET", ts.URL+"/api/game?id="+response.Id, nil, &byId)
+			if byId.Number != number || byId.NextNumber != response.NextNumber {
+				t.Fatalf("GET /api/game?id=%s returned %+v, expected number %d", response.Id, byId, number)
+			}
+			previous, number = number, response.NextNumber
+		}
+		if previous != end {
+			t.Fatalf("Expected the walk for %d choices to end at %d, ended at %d", choices, end, previous)
+		}
+	}
+
+	for _, number := range []string{"0", "-1", "31", "abc", ""} {
+		if status := doRequest(t, "GET", ts.URL+"/api/game?number="+number, nil, nil); status != http.StatusBadRequest {
+			t.Errorf("GET /api/game?number=%s returned %d, expected %d", number, status, http.StatusBadRequest)
+		}
+	}
+}
@@ -2,15 +2,29 @@ package api
 
 import (
 	"time"
+
+	"github.com/stefanovazzocell/TuringMachine/src/token"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/daily"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/matchmaking"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/session"
 )
 
 const (
 	DefaultStoreForceCreate = false
 	DefaultShutdownTimeout  = 5 * time.Second
+	DefaultSessionTTL       = session.DefaultTTL
+	DefaultSessionStore     = ""
+	DefaultSessionSnapshot  = 10 * time.Second
+	DefaultRoomForfeit      = session.DefaultForfeitAfter
+	DefaultTokenTTL         = time.Duration(0)
+	DefaultDailyWindow      = daily.DefaultWindow
+	DefaultLeaderboardFile  = ""
+	DefaultMatchmakingTick  = time.Second
 )
 
 type apiConfig struct {
-	// The file name for the store
+	// The file name or http(s) URL for the store.
+	// JSONL and CSV exports are loaded in memory (see OpenGameSource)
 	StoreFileName string
 	// Set this option to force-recreate the store at init
 	StoreForceCreate bool
@@ -21,6 +35,49 @@ type apiConfig struct {
 
 	// Timeout for http server shutdown
 	ShutdownTimeout time.Duration
+
+	// The time after which an idle play session (or an empty room) is dropped
+	SessionTTL time.Duration
+	// Where sessions and rooms are saved on shutdown and restored on startup:
+	// a file or a redis:// URL (see kv.Open).
+	// Empty keeps them in memory only
+	SessionStore string
+	// How often sessions and rooms are also saved while running, so a crash
+	// only loses the changes since the last snapshot
+	SessionSnapshot time.Duration
+	// The time after which a player disconnected from a room forfeits, so
+	// the other players are not held up. Zero if they never do
+	RoomForfeit time.Duration
+
+	// The keys game tokens are signed with, the first one signs and all of them
+	// verify (for key rotation).
+	// If empty, a random key is generated and tokens do not survive restarts
+	TokenKeys []token.Key
+	// The lifetime of game tokens, zero if they never expire
+	TokenTTL time.Duration
+
+	// The secret game ids are keyed with, so they cannot be decoded back to the
+	// game without it (see game.IdCipher). Legacy ids are still accepted.
+	// If empty, the legacy ids are used and signed games are disabled
+	IdSecret string
+
+	// The secret daily puzzles are picked with, so they cannot be predicted.
+	// Changing it (or the window or rotation) changes the puzzles
+	DailySecret string
+	// The minimum number of days between two daily puzzles with the same game
+	DailyWindow int
+	// The number of choices and difficulty of the daily puzzle for each day
+	// of the week
+	DailyRotation daily.Rotation
+
+	// The file players, their results and ratings are kept in (see kv.OpenLog).
+	// Empty keeps them in memory only
+	LeaderboardFile string
+
+	// How players in the matchmaking queue are grouped
+	Matchmaking matchmaking.Options
+	// How often the matchmaking queue is grouped into matches
+	MatchmakingTick time.Duration
 }
 
 // Returns an apiConfig with the default values
@@ -32,5 +89,20 @@ func NewAPIConfig(storeFileName string, corsOrigin string) apiConfig {
 		CorsOrigins: corsOrigin,
 
 		ShutdownTimeout: DefaultShutdownTimeout,
+
+		SessionTTL:      DefaultSessionTTL,
+		SessionStore:    DefaultSessionStore,
+		SessionSnapshot: DefaultSessionSnapshot,
+		RoomForfeit:     DefaultRoomForfeit,
+
+		TokenTTL: DefaultTokenTTL,
+
+		DailyWindow:   DefaultDailyWindow,
+		DailyRotation: daily.DefaultRotation,
+
+		LeaderboardFile: DefaultLeaderboardFile,
+
+		Matchmaking:     matchmaking.DefaultOptions(),
+		MatchmakingTick: DefaultMatchmakingTick,
 	}
 }
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/stefanovazzocell/TuringMachine/src/token"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/daily"
+)
+
+const (
+	// How far ahead of UTC the daily puzzle is available, the day starts
+	// earlier in some time zones (up to UTC+14)
+	DailyLeadTime = 14 * time.Hour
+)
+
+type DailyResponse struct {
+	// The day of the puzzle (YYYY-MM-DD)
+	Date string `json:"date"`
+	// The difficulty of the day, the game might be of a different one
+	Difficulty string `json:"difficulty"`
+	// The verification cards symbol
+	Symbol string `json:"symbol"`
+	GameResponse
+}
+
+// Handles GET /api/daily?date=YYYY-MM-DD
+// Returns the puzzle of the day as a signed game (unsigned if signed games are
+// disabled, see ErrTokensDisabled), today (in UTC) if no date is given.
+// Puzzles of the following days are not available (http.StatusNotFound).
+func (a *api) handleDaily(w http.ResponseWriter, r *http.Request) {
+	now := time.Now().UTC()
+	date := now
+	if value := r.URL.Query().Get("date"); value != "" {
+		var err error
+		if date, err = time.Parse(daily.DateFormat, value); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	if date.Format(daily.DateFormat) > now.Add(DailyLeadTime).Format(daily.DateFormat) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	puzzle, err := a.daily.Puzzle(date)
+	if err == daily.ErrDateOutOfRange {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		slog.Warn("failed to pick the daily puzzle", "date", date.Format(daily.DateFormat), "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	response, ok := a.gameResponse(w, puzzle.Game, token.Claims{Mode: TokenModeDaily, Symbol: puzzle.Symbol}, a.tokenIds != nil)
+	if !ok {
+		return
+	}
+	_ = json.NewEncoder(w).Encode(DailyResponse{
+		Date:         puzzle.Date.Format(daily.DateFormat),
+		Difficulty:   puzzle.Rule.Difficulty.String(),
+		Symbol:       puzzle.Symbol,
+		GameResponse: response,
+	})
+}
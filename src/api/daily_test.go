@@ -0,0 +1,79 @@
+package api_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stefanovazzocell/TuringMachine/src/api"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/daily"
+)
+
+func TestDaily(t *testing.T) {
+	t.Parallel()
+
+	games := randomGames(t, 60)
+	newServer := func() string {
+		config := api.NewAPIConfig("", "*")
+		config.IdSecret = "secret"
+		config.DailySecret = "secret"
+		config.DailyWindow = 7
+		server := &http.Server{}
+		a, err := api.NewApi(server, memorySource(t, games), config)
+		if err != nil {
+			t.Fatalf("Failed to create api: %v", err)
+		}
+		return serveApi(t, server, a).URL
+	}
+	url, restarted := newServer(), newServer()
+
+	seen := map[string]string{}
+	for i := range 7 {
+		date := time.Date(2026, time.March, 2+i, 0, 0, 0, 0, time.UTC).Format(daily.DateFormat)
+		response := api.DailyResponse{}
+		status := doRequest(t, "GET", url+"/api/daily?date="+date, nil, &response)
+		rule := daily.DefaultRotation[time.Date(2026, time.March, 2+i, 0, 0, 0, 0, time.UTC).Weekday()]
+		if status != http.StatusOK || response.Date != date || response.Id != "" ||
+			len(response.Criterias) != rule.Choices || response.Difficulty != rule.Difficulty.String() ||
+			response.Token == "" || response.Code != "" || response.Laws != nil {
+			t.Fatalf("GET /api/daily?date=%s returned %d %+v", date, status, response)
+		}
+		for _, verifier := range response.Verifiers {
+			if !strings.HasPrefix(verifier, response.Symbol) {
+				t.Fatalf("Expected verifiers with the %q symbol, got %v", response.Symbol, response.Verifiers)
+			}
+		}
+		game := tokenClaims(t, response.Token).Game
+		if previous, ok := seen[game]; ok {
+			t.Fatalf("Puzzle of %s repeated on %s", previous, date)
+		}
+		seen[game] = date
+
+		// The same puzzle after a restart
+		again := api.DailyResponse{}
+		doRequest(t, "GET", restarted+"/api/daily?date="+date, nil, &again)
+		if tokenClaims(t, again.Token).Game != game || again.Symbol != response.Symbol {
+			t.Fatalf("Expected the same puzzle for %s after a restart, got %+v and %+v", date, response, again)
+		}
+	}
+
+	today := api.DailyResponse{}
+	if status := doRequest(t, "GET", url+"/api/daily", nil, &today); status != http.StatusOK || today.Date == "" {
+		t.Fatalf("GET /api/daily returned %d %+v", status, today)
+	}
+	testCases := []struct {
+		date     string
+		expected int
+	}{
+		{time.Now().UTC().AddDate(0, 0, 2).Format(daily.DateFormat), http.StatusNotFound},
+		{"1969-12-31", http.StatusBadRequest},
+		{"2026-13-01", http.StatusBadRequest},
+		{"yesterday", http.StatusBadRequest},
+	}
+	for _, testCase := range testCases {
+		if status := doRequest(t, "GET", url+"/api/daily?date="+testCase.date, nil, nil); status != testCase.expected {
+			t.Errorf("GET /api/daily?date=%s returned %d, expected %d", testCase.date, status, testCase.expected)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/session"
+)
+
+const (
+	// How often a comment is sent on idle event streams to keep them open
+	SessionEventsKeepAlive = 15 * time.Second
+)
+
+// The data of a server-sent session event, the event type is one of the
+// session.EventType values
+type SessionEvent struct {
+	Round int `json:"round"`
+	// For query and result events
+	Proposal     string `json:"proposal,omitempty"`
+	VerifierSlot *int   `json:"verifier_slot,omitempty"`
+	// For result events
+	Check *bool `json:"check,omitempty"`
+	// For guess events
+	Guess string `json:"guess,omitempty"`
+	// For guess and game_over events, hidden from guess events when results
+	// are hidden
+	Won *bool `json:"won,omitempty"`
+	// For game_over events
+	Queries []SessionQuery `json:"queries,omitempty"`
+	// For game_over events
+	Code string `json:"code,omitempty"`
+	// For game_over events
+	GameId string `json:"game_id,omitempty"`
+}
+
+type SpectatorResponse struct {
+	// A read-only id for GET /api/spectate/{id}/events
+	Spectator string `json:"spectator"`
+}
+
+// Handles POST /api/session/{id}/spectators?hide_results=true
+// Returns a spectator id, which only gives access to the session events
+// (see handleSpectatorEvents), so it can be shared unlike the session id.
+// With hide_results, verifier results are hidden from the spectator until the
+// game is over.
+func (a *api) handleAddSpectator(w http.ResponseWriter, r *http.Request) {
+	hide := false
+	if value := r.URL.Query().Get("hide_results"); value != "" {
+		var err error
+		if hide, err = strconv.ParseBool(value); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	spectator, err := a.sessions.AddSpectator(r.PathValue("id"), hide)
+	if err != nil {
+		writeSessionError(w, err)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(SpectatorResponse{Spectator: spectator})
+}
+
+// Handles GET /api/session/{id}/events
+// Streams the session events to its player, see streamSessionEvents
+func (a *api) handleSessionEvents(w http.ResponseWriter, r *http.Request) {
+	a.streamSessionEvents(w, r, r.PathValue("id"), false)
+}
+
+// Handles GET /api/spectate/{id}/events
+// Streams the session events to a spectator (see handleAddSpectator), hiding
+// the results if the player chose to
+func (a *api) handleSpectatorEvents(w http.ResponseWriter, r *http.Request) {
+	id, hide, err := a.sessions.Spectate(r.PathValue("id"))
+	if err != nil {
+		writeSessionError(w, err)
+		return
+	}
+	a.streamSessionEvents(w, r, id, hide)
+}
+
+/*
+* Helpers
+**/
+
+// Streams the events of a session as server-sent events, starting after the
+// Last-Event-ID header if any. If hide is set, verifier results are left out
+// until the game_over event, which always includes all the queries.
+// The stream ends after the game_over event.
+func (a *api) streamSessionEvents(w http.ResponseWriter, r *http.Request, id string, hide bool) {
+	last := 0
+	if value := r.Header.Get("Last-Event-ID"); value != "" {
+		var err error
+		if last, err = strconv.Atoi(value); err != nil || last < 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	updates, unsubscribe, err := a.sessions.Subscribe(id)
+	if err != nil {
+		writeSessionError(w, err)
+		return
+	}
+	defer unsubscribe()
+
+	// The stream outlives the server write timeout
+	controller := http.NewResponseController(w)
+	_ = controller.SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	keepAlive := time.NewTicker(SessionEventsKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		events, finished, err := a.sessions.Events(id, last)
+		if err != nil {
+			return
+		}
+		for _, event := range events {
+			last = event.Id
+			if hide && event.Type == session.EventResult {
+				continue
+			}
+			if err = a.writeSessionEvent(w, id, event, hide); err != nil {
+				return
+			}
+		}
+		if err = controller.Flush(); err != nil || finished {
+			return
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-updates:
+		case <-keepAlive.C:
+			if _, err = fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			if err = controller.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Writes a session event in the server-sent events format
+func (a *api) writeSessionEvent(w http.ResponseWriter, id string, event session.Event, hide bool) error {
+	data := SessionEvent{Round: event.Round}
+	switch event.Type {
+	case session.EventQuery:
+		data.Proposal = event.Proposal.String()
+		data.VerifierSlot = &event.Slot
+	case session.EventResult:
+		data.Proposal = event.Proposal.String()
+		data.VerifierSlot = &event.Slot
+		data.Check = &event.Check
+	case session.EventGuess:
+		data.Guess = event.Guess.String()
+		if !hide {
+			data.Won = &event.Won
+		}
+	case session.EventGameOver:
+		s, err := a.sessions.Get(id)
+		if err != nil {
+			return err
+		}
+		data.Won = &event.Won
+		data.Queries = sessionQueries(s.Rounds)
+		data.Code = s.Code().String()
+		data.GameId = a.gameId(s.Game)
+	}
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Id, event.Type, encoded)
+	return err
+}
@@ -0,0 +1,167 @@
+package api_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stefanovazzocell/TuringMachine/src/api"
+)
+
+// A parsed server-sent event
+type sseEvent struct {
+	id    int
+	event string
+	data  api.SessionEvent
+}
+
+// Opens an event stream, optionally resuming after an event id
+func openEvents(t *testing.T, url string, lastEventId int) (*http.Response, *bufio.Scanner) {
+	t.Helper()
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	if lastEventId > 0 {
+		req.Header.Set("Last-Event-ID", strconv.Itoa(lastEventId))
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET %s failed: %v", url, err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("GET %s returned %d %q", url, resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	return resp, bufio.NewScanner(resp.Body)
+}
+
+// Reads the next event from a stream, returns false at the end of the stream
+func nextEvent(t *testing.T, scanner *bufio.Scanner) (sseEvent, bool) {
+	t.Helper()
+	event := sseEvent{}
+	for scanner.Scan() {
+		line := scanner.Text()
+		field, value, _ := strings.Cut(line, ": ")
+		switch field {
+		case "":
+			if event.event != "" {
+				return event, true
+			}
+		case "id":
+			event.id, _ = strconv.Atoi(value)
+		case "event":
+			event.event = value
+		case "data":
+			if err := json.Unmarshal([]byte(value), &event.data); err != nil {
+				t.Fatalf("Invalid event data %q: %v", value, err)
+			}
+		}
+	}
+	return event, false
+}
+
+// Reads all the remaining events of a stream
+func readEvents(t *testing.T, scanner *bufio.Scanner) []sseEvent {
+	t.Helper()
+	events := []sseEvent{}
+	for {
+		event, ok := nextEvent(t, scanner)
+		if !ok {
+			return events
+		}
+		events = append(events, event)
+	}
+}
+
+func TestSessionEvents(t *testing.T) {
+	t.Parallel()
+
+	games := randomGames(t, 10)
+	ts := newTestServer(t, games)
+	g := games[0]
+	code, _ := g.Solve()
+
+	created := api.SessionResponse{}
+	if status := doRequest(t, "POST", ts.URL+"/api/session?id="+g.String(), nil, &created); status != http.StatusOK {
+		t.Fatalf("POST /api/session returned %d", status)
+	}
+	sessionURL := ts.URL + "/api/session/" + created.Id
+	spectators := map[bool]api.SpectatorResponse{}
+	for _, hide := range []bool{false, true} {
+		spectator := api.SpectatorResponse{}
+		url := sessionURL + "/spectators?hide_results=" + strconv.FormatBool(hide)
+		if status := doRequest(t, "POST", url, nil, &spectator); status != http.StatusOK || spectator.Spectator == "" {
+			t.Fatalf("POST %s returned %d %+v", url, status, spectator)
+		}
+		spectators[hide] = spectator
+	}
+
+	// The spectator id is read-only
+	spectatorSessionURL := ts.URL + "/api/session/" + spectators[false].Spectator
+	if status := doRequest(t, "GET", spectatorSessionURL, nil, nil); status != http.StatusNotFound {
+		t.Errorf("Expected %d to read the session as a spectator, got %d", http.StatusNotFound, status)
+	}
+	if status := doRequest(t, "POST", spectatorSessionURL+"/query", api.SessionQueryRequest{Proposal: code.String()}, nil); status != http.StatusNotFound {
+		t.Errorf("Expected %d to query as a spectator, got %d", http.StatusNotFound, status)
+	}
+
+	// A live spectator
+	_, live := openEvents(t, ts.URL+"/api/spectate/"+spectators[false].Spectator+"/events", 0)
+	if event, ok := nextEvent(t, live); !ok || event.id != 1 || event.event != "round_started" {
+		t.Fatalf("Expected the first round to start, got %+v", event)
+	}
+	doRequest(t, "POST", sessionURL+"/query", api.SessionQueryRequest{Proposal: code.String()}, nil)
+	doRequest(t, "POST", sessionURL+"/guess", api.SessionGuessRequest{Code: code.String()}, nil)
+	events := readEvents(t, live)
+	expected := []string{"query", "result", "guess", "game_over"}
+	if len(events) != len(expected) {
+		t.Fatalf("Expected %d events, got %+v", len(expected), events)
+	}
+	for i, event := range events {
+		if event.event != expected[i] || event.id != i+2 {
+			t.Errorf("Event %d is %+v, expected %q", i, event, expected[i])
+		}
+	}
+	if result := events[1].data; result.Check == nil || !*result.Check || result.VerifierSlot == nil {
+		t.Errorf("Unexpected result %+v", result)
+	}
+	if over := events[3].data; over.Won == nil || !*over.Won || over.Code != code.String() ||
+		over.GameId != g.String() || len(over.Queries) != 1 {
+		t.Errorf("Unexpected game over %+v", over)
+	}
+
+	// The player's own stream, reconnecting after the guess
+	_, resumed := openEvents(t, sessionURL+"/events", 4)
+	if events = readEvents(t, resumed); len(events) != 1 || events[0].event != "game_over" {
+		t.Errorf("Expected only the game over event, got %+v", events)
+	}
+
+	// Results hidden until the end, whatever the spectator asks for
+	_, hidden := openEvents(t, ts.URL+"/api/spectate/"+spectators[true].Spectator+"/events?hide_results=false", 0)
+	events = readEvents(t, hidden)
+	if len(events) != 4 || events[2].event != "guess" || events[2].data.Won != nil ||
+		events[3].data.Queries[0].Check != true {
+		t.Errorf("Unexpected events with hidden results %+v", events)
+	}
+
+	testCases := []struct {
+		method   string
+		url      string
+		expected int
+	}{
+		{"GET", ts.URL + "/api/session/unknown/events", http.StatusNotFound},
+		{"GET", ts.URL + "/api/spectate/unknown/events", http.StatusNotFound},
+		{"GET", ts.URL + "/api/spectate/" + created.Id + "/events", http.StatusNotFound},
+		{"POST", sessionURL + "/spectators?hide_results=maybe", http.StatusBadRequest},
+		{"POST", ts.URL + "/api/session/unknown/spectators", http.StatusNotFound},
+	}
+	for _, testCase := range testCases {
+		if status := doRequest(t, testCase.method, testCase.url, nil, nil); status != testCase.expected {
+			t.Errorf("%s %s returned %d, expected %d", testCase.method, testCase.url, status, testCase.expected)
+		}
+	}
+}
@@ -2,52 +2,281 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"log/slog"
+	"math/rand"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 
+	"github.com/stefanovazzocell/TuringMachine/src/token"
 	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
 	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/store"
 )
 
+const (
+	// The token mode of games requested by id
+	TokenModeId = "id"
+	// The token mode of random games
+	TokenModeRandom = "random"
+	// The token mode of daily puzzles
+	TokenModeDaily = "daily"
+	// The maximum number of corrections suggested for an invalid game id
+	MaxIdSuggestions = 5
+)
+
+var (
+	// Error returned for signed games when no id secret is configured, as
+	// the game of a token could be requested unsigned with its legacy id
+	ErrTokensDisabled = errors.New("signed games need an id secret")
+	// Error returned when checking a signed game outside of a session or a
+	// room, which count the checks
+	ErrTokenNotMetered = errors.New("signed games are played through a session")
+)
+
+// The ids, phrase and puzzle numbers are omitted for signed games, as the game
+// could be requested unsigned with them
 type GameResponse struct {
-	Id        string   `json:"id"`
-	Code      string   `json:"code"`
+	Id string `json:"id,omitempty"`
+	// The id with a check symbol, grouped to be read aloud (ABC-DEF-GHJ-K)
+	DisplayId string `json:"display_id,omitempty"`
+	// A phrase of words for the game, accepted in place of the id
+	Phrase string `json:"phrase,omitempty"`
+	// Omitted for signed games, see handleGiveUp
+	Code      string   `json:"code,omitempty"`
 	Criterias []int    `json:"criterias"`
 	Verifiers []string `json:"verifiers"`
-	Laws      []int    `json:"laws"`
+	// Omitted for signed games, see handleGiveUp
+	Laws []int `json:"laws,omitempty"`
+	// A signed token for the game, accepted in place of the id. Only issued
+	// for signed games, which leave out the code and laws.
+	Token string `json:"token,omitempty"`
+	// The puzzle number (the position of the game in the store, from 1),
+	// omitted for games that are not in the store
+	Number int64 `json:"number,omitempty"`
+	// The numbers of the previous and next puzzles with the same number of
+	// choices, if any
+	PreviousNumber int64 `json:"previous_number,omitempty"`
+	NextNumber     int64 `json:"next_number,omitempty"`
 }
 
-// Writes a game into a responsewriter
+// The body of http.StatusBadRequest responses to invalid game ids
+type InvalidGameResponse struct {
+	Error string `json:"error"`
+	// The byte offset of the invalid character in the id, if any
+	Position *int `json:"position,omitempty"`
+	// Ids of valid games one typo away from the requested one
+	Suggestions []string `json:"suggestions,omitempty"`
+}
+
+type GiveUpRequest struct {
+	// A signed game token (see GameResponse)
+	Token string `json:"token"`
+}
+
+type GiveUpResponse struct {
+	Id   string `json:"id"`
+	Code string `json:"code"`
+	Laws []int  `json:"laws"`
+}
+
+// Writes a game into a responsewriter, signed games come with a token signed
+// for the claims instead of the code and laws.
 // If the game has no solution responds with http.StatusBadRequest
-func writeGameResponse(w http.ResponseWriter, g game.Game) {
+func (a *api) writeGameResponse(w http.ResponseWriter, g game.Game, claims token.Claims, signed bool) {
+	response, ok := a.gameResponse(w, g, claims, signed)
+	if !ok {
+		return
+	}
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// Returns the response for a game, signed games come with a token signed for
+// the claims instead of the ids, code and laws.
+// If false is returned, the error response has already been written.
+func (a *api) gameResponse(w http.ResponseWriter, g game.Game, claims token.Claims, signed bool) (GameResponse, bool) {
 	code, ok := g.Solve()
 	if !ok {
 		// This game does not have a solution
 		w.WriteHeader(http.StatusBadRequest)
-		return
+		return GameResponse{}, false
 	}
-	criteriaCards, verificationCards, laws := g.GetCards()
-
-	_ = json.NewEncoder(w).Encode(GameResponse{
-		Id:        g.String(),
-		Code:      code.String(),
+	if signed && a.tokenIds == nil {
+		writeTokensDisabled(w)
+		return GameResponse{}, false
+	}
+	if claims.Symbol == "" {
+		claims.Symbol = game.VerificationSymbols[rand.Intn(len(game.VerificationSymbols))]
+	}
+	criteriaCards, verificationCards, laws := g.GetCardsWithSymbol(claims.Symbol)
+	response := GameResponse{
 		Criterias: criteriaCards,
 		Verifiers: verificationCards,
-		Laws:      laws,
-	})
+	}
+	if !signed {
+		response.Id = a.gameId(g)
+		response.DisplayId = game.FormatId(response.Id)
+		response.Phrase = a.ids.Phrase(g)
+		response.Code = code.String()
+		response.Laws = laws
+		response.Number, response.PreviousNumber, response.NextNumber = a.puzzleNumbers(g)
+		return response, true
+	}
+
+	// Tokens never come with the code, so they can't be used to claim a win
+	// without playing
+	claims.Game = a.tokenIds.Encode(g)
+	var err error
+	if response.Token, err = a.tokens.Sign(claims); err != nil {
+		slog.Warn("failed to sign game token", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return GameResponse{}, false
+	}
+	return response, true
 }
 
 // Handles GET /api/game
+// With ?signed=true the game comes with a token instead of the ids, code and
+// laws, games requested by token are always signed. Signed games need an id
+// secret (see ErrTokensDisabled).
 func (a *api) handleGetGame(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query()
+	signed := false
+	if value := query.Get("signed"); value != "" {
+		var err error
+		if signed, err = strconv.ParseBool(value); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	g, claims, ok := a.gameFromQuery(w, query)
+	if !ok {
+		return
+	}
+	a.writeGameResponse(w, g, claims, signed || query.Has("token"))
+}
 
-	if query.Has("id") {
-		a.handleGetGameById(w, query.Get("id"))
+// Handles POST /api/give-up {token: "..."}
+// Reveals the code and laws of a signed game, the token can't be used to
+// record a result afterwards
+func (a *api) handleGiveUp(w http.ResponseWriter, r *http.Request) {
+	request := GiveUpRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Token == "" {
+		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	a.handleGetGameRandom(w, query)
+	g, claims, ok := a.gameFromToken(w, request.Token)
+	if !ok {
+		return
+	}
+	if err := a.leaderboard.Forfeit(tokenSource(request.Token)); err != nil {
+		writeLeaderboardError(w, err)
+		return
+	}
+	code, _ := g.Solve()
+	_, _, laws := g.GetCardsWithSymbol(claims.Symbol)
+	_ = json.NewEncoder(w).Encode(GiveUpResponse{
+		Id:   a.gameId(g),
+		Code: code.String(),
+		Laws: laws,
+	})
+}
+
+// Returns the game of a token (?token=...), with the requested id
+// (?id=XXXXX), phrase (?phrase=...) or puzzle number (?number=123) or a random
+// one matching the query (?difficulty=1&choices=5),
+// along with the claims for its token.
+// If false is returned, the error response has already been written.
+func (a *api) gameFromQuery(w http.ResponseWriter, query url.Values) (game.Game, token.Claims, bool) {
+	if query.Has("token") {
+		return a.gameFromToken(w, query.Get("token"))
+	}
+	if query.Has("phrase") {
+		g, err := a.ids.DecodePhrase(query.Get("phrase"))
+		if err == nil {
+			err = validateGame(&g)
+		}
+		if err != nil {
+			writeInvalidGame(w, err)
+			return g, token.Claims{}, false
+		}
+		return g, token.Claims{Mode: TokenModeId}, true
+	}
+	if query.Has("number") {
+		g, ok := a.gameFromNumber(w, query.Get("number"))
+		return g, token.Claims{Mode: TokenModeId}, ok
+	}
+	if !query.Has("id") {
+		g, ok := a.randomGameFromQuery(w, query)
+		return g, token.Claims{Mode: TokenModeRandom}, ok
+	}
+	g, err := a.parseGameId(query.Get("id"))
+	if err != nil {
+		a.writeInvalidGameId(w, query.Get("id"), err)
+		return g, token.Claims{}, false
+	}
+	return g, token.Claims{Mode: TokenModeId}, true
+}
+
+// Returns the game of a signed token, and the token claims.
+// If false is returned, the error response has already been written.
+func (a *api) gameFromToken(w http.ResponseWriter, signed string) (game.Game, token.Claims, bool) {
+	if a.tokenIds == nil {
+		writeTokensDisabled(w)
+		return game.Game{}, token.Claims{}, false
+	}
+	claims, err := a.tokens.Verify(signed)
+	if err != nil {
+		writeInvalidToken(w, err)
+		return game.Game{}, claims, false
+	}
+	g, err := a.tokenIds.Decode(claims.Game)
+	if err == nil {
+		err = validateGame(&g)
+	}
+	if err != nil {
+		writeInvalidGame(w, err)
+		return g, claims, false
+	}
+	return g, claims, true
+}
+
+// Returns the game with a given puzzle number.
+// If false is returned, the error response has already been written.
+func (a *api) gameFromNumber(w http.ResponseWriter, number string) (game.Game, bool) {
+	n, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || n < 1 || n > a.store.NumberOfGames() {
+		w.WriteHeader(http.StatusBadRequest)
+		return game.Game{}, false
+	}
+	g, err := a.store.GetGame(n - 1)
+	if err != nil {
+		slog.Warn("failed to get game by number", "number", n, "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return g, false
+	}
+	return g, true
+}
+
+// Returns the puzzle number of a game and the numbers of the previous and next
+// puzzles with the same number of choices, 0 if there are none
+func (a *api) puzzleNumbers(g game.Game) (number, previous, next int64) {
+	idx, found, err := a.store.IndexOf(g)
+	if err != nil {
+		slog.Warn("failed to look up game", "game", g.Debug(), "err", err)
+	}
+	if !found {
+		return 0, 0, 0
+	}
+	start, end := a.store.GameRangeByChoices(g.NumberOfChoices())
+	if idx > start {
+		previous = idx
+	}
+	if idx+1 < end {
+		next = idx + 2
+	}
+	return idx + 1, previous, next
 }
 
 // Returns the number of choices requested or -1 on error.
@@ -57,14 +286,15 @@ func getChoicesCount(choices string) (c int) {
 		return -1
 	}
 	c = int(choices[0] - '0')
-	if c < 2 || c > 6 {
+	if c < game.MinNumberOfChoicesPerGame || c > game.MaxNumberOfChoicesPerGame {
 		return -1
 	}
 	return c
 }
 
-// Handles GET /api/game?difficulty=1&choices=5
-func (a *api) handleGetGameRandom(w http.ResponseWriter, query url.Values) {
+// Returns a random game matching ?difficulty=1&choices=5
+// If false is returned, the error response has already been written.
+func (a *api) randomGameFromQuery(w http.ResponseWriter, query url.Values) (game.Game, bool) {
 	// Try to identify the criterias/choices range
 	var choices int = 6
 	if query.Has("choices") {
@@ -74,7 +304,7 @@ func (a *api) handleGetGameRandom(w http.ResponseWriter, query url.Values) {
 	}
 	if choices == -1 {
 		w.WriteHeader(http.StatusBadRequest)
-		return
+		return game.Game{}, false
 	}
 	start, end := a.store.GameRangeByChoices(choices)
 	if !query.Has("choices") && !query.Has("criterias") {
@@ -85,52 +315,100 @@ func (a *api) handleGetGameRandom(w http.ResponseWriter, query url.Values) {
 	var g game.Game
 	var err error
 	if query.Has("difficulty") {
-		// Try to identify the difficulty
-		difficulty := game.HardDifficulty
-		switch strings.ToLower(query.Get("difficulty")) {
-		case "0", "easy":
-			difficulty = game.EasyDifficulty
-		case "1", "medium", "standard":
-			difficulty = game.StandardDifficulty
-		}
-
-		// If it's a hard problem try to look it up in the DB first as those are
-		// the most likely to get a hit.
-		if difficulty == game.HardDifficulty {
-			g, err = a.store.GetRandomGameInRangeWithDifficulty(start, end, difficulty)
-		}
-		// If the difficulty is easy/medium or we hit the max number of retries
-		// in searching for a hard game, generate a random one now.
-		if difficulty != game.HardDifficulty || err == store.ErrMaxRetries {
-			g, err = game.RandomSolvableGame(choices, difficulty)
-		}
+		g, err = a.randomGame(start, end, choices, parseDifficulty(query.Get("difficulty")))
 	} else {
 		g, err = a.store.GetRandomGameInRange(start, end)
+		// The source might not have games with this number of choices
+		if err == store.ErrEmptyRange {
+			g, err = game.RandomSolvableGame(choices, game.HardDifficulty)
+		}
 	}
 	if err != nil {
 		slog.Warn("failed to get random game", "err", err)
 		w.WriteHeader(http.StatusInternalServerError)
-		return
+		return g, false
 	}
+	return g, true
+}
 
-	writeGameResponse(w, g)
+// Returns the difficulty named (or numbered) by value, hard if unknown
+func parseDifficulty(value string) game.Difficulty {
+	switch strings.ToLower(value) {
+	case "0", "easy":
+		return game.EasyDifficulty
+	case "1", "medium", "standard":
+		return game.StandardDifficulty
+	}
+	return game.HardDifficulty
 }
 
-// Handles GET /api/game?id=XXXXX
-func (a *api) handleGetGameById(w http.ResponseWriter, id string) {
-	game, err := game.GameFromString(id)
-	if err != nil || !game.IsValid() {
-		w.WriteHeader(http.StatusBadRequest)
-		return
+// Returns a random game with a difficulty, from the store range if possible
+func (a *api) randomGame(start, end int64, choices int, difficulty game.Difficulty) (g game.Game, err error) {
+	// If it's a hard problem try to look it up in the DB first as those are
+	// the most likely to get a hit.
+	if difficulty == game.HardDifficulty {
+		g, err = a.store.GetRandomGameInRangeWithDifficulty(start, end, difficulty)
+	}
+	// If the difficulty is easy/medium or we hit the max number of retries
+	// in searching for a hard game, generate a random one now.
+	if difficulty != game.HardDifficulty || err == store.ErrMaxRetries || err == store.ErrEmptyRange {
+		g, err = game.RandomSolvableGame(choices, difficulty)
 	}
+	return g, err
+}
+
+// Parses a game id (keyed or legacy) and strictly validates the game
+func (a *api) parseGameId(id string) (game.Game, error) {
+	g, err := a.ids.Decode(id)
+	if err != nil {
+		return g, err
+	}
+	return g, validateGame(&g)
+}
+
+// Sorts and strictly validates a game
+func validateGame(g *game.Game) error {
 	// Sort the game (more likely to be valid)
-	game.Sort()
+	g.Sort()
 	// For a single game it's faster to compute if it's valid or not
-	if err = game.ValidateStrict(); err != nil {
-		w.Header().Set("TM-Invalid-Game-Reason", err.Error())
-		w.WriteHeader(http.StatusBadRequest)
-		return
+	return g.ValidateStrict()
+}
+
+// Returns the id of a game, keyed if an id secret is configured
+func (a *api) gameId(g game.Game) string {
+	return a.ids.Encode(g)
+}
+
+// Responds with http.StatusUnauthorized and the reason the token is invalid
+func writeInvalidToken(w http.ResponseWriter, err error) {
+	w.Header().Set("TM-Invalid-Token-Reason", err.Error())
+	w.WriteHeader(http.StatusUnauthorized)
+}
+
+// Responds with http.StatusNotImplemented, as no id secret is configured
+func writeTokensDisabled(w http.ResponseWriter) {
+	w.Header().Set("TM-Invalid-Token-Reason", ErrTokensDisabled.Error())
+	w.WriteHeader(http.StatusNotImplemented)
+}
+
+// Responds with http.StatusBadRequest, the reason the game id is invalid and
+// suggested corrections
+func (a *api) writeInvalidGameId(w http.ResponseWriter, id string, err error) {
+	response := InvalidGameResponse{Error: err.Error()}
+	var idErr *game.IdError
+	if errors.As(err, &idErr) {
+		response.Position = &idErr.Position
 	}
-	// Write response
-	writeGameResponse(w, game)
+	for _, g := range a.ids.Suggest(id, MaxIdSuggestions) {
+		response.Suggestions = append(response.Suggestions, game.FormatId(a.gameId(g)))
+	}
+	w.Header().Set("TM-Invalid-Game-Reason", err.Error())
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// Responds with http.StatusBadRequest and the reason the game is invalid
+func writeInvalidGame(w http.ResponseWriter, err error) {
+	w.Header().Set("TM-Invalid-Game-Reason", err.Error())
+	w.WriteHeader(http.StatusBadRequest)
 }
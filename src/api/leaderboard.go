@@ -0,0 +1,326 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/daily"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/leaderboard"
+)
+
+const (
+	// The number of leaderboard entries returned by default
+	DefaultLeaderboardLimit = 20
+	// The maximum number of leaderboard entries returned
+	MaxLeaderboardLimit = 100
+	// The puzzle parameter for the daily puzzle leaderboard
+	DailyPuzzle = "daily"
+)
+
+var (
+	// Error returned when recording the result of a session whose game was
+	// served with its code (e.g. requested by id)
+	ErrUnrankedSession = errors.New("the session game is not ranked")
+)
+
+type PlayerRequest struct {
+	Nickname string `json:"nickname"`
+}
+
+type PlayerResponse struct {
+	Id       string `json:"id"`
+	Nickname string `json:"nickname"`
+	// Only returned when the player is created, needed to record results
+	DeviceToken string `json:"device_token,omitempty"`
+}
+
+// A result is taken from a finished session, where the server counted the
+// checks. Only sessions of random games and of signed tokens (not requested by
+// id) are ranked; a token gives a single result, and none once given up on.
+type ResultRequest struct {
+	DeviceToken string `json:"device_token"`
+	Session     string `json:"session"`
+}
+
+type ResultResponse struct {
+	// The game id
+	Puzzle string    `json:"puzzle"`
+	Rounds int       `json:"rounds"`
+	Checks int       `json:"checks"`
+	Solved bool      `json:"solved"`
+	Time   time.Time `json:"time"`
+}
+
+type LeaderboardEntry struct {
+	Rank     int    `json:"rank"`
+	Player   string `json:"player"`
+	Nickname string `json:"nickname"`
+	// Only set in the rankings
+	Solved int `json:"solved,omitempty"`
+	Rounds int `json:"rounds"`
+	Checks int `json:"checks"`
+	// Only set in the puzzle leaderboards
+	Time *time.Time `json:"time,omitempty"`
+}
+
+type LeaderboardResponse struct {
+	// The game id, empty for the rankings and the daily puzzle (it would be
+	// served with its code by id)
+	Puzzle string `json:"puzzle,omitempty"`
+	// The day of the daily puzzle or of the daily ranking (YYYY-MM-DD)
+	Date    string             `json:"date,omitempty"`
+	Entries []LeaderboardEntry `json:"entries"`
+}
+
+type PlayerStatsResponse struct {
+	PlayerResponse
+	Created       time.Time        `json:"created"`
+	Played        int              `json:"played"`
+	Solved        int              `json:"solved"`
+	AverageRounds float64          `json:"average_rounds"`
+	AverageChecks float64          `json:"average_checks"`
+	Recent        []ResultResponse `json:"recent"`
+}
+
+// Handles POST /api/player {nickname: "alice"}
+func (a *api) handleCreatePlayer(w http.ResponseWriter, r *http.Request) {
+	request := PlayerRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	player, deviceToken, err := a.leaderboard.Register(request.Nickname)
+	if err != nil {
+		writeLeaderboardError(w, err)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(PlayerResponse{
+		Id:          player.Id,
+		Nickname:    player.Nickname,
+		DeviceToken: deviceToken,
+	})
+}
+
+// Handles POST /api/player/{id}/results {device_token: "...", session: "..."}
+func (a *api) handleRecordResult(w http.ResponseWriter, r *http.Request) {
+	request := ResultRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Session == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	player, err := a.leaderboard.Authenticate(r.PathValue("id"), request.DeviceToken)
+	if err != nil {
+		writeLeaderboardError(w, err)
+		return
+	}
+	s, err := a.sessions.Get(request.Session)
+	if err != nil {
+		writeSessionError(w, err)
+		return
+	}
+	if s.Origin == "" {
+		w.Header().Set("TM-Invalid-Result-Reason", ErrUnrankedSession.Error())
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if !s.Finished {
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+
+	result := leaderboard.Result{
+		Player: player.Id,
+		Puzzle: s.Game.String(),
+		Score:  leaderboard.ScoreRounds(s.Rounds, s.Won),
+	}
+	// The sessions of a token share its source (see sessionOrigin)
+	source := s.Origin
+	if source == TokenModeRandom {
+		source = "session/" + s.Id
+	}
+	if result, err = a.leaderboard.Record(result, source); err != nil {
+		writeLeaderboardError(w, err)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(a.resultResponse(result))
+}
+
+// Handles GET /api/player/{id}/stats
+func (a *api) handlePlayerStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := a.leaderboard.Stats(r.PathValue("id"))
+	if err != nil {
+		writeLeaderboardError(w, err)
+		return
+	}
+	response := PlayerStatsResponse{
+		PlayerResponse: PlayerResponse{
+			Id:       stats.Player.Id,
+			Nickname: stats.Player.Nickname,
+		},
+		Created:       stats.Player.Created,
+		Played:        stats.Played,
+		Solved:        stats.Solved,
+		AverageRounds: stats.AverageRounds,
+		AverageChecks: stats.AverageChecks,
+		Recent:        []ResultResponse{},
+	}
+	for _, result := range stats.Recent {
+		response.Recent = append(response.Recent, a.resultResponse(result))
+	}
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// Handles GET /api/leaderboard?puzzle=XXXXX&limit=20
+// With puzzle=daily, the leaderboard of the daily puzzle (of today or ?date=).
+// Without a puzzle, the ranking by puzzles solved (all-time, or of ?date=).
+func (a *api) handleLeaderboard(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit, ok := leaderboardLimit(query)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var date time.Time
+	if value := query.Get("date"); value != "" {
+		var err error
+		if date, err = time.Parse(daily.DateFormat, value); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	response := LeaderboardResponse{Entries: []LeaderboardEntry{}}
+
+	if !query.Has("puzzle") {
+		standings, err := a.leaderboard.Ranking(date, limit)
+		if err != nil {
+			writeLeaderboardError(w, err)
+			return
+		}
+		if !date.IsZero() {
+			response.Date = date.Format(daily.DateFormat)
+		}
+		for _, standing := range standings {
+			response.Entries = append(response.Entries, LeaderboardEntry{
+				Rank:     standing.Rank,
+				Player:   standing.Player,
+				Nickname: standing.Nickname,
+				Solved:   standing.Solved,
+				Rounds:   standing.Rounds,
+				Checks:   standing.Checks,
+			})
+		}
+		_ = json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	var g game.Game
+	if puzzle := query.Get("puzzle"); puzzle == DailyPuzzle {
+		now := time.Now().UTC()
+		if date.IsZero() {
+			date = now
+		}
+		// The puzzles of the following days are not revealed
+		if date.Format(daily.DateFormat) > now.Add(DailyLeadTime).Format(daily.DateFormat) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		dailyPuzzle, err := a.daily.Puzzle(date)
+		if errors.Is(err, daily.ErrDateOutOfRange) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		} else if err != nil {
+			slog.Warn("failed to pick the daily puzzle", "date", date.Format(daily.DateFormat), "err", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		g = dailyPuzzle.Game
+		response.Date = dailyPuzzle.Date.Format(daily.DateFormat)
+	} else {
+		var err error
+		if g, err = a.parseGameId(puzzle); err != nil {
+			a.writeInvalidGameId(w, puzzle, err)
+			return
+		}
+		response.Puzzle = a.gameId(g)
+	}
+	entries, err := a.leaderboard.Puzzle(g.String(), limit)
+	if err != nil {
+		writeLeaderboardError(w, err)
+		return
+	}
+	for _, entry := range entries {
+		response.Entries = append(response.Entries, LeaderboardEntry{
+			Rank:     entry.Rank,
+			Player:   entry.Player,
+			Nickname: entry.Nickname,
+			Rounds:   entry.Rounds,
+			Checks:   entry.Checks,
+			Time:     &entry.Time,
+		})
+	}
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+/*
+* Helpers
+**/
+
+// Returns the ?limit= of a leaderboard query, false if invalid
+func leaderboardLimit(query url.Values) (int, bool) {
+	if !query.Has("limit") {
+		return DefaultLeaderboardLimit, true
+	}
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit < 1 {
+		return 0, false
+	}
+	return min(limit, MaxLeaderboardLimit), true
+}
+
+// Returns the leaderboard source of a verified token, also the origin of its
+// sessions
+func tokenSource(signed string) string {
+	// The signature is unique to the claims
+	return "token/" + signed[strings.LastIndexByte(signed, '.')+1:]
+}
+
+// Returns the response for a result, with the id of its game
+func (a *api) resultResponse(result leaderboard.Result) ResultResponse {
+	response := ResultResponse{
+		Puzzle: result.Puzzle,
+		Rounds: result.Rounds,
+		Checks: result.Checks,
+		Solved: result.Solved,
+		Time:   result.Time,
+	}
+	if g, err := game.GameFromString(result.Puzzle); err == nil {
+		response.Puzzle = a.gameId(g)
+	}
+	return response
+}
+
+// Responds with the status matching a leaderboard error
+func writeLeaderboardError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, leaderboard.ErrPlayerNotFound):
+		w.WriteHeader(http.StatusNotFound)
+	case errors.Is(err, leaderboard.ErrInvalidDeviceToken):
+		w.WriteHeader(http.StatusUnauthorized)
+	case errors.Is(err, leaderboard.ErrAlreadyRecorded), errors.Is(err, leaderboard.ErrSourceUsed),
+		errors.Is(err, leaderboard.ErrSourceClaimed):
+		w.WriteHeader(http.StatusConflict)
+	case errors.Is(err, leaderboard.ErrInvalidNickname):
+		w.Header().Set("TM-Invalid-Result-Reason", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+	default:
+		slog.Warn("leaderboard request failed", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
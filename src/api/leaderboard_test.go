@@ -0,0 +1,140 @@
+package api_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stefanovazzocell/TuringMachine/src/api"
+)
+
+func TestLeaderboard(t *testing.T) {
+	t.Parallel()
+
+	games := randomGames(t, 1)
+	ts := newSignedTestServer(t, games)
+
+	players := map[string]api.PlayerResponse{}
+	for _, nickname := range []string{"alice", "bob", "carol"} {
+		player := api.PlayerResponse{}
+		status := doRequest(t, "POST", ts.URL+"/api/player", api.PlayerRequest{Nickname: nickname}, &player)
+		if status != http.StatusOK || player.Nickname != nickname || player.Id == "" || player.DeviceToken == "" {
+			t.Fatalf("POST /api/player returned %d %+v", status, player)
+		}
+		players[nickname] = player
+	}
+	if status := doRequest(t, "POST", ts.URL+"/api/player", api.PlayerRequest{}, nil); status != http.StatusBadRequest {
+		t.Errorf("Expected %d for an empty nickname, got %d", http.StatusBadRequest, status)
+	}
+	alice, bob, carol := players["alice"], players["bob"], players["carol"]
+	// The server only has one game, so the random games are the same
+	puzzle := api.GameResponse{}
+	doRequest(t, "GET", ts.URL+"/api/game?id="+games[0].String(), nil, &puzzle)
+	code := puzzle.Code
+	aliceResults := ts.URL + "/api/player/" + alice.Id + "/results"
+	bobResults := ts.URL + "/api/player/" + bob.Id + "/results"
+	carolResults := ts.URL + "/api/player/" + carol.Id + "/results"
+
+	// Games served with their code are not ranked
+	s := playSession(t, ts.URL, "?id="+puzzle.Id, [][]int{{0}}, code, true)
+	if status := doRequest(t, "POST", aliceResults, api.ResultRequest{DeviceToken: alice.DeviceToken, Session: s.Id}, nil); status != http.StatusForbidden {
+		t.Errorf("Expected %d for a game requested by id, got %d", http.StatusForbidden, status)
+	}
+	byId := api.GameResponse{}
+	doRequest(t, "GET", ts.URL+"/api/game?signed=true&id="+puzzle.Id, nil, &byId)
+	s = playSession(t, ts.URL, "?token="+byId.Token, [][]int{{0}}, code, true)
+	if status := doRequest(t, "POST", aliceResults, api.ResultRequest{DeviceToken: alice.DeviceToken, Session: s.Id}, nil); status != http.StatusForbidden {
+		t.Errorf("Expected %d for a token of a game requested by id, got %d", http.StatusForbidden, status)
+	}
+
+	// Alice plays a signed random game: 2 rounds and 3 checks
+	g := api.GameResponse{}
+	doRequest(t, "GET", ts.URL+"/api/game?signed=true", nil, &g)
+	s = playSession(t, ts.URL, "?token="+g.Token, [][]int{{0, 1}}, code, false)
+	if status := doRequest(t, "POST", aliceResults, api.ResultRequest{DeviceToken: alice.DeviceToken, Session: s.Id}, nil); status != http.StatusConflict {
+		t.Errorf("Expected %d for an unfinished session, got %d", http.StatusConflict, status)
+	}
+	sessionURL := ts.URL + "/api/session/" + s.Id
+	doRequest(t, "POST", sessionURL+"/round", nil, nil)
+	doRequest(t, "POST", sessionURL+"/query", api.SessionQueryRequest{Proposal: code, VerifierSlot: 2}, nil)
+	doRequest(t, "POST", sessionURL+"/guess", api.SessionGuessRequest{Code: code}, nil)
+
+	if status := doRequest(t, "POST", aliceResults, api.ResultRequest{DeviceToken: bob.DeviceToken, Session: s.Id}, nil); status != http.StatusUnauthorized {
+		t.Errorf("Expected %d for the wrong device token, got %d", http.StatusUnauthorized, status)
+	}
+	result := api.ResultResponse{}
+	status := doRequest(t, "POST", aliceResults, api.ResultRequest{DeviceToken: alice.DeviceToken, Session: s.Id}, &result)
+	if status != http.StatusOK || result.Puzzle != puzzle.Id || result.Rounds != 2 || result.Checks != 3 || !result.Solved {
+		t.Fatalf("POST /api/player/{id}/results returned %d %+v", status, result)
+	}
+	if status := doRequest(t, "POST", aliceResults, api.ResultRequest{DeviceToken: alice.DeviceToken, Session: s.Id}, nil); status != http.StatusConflict {
+		t.Errorf("Expected %d for a result recorded twice, got %d", http.StatusConflict, status)
+	}
+	// A token is played in a single session
+	if status := doRequest(t, "POST", ts.URL+"/api/session?token="+g.Token, nil, nil); status != http.StatusConflict {
+		t.Errorf("Expected %d for a second session of a token, got %d", http.StatusConflict, status)
+	}
+
+	// Bob plays a random session: 1 round and 3 checks
+	s = playSession(t, ts.URL, "", [][]int{{0, 1, 2}}, code, true)
+	if status := doRequest(t, "POST", bobResults, api.ResultRequest{DeviceToken: bob.DeviceToken, Session: s.Id}, &result); status != http.StatusOK ||
+		result.Rounds != 1 || result.Checks != 3 {
+		t.Fatalf("POST /api/player/{id}/results returned %d %+v", status, result)
+	}
+
+	// No result once a token is given up on
+	givenUp := api.GameResponse{}
+	doRequest(t, "GET", ts.URL+"/api/game?signed=true", nil, &givenUp)
+	doRequest(t, "POST", ts.URL+"/api/give-up", api.GiveUpRequest{Token: givenUp.Token}, nil)
+	s = playSession(t, ts.URL, "?token="+givenUp.Token, [][]int{{0}}, code, true)
+	if status := doRequest(t, "POST", carolResults, api.ResultRequest{DeviceToken: carol.DeviceToken, Session: s.Id}, nil); status != http.StatusConflict {
+		t.Errorf("Expected %d for a token given up on, got %d", http.StatusConflict, status)
+	}
+
+	board := api.LeaderboardResponse{}
+	status = doRequest(t, "GET", ts.URL+"/api/leaderboard?puzzle="+puzzle.Id, nil, &board)
+	if status != http.StatusOK || board.Puzzle != puzzle.Id || len(board.Entries) != 2 ||
+		board.Entries[0].Nickname != "bob" || board.Entries[1].Nickname != "alice" || board.Entries[1].Rank != 2 {
+		t.Fatalf("GET /api/leaderboard?puzzle=%s returned %d %+v", puzzle.Id, status, board)
+	}
+	status = doRequest(t, "GET", ts.URL+"/api/leaderboard?limit=1", nil, &board)
+	if status != http.StatusOK || len(board.Entries) != 1 || board.Entries[0].Solved != 1 || board.Entries[0].Nickname != "bob" {
+		t.Fatalf("GET /api/leaderboard returned %d %+v", status, board)
+	}
+	for _, query := range []string{"limit=0", "date=yesterday", "puzzle=123"} {
+		if status := doRequest(t, "GET", ts.URL+"/api/leaderboard?"+query, nil, nil); status != http.StatusBadRequest {
+			t.Errorf("GET /api/leaderboard?%s returned %d, expected %d", query, status, http.StatusBadRequest)
+		}
+	}
+
+	stats := api.PlayerStatsResponse{}
+	status = doRequest(t, "GET", ts.URL+"/api/player/"+alice.Id+"/stats", nil, &stats)
+	if status != http.StatusOK || stats.Nickname != "alice" || stats.DeviceToken != "" || stats.Played != 1 ||
+		stats.Solved != 1 || stats.AverageRounds != 2 || len(stats.Recent) != 1 || stats.Recent[0].Puzzle != puzzle.Id {
+		t.Fatalf("GET /api/player/{id}/stats returned %d %+v", status, stats)
+	}
+	if status := doRequest(t, "GET", ts.URL+"/api/player/unknown/stats", nil, nil); status != http.StatusNotFound {
+		t.Errorf("Expected %d for an unknown player, got %d", http.StatusNotFound, status)
+	}
+}
+
+// Creates a session with a query and plays a round with the code for each list
+// of verifier slots, then guesses the code if asked to. Returns the session.
+func playSession(t testing.TB, url string, query string, rounds [][]int, code string, guess bool) api.SessionResponse {
+	s := api.SessionResponse{}
+	if status := doRequest(t, "POST", url+"/api/session"+query, nil, &s); status != http.StatusOK {
+		t.Fatalf("POST /api/session%s returned %d", query, status)
+	}
+	sessionURL := url + "/api/session/" + s.Id
+	for i, slots := range rounds {
+		if i > 0 {
+			doRequest(t, "POST", sessionURL+"/round", nil, nil)
+		}
+		for _, slot := range slots {
+			doRequest(t, "POST", sessionURL+"/query", api.SessionQueryRequest{Proposal: code, VerifierSlot: slot}, nil)
+		}
+	}
+	if guess {
+		doRequest(t, "POST", sessionURL+"/guess", api.SessionGuessRequest{Code: code}, &s)
+	}
+	return s
+}
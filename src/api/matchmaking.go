@@ -0,0 +1,220 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/matchmaking"
+)
+
+const (
+	// Matchmaking events
+	MatchmakingEventWaiting = "waiting"
+	MatchmakingEventMatch   = "match"
+	MatchmakingEventExpired = "expired"
+)
+
+type MatchmakingRequest struct {
+	Player      string `json:"player"`
+	DeviceToken string `json:"device_token"`
+	// The preferred game, 5 choices and standard difficulty by default
+	Choices    int    `json:"choices,omitempty"`
+	Difficulty string `json:"difficulty,omitempty"`
+}
+
+type MatchmakingResponse struct {
+	Ticket string  `json:"ticket"`
+	Rating float64 `json:"rating"`
+}
+
+type MatchmakingPlayer struct {
+	Player   string  `json:"player"`
+	Nickname string  `json:"nickname"`
+	Rating   float64 `json:"rating"`
+}
+
+// The data of a server-sent matchmaking event
+type MatchmakingEvent struct {
+	// For waiting events, the players in the queue
+	Queued int `json:"queued,omitempty"`
+	// For match events, the room to join with the player profile
+	Room       string              `json:"room,omitempty"`
+	Choices    int                 `json:"choices,omitempty"`
+	Difficulty string              `json:"difficulty,omitempty"`
+	Players    []MatchmakingPlayer `json:"players,omitempty"`
+}
+
+// Handles POST /api/matchmaking {player: "...", device_token: "...", choices: 5, difficulty: "hard"}
+// Queues a player for a rated match, see handleMatchmakingEvents
+func (a *api) handleJoinMatchmaking(w http.ResponseWriter, r *http.Request) {
+	request := MatchmakingRequest{Choices: 5, Difficulty: "standard"}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	player, err := a.leaderboard.Authenticate(request.Player, request.DeviceToken)
+	if err != nil {
+		writeLeaderboardError(w, err)
+		return
+	}
+	rating, err := a.ratings.Get(player.Id)
+	if err != nil {
+		slog.Warn("failed to get a rating", "player", player.Id, "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	ticket, _, err := a.lobby.Join(matchmaking.Request{
+		Player:     player.Id,
+		Rating:     rating.Rating,
+		Choices:    request.Choices,
+		Difficulty: parseDifficulty(request.Difficulty),
+	})
+	switch {
+	case errors.Is(err, matchmaking.ErrInvalidChoices):
+		writeInvalidGame(w, err)
+		return
+	case errors.Is(err, matchmaking.ErrAlreadyQueued):
+		w.WriteHeader(http.StatusConflict)
+		return
+	case err != nil:
+		slog.Warn("failed to join the matchmaking queue", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(MatchmakingResponse{
+		Ticket: ticket.Id,
+		Rating: rating.Rating,
+	})
+}
+
+// Handles DELETE /api/matchmaking/{ticket}
+func (a *api) handleLeaveMatchmaking(w http.ResponseWriter, r *http.Request) {
+	if err := a.lobby.Leave(r.PathValue("ticket")); err != nil {
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// Handles GET /api/matchmaking/{ticket}/events (server-sent events)
+// Streams a waiting event, then a match event with the room to join (see
+// handleRoomSocket) or an expired event if the ticket was dropped.
+// Closing the stream before the match leaves the queue.
+func (a *api) handleMatchmakingEvents(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("ticket")
+	matched, err := a.lobby.Wait(id)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	// The stream outlives the server write timeout
+	controller := http.NewResponseController(w)
+	_ = controller.SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	keepAlive := time.NewTicker(SessionEventsKeepAlive)
+	defer keepAlive.Stop()
+	err = writeMatchmakingEvent(w, MatchmakingEventWaiting, MatchmakingEvent{Queued: a.lobby.Len()})
+	for err == nil {
+		if err = controller.Flush(); err != nil {
+			break
+		}
+		select {
+		case <-r.Context().Done():
+			err = r.Context().Err()
+		case <-a.done:
+			return
+		case match, ok := <-matched:
+			if !ok {
+				_ = writeMatchmakingEvent(w, MatchmakingEventExpired, MatchmakingEvent{})
+				_ = controller.Flush()
+				return
+			}
+			if err = writeMatchmakingEvent(w, MatchmakingEventMatch, a.matchmakingEvent(match)); err == nil {
+				_ = controller.Flush()
+				return
+			}
+		case <-keepAlive.C:
+			_, err = fmt.Fprint(w, ": keep-alive\n\n")
+		}
+	}
+	// The player is gone
+	_ = a.lobby.Leave(id)
+}
+
+/*
+* Helpers
+**/
+
+// Groups the matchmaking queue into matches until the api is closed
+func (a *api) matchmake() {
+	ticker := time.NewTicker(a.config.MatchmakingTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.done:
+			return
+		case <-ticker.C:
+		}
+		matches, err := a.lobby.Tick(a.setupMatch)
+		if err != nil {
+			slog.Warn("failed to set up some matches", "err", err)
+		}
+		for _, match := range matches {
+			slog.Debug("match made", "room", match.Room, "players", len(match.Players))
+		}
+	}
+}
+
+// Creates the rated room of a match, with a random game of its choices and
+// difficulty, only the matched players can join it
+func (a *api) setupMatch(match *matchmaking.Match) error {
+	start, end := a.store.GameRangeByChoices(match.Choices)
+	g, err := a.randomGame(start, end, match.Choices, match.Difficulty)
+	if err != nil {
+		return err
+	}
+	accounts := make([]string, len(match.Players))
+	for i, player := range match.Players {
+		accounts[i] = player.Player
+	}
+	room, err := a.rooms.CreateRated(g, accounts)
+	if err != nil {
+		return err
+	}
+	match.Room = room.Code()
+	return nil
+}
+
+// Returns the event data of a match
+func (a *api) matchmakingEvent(match matchmaking.Match) MatchmakingEvent {
+	event := MatchmakingEvent{
+		Room:       match.Room,
+		Choices:    match.Choices,
+		Difficulty: match.Difficulty.String(),
+	}
+	for _, player := range match.Players {
+		profile, _ := a.leaderboard.Player(player.Player)
+		event.Players = append(event.Players, MatchmakingPlayer{
+			Player:   player.Player,
+			Nickname: profile.Nickname,
+			Rating:   player.Rating,
+		})
+	}
+	return event
+}
+
+// Writes a matchmaking event in the server-sent events format
+func writeMatchmakingEvent(w http.ResponseWriter, event string, data MatchmakingEvent) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, encoded)
+	return err
+}
@@ -0,0 +1,112 @@
+package api_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stefanovazzocell/TuringMachine/src/api"
+)
+
+// Reads the next matchmaking event from a stream
+func nextMatchmakingEvent(t *testing.T, scanner *bufio.Scanner) (string, api.MatchmakingEvent) {
+	t.Helper()
+	event, data := "", api.MatchmakingEvent{}
+	for scanner.Scan() {
+		field, value, _ := strings.Cut(scanner.Text(), ": ")
+		switch field {
+		case "":
+			if event != "" {
+				return event, data
+			}
+		case "event":
+			event = value
+		case "data":
+			if err := json.Unmarshal([]byte(value), &data); err != nil {
+				t.Fatalf("Invalid event data %q: %v", value, err)
+			}
+		}
+	}
+	t.Fatalf("The stream ended: %v", scanner.Err())
+	return event, data
+}
+
+func TestMatchmaking(t *testing.T) {
+	t.Parallel()
+
+	config := api.NewAPIConfig("", "*")
+	config.Matchmaking.FillWait = 0
+	config.MatchmakingTick = 10 * time.Millisecond
+	server := &http.Server{}
+	a, err := api.NewApi(server, memorySource(t, randomGames(t, 30)), config)
+	if err != nil {
+		t.Fatalf("Failed to create api: %v", err)
+	}
+	ts := serveApi(t, server, a)
+
+	players := []api.PlayerResponse{}
+	for _, nickname := range []string{"alice", "bob"} {
+		player := api.PlayerResponse{}
+		doRequest(t, "POST", ts.URL+"/api/player", api.PlayerRequest{Nickname: nickname}, &player)
+		players = append(players, player)
+	}
+	request := api.MatchmakingRequest{Player: players[0].Id, DeviceToken: players[0].DeviceToken, Choices: 7}
+	if status := doRequest(t, "POST", ts.URL+"/api/matchmaking", request, nil); status != http.StatusBadRequest {
+		t.Errorf("Expected %d for 7 choices, got %d", http.StatusBadRequest, status)
+	}
+	request.DeviceToken = players[1].DeviceToken
+	if status := doRequest(t, "POST", ts.URL+"/api/matchmaking", request, nil); status != http.StatusUnauthorized {
+		t.Errorf("Expected %d for the wrong device token, got %d", http.StatusUnauthorized, status)
+	}
+
+	// Alice joins, leaves and joins again
+	alice := api.MatchmakingResponse{}
+	request = api.MatchmakingRequest{Player: players[0].Id, DeviceToken: players[0].DeviceToken, Choices: 4, Difficulty: "easy"}
+	if status := doRequest(t, "POST", ts.URL+"/api/matchmaking", request, &alice); status != http.StatusOK || alice.Ticket == "" || alice.Rating == 0 {
+		t.Fatalf("POST /api/matchmaking returned %d %+v", status, alice)
+	}
+	if status := doRequest(t, "POST", ts.URL+"/api/matchmaking", request, nil); status != http.StatusConflict {
+		t.Errorf("Expected %d for a player already queued, got %d", http.StatusConflict, status)
+	}
+	if status := doRequest(t, "DELETE", ts.URL+"/api/matchmaking/"+alice.Ticket, nil, nil); status != http.StatusOK {
+		t.Errorf("DELETE /api/matchmaking/{ticket} returned %d", status)
+	}
+	if status := doRequest(t, "DELETE", ts.URL+"/api/matchmaking/"+alice.Ticket, nil, nil); status != http.StatusNotFound {
+		t.Errorf("Expected %d for a ticket that left, got %d", http.StatusNotFound, status)
+	}
+	doRequest(t, "POST", ts.URL+"/api/matchmaking", request, &alice)
+	_, aliceEvents := openEvents(t, ts.URL+"/api/matchmaking/"+alice.Ticket+"/events", 0)
+	if event, data := nextMatchmakingEvent(t, aliceEvents); event != api.MatchmakingEventWaiting || data.Queued != 1 {
+		t.Fatalf("Expected a waiting event, got %s %+v", event, data)
+	}
+
+	// Bob joins: both are matched in a new room
+	bob := api.MatchmakingResponse{}
+	request = api.MatchmakingRequest{Player: players[1].Id, DeviceToken: players[1].DeviceToken, Choices: 4, Difficulty: "easy"}
+	doRequest(t, "POST", ts.URL+"/api/matchmaking", request, &bob)
+	_, bobEvents := openEvents(t, ts.URL+"/api/matchmaking/"+bob.Ticket+"/events", 0)
+	rooms := []string{}
+	for _, events := range []*bufio.Scanner{aliceEvents, bobEvents} {
+		event, data := nextMatchmakingEvent(t, events)
+		if event == api.MatchmakingEventWaiting {
+			event, data = nextMatchmakingEvent(t, events)
+		}
+		if event != api.MatchmakingEventMatch || data.Room == "" || data.Choices != 4 || data.Difficulty != "easy" ||
+			len(data.Players) != 2 || data.Players[0].Nickname != "alice" || data.Players[1].Nickname != "bob" {
+			t.Fatalf("Expected a match event, got %s %+v", event, data)
+		}
+		rooms = append(rooms, data.Room)
+	}
+	if rooms[0] != rooms[1] {
+		t.Fatalf("Expected the players in the same room, got %v", rooms)
+	}
+	// The room is ready to join
+	conn, _ := joinRoom(t, ts.URL, rooms[0], "alice&player="+players[0].Id+"&device_token="+players[0].DeviceToken)
+	state := readUntil(t, conn, func(message api.RoomMessage) bool { return message.State != nil }).State
+	if len(state.Criterias) != 4 {
+		t.Fatalf("Expected a game with 4 choices, got %+v", state)
+	}
+}
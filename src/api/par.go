@@ -0,0 +1,113 @@
+package api
+
+import (
+	"cmp"
+	"sync"
+
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/session"
+)
+
+const (
+	// The number of games whose par is kept in memory
+	ParCacheSize = 1024
+
+	// How a finished session compares to the par bot
+	ParUnder = "under"
+	ParEven  = "par"
+	ParOver  = "over"
+)
+
+// How a finished session compares with the par bot on the same game.
+// The par bot plays the strategy needing the fewest rounds then checks in the
+// worst case (see game.Par), players only beat it by being lucky.
+type SessionPar struct {
+	// The rounds and checks the bot needed
+	Rounds int `json:"rounds"`
+	Checks int `json:"checks"`
+	// The rounds and checks the player needed
+	PlayerRounds int `json:"player_rounds"`
+	PlayerChecks int `json:"player_checks"`
+	// One of ParUnder, ParEven or ParOver, a lost session is always over par
+	Result string `json:"result"`
+	// The first round in which the player's queries differ from the bot's, 0
+	// if they followed the same path
+	Diverged int `json:"diverged"`
+	// The queries made by the bot
+	Queries []SessionQuery `json:"queries"`
+}
+
+// The pars of the last games played, as finished sessions are read many
+// times and the bot is slow to play.
+type parCache struct {
+	lock  sync.Mutex
+	pars  map[game.Game]game.Par
+	order []game.Game
+}
+
+// Returns an empty par cache
+func newParCache() *parCache {
+	return &parCache{
+		pars:  make(map[game.Game]game.Par, ParCacheSize),
+		order: make([]game.Game, 0, ParCacheSize),
+	}
+}
+
+// Returns the par of a game, playing it if not cached.
+// The oldest game is evicted once the cache is full.
+func (cache *parCache) get(g game.Game) (game.Par, error) {
+	cache.lock.Lock()
+	par, ok := cache.pars[g]
+	cache.lock.Unlock()
+	if ok {
+		return par, nil
+	}
+
+	// Play without holding the lock, at worst a game is played twice
+	par, err := game.PlayPar(g)
+	if err != nil {
+		return game.Par{}, err
+	}
+
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	if _, ok := cache.pars[g]; !ok {
+		if len(cache.order) == ParCacheSize {
+			delete(cache.pars, cache.order[0])
+			cache.order = append(cache.order[:0], cache.order[1:]...)
+		}
+		cache.pars[g] = par
+		cache.order = append(cache.order, g)
+	}
+	return par, nil
+}
+
+// Returns the comparison of a finished session with the par bot
+func (a *api) sessionPar(s session.Session) (SessionPar, error) {
+	par, err := a.pars.get(s.Game)
+	if err != nil {
+		return SessionPar{}, err
+	}
+	player := game.Player{Rounds: s.Rounds}
+	response := SessionPar{
+		Rounds:       par.RoundsPlayed(),
+		Checks:       par.Checks(),
+		PlayerRounds: player.RoundsPlayed(),
+		PlayerChecks: player.Checks(),
+		Result:       ParOver,
+		Diverged:     par.Diverged(s.Rounds),
+		Queries:      sessionQueries(par.Rounds),
+	}
+	if s.Won {
+		switch cmp.Or(
+			cmp.Compare(response.PlayerRounds, response.Rounds),
+			cmp.Compare(response.PlayerChecks, response.Checks),
+		) {
+		case -1:
+			response.Result = ParUnder
+		case 0:
+			response.Result = ParEven
+		}
+	}
+	return response, nil
+}
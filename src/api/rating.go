@@ -0,0 +1,87 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/daily"
+)
+
+type RatingChange struct {
+	Time   time.Time `json:"time"`
+	Before float64   `json:"before"`
+	After  float64   `json:"after"`
+	Rank   int       `json:"rank"`
+	// The number of rated players in the match
+	Players int `json:"players"`
+	// The rating of the puzzle played
+	Puzzle float64 `json:"puzzle"`
+}
+
+type RatingResponse struct {
+	Player  string  `json:"player"`
+	Rating  float64 `json:"rating"`
+	Peak    float64 `json:"peak"`
+	Matches int     `json:"matches"`
+	// True while the rating is based on few matches
+	Provisional bool `json:"provisional"`
+	// Newest first
+	History []RatingChange `json:"history"`
+}
+
+// Handles GET /api/player/{id}/rating?since=YYYY-MM-DD&limit=20
+// Returns the rating of a player and its changes (since a date, if given)
+func (a *api) handlePlayerRating(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit, ok := leaderboardLimit(query)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var since time.Time
+	if value := query.Get("since"); value != "" {
+		var err error
+		if since, err = time.Parse(daily.DateFormat, value); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	player, err := a.leaderboard.Player(r.PathValue("id"))
+	if err != nil {
+		writeLeaderboardError(w, err)
+		return
+	}
+	rating, err := a.ratings.Get(player.Id)
+	if err != nil {
+		slog.Warn("failed to get a rating", "player", player.Id, "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	history, err := a.ratings.History(player.Id, since, limit)
+	if err != nil {
+		slog.Warn("failed to get a rating history", "player", player.Id, "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	response := RatingResponse{
+		Player:      player.Id,
+		Rating:      rating.Rating,
+		Peak:        rating.Peak,
+		Matches:     rating.Matches,
+		Provisional: rating.Provisional(),
+		History:     []RatingChange{},
+	}
+	for _, change := range history {
+		response.History = append(response.History, RatingChange{
+			Time:    change.Time,
+			Before:  change.Before,
+			After:   change.After,
+			Rank:    change.Rank,
+			Players: change.Players,
+			Puzzle:  change.Puzzle,
+		})
+	}
+	_ = json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,138 @@
+package api_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stefanovazzocell/TuringMachine/src/api"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
+)
+
+func TestRating(t *testing.T) {
+	t.Parallel()
+
+	// The lobby picks hard games from the store, so the test knows the code
+	g, err := game.RandomSolvableGame(4, game.HardDifficulty)
+	if err != nil {
+		t.Fatalf("Failed to generate a game: %v", err)
+	}
+	code, _ := g.Solve()
+	config := api.NewAPIConfig("", "*")
+	config.Matchmaking.FillWait = 0
+	config.MatchmakingTick = 10 * time.Millisecond
+	config.RoomForfeit = 100 * time.Millisecond
+	server := &http.Server{}
+	a, err := api.NewApi(server, memorySource(t, []game.Game{g}), config)
+	if err != nil {
+		t.Fatalf("Failed to create api: %v", err)
+	}
+	ts := serveApi(t, server, a)
+
+	players := map[string]api.PlayerResponse{}
+	for _, nickname := range []string{"alice", "bob", "carol"} {
+		player := api.PlayerResponse{}
+		doRequest(t, "POST", ts.URL+"/api/player", api.PlayerRequest{Nickname: nickname}, &player)
+		players[nickname] = player
+	}
+	alice, bob, carol := players["alice"], players["bob"], players["carol"]
+	join := func(name string, player api.PlayerResponse) string {
+		return name + "&player=" + player.Id + "&device_token=" + player.DeviceToken
+	}
+
+	// Rooms created directly are never rated
+	created := api.RoomResponse{}
+	doRequest(t, "POST", ts.URL+"/api/room?id="+g.String(), nil, &created)
+	aliceConn, _ := joinRoom(t, ts.URL, created.Room, join("alice", alice))
+	carolConn, _ := joinRoom(t, ts.URL, created.Room, join("carol", carol))
+	roomRequest(t, aliceConn, api.RoomRequest{Type: api.RoomMessageGuess, Code: code.String()})
+	roomRequest(t, carolConn, api.RoomRequest{Type: api.RoomMessageGuess, Code: code.String()})
+	aliceConn.Close()
+	carolConn.Close()
+
+	// Alice and bob are matched in a rated room
+	tickets := []string{}
+	for _, player := range []api.PlayerResponse{alice, bob} {
+		ticket := api.MatchmakingResponse{}
+		request := api.MatchmakingRequest{Player: player.Id, DeviceToken: player.DeviceToken, Choices: 4, Difficulty: "hard"}
+		doRequest(t, "POST", ts.URL+"/api/matchmaking", request, &ticket)
+		tickets = append(tickets, ticket.Ticket)
+	}
+	rooms := []string{}
+	for _, ticket := range tickets {
+		_, events := openEvents(t, ts.URL+"/api/matchmaking/"+ticket+"/events", 0)
+		event, data := nextMatchmakingEvent(t, events)
+		for event == api.MatchmakingEventWaiting {
+			event, data = nextMatchmakingEvent(t, events)
+		}
+		rooms = append(rooms, data.Room)
+	}
+	room := rooms[1]
+	if rooms[0] != room {
+		t.Fatalf("Expected the players in the same room, got %v", rooms)
+	}
+
+	// Only the matched players can join it
+	if status := doRequest(t, "GET", ts.URL+"/api/room/"+room+"/ws?name=alice&player="+alice.Id+"&device_token="+bob.DeviceToken, nil, nil); status != http.StatusUnauthorized {
+		t.Errorf("Expected %d for the wrong device token, got %d", http.StatusUnauthorized, status)
+	}
+	for _, name := range []string{"carol", join("carol", carol)} {
+		if status := doRequest(t, "GET", ts.URL+"/api/room/"+room+"/ws?name="+name, nil, nil); status != http.StatusForbidden {
+			t.Errorf("Expected %d to join as %s, got %d", http.StatusForbidden, name, status)
+		}
+	}
+	if status := doRequest(t, "POST", ts.URL+"/api/room/"+room+"/bots", api.RoomBotRequest{Strategy: "minimax"}, nil); status != http.StatusForbidden {
+		t.Errorf("Expected %d to add a bot, got %d", http.StatusForbidden, status)
+	}
+	aliceConn, aliceSecret := joinRoom(t, ts.URL, room, join("alice", alice))
+	bobConn, _ := joinRoom(t, ts.URL, room, join("bob", bob))
+	readUntil(t, bobConn, func(message api.RoomMessage) bool {
+		return message.State != nil && len(message.State.Players) == 2
+	})
+	if status := doRequest(t, "GET", ts.URL+"/api/room/"+room+"/ws?name="+join("bob2", bob), nil, nil); status != http.StatusForbidden {
+		t.Errorf("Expected %d for a second seat, got %d", http.StatusForbidden, status)
+	}
+
+	// Alice drops, her seat needs her profile to be taken back
+	aliceConn.Close()
+	readUntil(t, bobConn, func(message api.RoomMessage) bool {
+		return message.State != nil && !message.State.Players[0].Connected
+	})
+	if status := doRequest(t, "GET", ts.URL+"/api/room/"+room+"/ws?name=alice&secret="+aliceSecret, nil, nil); status != http.StatusForbidden {
+		t.Errorf("Expected %d to reconnect to a rated seat without the profile, got %d", http.StatusForbidden, status)
+	}
+	aliceConn, _ = joinRoom(t, ts.URL, room, "alice&secret="+aliceSecret+"&player="+alice.Id+"&device_token="+alice.DeviceToken)
+
+	// Bob solves the puzzle, alice queries then leaves for good: the match
+	// ends when she forfeits, without any further message
+	roomRequest(t, aliceConn, api.RoomRequest{Type: api.RoomMessageQuery, Proposal: code.String(), VerifierSlot: 0})
+	roomRequest(t, bobConn, api.RoomRequest{Type: api.RoomMessageGuess, Code: code.String()})
+	aliceConn.Close()
+	readUntil(t, bobConn, func(message api.RoomMessage) bool {
+		return message.State != nil && message.State.Finished
+	})
+
+	ratings := map[string]api.RatingResponse{}
+	for _, nickname := range []string{"alice", "bob"} {
+		player := players[nickname]
+		response := api.RatingResponse{}
+		status := doRequest(t, "GET", ts.URL+"/api/player/"+player.Id+"/rating", nil, &response)
+		if status != http.StatusOK || response.Player != player.Id || response.Matches != 1 || !response.Provisional ||
+			len(response.History) != 1 || response.History[0].After != response.Rating || response.History[0].Players != 2 {
+			t.Fatalf("GET /api/player/{id}/rating for %s returned %d %+v", nickname, status, response)
+		}
+		ratings[nickname] = response
+	}
+	if ratings["bob"].Rating <= ratings["alice"].Rating || ratings["bob"].History[0].Rank != 1 {
+		t.Fatalf("Expected bob to be rated higher than alice, got %+v", ratings)
+	}
+
+	for _, query := range []string{"since=last-week", "limit=-1"} {
+		if status := doRequest(t, "GET", ts.URL+"/api/player/"+bob.Id+"/rating?"+query, nil, nil); status != http.StatusBadRequest {
+			t.Errorf("GET /api/player/{id}/rating?%s returned %d, expected %d", query, status, http.StatusBadRequest)
+		}
+	}
+	if status := doRequest(t, "GET", ts.URL+"/api/player/unknown/rating", nil, nil); status != http.StatusNotFound {
+		t.Errorf("Expected %d for an unknown player, got %d", http.StatusNotFound, status)
+	}
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/replay"
+)
+
+type ReplayResponse struct {
+	GameId string `json:"game_id"`
+	Won    bool   `json:"won"`
+	Rounds int    `json:"rounds"`
+	Checks int    `json:"checks"`
+	// What the player could deduce at the step requested
+	State ReplayState `json:"state"`
+}
+
+// What a player could deduce after some queries of a replay
+type ReplayState struct {
+	// The number of queries made, from 0 to ReplayResponse.Checks
+	Step int `json:"step"`
+	// The round of the last query, starting from 1
+	Round int `json:"round"`
+	// The last query, nil for step 0
+	Query *SessionQuery `json:"query,omitempty"`
+	// The number of combinations of laws still possible
+	Combinations int `json:"combinations"`
+	// The codes still possible, most likely first
+	Solutions []string `json:"solutions"`
+	// The ids of the laws still possible, per verifier slot
+	Laws [][]int `json:"laws"`
+}
+
+// Handles GET /api/session/{id}/replay
+func (a *api) handleSessionReplay(w http.ResponseWriter, r *http.Request) {
+	s, err := a.sessions.Get(r.PathValue("id"))
+	if err != nil {
+		writeSessionError(w, err)
+		return
+	}
+	// The game id would give the game away
+	if !s.Finished {
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+	response, err := a.sessions.Replay(s.Id)
+	if err != nil {
+		writeSessionError(w, err)
+		return
+	}
+	response.GameId = a.gameId(s.Game)
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// Handles POST /api/replay?step=4 {version: 1, game_id: "XXXXX", ...}
+func (a *api) handleUploadReplay(w http.ResponseWriter, r *http.Request) {
+	upload, g, player, ok := a.readReplay(w, r)
+	if !ok {
+		return
+	}
+	step := getPositiveInt(r.URL.Query().Get("step"), upload.Queries())
+	state, err := upload.Step(g, step)
+	if err != nil {
+		writeInvalidReplay(w, err)
+		return
+	}
+
+	response := ReplayResponse{
+		GameId: a.gameId(g),
+		Won:    player.Solved,
+		Rounds: player.RoundsPlayed(),
+		Checks: player.Checks(),
+		State: ReplayState{
+			Step:         state.Step,
+			Round:        state.Round,
+			Combinations: state.Candidates.Len(),
+			Solutions:    []string{},
+			Laws:         [][]int{},
+		},
+	}
+	if state.Query != nil {
+		response.State.Query = &SessionQuery{
+			Round:        state.Round,
+			Proposal:     state.Proposal.String(),
+			VerifierSlot: state.Query.Verifier,
+			Check:        state.Query.Result,
+		}
+	}
+	for _, code := range state.Candidates.Solutions() {
+		response.State.Solutions = append(response.State.Solutions, code.String())
+	}
+	for slot := range state.Candidates.Verifiers() {
+		laws := []int{}
+		for _, law := range state.Candidates.Laws(slot) {
+			laws = append(laws, int(law.Id))
+		}
+		response.State.Laws = append(response.State.Laws, laws)
+	}
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// Reads and validates a replay from a request body, returns the game and the
+// player at the end of the replay.
+// If false is returned, the error response has already been written.
+func (a *api) readReplay(w http.ResponseWriter, r *http.Request) (replay.Replay, game.Game, game.Player, bool) {
+	upload := replay.Replay{}
+	if err := json.NewDecoder(r.Body).Decode(&upload); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return upload, game.Game{}, game.Player{}, false
+	}
+	g, err := a.parseGameId(upload.GameId)
+	if err != nil {
+		a.writeInvalidGameId(w, upload.GameId, err)
+		return upload, g, game.Player{}, false
+	}
+	player, err := upload.Validate(g)
+	if err != nil {
+		writeInvalidReplay(w, err)
+		return upload, g, player, false
+	}
+	return upload, g, player, true
+}
+
+// Responds with http.StatusBadRequest and the reason the replay is invalid
+func writeInvalidReplay(w http.ResponseWriter, err error) {
+	w.Header().Set("TM-Invalid-Replay-Reason", err.Error())
+	w.WriteHeader(http.StatusBadRequest)
+}
@@ -0,0 +1,101 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stefanovazzocell/TuringMachine/src/api"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/replay"
+)
+
+func TestReplay(t *testing.T) {
+	t.Parallel()
+
+	games := randomGames(t, 10)
+	ts := newTestServer(t, games)
+	g, par := games[0], game.Par{}
+	// A game that needs some queries
+	for _, g = range games {
+		var err error
+		if par, err = game.PlayPar(g); err != nil {
+			t.Fatalf("PlayPar failed: %v", err)
+		}
+		if par.Checks() > 0 {
+			break
+		}
+	}
+
+	created := api.SessionResponse{}
+	doRequest(t, "POST", ts.URL+"/api/session?id="+g.String(), nil, &created)
+	sessionURL := ts.URL + "/api/session/" + created.Id
+	if status := doRequest(t, "GET", sessionURL+"/replay", nil, nil); status != http.StatusConflict {
+		t.Fatalf("Expected %d for the replay of an unfinished session, got %d", http.StatusConflict, status)
+	}
+	for i, round := range par.Rounds {
+		if i > 0 {
+			doRequest(t, "POST", sessionURL+"/round", nil, nil)
+		}
+		for _, query := range round.Queries {
+			doRequest(t, "POST", sessionURL+"/query", api.SessionQueryRequest{
+				Proposal:     query.Proposal.String(),
+				VerifierSlot: query.Verifier,
+			}, nil)
+		}
+	}
+	doRequest(t, "POST", sessionURL+"/guess", api.SessionGuessRequest{Code: par.Guess.String()}, nil)
+
+	played := replay.Replay{}
+	if status := doRequest(t, "GET", sessionURL+"/replay", nil, &played); status != http.StatusOK {
+		t.Fatalf("Expected %d for the replay, got %d", http.StatusOK, status)
+	}
+	if played.GameId != g.String() || played.Mode != replay.ModeSession ||
+		played.Queries() != par.Checks() || played.Guess == nil || !played.Guess.Correct {
+		t.Fatalf("Unexpected replay %+v", played)
+	}
+	if status := doRequest(t, "GET", ts.URL+"/api/session/unknown/replay", nil, nil); status != http.StatusNotFound {
+		t.Fatalf("Expected %d for an unknown session, got %d", http.StatusNotFound, status)
+	}
+
+	// Uploading the replay reconstructs the deduction state
+	uploaded := api.ReplayResponse{}
+	if status := doRequest(t, "POST", ts.URL+"/api/replay", played, &uploaded); status != http.StatusOK {
+		t.Fatalf("Expected %d for the upload, got %d", http.StatusOK, status)
+	}
+	if !uploaded.Won || uploaded.Rounds != par.RoundsPlayed() || uploaded.Checks != par.Checks() ||
+		uploaded.State.Step != par.Checks() || uploaded.State.Query == nil ||
+		len(uploaded.State.Solutions) != 1 || uploaded.State.Solutions[0] != par.Guess.String() ||
+		len(uploaded.State.Laws) != g.NumberOfChoices() {
+		t.Fatalf("Unexpected upload response %+v", uploaded)
+	}
+	start := api.ReplayResponse{}
+	doRequest(t, "POST", ts.URL+"/api/replay?step=0", played, &start)
+	if start.State.Step != 0 || start.State.Query != nil || start.State.Combinations <= uploaded.State.Combinations {
+		t.Fatalf("Unexpected state at step 0 %+v", start.State)
+	}
+	if status := doRequest(t, "POST", ts.URL+"/api/replay?step=100", played, nil); status != http.StatusBadRequest {
+		t.Fatalf("Expected %d for an invalid step, got %d", http.StatusBadRequest, status)
+	}
+
+	// Tampered replays are rejected with a reason
+	tampered := played
+	tampered.Guess = &replay.Guess{Code: played.Guess.Code, Correct: false, Time: played.Guess.Time}
+	raw, err := json.Marshal(tampered)
+	if err != nil {
+		t.Fatalf("Failed to encode replay: %v", err)
+	}
+	resp, err := http.Post(ts.URL+"/api/replay", "application/json", bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("Failed to upload replay: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest || resp.Header.Get("TM-Invalid-Replay-Reason") == "" {
+		t.Fatalf("Expected %d with a reason for a tampered replay, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+	tampered.GameId = "invalid"
+	if status := doRequest(t, "POST", ts.URL+"/api/replay", tampered, nil); status != http.StatusBadRequest {
+		t.Fatalf("Expected %d for an invalid game id, got %d", http.StatusBadRequest, status)
+	}
+}
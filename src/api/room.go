@@ -0,0 +1,344 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/bot"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/rating"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/session"
+	"github.com/stefanovazzocell/TuringMachine/src/websocket"
+)
+
+const (
+	// How often the server pings room connections
+	RoomPingInterval = 30 * time.Second
+
+	// Client messages
+	RoomMessageQuery   = "query"
+	RoomMessageEndTurn = "end_turn"
+	RoomMessageGuess   = "guess"
+	// Server messages
+	RoomMessageJoined = "joined"
+	RoomMessageState  = "state"
+	RoomMessageResult = "result"
+	RoomMessageError  = "error"
+)
+
+type RoomResponse struct {
+	Room      string   `json:"room"`
+	Criterias []int    `json:"criterias"`
+	Verifiers []string `json:"verifiers"`
+}
+
+type RoomPlayer struct {
+	Name      string `json:"name"`
+	Connected bool   `json:"connected"`
+	Bot       bool   `json:"bot"`
+	Checks    int    `json:"checks"`
+	Done      bool   `json:"done"`
+	Guessed   bool   `json:"guessed"`
+	Forfeited bool   `json:"forfeited"`
+}
+
+type RoomStanding struct {
+	Player     string `json:"player"`
+	Rank       int    `json:"rank"`
+	Won        bool   `json:"won"`
+	Solved     bool   `json:"solved"`
+	Eliminated bool   `json:"eliminated"`
+	Rounds     int    `json:"rounds"`
+	Checks     int    `json:"checks"`
+}
+
+type RoomState struct {
+	Room      string       `json:"room"`
+	Criterias []int        `json:"criterias"`
+	Verifiers []string     `json:"verifiers"`
+	Round     int          `json:"round"`
+	Players   []RoomPlayer `json:"players"`
+	// The queries of the receiving player
+	Queries  []SessionQuery `json:"queries"`
+	Finished bool           `json:"finished"`
+	// Only set once the match is finished
+	Standings []RoomStanding `json:"standings,omitempty"`
+	// Only set once the match is finished
+	Code string `json:"code,omitempty"`
+	// Only set once the match is finished
+	GameId string `json:"game_id,omitempty"`
+}
+
+type RoomBotRequest struct {
+	// One of the built-in strategies: random, greedy, minimax or human
+	Strategy string `json:"strategy"`
+	// The name of the bot in the room, "<strategy> bot" by default
+	Name string `json:"name,omitempty"`
+}
+
+type RoomBotResponse struct {
+	Name     string `json:"name"`
+	Strategy string `json:"strategy"`
+}
+
+// A message sent by a client in a room
+type RoomRequest struct {
+	// One of RoomMessageQuery, RoomMessageEndTurn or RoomMessageGuess
+	Type string `json:"type"`
+	// For RoomMessageQuery
+	Proposal     string `json:"proposal,omitempty"`
+	VerifierSlot int    `json:"verifier_slot,omitempty"`
+	// For RoomMessageGuess
+	Code string `json:"code,omitempty"`
+}
+
+// A message sent by the server in a room
+type RoomMessage struct {
+	// One of RoomMessageJoined, RoomMessageState, RoomMessageResult or
+	// RoomMessageError
+	Type string `json:"type"`
+	// For RoomMessageJoined, the secret to reconnect as the player with
+	Secret string `json:"secret,omitempty"`
+	// For RoomMessageState
+	State *RoomState `json:"state,omitempty"`
+	// For RoomMessageResult
+	Check bool `json:"check,omitempty"`
+	// For RoomMessageError
+	Error string `json:"error,omitempty"`
+}
+
+// Handles POST /api/room?difficulty=hard&choices=5 (or ?id=XXXXX or ?token=...)
+func (a *api) handleCreateRoom(w http.ResponseWriter, r *http.Request) {
+	g, _, ok := a.gameFromQuery(w, r.URL.Query())
+	if !ok {
+		return
+	}
+	room, err := a.rooms.Create(g)
+	if err != nil {
+		writeSessionError(w, err)
+		return
+	}
+	view := room.View("")
+	_ = json.NewEncoder(w).Encode(RoomResponse{
+		Room:      view.Code,
+		Criterias: view.Criterias,
+		Verifiers: view.Verifiers,
+	})
+}
+
+// Handles POST /api/room/{code}/bots {strategy: "minimax", name: "Deep Thought"}
+// Adds a bot opponent to the room, before the first query
+func (a *api) handleAddRoomBot(w http.ResponseWriter, r *http.Request) {
+	room, err := a.rooms.Get(r.PathValue("code"))
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	request := RoomBotRequest{}
+	if err = json.NewDecoder(r.Body).Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	strategy, err := bot.New(request.Strategy)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if request.Name == "" {
+		request.Name = strategy.Name() + " bot"
+	}
+	err = room.AddBot(request.Name, strategy)
+	switch {
+	case errors.Is(err, session.ErrInvalidName):
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	case errors.Is(err, session.ErrRatedRoom):
+		w.WriteHeader(http.StatusForbidden)
+		return
+	case err != nil:
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(RoomBotResponse{
+		Name:     strings.TrimSpace(request.Name),
+		Strategy: strategy.Name(),
+	})
+}
+
+// Handles GET /api/room/{code}/ws?name=alice&secret=...&player=...&device_token=...
+// Upgrades to a websocket: the client sends RoomRequest messages and receives
+// RoomMessage messages, starting with a RoomMessageJoined with the secret to
+// reconnect with and including a RoomMessageState on every change.
+func (a *api) handleRoomSocket(w http.ResponseWriter, r *http.Request) {
+	room, err := a.rooms.Get(r.PathValue("code"))
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	query := r.URL.Query()
+	name := query.Get("name")
+	// Players joining with their leaderboard profile are rated in matchmaking
+	// rooms, which only they can join
+	account := ""
+	if query.Has("player") {
+		player, err := a.leaderboard.Authenticate(query.Get("player"), query.Get("device_token"))
+		if err != nil {
+			writeLeaderboardError(w, err)
+			return
+		}
+		account = player.Id
+	}
+	updates, secret, err := room.JoinAs(name, query.Get("secret"), account)
+	switch {
+	case errors.Is(err, session.ErrInvalidName):
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	case errors.Is(err, session.ErrWrongSecret), errors.Is(err, session.ErrAccountMismatch),
+		errors.Is(err, session.ErrRatedRoom):
+		w.WriteHeader(http.StatusForbidden)
+		return
+	case err != nil:
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+	defer room.Leave(name)
+
+	conn, err := websocket.Upgrade(w, r)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	conn.SetIdleTimeout(2 * RoomPingInterval)
+	if err = conn.WriteJSON(RoomMessage{Type: RoomMessageJoined, Secret: secret}); err != nil {
+		return
+	}
+
+	// Send state updates and pings
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(RoomPingInterval)
+		defer ticker.Stop()
+		for {
+			var err error
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				err = conn.Ping()
+			case <-updates:
+				err = conn.WriteJSON(RoomMessage{
+					Type:  RoomMessageState,
+					State: a.roomState(room.View(name)),
+				})
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	// Handle client messages
+	for {
+		request := RoomRequest{}
+		if err := conn.ReadJSON(&request); err != nil {
+			slog.Debug("room connection closed", "room", room.Code(), "err", err)
+			return
+		}
+		response := handleRoomRequest(room, name, request)
+		if err := conn.WriteJSON(response); err != nil {
+			return
+		}
+	}
+}
+
+/*
+* Helpers
+**/
+
+// Applies a client message to a room, returns the response for the client
+func handleRoomRequest(room *session.Room, name string, request RoomRequest) RoomMessage {
+	var err error
+	response := RoomMessage{Type: RoomMessageResult}
+	switch request.Type {
+	case RoomMessageQuery:
+		var proposal game.Code
+		if proposal, err = game.CodeFromString(request.Proposal); err == nil {
+			response.Check, err = room.Query(name, proposal, request.VerifierSlot)
+		}
+	case RoomMessageEndTurn:
+		err = room.EndTurn(name)
+	case RoomMessageGuess:
+		var code game.Code
+		if code, err = game.CodeFromString(request.Code); err == nil {
+			response.Check, err = room.Guess(name, code)
+		}
+	default:
+		err = errors.New("unknown message type")
+	}
+	if err != nil {
+		return RoomMessage{Type: RoomMessageError, Error: err.Error()}
+	}
+	return response
+}
+
+// Rates the finished match of a rated room (see setupMatch), among the players
+// that joined with their leaderboard profile. Called with the room locked.
+func (a *api) rateRoom(code string, outcome session.RoomOutcome) {
+	placements := []rating.Placement{}
+	for _, standing := range outcome.Standings {
+		if account, ok := outcome.Accounts[standing.Player]; ok {
+			placements = append(placements, rating.Placement{
+				Player: account,
+				Rank:   standing.Rank,
+				Solved: standing.Solved,
+			})
+		}
+	}
+	if len(placements) < 2 {
+		return
+	}
+	now := time.Now()
+	match := fmt.Sprintf("room-%s-%d", code, now.UnixNano())
+	if _, err := a.ratings.Rate(match, outcome.Game, placements, now); err != nil {
+		slog.Warn("failed to rate a room match", "room", code, "err", err)
+	}
+}
+
+// Returns the state sent to a player from their view of the room
+func (a *api) roomState(view session.RoomView) *RoomState {
+	state := &RoomState{
+		Room:      view.Code,
+		Criterias: view.Criterias,
+		Verifiers: view.Verifiers,
+		Round:     view.Round,
+		Players:   make([]RoomPlayer, len(view.Players)),
+		Queries:   sessionQueries(view.Rounds),
+		Finished:  view.Finished,
+	}
+	for i, player := range view.Players {
+		state.Players[i] = RoomPlayer(player)
+	}
+	if view.Finished {
+		for _, standing := range view.Standings {
+			state.Standings = append(state.Standings, RoomStanding{
+				Player:     standing.Player,
+				Rank:       standing.Rank,
+				Won:        standing.Won,
+				Solved:     standing.Solved,
+				Eliminated: standing.Eliminated,
+				Rounds:     standing.RoundsPlayed,
+				Checks:     standing.Checks,
+			})
+		}
+		code, _ := view.Game.Solve()
+		state.Code = code.String()
+		state.GameId = a.gameId(view.Game)
+	}
+	return state
+}
@@ -0,0 +1,179 @@
+package api_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stefanovazzocell/TuringMachine/src/api"
+	"github.com/stefanovazzocell/TuringMachine/src/websocket"
+)
+
+// Connects to a room as a player, returns the connection and the secret of
+// the player
+func joinRoom(t *testing.T, url string, room string, name string) (*websocket.Conn, string) {
+	conn, err := websocket.Dial(url + "/api/room/" + room + "/ws?name=" + name)
+	if err != nil {
+		t.Fatalf("Failed to join room %s as %s: %v", room, name, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	joined := api.RoomMessage{}
+	if err = conn.ReadJSON(&joined); err != nil || joined.Type != api.RoomMessageJoined || joined.Secret == "" {
+		t.Fatalf("Expected a joined message with a secret, got %+v (%v)", joined, err)
+	}
+	return conn, joined.Secret
+}
+
+// Reads messages until one matches a condition
+func readUntil(t *testing.T, conn *websocket.Conn, fn func(message api.RoomMessage) bool) api.RoomMessage {
+	for {
+		message := api.RoomMessage{}
+		if err := conn.ReadJSON(&message); err != nil {
+			t.Fatalf("Failed to read message: %v", err)
+		}
+		if fn(message) {
+			return message
+		}
+	}
+}
+
+// Sends a request and returns the response (skipping state updates)
+func roomRequest(t *testing.T, conn *websocket.Conn, request api.RoomRequest) api.RoomMessage {
+	if err := conn.WriteJSON(request); err != nil {
+		t.Fatalf("Failed to send %+v: %v", request, err)
+	}
+	return readUntil(t, conn, func(message api.RoomMessage) bool {
+		return message.Type != api.RoomMessageState
+	})
+}
+
+func TestRoom(t *testing.T) {
+	t.Parallel()
+
+	games := randomGames(t, 10)
+	ts := newTestServer(t, games)
+	g := games[0]
+	code, _ := g.Solve()
+
+	created := api.RoomResponse{}
+	if status := doRequest(t, "POST", ts.URL+"/api/room?id="+g.String(), nil, &created); status != http.StatusOK || len(created.Room) != 6 {
+		t.Fatalf("POST /api/room returned %d %+v", status, created)
+	}
+
+	alice, _ := joinRoom(t, ts.URL, created.Room, "alice")
+	readUntil(t, alice, func(message api.RoomMessage) bool {
+		return message.State != nil && len(message.State.Players) == 1
+	})
+	bob, bobSecret := joinRoom(t, ts.URL, created.Room, "bob")
+	readUntil(t, alice, func(message api.RoomMessage) bool {
+		return message.State != nil && len(message.State.Players) == 2
+	})
+
+	// A second connection for the same player is rejected
+	resp, err := http.Get(ts.URL + "/api/room/" + created.Room + "/ws?name=bob")
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("Expected %d for a connected name, got %d", http.StatusConflict, resp.StatusCode)
+	}
+
+	// Alice queries: she gets the result, bob only sees her progress
+	result := roomRequest(t, alice, api.RoomRequest{Type: api.RoomMessageQuery, Proposal: code.String(), VerifierSlot: 1})
+	if result.Type != api.RoomMessageResult || !result.Check {
+		t.Fatalf("Unexpected query result %+v", result)
+	}
+	state := readUntil(t, bob, func(message api.RoomMessage) bool {
+		return message.State != nil && message.State.Players[0].Checks == 1
+	}).State
+	if len(state.Queries) != 0 || state.Code != "" {
+		t.Fatalf("Bob should not see alice's results: %+v", state)
+	}
+	if message := roomRequest(t, alice, api.RoomRequest{Type: api.RoomMessageQuery, Proposal: code.String(), VerifierSlot: 1}); message.Type != api.RoomMessageError {
+		t.Errorf("Expected an error for a repeated query, got %+v", message)
+	}
+
+	// Bob drops, only his secret takes his seat back
+	bob.Close()
+	readUntil(t, alice, func(message api.RoomMessage) bool {
+		return message.State != nil && !message.State.Players[1].Connected
+	})
+	for _, secret := range []string{"", "guess"} {
+		if status := doRequest(t, "GET", ts.URL+"/api/room/"+created.Room+"/ws?name=bob&secret="+secret, nil, nil); status != http.StatusForbidden {
+			t.Errorf("Expected %d to reconnect with secret %q, got %d", http.StatusForbidden, secret, status)
+		}
+	}
+	bob, secret := joinRoom(t, ts.URL, created.Room, "bob&secret="+bobSecret)
+	if secret != bobSecret {
+		t.Errorf("Expected the same secret after reconnecting, got %q", secret)
+	}
+
+	// Bob guesses right, alice ends her turn
+	if message := roomRequest(t, bob, api.RoomRequest{Type: api.RoomMessageGuess, Code: code.String()}); message.Type != api.RoomMessageResult || !message.Check {
+		t.Fatalf("Unexpected guess result %+v", message)
+	}
+	if message := roomRequest(t, alice, api.RoomRequest{Type: api.RoomMessageEndTurn}); message.Type != api.RoomMessageResult {
+		t.Fatalf("Unexpected end turn result %+v", message)
+	}
+	state = readUntil(t, alice, func(message api.RoomMessage) bool {
+		return message.State != nil && message.State.Finished
+	}).State
+	if state.Code != code.String() || state.GameId != g.String() || len(state.Standings) != 2 ||
+		state.Standings[0].Player != "bob" || !state.Standings[0].Won || len(state.Queries) != 1 {
+		t.Fatalf("Unexpected final state %+v", state)
+	}
+
+	if status := doRequest(t, "GET", ts.URL+"/api/room/ZZZZZZ/ws?name=carol", nil, nil); status != http.StatusNotFound {
+		t.Errorf("Expected %d for an unknown room, got %d", http.StatusNotFound, status)
+	}
+}
+
+func TestRoomBots(t *testing.T) {
+	t.Parallel()
+
+	games := randomGames(t, 10)
+	ts := newTestServer(t, games)
+	created := api.RoomResponse{}
+	doRequest(t, "POST", ts.URL+"/api/room?id="+games[0].String(), nil, &created)
+	botsURL := ts.URL + "/api/room/" + created.Room + "/bots"
+
+	added := api.RoomBotResponse{}
+	if status := doRequest(t, "POST", botsURL, api.RoomBotRequest{Strategy: "minimax"}, &added); status != http.StatusOK ||
+		added.Name != "minimax bot" || added.Strategy != "minimax" {
+		t.Fatalf("POST /api/room/{code}/bots returned %d %+v", status, added)
+	}
+	testCases := []struct {
+		url      string
+		request  api.RoomBotRequest
+		expected int
+	}{
+		{botsURL, api.RoomBotRequest{Strategy: "oracle"}, http.StatusBadRequest},
+		{botsURL, api.RoomBotRequest{Strategy: "greedy", Name: " "}, http.StatusBadRequest},
+		{botsURL, api.RoomBotRequest{Strategy: "greedy", Name: "minimax bot"}, http.StatusConflict},
+		{ts.URL + "/api/room/XXXXXX/bots", api.RoomBotRequest{Strategy: "greedy"}, http.StatusNotFound},
+	}
+	for _, testCase := range testCases {
+		if status := doRequest(t, "POST", testCase.url, testCase.request, nil); status != testCase.expected {
+			t.Errorf("POST %s %+v returned %d, expected %d", testCase.url, testCase.request, status, testCase.expected)
+		}
+	}
+
+	// The bot plays each time alice ends her turn, until it wins
+	alice, _ := joinRoom(t, ts.URL, created.Room, "alice")
+	state := readUntil(t, alice, func(message api.RoomMessage) bool { return message.State != nil }).State
+	if len(state.Players) != 2 || !state.Players[0].Bot || state.Players[1].Bot {
+		t.Fatalf("Unexpected state with a bot %+v", state)
+	}
+	for !state.Finished {
+		if err := alice.WriteJSON(api.RoomRequest{Type: api.RoomMessageEndTurn}); err != nil {
+			t.Fatalf("Failed to end the turn: %v", err)
+		}
+		round := state.Round
+		state = readUntil(t, alice, func(message api.RoomMessage) bool {
+			return message.State != nil && (message.State.Round > round || message.State.Finished)
+		}).State
+	}
+	if len(state.Standings) != 2 || state.Standings[0].Player != "minimax bot" || !state.Standings[0].Won {
+		t.Fatalf("Expected the bot to win, got %+v", state.Standings)
+	}
+}
@@ -8,11 +8,78 @@ import (
 func (a api) registerRoutes() {
 	// GET /api/game?difficulty=hard&choices=5
 	// GET /api/game?id=XXXXX
+	// GET /api/game?id=XXXXX&signed=true
+	// GET /api/game?token=...
+	// GET /api/game?phrase=amber-falcon-river-seven-acorn
+	// GET /api/game?number=1234
 	a.mux.HandleFunc("GET /api/game", a.corsWrapper("GET", a.handleGetGame))
+	// GET /api/game/similar?id=XXXXX&limit=10&shared=2
+	a.mux.HandleFunc("GET /api/game/similar", a.corsWrapper("GET", a.handleGetSimilarGames))
+	// GET /api/daily?date=YYYY-MM-DD
+	a.mux.HandleFunc("GET /api/daily", a.corsWrapper("GET", a.handleDaily))
 	// POST /api/solve {criterias: [...], verifiers: [...]}
 	a.mux.HandleFunc("POST /api/solve", a.corsWrapper("POST", a.handleSolveGame))
 	// GET /api/verify?law=12&proposal=345
+	// GET /api/verify?id=XXXXX&slot=0&proposal=345
 	a.mux.HandleFunc("GET /api/verify", a.corsWrapper("GET", a.handleVerify))
+	// POST /api/guess {id: "XXXXX", code: "345"}
+	a.mux.HandleFunc("POST /api/guess", a.corsWrapper("POST", a.handleGuess))
+	// POST /api/give-up {token: "..."}
+	a.mux.HandleFunc("POST /api/give-up", a.corsWrapper("POST", a.handleGiveUp))
+
+	// POST /api/session?difficulty=hard&choices=5
+	// POST /api/session?id=XXXXX
+	a.mux.HandleFunc("POST /api/session", a.corsWrapper("POST", a.handleCreateSession))
+	// GET /api/session/{id}
+	a.mux.HandleFunc("GET /api/session/{id}", a.corsWrapper("GET", a.handleGetSession))
+	// POST /api/session/{id}/query {proposal: "345", verifier_slot: 0}
+	a.mux.HandleFunc("POST /api/session/{id}/query", a.corsWrapper("POST", a.handleSessionQuery))
+	// POST /api/session/{id}/round
+	a.mux.HandleFunc("POST /api/session/{id}/round", a.corsWrapper("POST", a.handleSessionEndRound))
+	// POST /api/session/{id}/guess {code: "345"}
+	a.mux.HandleFunc("POST /api/session/{id}/guess", a.corsWrapper("POST", a.handleSessionGuess))
+	// GET /api/session/{id}/events (server-sent events)
+	a.mux.HandleFunc("GET /api/session/{id}/events", a.corsWrapper("GET", a.handleSessionEvents))
+	// POST /api/session/{id}/spectators?hide_results=true
+	a.mux.HandleFunc("POST /api/session/{id}/spectators", a.corsWrapper("POST", a.handleAddSpectator))
+	// GET /api/spectate/{id}/events (server-sent events, read-only)
+	a.mux.HandleFunc("GET /api/spectate/{id}/events", a.corsWrapper("GET", a.handleSpectatorEvents))
+	// GET /api/session/{id}/replay
+	a.mux.HandleFunc("GET /api/session/{id}/replay", a.corsWrapper("GET", a.handleSessionReplay))
+	// POST /api/replay?step=4 {version: 1, game_id: "XXXXX", mode: "session", ...}
+	a.mux.HandleFunc("POST /api/replay", a.corsWrapper("POST", a.handleUploadReplay))
+	// POST /api/analyze {version: 1, game_id: "XXXXX", mode: "session", ...}
+	a.mux.HandleFunc("POST /api/analyze", a.corsWrapper("POST", a.handleAnalyze))
+
+	// POST /api/room?difficulty=hard&choices=5
+	// POST /api/room?id=XXXXX
+	a.mux.HandleFunc("POST /api/room", a.corsWrapper("POST", a.handleCreateRoom))
+	// POST /api/room/{code}/bots {strategy: "minimax", name: "Deep Thought"}
+	a.mux.HandleFunc("POST /api/room/{code}/bots", a.corsWrapper("POST", a.handleAddRoomBot))
+	// GET /api/room/{code}/ws?name=alice (websocket)
+	// GET /api/room/{code}/ws?name=alice&secret=... (websocket, reconnecting)
+	// GET /api/room/{code}/ws?name=alice&player=...&device_token=... (websocket, rated in matchmaking rooms)
+	a.mux.HandleFunc("GET /api/room/{code}/ws", a.corsWrapper("GET", a.handleRoomSocket))
+
+	// POST /api/matchmaking {player: "...", device_token: "...", choices: 5, difficulty: "hard"}
+	a.mux.HandleFunc("POST /api/matchmaking", a.corsWrapper("POST", a.handleJoinMatchmaking))
+	// DELETE /api/matchmaking/{ticket}
+	a.mux.HandleFunc("DELETE /api/matchmaking/{ticket}", a.corsWrapper("DELETE", a.handleLeaveMatchmaking))
+	// GET /api/matchmaking/{ticket}/events (server-sent events)
+	a.mux.HandleFunc("GET /api/matchmaking/{ticket}/events", a.corsWrapper("GET", a.handleMatchmakingEvents))
+
+	// POST /api/player {nickname: "alice"}
+	a.mux.HandleFunc("POST /api/player", a.corsWrapper("POST", a.handleCreatePlayer))
+	// POST /api/player/{id}/results {device_token: "...", session: "..."}
+	a.mux.HandleFunc("POST /api/player/{id}/results", a.corsWrapper("POST", a.handleRecordResult))
+	// GET /api/player/{id}/stats
+	a.mux.HandleFunc("GET /api/player/{id}/stats", a.corsWrapper("GET", a.handlePlayerStats))
+	// GET /api/player/{id}/rating?since=YYYY-MM-DD&limit=20
+	a.mux.HandleFunc("GET /api/player/{id}/rating", a.corsWrapper("GET", a.handlePlayerRating))
+	// GET /api/leaderboard?puzzle=XXXXX&limit=20
+	// GET /api/leaderboard?puzzle=daily&date=YYYY-MM-DD
+	// GET /api/leaderboard?date=YYYY-MM-DD
+	a.mux.HandleFunc("GET /api/leaderboard", a.corsWrapper("GET", a.handleLeaderboard))
 
 	// Default handler
 	a.mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
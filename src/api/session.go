@@ -0,0 +1,210 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	"github.com/stefanovazzocell/TuringMachine/src/token"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/session"
+)
+
+type SessionQuery struct {
+	// The round of the query, starting from 1
+	Round        int    `json:"round"`
+	Proposal     string `json:"proposal"`
+	VerifierSlot int    `json:"verifier_slot"`
+	Check        bool   `json:"check"`
+}
+
+type SessionResponse struct {
+	Id        string         `json:"id"`
+	Criterias []int          `json:"criterias"`
+	Verifiers []string       `json:"verifiers"`
+	Queries   []SessionQuery `json:"queries"`
+	// The current round, starting from 1
+	Round    int  `json:"round"`
+	Finished bool `json:"finished"`
+	Won      bool `json:"won"`
+	// Only set once the session is finished
+	Code string `json:"code,omitempty"`
+	// Only set once the session is finished
+	GameId string `json:"game_id,omitempty"`
+	// Only set once the session is finished
+	Par *SessionPar `json:"par,omitempty"`
+}
+
+type SessionQueryRequest struct {
+	Proposal string `json:"proposal"`
+	// The index of the verifier in SessionResponse.Verifiers
+	VerifierSlot int `json:"verifier_slot"`
+}
+
+type SessionQueryResponse struct {
+	Check bool `json:"check"`
+}
+
+type SessionGuessRequest struct {
+	Code string `json:"code"`
+}
+
+// Returns the queries made in a list of rounds
+func sessionQueries(rounds []game.Round) []SessionQuery {
+	queries := []SessionQuery{}
+	for i, round := range rounds {
+		for _, query := range round.Queries {
+			queries = append(queries, SessionQuery{
+				Round:        i + 1,
+				Proposal:     query.Proposal.String(),
+				VerifierSlot: query.Verifier,
+				Check:        query.Result,
+			})
+		}
+	}
+	return queries
+}
+
+// Returns the origin of a session for a game from a query (see
+// gameFromQuery): the source of its token, TokenModeRandom for random games,
+// or empty if the game is served with its code (e.g. requested by id)
+func sessionOrigin(query url.Values, claims token.Claims) string {
+	switch {
+	case claims.Mode == TokenModeId:
+		return ""
+	case query.Has("token"):
+		return tokenSource(query.Get("token"))
+	case claims.Mode == TokenModeRandom:
+		return TokenModeRandom
+	}
+	return ""
+}
+
+// Writes a session into a responsewriter, the code and the par are only
+// included once the session is finished
+func (a *api) writeSessionResponse(w http.ResponseWriter, s session.Session) {
+	response := SessionResponse{
+		Id:        s.Id,
+		Criterias: s.Criterias,
+		Verifiers: s.Verifiers,
+		Queries:   sessionQueries(s.Rounds),
+		Round:     len(s.Rounds),
+		Finished:  s.Finished,
+		Won:       s.Won,
+	}
+	if s.Finished {
+		response.Code = s.Code().String()
+		response.GameId = a.gameId(s.Game)
+		if par, err := a.sessionPar(s); err != nil {
+			slog.Warn("failed to play the par", "game", response.GameId, "err", err)
+		} else {
+			response.Par = &par
+		}
+	}
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// Handles POST /api/session?difficulty=hard&choices=5 (or ?id=XXXXX or ?token=...)
+// A token is played in a single session, so its puzzle cannot be explored in
+// another session before the one recorded on the leaderboard.
+func (a *api) handleCreateSession(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	g, claims, ok := a.gameFromQuery(w, query)
+	if !ok {
+		return
+	}
+	origin := sessionOrigin(query, claims)
+	if query.Has("token") {
+		if err := a.leaderboard.Claim(tokenSource(query.Get("token"))); err != nil {
+			writeLeaderboardError(w, err)
+			return
+		}
+	}
+	s, err := a.sessions.CreateFrom(g, origin)
+	if err != nil {
+		writeSessionError(w, err)
+		return
+	}
+	a.writeSessionResponse(w, s)
+}
+
+// Handles GET /api/session/{id}
+func (a *api) handleGetSession(w http.ResponseWriter, r *http.Request) {
+	s, err := a.sessions.Get(r.PathValue("id"))
+	if err != nil {
+		writeSessionError(w, err)
+		return
+	}
+	a.writeSessionResponse(w, s)
+}
+
+// Handles POST /api/session/{id}/query {proposal: "345", verifier_slot: 0}
+func (a *api) handleSessionQuery(w http.ResponseWriter, r *http.Request) {
+	request := SessionQueryRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	proposal, err := game.CodeFromString(request.Proposal)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	check, err := a.sessions.Query(r.PathValue("id"), proposal, request.VerifierSlot)
+	if err != nil {
+		writeSessionError(w, err)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(SessionQueryResponse{
+		Check: check,
+	})
+}
+
+// Handles POST /api/session/{id}/round
+func (a *api) handleSessionEndRound(w http.ResponseWriter, r *http.Request) {
+	s, err := a.sessions.EndRound(r.PathValue("id"))
+	if err != nil {
+		writeSessionError(w, err)
+		return
+	}
+	a.writeSessionResponse(w, s)
+}
+
+// Handles POST /api/session/{id}/guess {code: "345"}
+func (a *api) handleSessionGuess(w http.ResponseWriter, r *http.Request) {
+	request := SessionGuessRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	code, err := game.CodeFromString(request.Code)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	s, err := a.sessions.Guess(r.PathValue("id"), code)
+	if err != nil {
+		writeSessionError(w, err)
+		return
+	}
+	a.writeSessionResponse(w, s)
+}
+
+// Responds with the status matching a session error
+func writeSessionError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, session.ErrNotFound):
+		w.WriteHeader(http.StatusNotFound)
+	case errors.Is(err, game.ErrMatchFinished),
+		errors.Is(err, game.ErrRoundTooManyQueries),
+		errors.Is(err, game.ErrRoundRepeatedQuery):
+		w.WriteHeader(http.StatusConflict)
+	case errors.Is(err, game.ErrRoundInvalidVerifier), errors.Is(err, session.ErrNoSolution):
+		w.WriteHeader(http.StatusBadRequest)
+	default:
+		slog.Warn("session request failed", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
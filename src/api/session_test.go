@@ -0,0 +1,249 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stefanovazzocell/TuringMachine/src/api"
+	"github.com/stefanovazzocell/TuringMachine/src/kv"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/store"
+)
+
+func TestSession(t *testing.T) {
+	t.Parallel()
+
+	games := randomGames(t, 10)
+	ts := newTestServer(t, games)
+	g := games[0]
+	code, _ := g.Solve()
+
+	created := api.SessionResponse{}
+	status := doRequest(t, "POST", ts.URL+"/api/session?id="+g.String(), nil, &created)
+	if status != http.StatusOK || created.Id == "" || created.Code != "" || created.GameId != "" || created.Par != nil ||
+		len(created.Verifiers) != g.NumberOfChoices() {
+		t.Fatalf("POST /api/session returned %d %+v", status, created)
+	}
+	sessionURL := ts.URL + "/api/session/" + created.Id
+
+	for slot := range g.NumberOfChoices() {
+		// At most 3 verifiers per round
+		if slot == game.MaxQueriesPerRound {
+			status = doRequest(t, "POST", sessionURL+"/query", api.SessionQueryRequest{
+				Proposal:     code.String(),
+				VerifierSlot: slot,
+			}, nil)
+			if status != http.StatusConflict {
+				t.Fatalf("Expected %d for a 4th query in a round, got %d", http.StatusConflict, status)
+			}
+			round := api.SessionResponse{}
+			if status = doRequest(t, "POST", sessionURL+"/round", nil, &round); status != http.StatusOK || round.Round != 2 {
+				t.Fatalf("POST %s/round returned %d %+v", sessionURL, status, round)
+			}
+		}
+		query := api.SessionQueryResponse{}
+		status = doRequest(t, "POST", sessionURL+"/query", api.SessionQueryRequest{
+			Proposal:     code.String(),
+			VerifierSlot: slot,
+		}, &query)
+		if status != http.StatusOK || !query.Check {
+			t.Fatalf("POST %s/query for slot %d returned %d %+v", sessionURL, slot, status, query)
+		}
+	}
+
+	current := api.SessionResponse{}
+	status = doRequest(t, "GET", sessionURL, nil, &current)
+	if status != http.StatusOK || len(current.Queries) != g.NumberOfChoices() || current.Code != "" ||
+		current.Queries[len(current.Queries)-1].Round != 2 {
+		t.Fatalf("GET %s returned %d %+v", sessionURL, status, current)
+	}
+
+	finished := api.SessionResponse{}
+	status = doRequest(t, "POST", sessionURL+"/guess", api.SessionGuessRequest{Code: code.String()}, &finished)
+	if status != http.StatusOK || !finished.Finished || !finished.Won || finished.Code != code.String() ||
+		finished.GameId != g.String() || finished.Par == nil {
+		t.Fatalf("POST %s/guess returned %d %+v", sessionURL, status, finished)
+	}
+	if par := finished.Par; par.PlayerRounds != 2 || par.PlayerChecks != g.NumberOfChoices() || par.Diverged == 0 {
+		t.Fatalf("Unexpected par %+v", par)
+	}
+
+	testCases := []struct {
+		method   string
+		url      string
+		body     any
+		expected int
+	}{
+		{"POST", sessionURL + "/guess", api.SessionGuessRequest{Code: code.String()}, http.StatusConflict},
+		{"POST", sessionURL + "/query", api.SessionQueryRequest{Proposal: code.String()}, http.StatusConflict},
+		{"GET", ts.URL + "/api/session/unknown", nil, http.StatusNotFound},
+		{"POST", ts.URL + "/api/session/unknown/guess", api.SessionGuessRequest{Code: code.String()}, http.StatusNotFound},
+		{"POST", sessionURL + "/guess", api.SessionGuessRequest{Code: "999"}, http.StatusBadRequest},
+		{"POST", ts.URL + "/api/session?choices=9", nil, http.StatusBadRequest},
+	}
+	for _, testCase := range testCases {
+		if status = doRequest(t, testCase.method, testCase.url, testCase.body, nil); status != testCase.expected {
+			t.Errorf("%s %s returned %d, expected %d", testCase.method, testCase.url, status, testCase.expected)
+		}
+	}
+}
+
+func TestSessionPar(t *testing.T) {
+	t.Parallel()
+
+	games := randomGames(t, 10)
+	ts := newTestServer(t, games)
+	g := games[0]
+	par, err := game.PlayPar(g)
+	if err != nil {
+		t.Fatalf("PlayPar failed: %v", err)
+	}
+
+	// Following the bot is playing at par
+	created := api.SessionResponse{}
+	doRequest(t, "POST", ts.URL+"/api/session?id="+g.String(), nil, &created)
+	sessionURL := ts.URL + "/api/session/" + created.Id
+	for i, round := range par.Rounds {
+		if i > 0 {
+			doRequest(t, "POST", sessionURL+"/round", nil, nil)
+		}
+		for _, query := range round.Queries {
+			doRequest(t, "POST", sessionURL+"/query", api.SessionQueryRequest{
+				Proposal:     query.Proposal.String(),
+				VerifierSlot: query.Verifier,
+			}, nil)
+		}
+	}
+	finished := api.SessionResponse{}
+	doRequest(t, "POST", sessionURL+"/guess", api.SessionGuessRequest{Code: par.Guess.String()}, &finished)
+	if finished.Par == nil || finished.Par.Result != api.ParEven || finished.Par.Diverged != 0 ||
+		finished.Par.Rounds != par.RoundsPlayed() || finished.Par.Checks != par.Checks() ||
+		len(finished.Par.Queries) != par.Checks() {
+		t.Fatalf("Expected to be at par, got %+v", finished.Par)
+	}
+
+	// A wrong guess is over par
+	doRequest(t, "POST", ts.URL+"/api/session?id="+g.String(), nil, &created)
+	wrong := game.CodeFromNumbers(1, 1, 1)
+	if wrong == par.Guess {
+		wrong = game.CodeFromNumbers(5, 5, 5)
+	}
+	doRequest(t, "POST", ts.URL+"/api/session/"+created.Id+"/guess", api.SessionGuessRequest{Code: wrong.String()}, &finished)
+	if finished.Par == nil || finished.Par.Result != api.ParOver || finished.Par.PlayerRounds != 0 {
+		t.Fatalf("Expected to be over par, got %+v", finished.Par)
+	}
+}
+
+func TestSessionInvalidSlot(t *testing.T) {
+	t.Parallel()
+
+	games := randomGames(t, 10)
+	ts := newTestServer(t, games)
+
+	created := api.SessionResponse{}
+	if status := doRequest(t, "POST", ts.URL+"/api/session?choices=4", nil, &created); status != http.StatusOK {
+		t.Fatalf("POST /api/session returned %d", status)
+	}
+	status := doRequest(t, "POST", ts.URL+"/api/session/"+created.Id+"/query", api.SessionQueryRequest{
+		Proposal:     "111",
+		VerifierSlot: 4,
+	}, nil)
+	if status != http.StatusBadRequest {
+		t.Errorf("Expected %d for an invalid slot, got %d", http.StatusBadRequest, status)
+	}
+}
+
+func TestSessionPersistence(t *testing.T) {
+	t.Parallel()
+
+	games := randomGames(t, 10)
+	config := api.NewAPIConfig("", "*")
+	config.SessionStore = filepath.Join(t.TempDir(), "sessions.kv")
+	// Starts an API sharing the session store, returns its URL and a function
+	// to shut it down
+	start := func() (string, func()) {
+		source, err := store.NewMemoryStore(games)
+		if err != nil {
+			t.Fatalf("Failed to create memory store: %v", err)
+		}
+		server := &http.Server{}
+		a, err := api.NewApi(server, source, config)
+		if err != nil {
+			t.Fatalf("Failed to create api: %v", err)
+		}
+		ts := httptest.NewServer(server.Handler)
+		return ts.URL, func() {
+			ts.Close()
+			a.Close()
+		}
+	}
+
+	url, stop := start()
+	created := api.SessionResponse{}
+	if status := doRequest(t, "POST", url+"/api/session?id="+games[0].String(), nil, &created); status != http.StatusOK {
+		t.Fatalf("POST /api/session returned %d", status)
+	}
+	code, _ := games[0].Solve()
+	if status := doRequest(t, "POST", url+"/api/session/"+created.Id+"/query", api.SessionQueryRequest{
+		Proposal: code.String(),
+	}, nil); status != http.StatusOK {
+		t.Fatalf("POST /api/session/%s/query returned %d", created.Id, status)
+	}
+	stop()
+
+	// The session survives the restart
+	url, stop = start()
+	defer stop()
+	restored := api.SessionResponse{}
+	status := doRequest(t, "GET", url+"/api/session/"+created.Id, nil, &restored)
+	if status != http.StatusOK || len(restored.Queries) != 1 || !restored.Queries[0].Check {
+		t.Fatalf("GET /api/session/%s returned %d %+v after restarting", created.Id, status, restored)
+	}
+}
+
+func TestSessionSnapshots(t *testing.T) {
+	t.Parallel()
+
+	games := randomGames(t, 10)
+	config := api.NewAPIConfig("", "*")
+	config.SessionStore = filepath.Join(t.TempDir(), "sessions.kv")
+	config.SessionSnapshot = 10 * time.Millisecond
+	// Starts an API sharing the session store, closed at the end of the test
+	start := func() string {
+		server := &http.Server{}
+		a, err := api.NewApi(server, memorySource(t, games), config)
+		if err != nil {
+			t.Fatalf("Failed to create api: %v", err)
+		}
+		return serveApi(t, server, a).URL
+	}
+	url := start()
+	created := api.SessionResponse{}
+	if status := doRequest(t, "POST", url+"/api/session?id="+games[0].String(), nil, &created); status != http.StatusOK {
+		t.Fatalf("POST /api/session returned %d", status)
+	}
+
+	// The session is saved without shutting down, as if the server crashed
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		backend, err := kv.OpenFile(config.SessionStore)
+		if err != nil {
+			t.Fatalf("Failed to open the session store: %v", err)
+		}
+		keys, _ := backend.Keys("session/")
+		if len(keys) == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("The session was not snapshotted, found %v", keys)
+		}
+		time.Sleep(config.SessionSnapshot)
+	}
+	restarted := start()
+	if status := doRequest(t, "GET", restarted+"/api/session/"+created.Id, nil, nil); status != http.StatusOK {
+		t.Fatalf("GET /api/session/%s returned %d after a crash", created.Id, status)
+	}
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/store"
+)
+
+const (
+	// The default number of similar games returned
+	DefaultSimilarLimit = 10
+	// The maximum number of similar games returned
+	MaxSimilarLimit = 100
+	// The default minimum number of shared criterias for similar games
+	DefaultSimilarShared = 2
+)
+
+type SimilarGame struct {
+	Id        string `json:"id"`
+	Criterias []int  `json:"criterias"`
+	Shared    int    `json:"shared"`
+	SameCode  bool   `json:"same_code"`
+	Swap      bool   `json:"swap"`
+}
+
+type SimilarResponse struct {
+	Id      string        `json:"id"`
+	Similar []SimilarGame `json:"similar"`
+}
+
+// A criteria index that's only built the first time it's needed, as it takes
+// a few seconds and a fair amount of memory.
+type lazyCriteriaIndex struct {
+	lock  sync.Mutex
+	index *store.CriteriaIndex
+}
+
+// Returns the criteria index for a source, building it if needed.
+// A failed build is not cached, the next call tries again.
+func (lazy *lazyCriteriaIndex) get(s store.GameSource) (*store.CriteriaIndex, error) {
+	lazy.lock.Lock()
+	defer lazy.lock.Unlock()
+	if lazy.index != nil {
+		return lazy.index, nil
+	}
+	index, err := store.NewCriteriaIndex(context.Background(), s)
+	if err != nil {
+		return nil, err
+	}
+	lazy.index = index
+	return index, nil
+}
+
+// Returns a positive integer query parameter, the fallback if not set, or -1
+// if invalid.
+func getPositiveInt(value string, fallback int) int {
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return -1
+	}
+	return n
+}
+
+// Handles GET /api/game/similar?id=XXXXX&limit=10&shared=2
+func (a *api) handleGetSimilarGames(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	g, err := a.parseGameId(query.Get("id"))
+	if err != nil {
+		a.writeInvalidGameId(w, query.Get("id"), err)
+		return
+	}
+	limit := getPositiveInt(query.Get("limit"), DefaultSimilarLimit)
+	shared := getPositiveInt(query.Get("shared"), DefaultSimilarShared)
+	if limit == -1 || shared == -1 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	limit = min(limit, MaxSimilarLimit)
+
+	index, err := a.similar.get(a.store)
+	if err != nil {
+		slog.Error("failed to build criteria index", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	similar, err := index.Similar(g, shared, limit)
+	if err != nil {
+		slog.Warn("failed to find similar games", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	response := SimilarResponse{
+		Id:      a.gameId(g),
+		Similar: make([]SimilarGame, len(similar)),
+	}
+	for i, result := range similar {
+		criterias, _, _ := result.Game.GetCards()
+		response.Similar[i] = SimilarGame{
+			Id:        a.gameId(result.Game),
+			Criterias: criterias,
+			Shared:    result.SharedCriterias,
+			SameCode:  result.SameCode,
+			Swap:      result.Swap,
+		}
+	}
+	_ = json.NewEncoder(w).Encode(response)
+}
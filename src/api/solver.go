@@ -17,7 +17,7 @@ type SolverResponse struct {
 }
 
 // Writes a SolverResponse into a responsewriter
-func writeSolverResponse(w http.ResponseWriter, g game.Game) {
+func (a *api) writeSolverResponse(w http.ResponseWriter, g game.Game) {
 	codes := g.GetMask().GetAllCodes()
 	codesStr := make([]string, len(codes))
 	for i := range len(codes) {
@@ -27,7 +27,7 @@ func writeSolverResponse(w http.ResponseWriter, g game.Game) {
 	criteriaCards, verificationCards, laws := g.GetCards()
 
 	_ = json.NewEncoder(w).Encode(SolverResponse{
-		Id:        g.String(),
+		Id:        a.gameId(g),
 		Solutions: codesStr,
 		Criterias: criteriaCards,
 		Verifiers: verificationCards,
@@ -83,5 +83,5 @@ func (a *api) handleSolveGame(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	writeSolverResponse(w, g)
+	a.writeSolverResponse(w, g)
 }
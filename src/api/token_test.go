@@ -0,0 +1,160 @@
+package api_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stefanovazzocell/TuringMachine/src/api"
+	"github.com/stefanovazzocell/TuringMachine/src/token"
+)
+
+func TestGameToken(t *testing.T) {
+	t.Parallel()
+
+	games := randomGames(t, 10)
+	ts := newSignedTestServer(t, games)
+	g := games[0]
+	code, _ := g.Solve()
+
+	// Tokens are only issued for signed games, without the ids, code and laws
+	unsigned := api.GameResponse{}
+	if status := doRequest(t, "GET", ts.URL+"/api/game?id="+g.String(), nil, &unsigned); status != http.StatusOK ||
+		unsigned.Token != "" || unsigned.Code != code.String() || unsigned.Id == "" || unsigned.Phrase == "" {
+		t.Fatalf("GET /api/game returned %d %+v", status, unsigned)
+	}
+	issued := api.GameResponse{}
+	if status := doRequest(t, "GET", ts.URL+"/api/game?signed=true&id="+g.String(), nil, &issued); status != http.StatusOK ||
+		issued.Token == "" || issued.Code != "" || issued.Laws != nil ||
+		issued.Id != "" || issued.DisplayId != "" || issued.Phrase != "" || issued.Number != 0 {
+		t.Fatalf("GET /api/game?signed=true returned %d %+v", status, issued)
+	}
+	if status := doRequest(t, "GET", ts.URL+"/api/game?signed=maybe&id="+g.String(), nil, nil); status != http.StatusBadRequest {
+		t.Errorf("Expected %d for an invalid signed value, got %d", http.StatusBadRequest, status)
+	}
+	// The token keeps the game and its symbol, and never reveals the code
+	fromToken := api.GameResponse{}
+	status := doRequest(t, "GET", ts.URL+"/api/game?"+url.Values{"token": {issued.Token}, "signed": {"false"}}.Encode(), nil, &fromToken)
+	if status != http.StatusOK || fromToken.Id != "" || fromToken.Criterias[0] != issued.Criterias[0] ||
+		fromToken.Verifiers[0] != issued.Verifiers[0] || fromToken.Code != "" {
+		t.Fatalf("GET /api/game?token=... returned %d %+v, expected %+v", status, fromToken, issued)
+	}
+
+	// The game in the token claims is not a usable id
+	claims := tokenClaims(t, issued.Token)
+	if status = doRequest(t, "GET", ts.URL+"/api/game?id="+claims.Game, nil, &unsigned); status == http.StatusOK && unsigned.Code == code.String() {
+		t.Errorf("Expected the token game not to be served unsigned, got %+v", unsigned)
+	}
+
+	// Signed games are only checked in sessions, which count the checks
+	status = doRequest(t, "GET", ts.URL+"/api/verify?"+url.Values{
+		"token":    {issued.Token},
+		"slot":     {"0"},
+		"proposal": {code.String()},
+	}.Encode(), nil, nil)
+	if status != http.StatusBadRequest {
+		t.Errorf("GET /api/verify?token=... returned %d, expected %d", status, http.StatusBadRequest)
+	}
+	created := api.SessionResponse{}
+	status = doRequest(t, "POST", ts.URL+"/api/session?"+url.Values{"token": {issued.Token}}.Encode(), nil, &created)
+	if status != http.StatusOK || created.Criterias[0] != issued.Criterias[0] {
+		t.Fatalf("POST /api/session?token=... returned %d %+v", status, created)
+	}
+
+	// Giving up reveals the code and laws
+	givenUp := api.GiveUpResponse{}
+	status = doRequest(t, "POST", ts.URL+"/api/give-up", api.GiveUpRequest{Token: issued.Token}, &givenUp)
+	if status != http.StatusOK || givenUp.Id != unsigned.Id || givenUp.Code != code.String() || len(givenUp.Laws) != g.NumberOfChoices() {
+		t.Fatalf("POST /api/give-up returned %d %+v", status, givenUp)
+	}
+	if status = doRequest(t, "POST", ts.URL+"/api/give-up", api.GiveUpRequest{}, nil); status != http.StatusBadRequest {
+		t.Errorf("Expected %d to give up without a token, got %d", http.StatusBadRequest, status)
+	}
+
+	// Tokens from other servers, tampered with or expired are rejected
+	other := newSignedTestServer(t, games)
+	foreign := api.GameResponse{}
+	doRequest(t, "GET", other.URL+"/api/game?signed=true&id="+g.String(), nil, &foreign)
+	// The issued claims re-encoded with another seed, so the payload always
+	// differs from the signed one
+	parts := strings.Split(issued.Token, ".")
+	claims.Seed++
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("Failed to marshal the token claims: %v", err)
+	}
+	tampered := parts[0] + "." + base64.RawURLEncoding.EncodeToString(payload) + "." + parts[2]
+
+	config := api.NewAPIConfig("", "*")
+	config.IdSecret = "secret"
+	config.TokenKeys = []token.Key{{Id: "test", Secret: []byte("0123456789abcdef")}}
+	config.TokenTTL = time.Nanosecond
+	server := &http.Server{}
+	a, err := api.NewApi(server, memorySource(t, games), config)
+	if err != nil {
+		t.Fatalf("Failed to create api: %v", err)
+	}
+	expiring := serveApi(t, server, a)
+	expired := api.GameResponse{}
+	doRequest(t, "GET", expiring.URL+"/api/game?signed=true&id="+g.String(), nil, &expired)
+	if !strings.HasPrefix(expired.Token, "test.") {
+		t.Errorf("Expected the configured key to be used, got %q", expired.Token)
+	}
+
+	for _, testCase := range []struct {
+		url   string
+		token string
+	}{
+		{ts.URL, foreign.Token},
+		{ts.URL, tampered},
+		{ts.URL, "garbage"},
+		{expiring.URL, expired.Token},
+	} {
+		status = doRequest(t, "POST", testCase.url+"/api/session?"+url.Values{"token": {testCase.token}}.Encode(), nil, nil)
+		if status != http.StatusUnauthorized {
+			t.Errorf("POST /api/session with token %q returned %d, expected %d", testCase.token, status, http.StatusUnauthorized)
+		}
+		status = doRequest(t, "POST", testCase.url+"/api/give-up", api.GiveUpRequest{Token: testCase.token}, nil)
+		if status != http.StatusUnauthorized {
+			t.Errorf("POST /api/give-up with token %q returned %d, expected %d", testCase.token, status, http.StatusUnauthorized)
+		}
+	}
+}
+
+func TestGameTokenDisabled(t *testing.T) {
+	t.Parallel()
+
+	// Without an id secret the game of a token could be requested unsigned
+	games := randomGames(t, 1)
+	ts := newTestServer(t, games)
+	if status := doRequest(t, "GET", ts.URL+"/api/game?signed=true&id="+games[0].String(), nil, nil); status != http.StatusNotImplemented {
+		t.Errorf("GET /api/game?signed=true returned %d, expected %d", status, http.StatusNotImplemented)
+	}
+	signed := newSignedTestServer(t, games)
+	issued := api.GameResponse{}
+	doRequest(t, "GET", signed.URL+"/api/game?signed=true&id="+games[0].String(), nil, &issued)
+	if status := doRequest(t, "GET", ts.URL+"/api/game?"+url.Values{"token": {issued.Token}}.Encode(), nil, nil); status != http.StatusNotImplemented {
+		t.Errorf("GET /api/game?token=... returned %d, expected %d", status, http.StatusNotImplemented)
+	}
+}
+
+// Returns the (unverified) claims of a token
+func tokenClaims(t testing.TB, signed string) token.Claims {
+	parts := strings.Split(signed, ".")
+	if len(parts) != 3 {
+		t.Fatalf("Invalid token %q", signed)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		t.Fatalf("Failed to decode the token payload: %v", err)
+	}
+	claims := token.Claims{}
+	if err = json.Unmarshal(payload, &claims); err != nil {
+		t.Fatalf("Failed to unmarshal the token claims: %v", err)
+	}
+	return claims
+}
@@ -3,6 +3,7 @@ package api
 import (
 	"encoding/json"
 	"net/http"
+	"net/url"
 	"strconv"
 
 	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
@@ -12,10 +13,33 @@ type VerifyResponse struct {
 	Check bool `json:"check"`
 }
 
+type GuessRequest struct {
+	// The game id
+	Id   string `json:"id,omitempty"`
+	Code string `json:"code"`
+}
+
+type GuessResponse struct {
+	Correct bool `json:"correct"`
+}
+
 // Handles GET /api/verify?law=12&proposal=345
+// and GET /api/verify?id=XXXXX&slot=0&proposal=345
+// Checking by game and verifier slot does not reveal the law to the client.
+// Signed games are only checked in sessions and rooms, which count the checks
+// (see ErrTokenNotMetered).
 func (a *api) handleVerify(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query()
 
+	if query.Has("token") {
+		w.Header().Set("TM-Invalid-Token-Reason", ErrTokenNotMetered.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if query.Has("id") {
+		a.handleVerifySlot(w, r)
+		return
+	}
 	if !query.Has("law") || !query.Has("proposal") {
 		w.WriteHeader(http.StatusBadRequest)
 		return
@@ -42,3 +66,54 @@ func (a *api) handleVerify(w http.ResponseWriter, r *http.Request) {
 		Check: valid,
 	})
 }
+
+// Handles POST /api/guess {id: "XXXXX", code: "345"}
+// Signed games are only guessed in sessions and rooms (see ErrTokenNotMetered)
+func (a *api) handleGuess(w http.ResponseWriter, r *http.Request) {
+	request := GuessRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	g, _, ok := a.gameFromQuery(w, url.Values{"id": {request.Id}})
+	if !ok {
+		return
+	}
+	code, err := game.CodeFromString(request.Code)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	solution, _ := g.Solve()
+	_ = json.NewEncoder(w).Encode(GuessResponse{
+		Correct: code == solution,
+	})
+}
+
+// Handles GET /api/verify?id=XXXXX&slot=0&proposal=345
+func (a *api) handleVerifySlot(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	if !query.Has("slot") || !query.Has("proposal") {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	g, _, ok := a.gameFromQuery(w, query)
+	if !ok {
+		return
+	}
+	slot, err := strconv.Atoi(query.Get("slot"))
+	if err != nil || slot < 0 || slot >= g.NumberOfChoices() {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	code, err := game.CodeFromString(query.Get("proposal"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	_ = json.NewEncoder(w).Encode(VerifyResponse{
+		Check: g[slot].Law().Mask.Check(code),
+	})
+}
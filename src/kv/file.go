@@ -0,0 +1,162 @@
+package kv
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A backend held in memory and persisted to a JSON file.
+// Changes are written to the file on Flush and Close.
+// Safe for concurrent use.
+type FileBackend struct {
+	filename string
+
+	lock    sync.Mutex
+	entries map[string]fileEntry
+	closed  bool
+}
+
+// A value in a file backend
+type fileEntry struct {
+	Value []byte `json:"value"`
+	// In milliseconds since the epoch, zero if the entry never expires
+	Expires int64 `json:"expires,omitempty"`
+}
+
+// Returns true if the entry has expired at a given time
+func (entry fileEntry) expired(now time.Time) bool {
+	return entry.Expires != 0 && now.UnixMilli() > entry.Expires
+}
+
+// Opens a file backend, the file is created on the first Flush if missing
+func OpenFile(filename string) (*FileBackend, error) {
+	backend := &FileBackend{
+		filename: filename,
+		entries:  map[string]fileEntry{},
+	}
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return backend, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, &backend.entries); err != nil {
+		return nil, err
+	}
+	return backend, nil
+}
+
+// Stores a value under a key, expiring after ttl (never if 0)
+func (backend *FileBackend) Put(key string, value []byte, ttl time.Duration) error {
+	entry := fileEntry{Value: append([]byte(nil), value...)}
+	if ttl > 0 {
+		entry.Expires = time.Now().Add(ttl).UnixMilli()
+	}
+	backend.lock.Lock()
+	defer backend.lock.Unlock()
+	if backend.closed {
+		return ErrClosed
+	}
+	backend.entries[key] = entry
+	return nil
+}
+
+// Returns the value of a key, or ErrNotFound
+func (backend *FileBackend) Get(key string) ([]byte, error) {
+	backend.lock.Lock()
+	defer backend.lock.Unlock()
+	if backend.closed {
+		return nil, ErrClosed
+	}
+	entry, ok := backend.entries[key]
+	if !ok || entry.expired(time.Now()) {
+		return nil, ErrNotFound
+	}
+	return append([]byte(nil), entry.Value...), nil
+}
+
+// Removes a key, missing keys are ignored
+func (backend *FileBackend) Delete(key string) error {
+	backend.lock.Lock()
+	defer backend.lock.Unlock()
+	if backend.closed {
+		return ErrClosed
+	}
+	delete(backend.entries, key)
+	return nil
+}
+
+// Returns the keys starting with prefix, in no particular order
+func (backend *FileBackend) Keys(prefix string) ([]string, error) {
+	backend.lock.Lock()
+	defer backend.lock.Unlock()
+	if backend.closed {
+		return nil, ErrClosed
+	}
+	now := time.Now()
+	keys := []string{}
+	for key, entry := range backend.entries {
+		if strings.HasPrefix(key, prefix) && !entry.expired(now) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// Writes the entries that have not expired to the file
+func (backend *FileBackend) Flush() error {
+	backend.lock.Lock()
+	defer backend.lock.Unlock()
+	if backend.closed {
+		return ErrClosed
+	}
+	return backend.flush()
+}
+
+// Flushes the entries and closes the backend
+func (backend *FileBackend) Close() error {
+	backend.lock.Lock()
+	defer backend.lock.Unlock()
+	if backend.closed {
+		return ErrClosed
+	}
+	backend.closed = true
+	return backend.flush()
+}
+
+/*
+* Helpers
+**/
+
+// Writes the entries to the file atomically, must hold the lock
+func (backend *FileBackend) flush() error {
+	now := time.Now()
+	for key, entry := range backend.entries {
+		if entry.expired(now) {
+			delete(backend.entries, key)
+		}
+	}
+	data, err := json.Marshal(backend.entries)
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file first, so a crash never leaves a partial file
+	tmp, err := os.CreateTemp(filepath.Dir(backend.filename), filepath.Base(backend.filename)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), backend.filename)
+}
@@ -0,0 +1,38 @@
+package kv
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	// Error returned when a key does not exist (or has expired)
+	ErrNotFound = errors.New("key not found")
+	// Error returned when using a closed backend
+	ErrClosed = errors.New("the backend is closed")
+)
+
+// A key-value store where keys expire
+type Backend interface {
+	// Stores a value under a key, expiring after ttl (never if 0)
+	Put(key string, value []byte, ttl time.Duration) error
+	// Returns the value of a key, or ErrNotFound
+	Get(key string) ([]byte, error)
+	// Removes a key, missing keys are ignored
+	Delete(key string) error
+	// Returns the keys starting with prefix, in no particular order
+	Keys(prefix string) ([]string, error)
+	// Persists any pending change and releases the backend
+	Close() error
+}
+
+// Opens a backend from its location:
+// - a redis:// URL connects to a Redis server (see DialRedis)
+// - anything else is a file (see OpenFile)
+func Open(location string) (Backend, error) {
+	if strings.HasPrefix(location, "redis://") {
+		return DialRedis(location)
+	}
+	return OpenFile(location)
+}
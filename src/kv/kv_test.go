@@ -0,0 +1,190 @@
+package kv_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stefanovazzocell/TuringMachine/src/kv"
+	"github.com/stefanovazzocell/TuringMachine/src/kv/redistest"
+)
+
+// Runs the tests common to all backends
+func testBackend(t *testing.T, backend kv.Backend) {
+	t.Helper()
+
+	if err := backend.Put("session/a", []byte("1"), 0); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := backend.Put("session/b*", []byte{0, '\r', '\n', 255}, time.Hour); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := backend.Put("room/a", []byte("3"), 10*time.Millisecond); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if value, err := backend.Get("session/b*"); err != nil || string(value) != "\x00\r\n\xff" {
+		t.Errorf("Get(session/b*) = (%q, %v)", value, err)
+	}
+	keys, err := backend.Keys("session/")
+	slices.Sort(keys)
+	if err != nil || !slices.Equal(keys, []string{"session/a", "session/b*"}) {
+		t.Errorf("Keys(session/) = (%v, %v)", keys, err)
+	}
+	if keys, err = backend.Keys("session/b*"); err != nil || len(keys) != 1 {
+		t.Errorf("Keys(session/b*) = (%v, %v), expected the special characters to be escaped", keys, err)
+	}
+
+	// Expiry
+	time.Sleep(20 * time.Millisecond)
+	if _, err = backend.Get("room/a"); !errors.Is(err, kv.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for an expired key, got %v", err)
+	}
+	if keys, err = backend.Keys("room/"); err != nil || len(keys) != 0 {
+		t.Errorf("Keys(room/) = (%v, %v), expected no keys", keys, err)
+	}
+
+	// Delete
+	if err = backend.Delete("session/a"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err = backend.Delete("missing"); err != nil {
+		t.Errorf("Delete failed for a missing key: %v", err)
+	}
+	if _, err = backend.Get("session/a"); !errors.Is(err, kv.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a deleted key, got %v", err)
+	}
+}
+
+func TestFileBackend(t *testing.T) {
+	t.Parallel()
+
+	filename := filepath.Join(t.TempDir(), "sessions.kv")
+	backend, err := kv.Open(filename)
+	if err != nil {
+		t.Fatalf("Failed to open %s: %v", filename, err)
+	}
+	testBackend(t, backend)
+	if err = backend.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err = backend.Put("session/a", nil, 0); !errors.Is(err, kv.ErrClosed) {
+		t.Errorf("Expected ErrClosed, got %v", err)
+	}
+
+	// The values are kept across restarts
+	reopened, err := kv.OpenFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to reopen %s: %v", filename, err)
+	}
+	defer reopened.Close()
+	if value, err := reopened.Get("session/b*"); err != nil || len(value) != 4 {
+		t.Errorf("Get(session/b*) = (%q, %v) after reopening", value, err)
+	}
+}
+
+func TestLogBackend(t *testing.T) {
+	t.Parallel()
+
+	filename := filepath.Join(t.TempDir(), "leaderboard.db")
+	backend, err := kv.OpenLog(filename)
+	if err != nil {
+		t.Fatalf("Failed to open %s: %v", filename, err)
+	}
+	testBackend(t, backend)
+
+	// Changes are on disk before closing, a partial record is dropped
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("Failed to open %s: %v", filename, err)
+	}
+	if _, err = file.WriteString(`{"key":"session/c","val`); err != nil {
+		t.Fatalf("Failed to write to %s: %v", filename, err)
+	}
+	file.Close()
+	crashed, err := kv.OpenLog(filename)
+	if err != nil {
+		t.Fatalf("Failed to reopen %s: %v", filename, err)
+	}
+	keys, err := crashed.Keys("session/")
+	if err != nil || !slices.Equal(keys, []string{"session/b*"}) {
+		t.Errorf("Keys(session/) = (%v, %v) after reopening", keys, err)
+	}
+	if err = crashed.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err = backend.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err = backend.Put("session/a", nil, 0); !errors.Is(err, kv.ErrClosed) {
+		t.Errorf("Expected ErrClosed, got %v", err)
+	}
+
+	// Compacted once the log grows
+	reopened, err := kv.OpenLog(filename)
+	if err != nil {
+		t.Fatalf("Failed to reopen %s: %v", filename, err)
+	}
+	defer reopened.Close()
+	for i := range 3000 {
+		if err = reopened.Put("counter", []byte(strconv.Itoa(i)), 0); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		t.Fatalf("Failed to stat %s: %v", filename, err)
+	}
+	if info.Size() > 64*1024 {
+		t.Errorf("Expected the log to be compacted, got %d bytes", info.Size())
+	}
+	if value, err := reopened.Get("counter"); err != nil || string(value) != "2999" {
+		t.Errorf("Get(counter) = (%q, %v)", value, err)
+	}
+
+	// In memory only
+	memory, err := kv.OpenLog("")
+	if err != nil {
+		t.Fatalf("Failed to open a memory log: %v", err)
+	}
+	testBackend(t, memory)
+	if err = memory.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+}
+
+func TestRedisBackend(t *testing.T) {
+	t.Parallel()
+
+	server := redistest.NewServer("secret")
+	defer server.Close()
+
+	if _, err := kv.DialRedis(server.URL); !errors.Is(err, kv.ErrRedis) {
+		t.Errorf("Expected ErrRedis without a password, got %v", err)
+	}
+	backend, err := kv.Open("redis://:secret@" + server.URL[len("redis://"):] + "/2")
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer backend.Close()
+	testBackend(t, backend)
+	if server.Len(2) == 0 || server.Len(0) != 0 {
+		t.Errorf("Expected the keys in database 2")
+	}
+
+	// Reconnects after losing the connection
+	server.CloseClientConnections()
+	if _, err = backend.Get("session/b*"); err == nil {
+		t.Errorf("Expected an error on a closed connection")
+	}
+	if value, err := backend.Get("session/b*"); err != nil || len(value) != 4 {
+		t.Errorf("Get(session/b*) = (%q, %v) after reconnecting", value, err)
+	}
+
+	if _, err = kv.DialRedis("redis://127.0.0.1:6379/nope"); err == nil {
+		t.Errorf("Expected an error for an invalid database")
+	}
+}
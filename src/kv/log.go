@@ -0,0 +1,239 @@
+package kv
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// The log is compacted once it has this many records more than twice the
+	// number of entries
+	logCompactSlack = 1024
+)
+
+// A backend held in memory and persisted to an append-only log file, one
+// JSON record per line.
+// Every change is written (and synced) to the file before returning, so
+// nothing is lost on a crash. The log is compacted when opened, when closed
+// and once it grows too large.
+// An empty filename keeps the entries in memory only.
+// Safe for concurrent use.
+type LogBackend struct {
+	filename string
+
+	lock    sync.Mutex
+	entries map[string]fileEntry
+	// The log file, nil if in memory only
+	file *os.File
+	// The number of records in the log
+	records int
+	closed  bool
+}
+
+// A change in a log backend
+type logRecord struct {
+	Key     string `json:"key"`
+	Value   []byte `json:"value,omitempty"`
+	Expires int64  `json:"expires,omitempty"`
+	// True if the key was deleted
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+// Opens a log backend, the file is created if missing.
+// A partial record at the end of the file (from a crash) is dropped.
+func OpenLog(filename string) (*LogBackend, error) {
+	backend := &LogBackend{
+		filename: filename,
+		entries:  map[string]fileEntry{},
+	}
+	if filename == "" {
+		return backend, nil
+	}
+	if err := backend.load(); err != nil {
+		return nil, err
+	}
+	if err := backend.compact(); err != nil {
+		return nil, err
+	}
+	return backend, nil
+}
+
+// Stores a value under a key, expiring after ttl (never if 0)
+func (backend *LogBackend) Put(key string, value []byte, ttl time.Duration) error {
+	record := logRecord{Key: key, Value: append([]byte(nil), value...)}
+	if ttl > 0 {
+		record.Expires = time.Now().Add(ttl).UnixMilli()
+	}
+	return backend.write(record)
+}
+
+// Returns the value of a key, or ErrNotFound
+func (backend *LogBackend) Get(key string) ([]byte, error) {
+	backend.lock.Lock()
+	defer backend.lock.Unlock()
+	if backend.closed {
+		return nil, ErrClosed
+	}
+	entry, ok := backend.entries[key]
+	if !ok || entry.expired(time.Now()) {
+		return nil, ErrNotFound
+	}
+	return append([]byte(nil), entry.Value...), nil
+}
+
+// Removes a key, missing keys are ignored
+func (backend *LogBackend) Delete(key string) error {
+	backend.lock.Lock()
+	_, ok := backend.entries[key]
+	backend.lock.Unlock()
+	if !ok {
+		return nil
+	}
+	return backend.write(logRecord{Key: key, Deleted: true})
+}
+
+// Returns the keys starting with prefix, in no particular order
+func (backend *LogBackend) Keys(prefix string) ([]string, error) {
+	backend.lock.Lock()
+	defer backend.lock.Unlock()
+	if backend.closed {
+		return nil, ErrClosed
+	}
+	now := time.Now()
+	keys := []string{}
+	for key, entry := range backend.entries {
+		if strings.HasPrefix(key, prefix) && !entry.expired(now) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// Compacts the log and closes the backend
+func (backend *LogBackend) Close() error {
+	backend.lock.Lock()
+	defer backend.lock.Unlock()
+	if backend.closed {
+		return ErrClosed
+	}
+	backend.closed = true
+	if backend.file == nil {
+		return nil
+	}
+	err := backend.compact()
+	return errors.Join(err, backend.file.Close())
+}
+
+/*
+* Helpers
+**/
+
+// Appends a record to the log and applies it
+func (backend *LogBackend) write(record logRecord) error {
+	backend.lock.Lock()
+	defer backend.lock.Unlock()
+	if backend.closed {
+		return ErrClosed
+	}
+	if backend.file != nil {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		if _, err = backend.file.Write(append(line, '\n')); err != nil {
+			return err
+		}
+		if err = backend.file.Sync(); err != nil {
+			return err
+		}
+	}
+	backend.apply(record)
+	if backend.file != nil && backend.records > 2*len(backend.entries)+logCompactSlack {
+		return backend.compact()
+	}
+	return nil
+}
+
+// Reads the records of the log file, if any
+func (backend *LogBackend) load() error {
+	file, err := os.Open(backend.filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// Either the end of the file or a partial record
+			break
+		} else if err != nil {
+			return err
+		}
+		record := logRecord{}
+		if err = json.Unmarshal(bytes.TrimSpace(line), &record); err != nil {
+			return err
+		}
+		backend.apply(record)
+	}
+	return nil
+}
+
+// Applies a record to the entries
+func (backend *LogBackend) apply(record logRecord) {
+	backend.records++
+	if record.Deleted {
+		delete(backend.entries, record.Key)
+		return
+	}
+	backend.entries[record.Key] = fileEntry{Value: record.Value, Expires: record.Expires}
+}
+
+// Rewrites the log with the entries that have not expired and reopens it for
+// appending, must hold the lock (or be the only user)
+func (backend *LogBackend) compact() error {
+	now := time.Now()
+	buffer := bytes.Buffer{}
+	for key, entry := range backend.entries {
+		if entry.expired(now) {
+			delete(backend.entries, key)
+			continue
+		}
+		line, err := json.Marshal(logRecord{Key: key, Value: entry.Value, Expires: entry.Expires})
+		if err != nil {
+			return err
+		}
+		buffer.Write(append(line, '\n'))
+	}
+	// Write to a temporary file first, so a crash never leaves a partial log
+	tmp, err := os.CreateTemp(filepath.Dir(backend.filename), filepath.Base(backend.filename)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(buffer.Bytes()); err == nil {
+		err = tmp.Sync()
+	}
+	if err = errors.Join(err, tmp.Close()); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp.Name(), backend.filename); err != nil {
+		return err
+	}
+	if backend.file != nil {
+		backend.file.Close()
+	}
+	backend.file, err = os.OpenFile(backend.filename, os.O_WRONLY|os.O_APPEND, 0)
+	backend.records = len(backend.entries)
+	return err
+}
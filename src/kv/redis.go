@@ -0,0 +1,293 @@
+package kv
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// The port used when a redis URL does not have one
+	DefaultRedisPort = "6379"
+	// The time within which a redis command must complete
+	DefaultRedisTimeout = 5 * time.Second
+	// The number of keys requested per SCAN call
+	redisScanCount = 100
+)
+
+var (
+	// Error returned when the server replies with an error
+	ErrRedis = errors.New("redis error")
+	// Error returned when the server reply cannot be parsed
+	ErrRedisProtocol = errors.New("redis protocol error")
+)
+
+// A backend storing keys on a Redis server (or anything speaking RESP2 with
+// the SET, GET, DEL and SCAN commands).
+// The connection is re-established on the next command after a failure.
+// Safe for concurrent use.
+type RedisBackend struct {
+	address  string
+	username string
+	password string
+	database int
+	// The time within which each command must complete
+	Timeout time.Duration
+
+	lock   sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+	closed bool
+}
+
+// Connects to a Redis server from a URL like
+// redis://[[username]:password@]host[:port][/database]
+func DialRedis(rawURL string) (*RedisBackend, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "redis" || u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid redis URL %q", rawURL)
+	}
+	backend := &RedisBackend{
+		address:  u.Host,
+		username: u.User.Username(),
+		Timeout:  DefaultRedisTimeout,
+	}
+	if u.Port() == "" {
+		backend.address = net.JoinHostPort(u.Hostname(), DefaultRedisPort)
+	}
+	backend.password, _ = u.User.Password()
+	if database := strings.TrimPrefix(u.Path, "/"); database != "" {
+		if backend.database, err = strconv.Atoi(database); err != nil {
+			return nil, fmt.Errorf("invalid redis database %q", database)
+		}
+	}
+
+	// Connect right away to report configuration errors early
+	backend.lock.Lock()
+	defer backend.lock.Unlock()
+	if err = backend.connect(); err != nil {
+		return nil, err
+	}
+	return backend, nil
+}
+
+// Stores a value under a key, expiring after ttl (never if 0)
+func (backend *RedisBackend) Put(key string, value []byte, ttl time.Duration) error {
+	args := []string{"SET", key, string(value)}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(max(ttl.Milliseconds(), 1), 10))
+	}
+	_, err := backend.do(args...)
+	return err
+}
+
+// Returns the value of a key, or ErrNotFound
+func (backend *RedisBackend) Get(key string) ([]byte, error) {
+	reply, err := backend.do("GET", key)
+	if err != nil {
+		return nil, err
+	}
+	switch value := reply.(type) {
+	case nil:
+		return nil, ErrNotFound
+	case []byte:
+		return value, nil
+	}
+	return nil, ErrRedisProtocol
+}
+
+// Removes a key, missing keys are ignored
+func (backend *RedisBackend) Delete(key string) error {
+	_, err := backend.do("DEL", key)
+	return err
+}
+
+// Returns the keys starting with prefix, in no particular order
+func (backend *RedisBackend) Keys(prefix string) ([]string, error) {
+	pattern := globEscaper.Replace(prefix) + "*"
+	keys := []string{}
+	seen := map[string]struct{}{}
+	cursor := "0"
+	for {
+		reply, err := backend.do("SCAN", cursor, "MATCH", pattern, "COUNT", strconv.Itoa(redisScanCount))
+		if err != nil {
+			return nil, err
+		}
+		parts, ok := reply.([]any)
+		if !ok || len(parts) != 2 {
+			return nil, ErrRedisProtocol
+		}
+		next, ok := parts[0].([]byte)
+		batch, ok2 := parts[1].([]any)
+		if !ok || !ok2 {
+			return nil, ErrRedisProtocol
+		}
+		for _, item := range batch {
+			key, ok := item.([]byte)
+			if !ok {
+				return nil, ErrRedisProtocol
+			}
+			// SCAN may return a key more than once
+			if _, dup := seen[string(key)]; !dup {
+				seen[string(key)] = struct{}{}
+				keys = append(keys, string(key))
+			}
+		}
+		if cursor = string(next); cursor == "0" {
+			return keys, nil
+		}
+	}
+}
+
+// Closes the connection to the server
+func (backend *RedisBackend) Close() error {
+	backend.lock.Lock()
+	defer backend.lock.Unlock()
+	if backend.closed {
+		return ErrClosed
+	}
+	backend.closed = true
+	if backend.conn == nil {
+		return nil
+	}
+	return backend.conn.Close()
+}
+
+/*
+* Helpers
+**/
+
+// Escapes the characters that have a meaning in redis glob patterns
+var globEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+
+// Sends a command and returns its reply, reconnecting if needed
+func (backend *RedisBackend) do(args ...string) (any, error) {
+	backend.lock.Lock()
+	defer backend.lock.Unlock()
+	if backend.closed {
+		return nil, ErrClosed
+	}
+	if backend.conn == nil {
+		if err := backend.connect(); err != nil {
+			return nil, err
+		}
+	}
+	reply, err := backend.roundTrip(args...)
+	if err != nil && !errors.Is(err, ErrRedis) {
+		// The connection is in an unknown state, start over next time
+		backend.conn.Close()
+		backend.conn = nil
+	}
+	return reply, err
+}
+
+// Opens the connection, authenticates and selects the database, must hold
+// the lock
+func (backend *RedisBackend) connect() error {
+	conn, err := net.DialTimeout("tcp", backend.address, backend.Timeout)
+	if err != nil {
+		return err
+	}
+	backend.conn = conn
+	backend.reader = bufio.NewReader(conn)
+	if backend.password != "" {
+		args := []string{"AUTH", backend.password}
+		if backend.username != "" {
+			args = []string{"AUTH", backend.username, backend.password}
+		}
+		_, err = backend.roundTrip(args...)
+	}
+	if err == nil && backend.database != 0 {
+		_, err = backend.roundTrip("SELECT", strconv.Itoa(backend.database))
+	}
+	if err == nil {
+		_, err = backend.roundTrip("PING")
+	}
+	if err != nil {
+		conn.Close()
+		backend.conn = nil
+	}
+	return err
+}
+
+// Writes a command and reads its reply, must hold the lock
+func (backend *RedisBackend) roundTrip(args ...string) (any, error) {
+	if err := backend.conn.SetDeadline(time.Now().Add(backend.Timeout)); err != nil {
+		return nil, err
+	}
+	command := []byte("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		command = append(command, '$')
+		command = strconv.AppendInt(command, int64(len(arg)), 10)
+		command = append(command, "\r\n"...)
+		command = append(command, arg...)
+		command = append(command, "\r\n"...)
+	}
+	if _, err := backend.conn.Write(command); err != nil {
+		return nil, err
+	}
+	return readReply(backend.reader)
+}
+
+// Reads a RESP2 reply: a string, []byte, int64, nil, []any or an ErrRedis
+func readReply(reader *bufio.Reader) (any, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || !strings.HasSuffix(line, "\r\n") {
+		return nil, ErrRedisProtocol
+	}
+	kind, payload := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return payload, nil
+	case '-':
+		return nil, fmt.Errorf("%w: %s", ErrRedis, payload)
+	case ':':
+		n, err := strconv.ParseInt(payload, 10, 64)
+		if err != nil {
+			return nil, ErrRedisProtocol
+		}
+		return n, nil
+	case '$':
+		n, err := strconv.Atoi(payload)
+		if err != nil || n < -1 {
+			return nil, ErrRedisProtocol
+		}
+		if n == -1 {
+			return nil, nil
+		}
+		data := make([]byte, n+2)
+		if _, err = io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+		return data[:n], nil
+	case '*':
+		n, err := strconv.Atoi(payload)
+		if err != nil || n < -1 {
+			return nil, ErrRedisProtocol
+		}
+		if n == -1 {
+			return nil, nil
+		}
+		items := make([]any, n)
+		for i := range items {
+			if items[i], err = readReply(reader); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, ErrRedisProtocol
+}
@@ -0,0 +1,295 @@
+// Package redistest provides an in-process Redis stand-in for tests, in the
+// spirit of net/http/httptest.
+package redistest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A server speaking enough of RESP2 for kv.RedisBackend: PING, AUTH, SELECT,
+// SET (with PX), GET, DEL and SCAN.
+// Each database is a separate key space.
+type Server struct {
+	// The URL of the server, redis://127.0.0.1:port
+	URL string
+	// If set, connections must authenticate with this password
+	password string
+
+	listener net.Listener
+	wait     sync.WaitGroup
+
+	lock   sync.Mutex
+	data   map[int]map[string]entry
+	conns  map[net.Conn]struct{}
+	closed bool
+}
+
+// A stored value
+type entry struct {
+	value   string
+	expires time.Time
+}
+
+// Starts a new server on a random local port, clients must authenticate if a
+// password is set
+func NewServer(password string) *Server {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("redistest: failed to listen: %v", err))
+	}
+	server := &Server{
+		URL:      "redis://" + listener.Addr().String(),
+		password: password,
+		listener: listener,
+		data:     map[int]map[string]entry{},
+		conns:    map[net.Conn]struct{}{},
+	}
+	server.wait.Add(1)
+	go server.serve()
+	return server
+}
+
+// Drops the open connections, as a server restart would, the data is kept
+func (server *Server) CloseClientConnections() {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	for conn := range server.conns {
+		conn.Close()
+	}
+}
+
+// Returns the number of keys held in a database, including expired ones
+func (server *Server) Len(database int) int {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	return len(server.data[database])
+}
+
+// Stops the server and waits for its connections to be closed
+func (server *Server) Close() {
+	server.lock.Lock()
+	server.closed = true
+	server.lock.Unlock()
+	server.listener.Close()
+	server.CloseClientConnections()
+	server.wait.Wait()
+}
+
+/*
+* Helpers
+**/
+
+// Accepts connections until the listener is closed
+func (server *Server) serve() {
+	defer server.wait.Done()
+	for {
+		conn, err := server.listener.Accept()
+		if err != nil {
+			return
+		}
+		server.lock.Lock()
+		if server.closed {
+			server.lock.Unlock()
+			conn.Close()
+			return
+		}
+		server.conns[conn] = struct{}{}
+		server.lock.Unlock()
+		server.wait.Add(1)
+		go server.handle(conn)
+	}
+}
+
+// Serves the commands of a connection
+func (server *Server) handle(conn net.Conn) {
+	defer server.wait.Done()
+	defer func() {
+		server.lock.Lock()
+		delete(server.conns, conn)
+		server.lock.Unlock()
+		conn.Close()
+	}()
+	reader := bufio.NewReader(conn)
+	session := &connState{authenticated: server.password == ""}
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+		if _, err = io.WriteString(conn, server.execute(session, args)); err != nil {
+			return
+		}
+	}
+}
+
+// The state of a connection
+type connState struct {
+	authenticated bool
+	database      int
+}
+
+// Runs a command, returns the encoded reply
+func (server *Server) execute(session *connState, args []string) string {
+	if len(args) == 0 {
+		return "-ERR empty command\r\n"
+	}
+	command := strings.ToUpper(args[0])
+	if command == "AUTH" {
+		if len(args) < 2 || args[len(args)-1] != server.password {
+			return "-WRONGPASS invalid password\r\n"
+		}
+		session.authenticated = true
+		return "+OK\r\n"
+	}
+	if !session.authenticated {
+		return "-NOAUTH Authentication required.\r\n"
+	}
+
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	keys := server.data[session.database]
+	if keys == nil {
+		keys = map[string]entry{}
+		server.data[session.database] = keys
+	}
+	now := time.Now()
+	switch {
+	case command == "PING":
+		return "+PONG\r\n"
+	case command == "SELECT" && len(args) == 2:
+		database, err := strconv.Atoi(args[1])
+		if err != nil || database < 0 {
+			return "-ERR invalid DB index\r\n"
+		}
+		session.database = database
+		return "+OK\r\n"
+	case command == "SET" && (len(args) == 3 || len(args) == 5):
+		value := entry{value: args[2]}
+		if len(args) == 5 {
+			ms, err := strconv.ParseInt(args[4], 10, 64)
+			if strings.ToUpper(args[3]) != "PX" || err != nil || ms <= 0 {
+				return "-ERR syntax error\r\n"
+			}
+			value.expires = now.Add(time.Duration(ms) * time.Millisecond)
+		}
+		keys[args[1]] = value
+		return "+OK\r\n"
+	case command == "GET" && len(args) == 2:
+		value, ok := keys[args[1]]
+		if !ok || value.expired(now) {
+			return "$-1\r\n"
+		}
+		return bulk(value.value)
+	case command == "DEL" && len(args) >= 2:
+		deleted := 0
+		for _, key := range args[1:] {
+			if value, ok := keys[key]; ok && !value.expired(now) {
+				deleted++
+			}
+			delete(keys, key)
+		}
+		return ":" + strconv.Itoa(deleted) + "\r\n"
+	case command == "SCAN" && len(args) >= 2:
+		// Everything is returned in a single iteration
+		pattern := "*"
+		for i := 2; i+1 < len(args); i += 2 {
+			if strings.ToUpper(args[i]) == "MATCH" {
+				pattern = args[i+1]
+			}
+		}
+		reply := []string{}
+		for key, value := range keys {
+			if globMatch(pattern, key) && !value.expired(now) {
+				reply = append(reply, bulk(key))
+			}
+		}
+		return "*2\r\n" + bulk("0") + "*" + strconv.Itoa(len(reply)) + "\r\n" + strings.Join(reply, "")
+	}
+	return "-ERR unknown command or wrong number of arguments\r\n"
+}
+
+// Returns true if the entry has expired at a given time
+func (value entry) expired(now time.Time) bool {
+	return !value.expires.IsZero() && now.After(value.expires)
+}
+
+// Returns true if s matches a glob pattern with *, ? and \ escapes
+func globMatch(pattern string, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for i := len(s); i >= 0; i-- {
+				if globMatch(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if len(s) == 0 || s[0] != pattern[0] {
+				return false
+			}
+		}
+		pattern, s = pattern[1:], s[1:]
+	}
+	return len(s) == 0
+}
+
+// Returns a bulk string reply
+func bulk(value string) string {
+	return "$" + strconv.Itoa(len(value)) + "\r\n" + value + "\r\n"
+}
+
+// Reads a command sent as an array of bulk strings
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	n, err := readHeader(reader, '*')
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		size, err := readHeader(reader, '$')
+		if err != nil {
+			return nil, err
+		}
+		data := make([]byte, size+2)
+		if _, err = io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+		args[i] = string(data[:size])
+	}
+	return args, nil
+}
+
+// Reads a line like *3 or $5, returns the number
+func readHeader(reader *bufio.Reader, kind byte) (int, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return 0, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if len(line) < 2 || line[0] != kind {
+		return 0, errors.New("redistest: unexpected command format")
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n < 0 {
+		return 0, errors.New("redistest: unexpected command format")
+	}
+	return n, nil
+}
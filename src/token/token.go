@@ -0,0 +1,163 @@
+package token
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	// The minimum length of a signing secret
+	MinSecretLength = 16
+)
+
+var (
+	// Error returned when creating a signer without keys
+	ErrNoKeys = errors.New("at least one signing key is required")
+	// Error returned when a key is not valid (empty id, short secret or
+	// duplicate)
+	ErrInvalidKey = errors.New("invalid signing key")
+	// Error returned when a token is malformed or its signature is wrong
+	ErrInvalidToken = errors.New("invalid token")
+	// Error returned when a token was signed by a key that is not known
+	ErrUnknownKey = errors.New("the token key is not known")
+	// Error returned when a token has expired
+	ErrExpired = errors.New("the token has expired")
+)
+
+// The data carried by a token
+type Claims struct {
+	// The game id
+	Game string `json:"game"`
+	// How the game was picked (for example "random" or "daily")
+	Mode string `json:"mode,omitempty"`
+	// The seed the game was picked with, if any
+	Seed int64 `json:"seed,omitempty"`
+	// The symbol the verification cards are shown with
+	Symbol string `json:"symbol,omitempty"`
+	// In seconds since the epoch
+	IssuedAt int64 `json:"iat"`
+	// In seconds since the epoch, zero if the token never expires
+	ExpiresAt int64 `json:"exp,omitempty"`
+}
+
+// A signing key, identified in the tokens it signs by its id
+type Key struct {
+	Id     string
+	Secret []byte
+}
+
+// Parses keys in the "id:secret,id:secret" format, the first one is used for
+// signing
+func ParseKeys(keys string) ([]Key, error) {
+	parsed := []Key{}
+	for _, field := range strings.Split(keys, ",") {
+		id, secret, ok := strings.Cut(strings.TrimSpace(field), ":")
+		if !ok {
+			return nil, ErrInvalidKey
+		}
+		parsed = append(parsed, Key{Id: id, Secret: []byte(secret)})
+	}
+	return parsed, nil
+}
+
+// Signs and verifies tokens in the format <key id>.<claims>.<signature>, with
+// the claims as base64 JSON and the signature an HMAC-SHA256 of the first two
+// parts.
+// Keys can be rotated by adding a new key first, and dropping the old one once
+// its tokens have expired.
+// Safe for concurrent use.
+type Signer struct {
+	// The key used for signing
+	current Key
+	// All the keys accepted for verification, by id
+	keys map[string][]byte
+	// The lifetime of the tokens issued, zero if they never expire
+	ttl time.Duration
+}
+
+// Returns a signer that signs with the first key and verifies with any of
+// them. The tokens issued expire after ttl, never if zero.
+func NewSigner(ttl time.Duration, keys ...Key) (*Signer, error) {
+	if len(keys) == 0 {
+		return nil, ErrNoKeys
+	}
+	signer := &Signer{
+		current: keys[0],
+		keys:    map[string][]byte{},
+		ttl:     ttl,
+	}
+	for _, key := range keys {
+		if _, dup := signer.keys[key.Id]; dup || key.Id == "" ||
+			strings.Contains(key.Id, ".") || len(key.Secret) < MinSecretLength {
+			return nil, ErrInvalidKey
+		}
+		signer.keys[key.Id] = key.Secret
+	}
+	return signer, nil
+}
+
+// Returns a signed token for the claims, IssuedAt and ExpiresAt are set by the
+// signer
+func (signer *Signer) Sign(claims Claims) (string, error) {
+	now := time.Now()
+	claims.IssuedAt = now.Unix()
+	claims.ExpiresAt = 0
+	if signer.ttl > 0 {
+		claims.ExpiresAt = now.Add(signer.ttl).Unix()
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	unsigned := signer.current.Id + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + sign(signer.current.Secret, unsigned), nil
+}
+
+// Returns the claims of a token if it was signed by a known key and has not
+// expired
+func (signer *Signer) Verify(token string) (Claims, error) {
+	claims := Claims{}
+	last := strings.LastIndexByte(token, '.')
+	if last < 0 {
+		return claims, ErrInvalidToken
+	}
+	unsigned, signature := token[:last], token[last+1:]
+	id, payload, ok := strings.Cut(unsigned, ".")
+	if !ok {
+		return claims, ErrInvalidToken
+	}
+	secret, ok := signer.keys[id]
+	if !ok {
+		return claims, ErrUnknownKey
+	}
+	if !hmac.Equal([]byte(signature), []byte(sign(secret, unsigned))) {
+		return claims, ErrInvalidToken
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return claims, ErrInvalidToken
+	}
+	if err = json.Unmarshal(data, &claims); err != nil {
+		return claims, ErrInvalidToken
+	}
+	if claims.ExpiresAt != 0 && time.Now().Unix() >= claims.ExpiresAt {
+		return claims, ErrExpired
+	}
+	return claims, nil
+}
+
+/*
+* Helpers
+**/
+
+// Returns the base64 HMAC-SHA256 signature of a message
+func sign(secret []byte, message string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(message))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,105 @@
+package token_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stefanovazzocell/TuringMachine/src/token"
+)
+
+var (
+	oldKey = token.Key{Id: "2024", Secret: []byte("0123456789abcdef")}
+	newKey = token.Key{Id: "2025", Secret: []byte("fedcba9876543210")}
+)
+
+func TestSignAndVerify(t *testing.T) {
+	t.Parallel()
+
+	signer, err := token.NewSigner(time.Hour, oldKey)
+	if err != nil {
+		t.Fatalf("Failed to create signer: %v", err)
+	}
+	claims := token.Claims{Game: "ABCDEFGHJ", Mode: "daily", Seed: 42, Symbol: "#"}
+	signed, err := signer.Sign(claims)
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	if !strings.HasPrefix(signed, "2024.") {
+		t.Errorf("Expected the token to start with the key id, got %q", signed)
+	}
+	verified, err := signer.Verify(signed)
+	if err != nil || verified.Game != claims.Game || verified.Mode != claims.Mode ||
+		verified.Seed != claims.Seed || verified.Symbol != claims.Symbol ||
+		verified.ExpiresAt-verified.IssuedAt != 3600 {
+		t.Fatalf("Verify(...) = (%+v, %v)", verified, err)
+	}
+
+	// Rotation: tokens of the old key are still accepted
+	rotated, err := token.NewSigner(time.Hour, newKey, oldKey)
+	if err != nil {
+		t.Fatalf("Failed to create signer: %v", err)
+	}
+	if _, err = rotated.Verify(signed); err != nil {
+		t.Errorf("Expected the old token to be accepted after rotation, got %v", err)
+	}
+	fresh, _ := rotated.Sign(claims)
+	if !strings.HasPrefix(fresh, "2025.") {
+		t.Errorf("Expected new tokens to use the new key, got %q", fresh)
+	}
+	if _, err = signer.Verify(fresh); !errors.Is(err, token.ErrUnknownKey) {
+		t.Errorf("Expected ErrUnknownKey, got %v", err)
+	}
+
+	// Tampering
+	parts := strings.Split(signed, ".")
+	forged, _ := rotated.Sign(token.Claims{Game: "ZZZZZZZZZ"})
+	testCases := []string{
+		"",
+		"nope",
+		parts[0] + "." + strings.Split(forged, ".")[1] + "." + parts[2],
+		parts[0] + "." + parts[1] + "." + parts[2] + "x",
+		parts[0] + "." + parts[1],
+		parts[0] + "..." + parts[2],
+	}
+	for _, testCase := range testCases {
+		if _, err = signer.Verify(testCase); !errors.Is(err, token.ErrInvalidToken) {
+			t.Errorf("Verify(%q) returned %v, expected ErrInvalidToken", testCase, err)
+		}
+	}
+
+	// Expiry
+	expiring, _ := token.NewSigner(time.Nanosecond, oldKey)
+	signed, _ = expiring.Sign(claims)
+	if _, err = expiring.Verify(signed); !errors.Is(err, token.ErrExpired) {
+		t.Errorf("Expected ErrExpired, got %v", err)
+	}
+}
+
+func TestKeys(t *testing.T) {
+	t.Parallel()
+
+	keys, err := token.ParseKeys("2025:fedcba9876543210, 2024:0123456789abcdef")
+	if err != nil || len(keys) != 2 || keys[0].Id != newKey.Id || string(keys[1].Secret) != string(oldKey.Secret) {
+		t.Fatalf("ParseKeys(...) = (%+v, %v)", keys, err)
+	}
+	if _, err = token.ParseKeys("2025"); !errors.Is(err, token.ErrInvalidKey) {
+		t.Errorf("Expected ErrInvalidKey, got %v", err)
+	}
+
+	testCases := [][]token.Key{
+		{{Id: "short", Secret: []byte("secret")}},
+		{{Id: "", Secret: oldKey.Secret}},
+		{{Id: "a.b", Secret: oldKey.Secret}},
+		{oldKey, oldKey},
+	}
+	for _, testCase := range testCases {
+		if _, err = token.NewSigner(0, testCase...); !errors.Is(err, token.ErrInvalidKey) {
+			t.Errorf("NewSigner(%v) returned %v, expected ErrInvalidKey", testCase, err)
+		}
+	}
+	if _, err = token.NewSigner(0); !errors.Is(err, token.ErrNoKeys) {
+		t.Errorf("Expected ErrNoKeys, got %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package bot
+
+import (
+	"errors"
+
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
+)
+
+const (
+	// The maximum number of rounds a bot plays before guessing the most
+	// likely solution
+	MaxRounds = 20
+	// The name of the bot when playing alone
+	player = "bot"
+)
+
+var (
+	// Error returned for an unknown strategy name
+	ErrUnknownStrategy = errors.New("unknown strategy")
+)
+
+// How a bot picks its queries.
+// Strategies only choose what to test: the bot tracks what is known about the
+// game (see game.Candidates), skips queries with a known result and guesses
+// once the solution is known.
+type Strategy interface {
+	// Returns the name of the strategy (see New)
+	Name() string
+	// Returns the proposal to test this round and the verifiers to query (up
+	// to game.MaxQueriesPerRound, in order) given the combinations of laws
+	// still possible
+	Choose(candidates game.Candidates) (game.Code, []int)
+	// Observes the result of a query made by the bot
+	Observe(query game.Query)
+}
+
+// A player of a match following a strategy
+type Bot struct {
+	Name     string
+	Strategy Strategy
+}
+
+// Plays the current round of a match for the bot: queries the verifiers
+// chosen by the strategy, then guesses if the solution is known (or after
+// MaxRounds) and otherwise ends its turn.
+// What the bot knows is rebuilt from its rounds in the match.
+func (bot Bot) PlayRound(match *game.Match) error {
+	player, err := match.Player(bot.Name)
+	if err != nil {
+		return err
+	}
+	candidates := game.NewCandidates(match.Game())
+	for _, round := range player.Rounds {
+		for _, query := range round.Queries {
+			candidates = candidates.Filter(query)
+		}
+	}
+
+	if _, ok := candidates.Solution(); !ok {
+		proposal, verifiers := bot.Strategy.Choose(candidates)
+		for i, verifier := range verifiers {
+			if _, ok = candidates.Solution(); ok || i == game.MaxQueriesPerRound {
+				break
+			}
+			if !candidates.Splits(proposal, verifier) {
+				continue
+			}
+			result, err := match.Query(bot.Name, proposal, verifier)
+			if err != nil {
+				return err
+			}
+			query := game.Query{Proposal: proposal, Verifier: verifier, Result: result}
+			bot.Strategy.Observe(query)
+			candidates = candidates.Filter(query)
+		}
+	}
+
+	solution, ok := candidates.Solution()
+	if !ok && match.Round() < MaxRounds {
+		return match.EndTurn(bot.Name)
+	}
+	if !ok {
+		solution = candidates.Solutions()[0]
+	}
+	_, err = match.Guess(bot.Name, solution)
+	return err
+}
+
+// Plays a game alone with a strategy, returns the bot as a player of the
+// finished match.
+// The game must have a unique solution.
+func Play(g game.Game, strategy Strategy) (game.Player, error) {
+	match, err := game.NewMatch(g, player)
+	if err != nil {
+		return game.Player{}, err
+	}
+	bot := Bot{Name: player, Strategy: strategy}
+	for !match.Finished() {
+		if err = bot.PlayRound(match); err != nil {
+			return game.Player{}, err
+		}
+	}
+	return match.Player(player)
+}
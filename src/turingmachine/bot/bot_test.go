@@ -0,0 +1,86 @@
+package bot_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/bot"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
+)
+
+// Returns a random game with a unique solution
+func randomGame(t *testing.T, choices int, difficulty game.Difficulty) game.Game {
+	t.Helper()
+	g, err := game.RandomSolvableGame(choices, difficulty)
+	if err != nil {
+		t.Fatalf("Failed to generate random game: %v", err)
+	}
+	return g
+}
+
+func TestStrategies(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range bot.Strategies {
+		for _, choices := range []int{4, 5, 6} {
+			g := randomGame(t, choices, game.HardDifficulty)
+			strategy, err := bot.New(name)
+			if err != nil || strategy.Name() != name {
+				t.Fatalf("New(%s) = (%v, %v)", name, strategy, err)
+			}
+			player, err := bot.Play(g, strategy)
+			if err != nil {
+				t.Fatalf("Play(%s) with %s failed: %v", g.String(), name, err)
+			}
+			// Only the rounds cap can make a bot guess wrong
+			if !player.Guessed || (!player.Solved && len(player.Rounds) < bot.MaxRounds) {
+				t.Fatalf("Play(%s) with %s = %+v, expected the code to be guessed", g.String(), name, player)
+			}
+		}
+	}
+	if _, err := bot.New("oracle"); !errors.Is(err, bot.ErrUnknownStrategy) {
+		t.Errorf("Expected ErrUnknownStrategy, got %v", err)
+	}
+}
+
+func TestRandomSeed(t *testing.T) {
+	t.Parallel()
+
+	g := randomGame(t, 5, game.StandardDifficulty)
+	first, err := bot.Play(g, bot.NewRandom(42))
+	if err != nil {
+		t.Fatalf("Play failed: %v", err)
+	}
+	second, _ := bot.Play(g, bot.NewRandom(42))
+	if !slices.EqualFunc(first.Rounds, second.Rounds, func(a, b game.Round) bool {
+		return slices.Equal(a.Queries, b.Queries)
+	}) {
+		t.Fatalf("Expected the same seed to play the same rounds, got %+v and %+v", first.Rounds, second.Rounds)
+	}
+}
+
+func TestBotInMatch(t *testing.T) {
+	t.Parallel()
+
+	g := randomGame(t, 5, game.StandardDifficulty)
+	match, err := game.NewMatch(g, "alice", "minimax")
+	if err != nil {
+		t.Fatalf("Failed to create match: %v", err)
+	}
+	opponent := bot.Bot{Name: "minimax", Strategy: bot.Minimax{}}
+	for !match.Finished() {
+		if err = match.EndTurn("alice"); err != nil {
+			t.Fatalf("EndTurn failed: %v", err)
+		}
+		if err = opponent.PlayRound(match); err != nil {
+			t.Fatalf("PlayRound failed in round %d: %v", match.Round(), err)
+		}
+	}
+	if standings := match.Standings(); standings[0].Player != "minimax" || !standings[0].Won {
+		t.Fatalf("Expected the bot to win, got %+v", standings)
+	}
+	if err = opponent.PlayRound(match); !errors.Is(err, game.ErrMatchFinished) {
+		t.Errorf("Expected ErrMatchFinished, got %v", err)
+	}
+}
@@ -0,0 +1,167 @@
+package bot
+
+import (
+	"cmp"
+	"math"
+	"math/rand/v2"
+	"slices"
+
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
+)
+
+const (
+	// The built-in strategies
+	RandomStrategy  = "random"
+	GreedyStrategy  = "greedy"
+	MinimaxStrategy = "minimax"
+	HumanStrategy   = "human"
+)
+
+var (
+	// The names of the built-in strategies
+	Strategies = []string{RandomStrategy, GreedyStrategy, MinimaxStrategy, HumanStrategy}
+)
+
+// Returns a new built-in strategy by name
+func New(name string) (Strategy, error) {
+	switch name {
+	case RandomStrategy:
+		return NewRandom(rand.Uint64()), nil
+	case GreedyStrategy:
+		return Greedy{}, nil
+	case MinimaxStrategy:
+		return Minimax{}, nil
+	case HumanStrategy:
+		return NewHuman(), nil
+	}
+	return nil, ErrUnknownStrategy
+}
+
+// Tests a random proposal against random verifiers
+type Random struct {
+	rand *rand.Rand
+}
+
+// Returns a random strategy, the same seed makes the same choices
+func NewRandom(seed uint64) *Random {
+	return &Random{rand: rand.New(rand.NewPCG(seed, seed))}
+}
+
+func (strategy *Random) Name() string {
+	return RandomStrategy
+}
+
+func (strategy *Random) Choose(candidates game.Candidates) (game.Code, []int) {
+	codes := game.BaseMask.GetAllCodes()
+	verifiers := strategy.rand.Perm(candidates.Verifiers())
+	return codes[strategy.rand.IntN(len(codes))], verifiers[:min(len(verifiers), game.MaxQueriesPerRound)]
+}
+
+func (strategy *Random) Observe(game.Query) {}
+
+// Tests the proposal and verifiers with the most informative results: the
+// highest entropy over the combinations of laws left, then the fewest
+// verifiers
+type Greedy struct{}
+
+func (Greedy) Name() string {
+	return GreedyStrategy
+}
+
+func (Greedy) Choose(candidates game.Candidates) (game.Code, []int) {
+	total := float64(candidates.Len())
+	return best(candidates, func(outcomes []game.Outcome) float64 {
+		// The negative entropy
+		cost := 0.0
+		for _, outcome := range outcomes {
+			p := float64(outcome.Combinations) / total
+			cost += p * math.Log2(p)
+		}
+		return cost
+	})
+}
+
+func (Greedy) Observe(game.Query) {}
+
+// Tests the proposal and verifiers with the best worst case: the fewest
+// solutions left whatever the results, then the fewest combinations of laws
+// left, then the fewest verifiers
+type Minimax struct{}
+
+func (Minimax) Name() string {
+	return MinimaxStrategy
+}
+
+func (Minimax) Choose(candidates game.Candidates) (game.Code, []int) {
+	return best(candidates, func(outcomes []game.Outcome) float64 {
+		worst := game.Outcome{}
+		for _, outcome := range outcomes {
+			worst.Solutions = max(worst.Solutions, outcome.Solutions)
+			worst.Combinations = max(worst.Combinations, outcome.Combinations)
+		}
+		return float64(worst.Solutions)*math.MaxInt32 + float64(worst.Combinations)
+	})
+}
+
+func (Minimax) Observe(game.Query) {}
+
+// Plays like a person would: tests the most likely solution not tested yet
+// against the verifiers it knows the least about (the most laws left)
+type Human struct {
+	tested map[game.Code]bool
+}
+
+// Returns a human-like strategy
+func NewHuman() *Human {
+	return &Human{tested: map[game.Code]bool{}}
+}
+
+func (strategy *Human) Name() string {
+	return HumanStrategy
+}
+
+func (strategy *Human) Choose(candidates game.Candidates) (game.Code, []int) {
+	solutions := candidates.Solutions()
+	proposal := solutions[0]
+	for _, solution := range solutions {
+		if !strategy.tested[solution] {
+			proposal = solution
+			break
+		}
+	}
+	verifiers := []int{}
+	for verifier := range candidates.Verifiers() {
+		if candidates.Splits(proposal, verifier) {
+			verifiers = append(verifiers, verifier)
+		}
+	}
+	slices.SortStableFunc(verifiers, func(a, b int) int {
+		return cmp.Compare(len(candidates.Laws(b)), len(candidates.Laws(a)))
+	})
+	return proposal, verifiers[:min(len(verifiers), game.MaxQueriesPerRound)]
+}
+
+func (strategy *Human) Observe(query game.Query) {
+	strategy.tested[query.Proposal] = true
+}
+
+/*
+* Helpers
+**/
+
+// Returns the proposal and verifiers with the lowest cost of their outcomes,
+// then with the fewest verifiers
+func best(candidates game.Candidates, cost func([]game.Outcome) float64) (game.Code, []int) {
+	bestProposal, bestVerifiers, bestCost := game.Code(0), []int(nil), 0.0
+	sets := game.VerifierSets(candidates.Verifiers())
+	for _, proposal := range game.BaseMask.GetAllCodes() {
+		for _, verifiers := range sets {
+			current := cost(candidates.Partition(proposal, verifiers))
+			if bestVerifiers == nil || current < bestCost ||
+				(current == bestCost && len(verifiers) < len(bestVerifiers)) {
+				bestProposal, bestVerifiers, bestCost = proposal, verifiers, current
+			}
+		}
+	}
+	return bestProposal, bestVerifiers
+}
@@ -0,0 +1,350 @@
+package bot
+
+import (
+	"cmp"
+	"context"
+	"math"
+	"math/rand/v2"
+	"runtime"
+	"slices"
+	"sync"
+
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/store"
+)
+
+const (
+	// The number of weak spots reported per strategy
+	WeakSpots = 5
+	// The minimum number of games with a criteria card for it to be a weak
+	// spot
+	weakSpotMinGames = 3
+	// How many indexes are drawn per game sampled before giving up on a bucket
+	sampleTriesPerGame = 100
+)
+
+// How a tournament is run
+type TournamentOptions struct {
+	// The number of games sampled per difficulty and number of choices
+	Games int
+	// The seed of the sampling and of the random strategy: the same seed on
+	// the same store plays the same games
+	Seed         uint64
+	Choices      []int
+	Difficulties []game.Difficulty
+	// The strategies playing (see New)
+	Strategies []string
+	// The number of games played in parallel
+	Workers int
+}
+
+// Returns the default options: 20 games of each kind for all the built-in
+// strategies, on all CPUs
+func DefaultTournamentOptions() TournamentOptions {
+	return TournamentOptions{
+		Games:        20,
+		Seed:         1,
+		Choices:      []int{4, 5, 6},
+		Difficulties: []game.Difficulty{game.EasyDifficulty, game.StandardDifficulty, game.HardDifficulty},
+		Strategies:   slices.Clone(Strategies),
+		Workers:      runtime.NumCPU(),
+	}
+}
+
+// The results of a tournament
+type Report struct {
+	Seed uint64 `json:"seed"`
+	// The number of games played by each strategy
+	Games      int              `json:"games"`
+	Strategies []StrategyReport `json:"strategies"`
+}
+
+// The results of a strategy in a tournament
+type StrategyReport struct {
+	Strategy string `json:"strategy"`
+	Stats
+	// The results by number of choices and difficulty
+	Buckets []BucketReport `json:"buckets"`
+	// The criteria cards with the most rounds needed compared to the average
+	WeakSpots []CriteriaReport `json:"weak_spots"`
+}
+
+// The results of a strategy on the games of a kind
+type BucketReport struct {
+	Choices    int    `json:"choices"`
+	Difficulty string `json:"difficulty"`
+	Stats
+}
+
+// The results of a strategy on the games with a criteria card
+type CriteriaReport struct {
+	Criteria   uint8   `json:"criteria"`
+	Games      int     `json:"games"`
+	MeanRounds float64 `json:"mean_rounds"`
+	// The mean rounds over the mean rounds of all games
+	Ratio float64 `json:"ratio"`
+}
+
+// Aggregated results over some games
+type Stats struct {
+	Games int `json:"games"`
+	// The fraction of games solved
+	SolveRate float64 `json:"solve_rate"`
+	// The fraction of games in which the strategy did best (with ties) among
+	// all strategies: solving the game in the fewest rounds, then checks
+	WinRate float64 `json:"win_rate"`
+	Rounds  Summary `json:"rounds"`
+	Checks  Summary `json:"checks"`
+}
+
+// The distribution of a measure
+type Summary struct {
+	Mean float64 `json:"mean"`
+	P50  int     `json:"p50"`
+	P90  int     `json:"p90"`
+	P99  int     `json:"p99"`
+	Max  int     `json:"max"`
+}
+
+// A game sampled for a tournament
+type sample struct {
+	game    game.Game
+	choices int
+}
+
+// A game played by a strategy
+type play struct {
+	sample
+	solved bool
+	won    bool
+	rounds int
+	checks int
+}
+
+// Samples games from a source and plays them with each strategy, games are
+// played in parallel.
+func RunTournament(ctx context.Context, source store.GameSource, options TournamentOptions) (Report, error) {
+	for _, name := range options.Strategies {
+		if _, err := New(name); err != nil {
+			return Report{}, err
+		}
+	}
+	samples, err := sampleGames(source, options)
+	if err != nil {
+		return Report{}, err
+	}
+
+	// plays[strategy][sample]
+	plays := make([][]play, len(options.Strategies))
+	for i := range plays {
+		plays[i] = make([]play, len(samples))
+	}
+	jobs := make(chan int)
+	errs := make([]error, max(options.Workers, 1))
+	wg := sync.WaitGroup{}
+	for worker := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				if errs[worker] != nil {
+					continue
+				}
+				errs[worker] = playSample(samples[idx], idx, options, plays)
+			}
+		}()
+	}
+	for idx := range samples {
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- idx
+	}
+	close(jobs)
+	wg.Wait()
+	if err = ctx.Err(); err != nil {
+		return Report{}, err
+	}
+	for _, err = range errs {
+		if err != nil {
+			return Report{}, err
+		}
+	}
+
+	report := Report{Seed: options.Seed, Games: len(samples)}
+	for i, name := range options.Strategies {
+		strategy := StrategyReport{
+			Strategy:  name,
+			Stats:     stats(plays[i]),
+			WeakSpots: weakSpots(plays[i]),
+		}
+		for _, choices := range options.Choices {
+			for _, difficulty := range options.Difficulties {
+				bucket := slices.DeleteFunc(slices.Clone(plays[i]), func(play play) bool {
+					return play.choices != choices || play.game.Difficulty() != difficulty
+				})
+				if len(bucket) > 0 {
+					strategy.Buckets = append(strategy.Buckets, BucketReport{
+						Choices:    choices,
+						Difficulty: difficulty.String(),
+						Stats:      stats(bucket),
+					})
+				}
+			}
+		}
+		report.Strategies = append(report.Strategies, strategy)
+	}
+	return report, nil
+}
+
+/*
+* Helpers
+**/
+
+// Returns up to options.Games distinct games per number of choices and
+// difficulty, drawn with the seed
+func sampleGames(source store.GameSource, options TournamentOptions) ([]sample, error) {
+	random := rand.New(rand.NewPCG(options.Seed, options.Seed))
+	samples := []sample{}
+	for _, choices := range options.Choices {
+		start, end := source.GameRangeByChoices(choices)
+		for _, difficulty := range options.Difficulties {
+			drawn := map[int64]bool{}
+			found := 0
+			for tries := 0; found < options.Games && tries < options.Games*sampleTriesPerGame && int64(len(drawn)) < end-start; tries++ {
+				idx := start + random.Int64N(end-start)
+				if drawn[idx] {
+					continue
+				}
+				drawn[idx] = true
+				g, err := source.GetGame(idx)
+				if err != nil {
+					return nil, err
+				}
+				if g.Difficulty() == difficulty {
+					samples = append(samples, sample{game: g, choices: choices})
+					found++
+				}
+			}
+		}
+	}
+	return samples, nil
+}
+
+// Plays a sample with every strategy and ranks them
+func playSample(sample sample, idx int, options TournamentOptions, plays [][]play) error {
+	for i, name := range options.Strategies {
+		strategy, err := New(name)
+		if err != nil {
+			return err
+		}
+		if name == RandomStrategy {
+			strategy = NewRandom(options.Seed + uint64(idx))
+		}
+		player, err := Play(sample.game, strategy)
+		if err != nil {
+			return err
+		}
+		plays[i][idx] = play{
+			sample: sample,
+			solved: player.Solved,
+			rounds: player.RoundsPlayed(),
+			checks: player.Checks(),
+		}
+	}
+	best := plays[0][idx]
+	for i := range plays {
+		if comparePlays(plays[i][idx], best) < 0 {
+			best = plays[i][idx]
+		}
+	}
+	for i := range plays {
+		plays[i][idx].won = plays[i][idx].solved && comparePlays(plays[i][idx], best) == 0
+	}
+	return nil
+}
+
+// Compares two plays of a game, the best one first
+func comparePlays(a, b play) int {
+	if a.solved != b.solved {
+		if a.solved {
+			return -1
+		}
+		return 1
+	}
+	return cmp.Or(cmp.Compare(a.rounds, b.rounds), cmp.Compare(a.checks, b.checks))
+}
+
+// Returns the stats of some plays
+func stats(plays []play) Stats {
+	stats := Stats{Games: len(plays)}
+	if len(plays) == 0 {
+		return stats
+	}
+	rounds, checks := make([]int, len(plays)), make([]int, len(plays))
+	for i, play := range plays {
+		rounds[i], checks[i] = play.rounds, play.checks
+		if play.solved {
+			stats.SolveRate++
+		}
+		if play.won {
+			stats.WinRate++
+		}
+	}
+	stats.SolveRate /= float64(len(plays))
+	stats.WinRate /= float64(len(plays))
+	stats.Rounds = summarize(rounds)
+	stats.Checks = summarize(checks)
+	return stats
+}
+
+// Returns the summary of some values
+func summarize(values []int) Summary {
+	values = slices.Clone(values)
+	slices.Sort(values)
+	sum := 0
+	for _, value := range values {
+		sum += value
+	}
+	// The nearest rank percentile
+	percentile := func(p float64) int {
+		return values[max(int(math.Ceil(p*float64(len(values))))-1, 0)]
+	}
+	return Summary{
+		Mean: float64(sum) / float64(len(values)),
+		P50:  percentile(0.5),
+		P90:  percentile(0.9),
+		P99:  percentile(0.99),
+		Max:  values[len(values)-1],
+	}
+}
+
+// Returns the criteria cards with the highest mean rounds compared to all the
+// plays, only cards above the mean are reported
+func weakSpots(plays []play) []CriteriaReport {
+	overall := stats(plays).Rounds.Mean
+	rounds := map[uint8][]int{}
+	for _, play := range plays {
+		for _, choice := range play.game[:play.choices] {
+			id := choice.Criteria().Id
+			rounds[id] = append(rounds[id], play.rounds)
+		}
+	}
+	reports := []CriteriaReport{}
+	for criteria, values := range rounds {
+		mean := summarize(values).Mean
+		if len(values) < weakSpotMinGames || overall == 0 || mean <= overall {
+			continue
+		}
+		reports = append(reports, CriteriaReport{
+			Criteria:   criteria,
+			Games:      len(values),
+			MeanRounds: mean,
+			Ratio:      mean / overall,
+		})
+	}
+	slices.SortFunc(reports, func(a, b CriteriaReport) int {
+		return cmp.Or(cmp.Compare(b.Ratio, a.Ratio), cmp.Compare(a.Criteria, b.Criteria))
+	})
+	return reports[:min(len(reports), WeakSpots)]
+}
@@ -0,0 +1,66 @@
+package bot_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/bot"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/store"
+)
+
+func TestTournament(t *testing.T) {
+	t.Parallel()
+
+	games := []game.Game{}
+	for _, choices := range []int{4, 5, 6} {
+		for _, difficulty := range []game.Difficulty{game.EasyDifficulty, game.StandardDifficulty, game.HardDifficulty} {
+			for range 3 {
+				games = append(games, randomGame(t, choices, difficulty))
+			}
+		}
+	}
+	source, err := store.NewMemoryStore(games)
+	if err != nil {
+		t.Fatalf("Failed to create memory store: %v", err)
+	}
+	options := bot.DefaultTournamentOptions()
+	options.Games = 2
+	options.Seed = 7
+	options.Workers = 4
+
+	report, err := bot.RunTournament(context.Background(), source, options)
+	if err != nil {
+		t.Fatalf("RunTournament failed: %v", err)
+	}
+	if report.Games != 18 || len(report.Strategies) != len(bot.Strategies) {
+		t.Fatalf("Expected 18 games for each strategy, got %+v", report)
+	}
+	wins := 0.0
+	for _, strategy := range report.Strategies {
+		if strategy.Games != report.Games || len(strategy.Buckets) != 9 || strategy.Buckets[0].Games != 2 ||
+			strategy.Rounds.P50 > strategy.Rounds.P90 || strategy.Rounds.P90 > strategy.Rounds.Max {
+			t.Fatalf("Unexpected report for %s %+v", strategy.Strategy, strategy)
+		}
+		for _, spot := range strategy.WeakSpots {
+			if spot.Ratio <= 1 || spot.Games < 3 {
+				t.Fatalf("Unexpected weak spot for %s %+v", strategy.Strategy, spot)
+			}
+		}
+		wins += strategy.WinRate
+	}
+	if wins < 1 {
+		t.Fatalf("Expected at least one winner per game, got a total win rate of %f", wins)
+	}
+
+	// The same seed plays the same games
+	if again, err := bot.RunTournament(context.Background(), source, options); err != nil || !reflect.DeepEqual(report, again) {
+		t.Fatalf("Expected the same report with the same seed, got %+v and %+v (%v)", report, again, err)
+	}
+	options.Strategies = []string{"oracle"}
+	if _, err = bot.RunTournament(context.Background(), source, options); !errors.Is(err, bot.ErrUnknownStrategy) {
+		t.Errorf("Expected ErrUnknownStrategy, got %v", err)
+	}
+}
@@ -0,0 +1,232 @@
+package daily
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"math/bits"
+	"sync"
+	"time"
+
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/store"
+)
+
+const (
+	// The format of puzzle dates
+	DateFormat = time.DateOnly
+	// The default number of days a puzzle is not repeated for
+	DefaultWindow = 365
+	// The maximum number of games considered for a puzzle, the first one with
+	// the difficulty of the day is picked (or the first one if none is).
+	// Easy games are rare in the store (about 1 in 200 with 4 choices).
+	MaxCandidates = 4096
+	// The number of Feistel rounds of the store permutation
+	permutationRounds = 6
+	// The number of puzzles cached
+	maxCachedPuzzles = 1024
+)
+
+var (
+	// Error returned for dates before 1970-01-01
+	ErrDateOutOfRange = errors.New("the date is before 1970-01-01")
+	// Error returned when the store doesn't have enough games to avoid repeats
+	// within the window
+	ErrWindowTooLarge = errors.New("not enough games to avoid repeats within the window")
+)
+
+// The kind of puzzle of a day
+type Rule struct {
+	Choices    int
+	Difficulty game.Difficulty
+}
+
+// The rules for each day of the week, indexed by time.Weekday
+type Rotation [7]Rule
+
+// Easy puzzles at the start of the week, the hardest on Saturday
+var DefaultRotation = Rotation{
+	time.Sunday:    {Choices: 5, Difficulty: game.StandardDifficulty},
+	time.Monday:    {Choices: 4, Difficulty: game.EasyDifficulty},
+	time.Tuesday:   {Choices: 4, Difficulty: game.StandardDifficulty},
+	time.Wednesday: {Choices: 5, Difficulty: game.StandardDifficulty},
+	time.Thursday:  {Choices: 5, Difficulty: game.HardDifficulty},
+	time.Friday:    {Choices: 6, Difficulty: game.StandardDifficulty},
+	time.Saturday:  {Choices: 6, Difficulty: game.HardDifficulty},
+}
+
+// The puzzle of a day
+type Puzzle struct {
+	// Midnight UTC of the day
+	Date time.Time
+	Game game.Game
+	// The puzzle number (the position of the game in the store, from 1)
+	Number int64
+	// The verification cards symbol
+	Symbol string
+	// The rule of the day, the game might have a different difficulty if
+	// no candidate had the right one
+	Rule Rule
+}
+
+// Picks a puzzle for each day from a store.
+// Puzzles only depend on the date, the secret, the window, the rotation and
+// the games in the store: they are the same across restarts and store
+// regenerations.
+//
+// The games with a number of choices are visited in the order of a keyed
+// permutation: the n-th day with that number of choices considers the
+// candidates [n*k, (n+1)*k) (modulo the number of games), so no game repeats
+// until the permutation wraps around. k is picked so this doesn't happen
+// within the window.
+type Schedule struct {
+	source   store.GameSource
+	secret   []byte
+	window   int
+	rotation Rotation
+
+	lock  sync.Mutex
+	cache map[int64]Puzzle
+}
+
+// Returns a schedule for a store, window is the minimum number of days
+// between two puzzles with the same game.
+func NewSchedule(source store.GameSource, secret []byte, window int, rotation Rotation) *Schedule {
+	return &Schedule{
+		source:   source,
+		secret:   append([]byte(nil), secret...),
+		window:   max(window, 1),
+		rotation: rotation,
+		cache:    map[int64]Puzzle{},
+	}
+}
+
+// Returns the puzzle of the day of a date (in its location)
+func (schedule *Schedule) Puzzle(date time.Time) (Puzzle, error) {
+	year, month, dayOfMonth := date.Date()
+	date = time.Date(year, month, dayOfMonth, 0, 0, 0, 0, time.UTC)
+	if date.Unix() < 0 {
+		return Puzzle{}, ErrDateOutOfRange
+	}
+	day := date.Unix() / int64(24*time.Hour/time.Second)
+
+	schedule.lock.Lock()
+	defer schedule.lock.Unlock()
+	if puzzle, ok := schedule.cache[day]; ok {
+		return puzzle, nil
+	}
+	puzzle, err := schedule.pick(day)
+	if err != nil {
+		return puzzle, err
+	}
+	puzzle.Date = date
+	if len(schedule.cache) >= maxCachedPuzzles {
+		clear(schedule.cache)
+	}
+	schedule.cache[day] = puzzle
+	return puzzle, nil
+}
+
+/*
+* Helpers
+**/
+
+// Picks the puzzle of a day (since 1970-01-01)
+func (schedule *Schedule) pick(day int64) (Puzzle, error) {
+	rule := schedule.rotation[weekday(day)]
+	start, end := schedule.source.GameRangeByChoices(rule.Choices)
+	n := end - start
+	if n <= 0 {
+		return Puzzle{}, store.ErrEmptyRange
+	}
+	// The days with the same number of choices in any window, plus one
+	perWindow := int64(schedule.window+6) / 7 * schedule.daysPerWeek(rule.Choices)
+	candidates := min(MaxCandidates, n/(perWindow+1))
+	if candidates == 0 {
+		return Puzzle{}, ErrWindowTooLarge
+	}
+
+	first := (schedule.daysBefore(day, rule.Choices) % n) * candidates % n
+	puzzle := Puzzle{Rule: rule}
+	for i := range candidates {
+		idx := start + schedule.permute(rule.Choices, n, (first+i)%n)
+		g, err := schedule.source.GetGame(idx)
+		if err != nil {
+			return Puzzle{}, err
+		}
+		if i == 0 || g.Difficulty() == rule.Difficulty {
+			puzzle.Game, puzzle.Number = g, idx+1
+		}
+		if g.Difficulty() == rule.Difficulty {
+			break
+		}
+	}
+	symbols := uint64(len(game.VerificationSymbols))
+	puzzle.Symbol = game.VerificationSymbols[schedule.mac(0, 0, uint64(day))%symbols]
+	return puzzle, nil
+}
+
+// Returns the number of days of the week with a number of choices
+func (schedule *Schedule) daysPerWeek(choices int) int64 {
+	count := int64(0)
+	for _, rule := range schedule.rotation {
+		if rule.Choices == choices {
+			count++
+		}
+	}
+	return count
+}
+
+// Returns the number of days before a day (since 1970-01-01) with a number of
+// choices
+func (schedule *Schedule) daysBefore(day int64, choices int) int64 {
+	count := day / 7 * schedule.daysPerWeek(choices)
+	for d := day / 7 * 7; d < day; d++ {
+		if schedule.rotation[weekday(d)].Choices == choices {
+			count++
+		}
+	}
+	return count
+}
+
+// Returns the position of x in the keyed permutation of [0, n) for the games
+// with a number of choices
+func (schedule *Schedule) permute(choices int, n, x int64) int64 {
+	// A Feistel network on the smallest number of bits fitting n, walking the
+	// cycle until the value is in range
+	size := max(bits.Len64(uint64(n-1)), 2)
+	highBits, lowBits := size/2, size-size/2
+	value := uint64(x)
+	for {
+		high, low := value>>lowBits, value&(1<<lowBits-1)
+		for round := range permutationRounds {
+			if round%2 == 0 {
+				high ^= schedule.mac(uint64(choices), uint64(round+1), low) & (1<<highBits - 1)
+			} else {
+				low ^= schedule.mac(uint64(choices), uint64(round+1), high) & (1<<lowBits - 1)
+			}
+		}
+		value = high<<lowBits | low
+		if value < uint64(n) {
+			return int64(value)
+		}
+	}
+}
+
+// Returns the keyed hash of a value for a number of choices and a round
+// (round 0 is for the symbol of the day)
+func (schedule *Schedule) mac(choices, round, value uint64) uint64 {
+	mac := hmac.New(sha256.New, schedule.secret)
+	var input [24]byte
+	binary.BigEndian.PutUint64(input[0:], choices)
+	binary.BigEndian.PutUint64(input[8:], round)
+	binary.BigEndian.PutUint64(input[16:], value)
+	mac.Write(input[:])
+	return binary.BigEndian.Uint64(mac.Sum(nil))
+}
+
+// Returns the weekday of a day since 1970-01-01 (a Thursday)
+func weekday(day int64) time.Weekday {
+	return time.Weekday((day + int64(time.Thursday)) % 7)
+}
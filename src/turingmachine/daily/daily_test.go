@@ -0,0 +1,125 @@
+package daily_test
+
+import (
+	"errors"
+	"math/rand/v2"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/daily"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/store"
+)
+
+// Returns a memory store with n random games for each number of choices of
+// the default rotation
+func randomStore(t testing.TB, n int) ([]game.Game, *store.MemoryStore) {
+	games := []game.Game{}
+	difficulties := []game.Difficulty{game.EasyDifficulty, game.StandardDifficulty, game.HardDifficulty}
+	for choices := 4; choices <= 6; choices++ {
+		for i := range n {
+			g, err := game.RandomSolvableGame(choices, difficulties[i%len(difficulties)])
+			if err != nil {
+				t.Fatalf("Failed to generate random game: %v", err)
+			}
+			games = append(games, g)
+		}
+	}
+	source, err := store.NewMemoryStore(games)
+	if err != nil {
+		t.Fatalf("Failed to create memory store: %v", err)
+	}
+	return games, source
+}
+
+func TestSchedule(t *testing.T) {
+	t.Parallel()
+
+	const window = 30
+	games, source := randomStore(t, 60)
+	schedule := daily.NewSchedule(source, []byte("secret"), window, daily.DefaultRotation)
+
+	// A restart with a regenerated store (same games, in another order)
+	rand.Shuffle(len(games), func(i, j int) {
+		games[i], games[j] = games[j], games[i]
+	})
+	regenerated, err := store.NewMemoryStore(games)
+	if err != nil {
+		t.Fatalf("Failed to create memory store: %v", err)
+	}
+	restarted := daily.NewSchedule(regenerated, []byte("secret"), window, daily.DefaultRotation)
+	other := daily.NewSchedule(source, []byte("other secret"), window, daily.DefaultRotation)
+
+	start := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+	puzzles := []daily.Puzzle{}
+	differences := 0
+	for i := range 365 {
+		date := start.AddDate(0, 0, i)
+		puzzle, err := schedule.Puzzle(date)
+		if err != nil {
+			t.Fatalf("Puzzle(%s) failed: %v", date.Format(daily.DateFormat), err)
+		}
+		rule := daily.DefaultRotation[date.Weekday()]
+		expected, _ := source.GetGame(puzzle.Number - 1)
+		if !puzzle.Date.Equal(date) || puzzle.Rule != rule || puzzle.Game.NumberOfChoices() != rule.Choices ||
+			expected != puzzle.Game || !slices.Contains(game.VerificationSymbols[:], puzzle.Symbol) {
+			t.Fatalf("Unexpected puzzle %+v for %s", puzzle, date.Format(daily.DateFormat))
+		}
+		// Stable across restarts and regenerations, and for any time of the day
+		for _, s := range []*daily.Schedule{schedule, restarted} {
+			again, err := s.Puzzle(date.Add(23 * time.Hour))
+			if err != nil || again != puzzle {
+				t.Fatalf("Expected the same puzzle %+v for %s, got %+v (%v)", puzzle, date.Format(daily.DateFormat), again, err)
+			}
+		}
+		if otherPuzzle, _ := other.Puzzle(date); otherPuzzle.Game != puzzle.Game {
+			differences++
+		}
+		puzzles = append(puzzles, puzzle)
+	}
+	if differences < len(puzzles)/2 {
+		t.Fatalf("Expected the secret to change the puzzles, only %d of %d differ", differences, len(puzzles))
+	}
+
+	// No repeats within the window
+	for i, puzzle := range puzzles {
+		for j := i + 1; j < min(i+window, len(puzzles)); j++ {
+			if puzzles[j].Game == puzzle.Game {
+				t.Fatalf("Puzzle of %s repeated on %s", puzzle.Date.Format(daily.DateFormat), puzzles[j].Date.Format(daily.DateFormat))
+			}
+		}
+	}
+
+	// A third of the games have each difficulty, there are 5 candidates per
+	// puzzle: the difficulty of the day is usually respected
+	mismatches := 0
+	for _, puzzle := range puzzles {
+		if puzzle.Game.Difficulty() != puzzle.Rule.Difficulty {
+			mismatches++
+		}
+	}
+	if mismatches > len(puzzles)/4 {
+		t.Fatalf("Expected most puzzles to have the difficulty of the day, %d of %d do not", mismatches, len(puzzles))
+	}
+}
+
+func TestScheduleErrors(t *testing.T) {
+	t.Parallel()
+
+	_, source := randomStore(t, 10)
+	schedule := daily.NewSchedule(source, nil, 365, daily.DefaultRotation)
+	if _, err := schedule.Puzzle(time.Date(2026, time.March, 2, 0, 0, 0, 0, time.UTC)); !errors.Is(err, daily.ErrWindowTooLarge) {
+		t.Errorf("Expected ErrWindowTooLarge, got %v", err)
+	}
+	schedule = daily.NewSchedule(source, nil, 1, daily.DefaultRotation)
+	if _, err := schedule.Puzzle(time.Date(1969, time.December, 31, 0, 0, 0, 0, time.UTC)); !errors.Is(err, daily.ErrDateOutOfRange) {
+		t.Errorf("Expected ErrDateOutOfRange, got %v", err)
+	}
+	rotation := daily.DefaultRotation
+	rotation[time.Monday].Choices = 3
+	schedule = daily.NewSchedule(source, nil, 1, rotation)
+	if _, err := schedule.Puzzle(time.Date(2026, time.March, 2, 0, 0, 0, 0, time.UTC)); !errors.Is(err, store.ErrEmptyRange) {
+		t.Errorf("Expected ErrEmptyRange, got %v", err)
+	}
+}
@@ -0,0 +1,203 @@
+package game
+
+import (
+	"slices"
+)
+
+// The combinations of laws (one per verifier) a player can't rule out yet,
+// knowing only the criteria cards of a game and the results of their
+// queries. Only the combinations with a unique solution and no redundant
+// card are possible.
+type Candidates struct {
+	verifiers    int
+	combinations []combination
+}
+
+// A combination of laws, one per verifier
+type combination struct {
+	choices  [MaxNumberOfChoicesPerGame]Choice
+	solution Code
+}
+
+// The combinations sharing the same results for some queries
+type Outcome struct {
+	Combinations int
+	// The number of distinct solutions of the combinations
+	Solutions int
+}
+
+// Returns the candidates of a game before any query
+func NewCandidates(game Game) Candidates {
+	candidates := Candidates{verifiers: game.NumberOfChoices()}
+	choices := make([][]Choice, candidates.verifiers)
+	for i := range candidates.verifiers {
+		choices[i] = criteriaChoices(game[i])
+	}
+	var visit func(state State, slot int)
+	visit = func(state State, slot int) {
+		if slot == candidates.verifiers {
+			if state.IsSolved() && !state.HasRedundant() {
+				candidates.combinations = append(candidates.combinations, combination{
+					choices:  state.Game,
+					solution: state.mask.GetCode(),
+				})
+			}
+			return
+		}
+		for _, choice := range choices[slot] {
+			next := state
+			next.Game[slot] = choice
+			next.mask = state.mask.And(choice.Mask())
+			if !next.mask.HasNoSolution() {
+				visit(next, slot+1)
+			}
+		}
+	}
+	visit(State{mask: BaseMask}, 0)
+	return candidates
+}
+
+// Returns the number of verifiers in the game
+func (candidates Candidates) Verifiers() int {
+	return candidates.verifiers
+}
+
+// Returns the number of combinations left
+func (candidates Candidates) Len() int {
+	return len(candidates.combinations)
+}
+
+// Returns the solution of all the combinations left if they agree on one
+func (candidates Candidates) Solution() (Code, bool) {
+	if len(candidates.combinations) == 0 {
+		return 0, false
+	}
+	first := candidates.combinations[0].solution
+	for _, combination := range candidates.combinations[1:] {
+		if combination.solution != first {
+			return 0, false
+		}
+	}
+	return first, true
+}
+
+// Returns the distinct solutions left, the most common first (then by code)
+func (candidates Candidates) Solutions() []Code {
+	counts := map[Code]int{}
+	for _, combination := range candidates.combinations {
+		counts[combination.solution]++
+	}
+	solutions := make([]Code, 0, len(counts))
+	for solution := range counts {
+		solutions = append(solutions, solution)
+	}
+	slices.SortFunc(solutions, func(a, b Code) int {
+		if counts[a] != counts[b] {
+			return counts[b] - counts[a]
+		}
+		return int(a.GetIndex()) - int(b.GetIndex())
+	})
+	return solutions
+}
+
+// Returns the distinct laws a verifier may still have
+func (candidates Candidates) Laws(verifier int) []*Law {
+	laws := []*Law{}
+	for _, combination := range candidates.combinations {
+		law := combination.choices[verifier].Law()
+		if !slices.Contains(laws, law) {
+			laws = append(laws, law)
+		}
+	}
+	return laws
+}
+
+// Returns the candidates left after a query
+func (candidates Candidates) Filter(query Query) Candidates {
+	candidates.combinations = slices.DeleteFunc(slices.Clone(candidates.combinations), func(combination combination) bool {
+		return combination.choices[query.Verifier].Mask().Check(query.Proposal) != query.Result
+	})
+	return candidates
+}
+
+// Returns true if the result of a verifier for a proposal is not known yet
+func (candidates Candidates) Splits(proposal Code, verifier int) bool {
+	if len(candidates.combinations) == 0 {
+		return false
+	}
+	first := candidates.combinations[0].choices[verifier].Mask().Check(proposal)
+	for _, combination := range candidates.combinations[1:] {
+		if combination.choices[verifier].Mask().Check(proposal) != first {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the outcomes of testing a proposal against some verifiers: the
+// combinations grouped by their results (empty groups are left out)
+func (candidates Candidates) Partition(proposal Code, verifiers []int) []Outcome {
+	groups := [1 << MaxNumberOfChoicesPerGame]struct {
+		combinations int
+		solutions    CodeMask
+	}{}
+	for _, combination := range candidates.combinations {
+		results := 0
+		for i, verifier := range verifiers {
+			if combination.choices[verifier].Mask().Check(proposal) {
+				results |= 1 << i
+			}
+		}
+		groups[results].combinations++
+		groups[results].solutions = groups[results].solutions.or(combination.solution)
+	}
+	outcomes := []Outcome{}
+	for _, group := range groups {
+		if group.combinations > 0 {
+			outcomes = append(outcomes, Outcome{
+				Combinations: group.combinations,
+				Solutions:    int(group.solutions.Available()),
+			})
+		}
+	}
+	return outcomes
+}
+
+// Returns all the sets of 1 to MaxQueriesPerRound verifiers that can be
+// queried in a round, in ascending order
+func VerifierSets(verifiers int) [][]int {
+	sets := [][]int{}
+	for bits := 1; bits < 1<<verifiers; bits++ {
+		set := []int{}
+		for verifier := range verifiers {
+			if bits&(1<<verifier) != 0 {
+				set = append(set, verifier)
+			}
+		}
+		if len(set) <= MaxQueriesPerRound {
+			sets = append(sets, set)
+		}
+	}
+	return sets
+}
+
+/*
+* Helpers
+**/
+
+// Returns the choices sharing the criteria card of a choice, one per law
+func criteriaChoices(choice Choice) []Choice {
+	criteria := choice.Criteria()
+	for choice > BlankChoice+1 && (choice-1).Criteria() == criteria {
+		choice--
+	}
+	choices := []Choice{}
+	laws := map[uint8]bool{}
+	for ; choice != BlankChoice && choice.Criteria() == criteria; choice = choice.NextLaw() {
+		if !laws[choice.Law().Id] {
+			laws[choice.Law().Id] = true
+			choices = append(choices, choice)
+		}
+	}
+	return choices
+}
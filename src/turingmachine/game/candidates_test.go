@@ -0,0 +1,50 @@
+package game_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
+)
+
+func TestCandidates(t *testing.T) {
+	t.Parallel()
+
+	g, err := game.RandomSolvableGame(5, game.StandardDifficulty)
+	if err != nil {
+		t.Fatalf("Failed to generate random game: %v", err)
+	}
+	code, _ := g.Solve()
+	candidates := game.NewCandidates(g)
+	if candidates.Verifiers() != 5 || candidates.Len() == 0 || !slices.Contains(candidates.Solutions(), code) {
+		t.Fatalf("Expected the candidates to include the solution %s, got %d combinations %v",
+			code.String(), candidates.Len(), candidates.Solutions())
+	}
+
+	// Querying every verifier with every code leaves the laws of the game (or
+	// laws with the same mask)
+	for verifier := range g.NumberOfChoices() {
+		for _, proposal := range game.BaseMask.GetAllCodes() {
+			result := g[verifier].Law().Mask.Check(proposal)
+			if candidates.Splits(proposal, verifier) {
+				candidates = candidates.Filter(game.Query{Proposal: proposal, Verifier: verifier, Result: result})
+			}
+		}
+		for _, law := range candidates.Laws(verifier) {
+			if law.Mask != g[verifier].Law().Mask {
+				t.Fatalf("Expected verifier %d to have law %d, got law %d", verifier, g[verifier].Law().Id, law.Id)
+			}
+		}
+	}
+	if solution, ok := candidates.Solution(); !ok || solution != code {
+		t.Fatalf("Solution() = (%s, %v), expected %s", solution.String(), ok, code.String())
+	}
+	outcomes := candidates.Partition(code, []int{0, 1, 2})
+	if len(outcomes) != 1 || outcomes[0].Combinations != candidates.Len() || outcomes[0].Solutions != 1 {
+		t.Fatalf("Unexpected outcomes %+v", outcomes)
+	}
+
+	if sets := game.VerifierSets(5); len(sets) != 25 || !slices.Equal(sets[0], []int{0}) {
+		t.Fatalf("Expected 25 verifier sets, got %v", sets)
+	}
+}
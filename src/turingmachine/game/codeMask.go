@@ -26,7 +26,7 @@ type CodeMask struct {
 // Returns true if a code is green in this mask
 func (cm CodeMask) Check(code Code) bool {
 	idx := code.GetIndex()
-	if idx <= 64 {
+	if idx < 64 {
 		return (cm.lo>>idx)&0b1 == 0b1
 	}
 	return (cm.hi>>(idx-64))&0b1 == 0b1
@@ -124,3 +124,14 @@ func (cm CodeMask) And(m CodeMask) CodeMask {
 func (cm CodeMask) Equal(m CodeMask) bool {
 	return cm.hi == m.hi && cm.lo == m.lo
 }
+
+// Returns the mask with a code marked as available
+func (cm CodeMask) or(code Code) CodeMask {
+	idx := code.GetIndex()
+	if idx < 64 {
+		cm.lo |= 1 << idx
+	} else {
+		cm.hi |= 1 << (idx - 64)
+	}
+	return cm
+}
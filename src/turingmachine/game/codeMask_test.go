@@ -42,3 +42,20 @@ func TestCodeMask(t *testing.T) {
 		}
 	}
 }
+
+func TestCodeMaskCheckAllCodes(t *testing.T) {
+	t.Parallel()
+
+	for _, criteria := range game.Criterias {
+		for _, law := range criteria.Laws {
+			codes := law.Mask.GetAllCodes()
+			for idx := range uint8(5 * 5 * 5) {
+				code := game.CodeFromIndex(idx)
+				if law.Mask.Check(code) != slices.Contains(codes, code) {
+					t.Fatalf("Law(%d).Mask.Check(%s) = %v, expected the opposite",
+						law.Id, code, law.Mask.Check(code))
+				}
+			}
+		}
+	}
+}
@@ -13,3 +13,16 @@ const (
 
 // The difficulty of a game
 type Difficulty uint8
+
+// Returns the name of this difficulty
+func (difficulty Difficulty) String() string {
+	switch difficulty {
+	case EasyDifficulty:
+		return "easy"
+	case StandardDifficulty:
+		return "standard"
+	case HardDifficulty:
+		return "hard"
+	}
+	return "unknown"
+}
@@ -12,6 +12,8 @@ import (
 )
 
 const (
+	// The minimum number of choices per game
+	MinNumberOfChoicesPerGame = 2
 	// The maximum number of choices per game
 	MaxNumberOfChoicesPerGame = 6
 	// https://en.wikipedia.org/wiki/Base32#Crockford's_Base32
@@ -39,12 +41,14 @@ var (
 	ErrGameNoUniqueSolution     = errors.New("the game does not have a unique solution")
 	ErrGameHasRedundant         = errors.New("the game has a redundant card")
 
-	ErrGameStringLength = errors.New("a game string is always 9 bytes long")
+	ErrGameStringLength = errors.New("a game id is 9 characters long, plus an optional check symbol")
 )
 
 var (
 	// Decoder from string-ified game - populated on init.
 	base32decode [math.MaxUint8 + 1]uint64
+	// The characters accepted by the decoder - populated on init.
+	base32valid [math.MaxUint8 + 1]bool
 )
 
 func init() {
@@ -62,6 +66,11 @@ func init() {
 	base32decode['I'] = 1
 	base32decode['l'] = 1
 	base32decode['L'] = 1
+	base32decode['o'] = 0
+	base32decode['O'] = 0
+	for _, b := range base32encode + strings.ToLower(base32encode) + "iIlLoO" {
+		base32valid[b] = true
+	}
 }
 
 // A game can be distilled to the choices of criteria+laws in order from the
@@ -186,31 +195,18 @@ func (game Game) Value() int {
 }
 
 // Derives a Game from a game string.
-// Note: the string MUST follow Crockford's Base32 alphabet and must be a valid
-// game.
+// The string must follow Crockford's Base32 alphabet, optionally with
+// separators and a check symbol (see FormatId), or an *IdError is returned.
+// Note: the game might not be valid.
 func GameFromString(gameStr string) (Game, error) {
-	// Check length
-	if len(gameStr) != 9 {
-		return Game{}, ErrGameStringLength
+	id, keyed, err := parseId(gameStr)
+	if err != nil {
+		return Game{}, err
 	}
-
-	// Decode uid
-	var uid uint64 = base32decode[gameStr[0]] + (base32decode[gameStr[1]] << 5) +
-		(base32decode[gameStr[2]] << 10) + (base32decode[gameStr[3]] << 15) +
-		(base32decode[gameStr[4]] << 20) + (base32decode[gameStr[5]] << 25) +
-		(base32decode[gameStr[6]] << 30) + (base32decode[gameStr[7]] << 35) +
-		(base32decode[gameStr[8]] << 40)
-	uid ^= encoderScramble
-
-	// Convert back into a game
-	return Game{
-		Choice((uid / gameExp4) % gameExp1),
-		Choice((uid / gameExp2) % gameExp1),
-		Choice(uid % gameExp1),
-		Choice((uid / gameExp5) % gameExp1),
-		Choice((uid / gameExp1) % gameExp1),
-		Choice((uid / gameExp3) % gameExp1),
-	}, nil
+	if keyed {
+		return Game{}, ErrGameKeyedId
+	}
+	return gameFromUid(decodeUid(id) ^ encoderScramble), nil
 }
 
 // Sorts a game to make it more likely to pass strict validation
@@ -248,11 +244,29 @@ func (game *Game) Sort() {
 func (game Game) String() string {
 	// Generated a scrambled unique ID representing this game among all other
 	// valid games.
-	uid := uint64(game[2]) + uint64(game[4])*gameExp1 + uint64(game[1])*gameExp2 +
+	return encodeUid(game.uid() ^ encoderScramble)
+}
+
+// Returns the unique ID of this game among all other valid games
+func (game Game) uid() uint64 {
+	return uint64(game[2]) + uint64(game[4])*gameExp1 + uint64(game[1])*gameExp2 +
 		uint64(game[5])*gameExp3 + uint64(game[0])*gameExp4 + uint64(game[3])*gameExp5
-	uid ^= encoderScramble
+}
 
-	// Encode using the base32 encoder
+// Returns the game of a unique ID (see Game.uid)
+func gameFromUid(uid uint64) Game {
+	return Game{
+		Choice((uid / gameExp4) % gameExp1),
+		Choice((uid / gameExp2) % gameExp1),
+		Choice(uid % gameExp1),
+		Choice((uid / gameExp5) % gameExp1),
+		Choice((uid / gameExp1) % gameExp1),
+		Choice((uid / gameExp3) % gameExp1),
+	}
+}
+
+// Encodes a 45 bits value in 9 characters with the base32 encoder
+func encodeUid(uid uint64) string {
 	return string([]byte{
 		base32encode[uid&block5],
 		base32encode[(uid>>5)&block5],
@@ -266,6 +280,15 @@ func (game Game) String() string {
 	})
 }
 
+// Decodes a 45 bits value from 9 base32 characters (see encodeUid)
+func decodeUid(s string) uint64 {
+	return base32decode[s[0]] + (base32decode[s[1]] << 5) +
+		(base32decode[s[2]] << 10) + (base32decode[s[3]] << 15) +
+		(base32decode[s[4]] << 20) + (base32decode[s[5]] << 25) +
+		(base32decode[s[6]] << 30) + (base32decode[s[7]] << 35) +
+		(base32decode[s[8]] << 40)
+}
+
 // Writes the game to a byte slice.
 // Note: the byte slice MUST be of length MaxNumberOfChoices
 func (game Game) WriteTo(s []byte, startingIdx int) {
@@ -279,9 +302,36 @@ func (game Game) WriteTo(s []byte, startingIdx int) {
 
 // Returns the number of unique criterias found in this game
 func (game Game) uniqueCriterias() int {
-	return bits.OnesCount64(game[0].CriteriaIdMask() | game[1].CriteriaIdMask() |
+	return bits.OnesCount64(game.CriteriaIdMask())
+}
+
+// Returns a mask of the criteria ids in this game. Useful to count the
+// criterias shared between games
+func (game Game) CriteriaIdMask() uint64 {
+	return game[0].CriteriaIdMask() | game[1].CriteriaIdMask() |
 		game[2].CriteriaIdMask() | game[3].CriteriaIdMask() |
-		game[4].CriteriaIdMask() | game[5].CriteriaIdMask())
+		game[4].CriteriaIdMask() | game[5].CriteriaIdMask()
+}
+
+// Returns all the strictly valid games that differ from this game by a single
+// choice (i.e.: one criteria card or law swapped for another).
+func (game Game) Neighbours() []Game {
+	neighbours := []Game{}
+	n := game.NumberOfChoices()
+	for i := range n {
+		for choice := BlankChoice + 1; choice <= MaxChoice; choice++ {
+			if choice == game[i] {
+				continue
+			}
+			neighbour := game
+			neighbour[i] = choice
+			neighbour.Sort()
+			if neighbour.ValidateStrict() == nil {
+				neighbours = append(neighbours, neighbour)
+			}
+		}
+	}
+	return neighbours
 }
 
 // Returns a debug string
@@ -380,15 +430,29 @@ func (game Game) GetMask() CodeMask {
 // Returns a slice of criteria ids, a slice of verification cards
 // with a random symbol, and a slice of laws (ids) for this game
 func (game Game) GetCards() (criterias []int, verificationCards []string, laws []int) {
+	criterias, vc, laws := game.cards()
+	verificationCards = getRandomVerificationSymbol(vc)
+	return
+}
+
+// Returns the same as GetCards, with the verification cards represented with
+// a given symbol (one of VerificationSymbols)
+func (game Game) GetCardsWithSymbol(symbol string) (criterias []int, verificationCards []string, laws []int) {
+	criterias, vc, laws := game.cards()
+	verificationCards = getVerificationSymbol(vc, symbol)
+	return
+}
+
+// Returns the criteria ids, verification cards and law ids of this game
+func (game Game) cards() (criterias []int, verificationCards []VerificationCard, laws []int) {
 	l := game.NumberOfChoices()
 	criterias = make([]int, l)
 	laws = make([]int, l)
-	vc := make([]VerificationCard, l)
+	verificationCards = make([]VerificationCard, l)
 	for i := range l {
 		criterias[i] = int(game[i].Criteria().Id)
-		vc[i] = game[i].Law().VerificationCard
+		verificationCards[i] = game[i].Law().VerificationCard
 		laws[i] = int(game[i].Law().Id)
 	}
-	verificationCards = getRandomVerificationSymbol(vc)
 	return
 }
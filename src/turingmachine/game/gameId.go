@@ -0,0 +1,190 @@
+package game
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+const (
+	// The length of a game id, excluding the keyed prefix and check symbol
+	idLength = 9
+	// https://www.crockford.com/base32.html
+	// The check symbol of an id is its value modulo 37
+	checkSymbols = base32encode + "*~$=U"
+	// Characters ignored when parsing ids, ids are grouped with dashes
+	idSeparators = "- "
+	// The number of characters per group in a formatted id
+	idGroupLength = 3
+)
+
+var (
+	// Error returned for characters outside of the id alphabet
+	ErrGameIdCharacter = errors.New("invalid character in the game id")
+	// Error returned when the check symbol of an id does not match
+	ErrGameIdCheck = errors.New("the game id check symbol does not match")
+)
+
+// An error at a given character of a game id
+type IdError struct {
+	// The byte offset of the character in the id as given
+	Position int
+	Err      error
+}
+
+func (err *IdError) Error() string {
+	return err.Err.Error() + " at position " + strconv.Itoa(err.Position)
+}
+
+func (err *IdError) Unwrap() error {
+	return err.Err
+}
+
+// Returns an id with its check symbol, in groups separated by dashes
+// (ABC-DEF-GHJ-K) to be read aloud.
+// Note: the id MUST be a valid id (see IdCipher.Encode).
+func FormatId(id string) string {
+	id += string(checkSymbols[checkValue(id[len(id)-idLength:])])
+	var builder strings.Builder
+	for i := 0; i < len(id); i += idGroupLength {
+		if i > 0 {
+			builder.WriteByte('-')
+		}
+		builder.WriteString(id[i:min(i+idGroupLength, len(id))])
+	}
+	return builder.String()
+}
+
+// Returns up to limit valid games one typo away from an id: a character
+// changed, missing, added, or swapped with the next one.
+// Games are only suggested if strictly valid (and sorted), the order is
+// deterministic.
+func (cipher *IdCipher) Suggest(id string, limit int) []Game {
+	id = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(idSeparators, r) {
+			return -1
+		}
+		return r
+	}, id)
+	if len(id) > idLength+len(KeyedIdPrefix)+2 || len(id) < idLength-1 {
+		// More than a typo away
+		return nil
+	}
+	suggestions := []Game{}
+	seen := map[Game]struct{}{}
+	try := func(candidate string) bool {
+		g, err := cipher.Decode(candidate)
+		if err != nil {
+			return true
+		}
+		if _, ok := seen[g]; ok {
+			return true
+		}
+		seen[g] = struct{}{}
+		if g.ValidateStrict() != nil {
+			return true
+		}
+		suggestions = append(suggestions, g)
+		return len(suggestions) < limit
+	}
+
+	// Swapped characters
+	for i := 0; i+1 < len(id); i++ {
+		if id[i] != id[i+1] && !try(id[:i]+id[i+1:i+2]+id[i:i+1]+id[i+2:]) {
+			return suggestions
+		}
+	}
+	for i := range len(id) + 1 {
+		// Missing characters
+		for _, symbol := range checkSymbols {
+			if !try(id[:i] + string(symbol) + id[i:]) {
+				return suggestions
+			}
+		}
+		if i == len(id) {
+			break
+		}
+		// Added characters
+		if !try(id[:i] + id[i+1:]) {
+			return suggestions
+		}
+		// Changed characters
+		for _, symbol := range checkSymbols {
+			if byte(symbol) != id[i] && !try(id[:i]+string(symbol)+id[i+1:]) {
+				return suggestions
+			}
+		}
+	}
+	return suggestions
+}
+
+/*
+* Helpers
+**/
+
+// Parses an id, ignoring separators and verifying the check symbol if any.
+// Returns the canonical id (without check symbol) and true if it's keyed.
+func parseId(id string) (string, bool, error) {
+	canonical := make([]byte, 0, idLength+len(KeyedIdPrefix))
+	positions := make([]int, 0, idLength+len(KeyedIdPrefix)+1)
+	keyed := false
+	for i := 0; i < len(id); i++ {
+		if strings.IndexByte(idSeparators, id[i]) != -1 {
+			continue
+		}
+		if len(positions) == 0 && strings.EqualFold(id[i:i+1], KeyedIdPrefix) {
+			keyed = true
+			canonical = append(canonical, KeyedIdPrefix...)
+		}
+		positions = append(positions, i)
+	}
+	payload := positions
+	if keyed {
+		payload = payload[len(KeyedIdPrefix):]
+	}
+	if len(payload) != idLength && len(payload) != idLength+1 {
+		return "", false, ErrGameStringLength
+	}
+	for _, position := range payload[:idLength] {
+		if !base32valid[id[position]] {
+			return "", false, &IdError{Position: position, Err: ErrGameIdCharacter}
+		}
+		canonical = append(canonical, base32encode[base32decode[id[position]]])
+	}
+	if len(payload) == idLength+1 {
+		position := payload[idLength]
+		check := checkValue(string(canonical[len(canonical)-idLength:]))
+		switch checkSymbolValue(id[position]) {
+		case check:
+		case -1:
+			return "", false, &IdError{Position: position, Err: ErrGameIdCharacter}
+		default:
+			return "", false, &IdError{Position: position, Err: ErrGameIdCheck}
+		}
+	}
+	return string(canonical), keyed, nil
+}
+
+// Returns the check value of 9 canonical id characters, the value of the id
+// (read as a Base32 number) modulo 37
+func checkValue(id string) int {
+	check := 0
+	for i := range len(id) {
+		check = (check*32 + int(base32decode[id[i]])) % 37
+	}
+	return check
+}
+
+// Returns the value of a check symbol, or -1 if it's not one
+func checkSymbolValue(symbol byte) int {
+	if base32valid[symbol] {
+		return int(base32decode[symbol])
+	}
+	if symbol == 'u' {
+		symbol = 'U'
+	}
+	if i := strings.IndexByte(checkSymbols[len(base32encode):], symbol); i != -1 {
+		return len(base32encode) + i
+	}
+	return -1
+}
@@ -0,0 +1,141 @@
+package game_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
+)
+
+const base32Symbols = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+func TestGameIdCheckSymbol(t *testing.T) {
+	t.Parallel()
+
+	cipher := game.NewIdCipher([]byte("secret"))
+	for range 200 {
+		g, err := game.RandomSolvableGame(5, game.StandardDifficulty)
+		if err != nil {
+			t.Fatalf("Failed to generate random game: %v", err)
+		}
+		for _, id := range []string{g.String(), cipher.Encode(g)} {
+			formatted := game.FormatId(id)
+			checked := strings.ReplaceAll(formatted, "-", "")
+			if len(checked) != len(id)+1 || checked[:len(id)] != id {
+				t.Fatalf("FormatId(%q) = %q", id, formatted)
+			}
+			for _, variant := range []string{formatted, checked, strings.ToLower(formatted), strings.ReplaceAll(formatted, "-", " ")} {
+				if decoded, err := cipher.Decode(variant); err != nil || decoded != g {
+					t.Fatalf("Decode(%q) = (%s, %v), expected %s", variant, decoded.Debug(), err, g.Debug())
+				}
+			}
+
+			// Any single change or swap in the id is caught
+			start := len(checked) - 10
+			for i := start; i < len(checked)-1; i++ {
+				for _, symbol := range base32Symbols {
+					if byte(symbol) == checked[i] {
+						continue
+					}
+					typo := checked[:i] + string(symbol) + checked[i+1:]
+					if _, err := cipher.Decode(typo); !errors.Is(err, game.ErrGameIdCheck) {
+						t.Fatalf("Expected ErrGameIdCheck decoding %q (from %q), got %v", typo, checked, err)
+					}
+				}
+				if i+2 < len(checked) && checked[i] != checked[i+1] {
+					typo := checked[:i] + checked[i+1:i+2] + checked[i:i+1] + checked[i+2:]
+					if _, err := cipher.Decode(typo); !errors.Is(err, game.ErrGameIdCheck) {
+						t.Fatalf("Expected ErrGameIdCheck decoding %q (from %q), got %v", typo, checked, err)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestGameIdParsing(t *testing.T) {
+	t.Parallel()
+
+	g, err := game.GameFromString("6D32H59CZ")
+	if err != nil {
+		t.Fatalf("Failed to decode game: %v", err)
+	}
+	testCases := []struct {
+		id       string
+		err      error
+		position int
+	}{
+		{"6D32H59CZ", nil, -1},
+		{"6d3-2h5-9cz", nil, -1},
+		{"6D3 2H5 9CZ", nil, -1},
+		{"6D32H59C", game.ErrGameStringLength, -1},
+		{"6D32H59CZ00", game.ErrGameStringLength, -1},
+		{"", game.ErrGameStringLength, -1},
+		{"6D32H5!CZ", game.ErrGameIdCharacter, 6},
+		{"6D3-2H5-9C!", game.ErrGameIdCharacter, 10},
+		{"6D32H59C" + "\xff", game.ErrGameIdCharacter, 8},
+		{"6D32H59CZ!", game.ErrGameIdCharacter, 9},
+		{"U6D32H59CZ", game.ErrGameKeyedId, -1},
+	}
+	for _, testCase := range testCases {
+		actual, err := game.GameFromString(testCase.id)
+		if !errors.Is(err, testCase.err) {
+			t.Fatalf("GameFromString(%q) returned %v, expected %v", testCase.id, err, testCase.err)
+		}
+		if err == nil && actual != g {
+			t.Fatalf("GameFromString(%q) = %s, expected %s", testCase.id, actual.Debug(), g.Debug())
+		}
+		var idErr *game.IdError
+		if errors.As(err, &idErr) != (testCase.position != -1) || idErr != nil && idErr.Position != testCase.position {
+			t.Fatalf("GameFromString(%q) returned %v, expected position %d", testCase.id, err, testCase.position)
+		}
+	}
+
+	// Crockford aliases
+	checked := strings.ReplaceAll(game.FormatId("1A0000000"), "-", "")
+	for _, id := range []string{strings.Replace(checked, "1", "l", 1), strings.Replace(checked, "0", "o", 1)} {
+		if _, err = game.GameFromString(id); err != nil {
+			t.Fatalf("GameFromString(%q) returned %v", id, err)
+		}
+	}
+}
+
+func TestGameIdSuggest(t *testing.T) {
+	t.Parallel()
+
+	cipher := game.NewIdCipher([]byte("secret"))
+	for range 20 {
+		g, err := game.RandomSolvableGame(4, game.HardDifficulty)
+		if err != nil {
+			t.Fatalf("Failed to generate random game: %v", err)
+		}
+		checked := strings.ReplaceAll(game.FormatId(g.String()), "-", "")
+		for _, id := range []string{g.String(), cipher.Encode(g), checked} {
+			typos := []string{
+				id[:3] + id[4:],
+				id[:2] + id[3:4] + id[2:3] + id[4:],
+				id[:5] + "!" + id[6:],
+			}
+			for _, typo := range typos {
+				if decoded, err := cipher.Decode(typo); err == nil && decoded.ValidateStrict() == nil {
+					// Not detectable
+					continue
+				}
+				found := false
+				for _, suggestion := range cipher.Suggest(typo, 100) {
+					if err := suggestion.ValidateStrict(); err != nil {
+						t.Fatalf("Suggested invalid game %s for %q: %v", suggestion.Debug(), typo, err)
+					}
+					found = found || suggestion == g
+				}
+				if !found {
+					t.Fatalf("Expected %s in the suggestions for %q (from %q)", g.Debug(), typo, id)
+				}
+			}
+		}
+	}
+	if suggestions := cipher.Suggest("000", 5); len(suggestions) != 0 {
+		t.Fatalf("Expected no suggestions, got %d", len(suggestions))
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"slices"
 	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
@@ -102,6 +104,27 @@ func TestGameGetCards(t *testing.T) {
 
 }
 
+func TestGameGetCardsWithSymbol(t *testing.T) {
+	t.Parallel()
+
+	g, err := game.RandomSolvableGame(5, game.StandardDifficulty)
+	if err != nil {
+		t.Fatalf("Failed to generate random game: %v", err)
+	}
+	_, _, laws := g.GetCards()
+	for _, symbol := range game.VerificationSymbols {
+		_, verificationCards, symbolLaws := g.GetCardsWithSymbol(symbol)
+		if !slices.Equal(laws, symbolLaws) {
+			t.Fatalf("GetCardsWithSymbol(%q) returned laws %v, expected %v", symbol, symbolLaws, laws)
+		}
+		for _, card := range verificationCards {
+			if !strings.HasPrefix(card, symbol) {
+				t.Fatalf("GetCardsWithSymbol(%q) returned %v", symbol, verificationCards)
+			}
+		}
+	}
+}
+
 func TestGameFromCards(t *testing.T) {
 	t.Parallel()
 
@@ -325,3 +348,40 @@ func FuzzGameString(f *testing.F) {
 		}
 	})
 }
+
+func TestGameNeighbours(t *testing.T) {
+	t.Parallel()
+
+	for choices := 4; choices <= 6; choices++ {
+		for range 20 {
+			original, err := game.RandomSolvableGame(choices, game.HardDifficulty)
+			if err != nil {
+				t.Fatalf("Failed to generate random game: %v", err)
+			}
+			seen := map[game.Game]bool{}
+			for _, neighbour := range original.Neighbours() {
+				if seen[neighbour] || neighbour == original {
+					t.Fatalf("[%s].Neighbours() returned %s more than once or itself",
+						original.Debug(), neighbour.Debug())
+				}
+				seen[neighbour] = true
+				if err := neighbour.ValidateStrict(); err != nil {
+					t.Fatalf("[%s].Neighbours() returned invalid %s: %v",
+						original.Debug(), neighbour.Debug(), err)
+				}
+				shared := 0
+				for i := range neighbour.NumberOfChoices() {
+					for j := range original.NumberOfChoices() {
+						if neighbour[i] == original[j] {
+							shared++
+						}
+					}
+				}
+				if neighbour.NumberOfChoices() != choices || shared != choices-1 {
+					t.Fatalf("[%s].Neighbours() returned %s which is not a single swap away",
+						original.Debug(), neighbour.Debug())
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package game
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+)
+
+const (
+	// The prefix of keyed game ids. "U" is not part of Crockford's Base32
+	// alphabet, so legacy ids never start with it
+	KeyedIdPrefix = "U"
+	// The number of bits of a game uid
+	uidBits = 45
+	// The uid is split in two parts, alternately mixed with each other
+	uidHighBits = 23
+	uidLowBits  = uidBits - uidHighBits
+	// The number of Feistel rounds
+	idCipherRounds = 10
+)
+
+var (
+	// Error returned when decoding a keyed id without the key
+	ErrGameKeyedId = errors.New("the game id is keyed and no key is configured")
+	// Error returned when an id does not decode to a game
+	ErrGameInvalidId = errors.New("the game id is not valid")
+)
+
+// Encodes and decodes game ids with a secret key.
+// The 45 bits uid of a game (see Game.String) are permuted with a keyed
+// Feistel network, so ids keep the same format but cannot be decoded back to
+// the game without the key. Keyed ids are prefixed with KeyedIdPrefix to tell
+// them apart from legacy ones.
+// A nil IdCipher only handles legacy ids.
+type IdCipher struct {
+	key []byte
+}
+
+// Returns a cipher for a secret key
+func NewIdCipher(secret []byte) *IdCipher {
+	return &IdCipher{key: append([]byte(nil), secret...)}
+}
+
+// Returns true if the id is a valid keyed one
+func IsKeyedId(id string) bool {
+	_, keyed, err := parseId(id)
+	return err == nil && keyed
+}
+
+// Returns the id of a game, keyed unless the cipher is nil.
+// Note: the game MUST be valid.
+func (cipher *IdCipher) Encode(game Game) string {
+	if cipher == nil {
+		return game.String()
+	}
+	return KeyedIdPrefix + encodeUid(cipher.permute(game.uid(), false))
+}
+
+// Returns the game of a keyed or legacy id (see GameFromString)
+func (cipher *IdCipher) Decode(id string) (Game, error) {
+	id, keyed, err := parseId(id)
+	if err != nil {
+		return Game{}, err
+	}
+	if !keyed {
+		return gameFromUid(decodeUid(id) ^ encoderScramble), nil
+	}
+	if cipher == nil {
+		return Game{}, ErrGameKeyedId
+	}
+	uid := cipher.permute(decodeUid(id[len(KeyedIdPrefix):]), true)
+	if uid >= gameExp5*gameExp1 {
+		// Outside of the uid range, not an id this key produced
+		return Game{}, ErrGameInvalidId
+	}
+	return gameFromUid(uid), nil
+}
+
+/*
+* Helpers
+**/
+
+// Applies (or reverts) the keyed permutation on a uid
+func (cipher *IdCipher) permute(uid uint64, reverse bool) uint64 {
+	high, low := uid>>uidLowBits, uid&(1<<uidLowBits-1)
+	for i := range idCipherRounds {
+		round := i
+		if reverse {
+			round = idCipherRounds - 1 - i
+		}
+		if round%2 == 0 {
+			high ^= cipher.round(round, low) & (1<<uidHighBits - 1)
+		} else {
+			low ^= cipher.round(round, high) & (1<<uidLowBits - 1)
+		}
+	}
+	return high<<uidLowBits | low
+}
+
+// Returns the round function of the Feistel network
+func (cipher *IdCipher) round(round int, value uint64) uint64 {
+	mac := hmac.New(sha256.New, cipher.key)
+	input := [9]byte{byte(round)}
+	binary.BigEndian.PutUint64(input[1:], value)
+	mac.Write(input[:])
+	return binary.BigEndian.Uint64(mac.Sum(nil))
+}
@@ -0,0 +1,56 @@
+package game_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
+)
+
+func TestIdCipher(t *testing.T) {
+	t.Parallel()
+
+	cipher := game.NewIdCipher([]byte("secret"))
+	other := game.NewIdCipher([]byte("other secret"))
+	var none *game.IdCipher
+	seen := map[string]struct{}{}
+	for range 1000 {
+		g, err := game.RandomSolvableGame(4, game.HardDifficulty)
+		if err != nil {
+			t.Fatalf("Failed to generate random game: %v", err)
+		}
+		legacy := g.String()
+		keyed := cipher.Encode(g)
+		if len(keyed) != 10 || !strings.HasPrefix(keyed, game.KeyedIdPrefix) ||
+			!game.IsKeyedId(keyed) || game.IsKeyedId(legacy) || keyed[1:] == legacy {
+			t.Fatalf("Unexpected ids %q (keyed) and %q (legacy) for %s", keyed, legacy, g.Debug())
+		}
+		if keyed == other.Encode(g) {
+			t.Fatalf("Expected different keys to give different ids, got %q", keyed)
+		}
+		if none.Encode(g) != legacy {
+			t.Fatalf("Expected a nil cipher to give legacy ids")
+		}
+		if _, dup := seen[keyed]; dup {
+			continue
+		}
+		seen[keyed] = struct{}{}
+
+		// Both formats are accepted, keyed ones only with the key
+		for _, id := range []string{keyed, strings.ToLower(keyed), legacy} {
+			if decoded, err := cipher.Decode(id); err != nil || decoded != g {
+				t.Fatalf("Decode(%q) = (%s, %v), expected %s", id, decoded.Debug(), err, g.Debug())
+			}
+		}
+		if decoded, err := none.Decode(legacy); err != nil || decoded != g {
+			t.Fatalf("Decode(%q) without a key = (%s, %v)", legacy, decoded.Debug(), err)
+		}
+		if _, err = none.Decode(keyed); !errors.Is(err, game.ErrGameKeyedId) {
+			t.Fatalf("Expected ErrGameKeyedId, got %v", err)
+		}
+		if decoded, err := other.Decode(keyed); err == nil && decoded == g {
+			t.Fatalf("Decoded %q with the wrong key", keyed)
+		}
+	}
+}
@@ -0,0 +1,500 @@
+package game
+
+import (
+	"cmp"
+	"errors"
+	"slices"
+)
+
+const (
+	// The maximum number of verifiers a player can query in a round
+	MaxQueriesPerRound = 3
+)
+
+var (
+	ErrMatchNoPlayers        = errors.New("a match needs at least one player")
+	ErrMatchDuplicatePlayer  = errors.New("the player is already in the match")
+	ErrMatchUnknownPlayer    = errors.New("the player is not in the match")
+	ErrMatchStarted          = errors.New("the match has already started")
+	ErrMatchFinished         = errors.New("the match is finished")
+	ErrMatchPlayerEliminated = errors.New("the player was eliminated")
+	ErrMatchTurnOver         = errors.New("the player is done with this round")
+	ErrMatchInvalidSnapshot  = errors.New("the match snapshot is not valid")
+
+	ErrRoundProposalChanged = errors.New("only one proposal can be tested per round")
+	ErrRoundTooManyQueries  = errors.New("too many verifiers queried this round")
+	ErrRoundRepeatedQuery   = errors.New("the verifier was already queried this round")
+	ErrRoundInvalidVerifier = errors.New("the verifier is not part of the game")
+)
+
+// A verifier queried with a proposal, and its result
+type Query struct {
+	Proposal Code
+	// The index of the verifier (choice) in the game
+	Verifier int
+	Result   bool
+}
+
+// The queries made by a player in a round.
+// All queries share the same proposal.
+type Round struct {
+	Queries []Query
+}
+
+// Returns the proposal tested in this round, or false if no query was made
+func (round Round) Proposal() (Code, bool) {
+	if len(round.Queries) == 0 {
+		return 0, false
+	}
+	return round.Queries[0].Proposal, true
+}
+
+// Returns the number of verifiers queried in this round
+func (round Round) Checks() int {
+	return len(round.Queries)
+}
+
+// Returns an error if the query cannot be added to this round
+func (round Round) canQuery(proposal Code, verifier int) error {
+	if current, ok := round.Proposal(); ok && current != proposal {
+		return ErrRoundProposalChanged
+	}
+	if len(round.Queries) >= MaxQueriesPerRound {
+		return ErrRoundTooManyQueries
+	}
+	for _, query := range round.Queries {
+		if query.Verifier == verifier {
+			return ErrRoundRepeatedQuery
+		}
+	}
+	return nil
+}
+
+// A player taking part in a match
+type Player struct {
+	Name string
+	// The rounds played so far, the last one is the current round
+	Rounds []Round
+	// True once the player is done with the current round
+	Done bool
+	// True if the player made a guess
+	Guessed bool
+	// The guess made, if any
+	Guess Code
+	// True if the guess was correct
+	Solved bool
+	// True if the player left the match without guessing
+	Forfeited bool
+}
+
+// Returns true if the player made a wrong guess or forfeited
+func (player Player) Eliminated() bool {
+	return player.Forfeited || player.Guessed && !player.Solved
+}
+
+// Returns true if the player is still playing (has not guessed or forfeited)
+func (player Player) Playing() bool {
+	return !player.Guessed && !player.Forfeited
+}
+
+// Returns the number of rounds in which the player queried a verifier
+func (player Player) RoundsPlayed() int {
+	rounds := 0
+	for _, round := range player.Rounds {
+		if round.Checks() > 0 {
+			rounds++
+		}
+	}
+	return rounds
+}
+
+// Returns the total number of verifiers queried by the player
+func (player Player) Checks() int {
+	checks := 0
+	for _, round := range player.Rounds {
+		checks += round.Checks()
+	}
+	return checks
+}
+
+// Returns a copy of the player that does not share memory with this one
+func (player Player) clone() Player {
+	player.Rounds = slices.Clone(player.Rounds)
+	for i := range player.Rounds {
+		player.Rounds[i].Queries = slices.Clone(player.Rounds[i].Queries)
+	}
+	return player
+}
+
+// The final (or current) standing of a player in a match
+type Standing struct {
+	Player string
+	// The rank of the player starting from 1, tied players share a rank
+	Rank int
+	// True if the player won (possibly sharing the victory)
+	Won          bool
+	Solved       bool
+	Eliminated   bool
+	RoundsPlayed int
+	Checks       int
+}
+
+// A match of one or more players on the same game following the official
+// rules:
+// - each round, a player tests a single proposal against up to
+// MaxQueriesPerRound distinct verifiers
+// - a player may guess the code once, ending their round; a wrong guess
+// eliminates them
+// - a player that leaves the match forfeits, and is eliminated
+// - the match ends with the round in which someone guesses the code, or when
+// all players are eliminated
+// - the winners are the players that guessed the code, ranked by rounds and
+// then by verifier checks; tied players share the victory
+//
+// A match is not safe for concurrent use.
+type Match struct {
+	game    Game
+	players []Player
+	// The current round, starting from 1
+	round    int
+	finished bool
+}
+
+// Returns a new match on a game for the given players (by unique name).
+// The game must have a unique solution.
+func NewMatch(game Game, players ...string) (*Match, error) {
+	if !game.HasUniqueSolution() {
+		return nil, ErrGameNoUniqueSolution
+	}
+	if len(players) == 0 {
+		return nil, ErrMatchNoPlayers
+	}
+	match := &Match{
+		game:    game,
+		players: make([]Player, 0, len(players)),
+		round:   1,
+	}
+	for _, name := range players {
+		if err := match.AddPlayer(name); err != nil {
+			return nil, err
+		}
+	}
+	return match, nil
+}
+
+// Adds a player to the match, can only be done before the first query
+func (match *Match) AddPlayer(name string) error {
+	if match.finished {
+		return ErrMatchFinished
+	}
+	if match.round > 1 || match.anyQuery() {
+		return ErrMatchStarted
+	}
+	if _, err := match.player(name); err == nil {
+		return ErrMatchDuplicatePlayer
+	}
+	match.players = append(match.players, Player{
+		Name:   name,
+		Rounds: []Round{{}},
+	})
+	return nil
+}
+
+// Returns the game of this match
+func (match *Match) Game() Game {
+	return match.game
+}
+
+// Returns the current round, starting from 1
+func (match *Match) Round() int {
+	return match.round
+}
+
+// Returns true if the match is finished
+func (match *Match) Finished() bool {
+	return match.finished
+}
+
+// Returns a copy of a player
+func (match *Match) Player(name string) (Player, error) {
+	player, err := match.player(name)
+	if err != nil {
+		return Player{}, err
+	}
+	return player.clone(), nil
+}
+
+// Returns a copy of all the players, in the order they joined
+func (match *Match) Players() []Player {
+	players := make([]Player, len(match.players))
+	for i, player := range match.players {
+		players[i] = player.clone()
+	}
+	return players
+}
+
+// Tests a proposal against a verifier of the game for a player in the
+// current round, returns the result.
+func (match *Match) Query(name string, proposal Code, verifier int) (bool, error) {
+	player, err := match.activePlayer(name)
+	if err != nil {
+		return false, err
+	}
+	if verifier < 0 || verifier >= match.game.NumberOfChoices() {
+		return false, ErrRoundInvalidVerifier
+	}
+	round := &player.Rounds[len(player.Rounds)-1]
+	if err = round.canQuery(proposal, verifier); err != nil {
+		return false, err
+	}
+	result := match.game[verifier].Law().Mask.Check(proposal)
+	round.Queries = append(round.Queries, Query{
+		Proposal: proposal,
+		Verifier: verifier,
+		Result:   result,
+	})
+	return result, nil
+}
+
+// Makes the guess of a player, returns true if it's correct.
+// The player is done with this round, and eliminated if the guess is wrong.
+func (match *Match) Guess(name string, code Code) (bool, error) {
+	player, err := match.activePlayer(name)
+	if err != nil {
+		return false, err
+	}
+	solution, _ := match.game.Solve()
+	player.Guessed = true
+	player.Guess = code
+	player.Solved = code == solution
+	player.Done = true
+	match.endRoundIfDone()
+	return player.Solved, nil
+}
+
+// Eliminates a player that leaves the match before guessing.
+// The round ends if all the other players still in the game are done.
+func (match *Match) Forfeit(name string) error {
+	if match.finished {
+		return ErrMatchFinished
+	}
+	player, err := match.player(name)
+	if err != nil {
+		return err
+	}
+	if player.Eliminated() {
+		return ErrMatchPlayerEliminated
+	}
+	if player.Guessed {
+		return ErrMatchTurnOver
+	}
+	player.Forfeited = true
+	player.Done = true
+	match.endRoundIfDone()
+	return nil
+}
+
+// Marks a player as done with the current round.
+// The round ends once all players still in the game are done.
+func (match *Match) EndTurn(name string) error {
+	player, err := match.activePlayer(name)
+	if err != nil {
+		return err
+	}
+	player.Done = true
+	match.endRoundIfDone()
+	return nil
+}
+
+// Ends the current round regardless of the players being done, returns true
+// if the match is finished.
+func (match *Match) EndRound() bool {
+	if match.finished {
+		return true
+	}
+	active := 0
+	for _, player := range match.players {
+		if player.Solved {
+			match.finished = true
+		}
+		if player.Playing() {
+			active++
+		}
+	}
+	if active == 0 {
+		match.finished = true
+	}
+	if match.finished {
+		return true
+	}
+	match.round++
+	for i := range match.players {
+		if match.players[i].Playing() {
+			match.players[i].Done = false
+			match.players[i].Rounds = append(match.players[i].Rounds, Round{})
+		}
+	}
+	return false
+}
+
+// Returns the standings of the players, best first.
+// Players that solved the game are ranked by rounds played and then by
+// verifier checks, followed by the players still in the game and finally by
+// the eliminated ones (each sharing a rank).
+func (match *Match) Standings() []Standing {
+	standings := make([]Standing, len(match.players))
+	for i, player := range match.players {
+		standings[i] = Standing{
+			Player:       player.Name,
+			Solved:       player.Solved,
+			Eliminated:   player.Eliminated(),
+			RoundsPlayed: player.RoundsPlayed(),
+			Checks:       player.Checks(),
+		}
+	}
+	slices.SortStableFunc(standings, compareStandings)
+	for i := range standings {
+		standings[i].Rank = i + 1
+		if i > 0 && compareStandings(standings[i-1], standings[i]) == 0 {
+			standings[i].Rank = standings[i-1].Rank
+		}
+		standings[i].Won = match.finished && standings[i].Solved && standings[i].Rank == 1
+	}
+	return standings
+}
+
+// The state of a match that can be serialized, see Match.Snapshot and
+// RestoreMatch
+type MatchSnapshot struct {
+	Game     Game
+	Players  []Player
+	Round    int
+	Finished bool
+}
+
+// Returns a snapshot of the match that does not share memory with it
+func (match *Match) Snapshot() MatchSnapshot {
+	return MatchSnapshot{
+		Game:     match.game,
+		Players:  match.Players(),
+		Round:    match.round,
+		Finished: match.finished,
+	}
+}
+
+// Returns the match a snapshot was taken of.
+// The snapshot is checked against the rules, including the query results.
+func RestoreMatch(snapshot MatchSnapshot) (*Match, error) {
+	if !snapshot.Game.HasUniqueSolution() {
+		return nil, ErrGameNoUniqueSolution
+	}
+	if len(snapshot.Players) == 0 {
+		return nil, ErrMatchNoPlayers
+	}
+	match := &Match{
+		game:     snapshot.Game,
+		players:  make([]Player, 0, len(snapshot.Players)),
+		round:    snapshot.Round,
+		finished: snapshot.Finished,
+	}
+	solution, _ := snapshot.Game.Solve()
+	for _, player := range snapshot.Players {
+		if _, err := match.player(player.Name); err == nil {
+			return nil, ErrMatchDuplicatePlayer
+		}
+		// Eliminated players stop getting new rounds
+		if len(player.Rounds) == 0 || len(player.Rounds) > match.round ||
+			(player.Playing() && len(player.Rounds) != match.round) ||
+			(player.Guessed && (player.Solved != (player.Guess == solution) || !player.Done)) ||
+			(player.Forfeited && (player.Guessed || !player.Done)) {
+			return nil, ErrMatchInvalidSnapshot
+		}
+		for _, round := range player.Rounds {
+			checked := Round{}
+			for _, query := range round.Queries {
+				if err := checked.canQuery(query.Proposal, query.Verifier); err != nil ||
+					query.Verifier < 0 || query.Verifier >= snapshot.Game.NumberOfChoices() ||
+					query.Result != snapshot.Game[query.Verifier].Law().Mask.Check(query.Proposal) {
+					return nil, ErrMatchInvalidSnapshot
+				}
+				checked.Queries = append(checked.Queries, query)
+			}
+		}
+		match.players = append(match.players, player.clone())
+	}
+	return match, nil
+}
+
+/*
+* Helpers
+**/
+
+// Returns a player by name
+func (match *Match) player(name string) (*Player, error) {
+	for i := range match.players {
+		if match.players[i].Name == name {
+			return &match.players[i], nil
+		}
+	}
+	return nil, ErrMatchUnknownPlayer
+}
+
+// Returns a player that can still play in the current round
+func (match *Match) activePlayer(name string) (*Player, error) {
+	if match.finished {
+		return nil, ErrMatchFinished
+	}
+	player, err := match.player(name)
+	if err != nil {
+		return nil, err
+	}
+	if player.Eliminated() {
+		return nil, ErrMatchPlayerEliminated
+	}
+	if player.Done {
+		return nil, ErrMatchTurnOver
+	}
+	return player, nil
+}
+
+// Returns true if any player made a query
+func (match *Match) anyQuery() bool {
+	for _, player := range match.players {
+		if player.Checks() > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Ends the round if all the players still in the game are done
+func (match *Match) endRoundIfDone() {
+	for _, player := range match.players {
+		if player.Playing() && !player.Done {
+			return
+		}
+	}
+	match.EndRound()
+}
+
+// Compares two standings, the best one first
+func compareStandings(a, b Standing) int {
+	if group := cmp.Compare(standingGroup(a), standingGroup(b)); group != 0 || !a.Solved {
+		return group
+	}
+	return cmp.Or(
+		cmp.Compare(a.RoundsPlayed, b.RoundsPlayed),
+		cmp.Compare(a.Checks, b.Checks),
+	)
+}
+
+// Returns the group of a standing: solved, still playing, eliminated
+func standingGroup(standing Standing) int {
+	switch {
+	case standing.Solved:
+		return 0
+	case standing.Eliminated:
+		return 2
+	}
+	return 1
+}
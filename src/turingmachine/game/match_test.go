@@ -0,0 +1,288 @@
+package game_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
+)
+
+// Returns a match on a random game for the given players, and its solution
+func newTestMatch(t *testing.T, players ...string) (*game.Match, game.Code) {
+	g, err := game.RandomSolvableGame(5, game.StandardDifficulty)
+	if err != nil {
+		t.Fatalf("Failed to generate random game: %v", err)
+	}
+	match, err := game.NewMatch(g, players...)
+	if err != nil {
+		t.Fatalf("Failed to create match: %v", err)
+	}
+	code, _ := g.Solve()
+	return match, code
+}
+
+// Returns a code different from code
+func wrongCode(code game.Code) game.Code {
+	if code == game.MaxCode {
+		return game.CodeFromNumbers(1, 1, 1)
+	}
+	return code.Incr()
+}
+
+func TestMatchRoundRules(t *testing.T) {
+	t.Parallel()
+
+	match, code := newTestMatch(t, "alice")
+	other := wrongCode(code)
+
+	for verifier := range 3 {
+		result, err := match.Query("alice", code, verifier)
+		if err != nil || !result {
+			t.Fatalf("Query(%s, %d) = (%v, %v), expected (true, nil)", code, verifier, result, err)
+		}
+	}
+	testCases := []struct {
+		proposal game.Code
+		verifier int
+		expected error
+	}{
+		{code, 3, game.ErrRoundTooManyQueries},
+		{other, 3, game.ErrRoundProposalChanged},
+		{code, 5, game.ErrRoundInvalidVerifier},
+	}
+	for i, testCase := range testCases {
+		if _, err := match.Query("alice", testCase.proposal, testCase.verifier); !errors.Is(err, testCase.expected) {
+			t.Errorf("[%d] Query(%s, %d) returned %v, expected %v",
+				i, testCase.proposal, testCase.verifier, err, testCase.expected)
+		}
+	}
+	if _, err := match.Query("bob", code, 0); !errors.Is(err, game.ErrMatchUnknownPlayer) {
+		t.Errorf("Expected ErrMatchUnknownPlayer, got %v", err)
+	}
+
+	// A new round allows a new proposal, but not repeated verifiers
+	if err := match.EndTurn("alice"); err != nil {
+		t.Fatalf("EndTurn failed: %v", err)
+	}
+	if match.Round() != 2 {
+		t.Fatalf("Expected round 2 after all players ended their turn, got %d", match.Round())
+	}
+	if _, err := match.Query("alice", other, 0); err != nil {
+		t.Fatalf("Query with a new proposal in a new round failed: %v", err)
+	}
+	if _, err := match.Query("alice", other, 0); !errors.Is(err, game.ErrRoundRepeatedQuery) {
+		t.Errorf("Expected ErrRoundRepeatedQuery, got %v", err)
+	}
+	if err := match.AddPlayer("bob"); !errors.Is(err, game.ErrMatchStarted) {
+		t.Errorf("Expected ErrMatchStarted, got %v", err)
+	}
+
+	solved, err := match.Guess("alice", code)
+	if err != nil || !solved || !match.Finished() {
+		t.Fatalf("Guess(%s) = (%v, %v), expected the match to be won", code, solved, err)
+	}
+	if _, err = match.Query("alice", code, 1); !errors.Is(err, game.ErrMatchFinished) {
+		t.Errorf("Expected ErrMatchFinished, got %v", err)
+	}
+	player, err := match.Player("alice")
+	if err != nil || player.RoundsPlayed() != 2 || player.Checks() != 4 {
+		t.Errorf("Player(alice) = (%+v, %v), expected 2 rounds and 4 checks", player, err)
+	}
+}
+
+func TestMatchStandings(t *testing.T) {
+	t.Parallel()
+
+	match, code := newTestMatch(t, "alice", "bob", "carol", "dave", "erin")
+	// Round 1: everyone queries a different number of verifiers
+	for i, name := range []string{"alice", "bob", "carol", "dave"} {
+		for verifier := range 1 + i%3 {
+			if _, err := match.Query(name, code, verifier); err != nil {
+				t.Fatalf("Query failed for %s: %v", name, err)
+			}
+		}
+	}
+	// Erin guesses wrong and is eliminated
+	if solved, err := match.Guess("erin", wrongCode(code)); err != nil || solved {
+		t.Fatalf("Guess(...) = (%v, %v), expected a wrong guess", solved, err)
+	}
+	for _, name := range []string{"alice", "bob", "carol", "dave"} {
+		if err := match.EndTurn(name); err != nil {
+			t.Fatalf("EndTurn failed for %s: %v", name, err)
+		}
+		if name == "alice" {
+			if _, err := match.Query(name, code, 0); !errors.Is(err, game.ErrMatchTurnOver) {
+				t.Errorf("Expected ErrMatchTurnOver, got %v", err)
+			}
+		}
+	}
+	if match.Finished() || match.Round() != 2 {
+		t.Fatalf("Expected round 2, got round %d (finished: %v)", match.Round(), match.Finished())
+	}
+	if _, err := match.Query("erin", code, 0); !errors.Is(err, game.ErrMatchPlayerEliminated) {
+		t.Errorf("Expected ErrMatchPlayerEliminated, got %v", err)
+	}
+
+	// Round 2: alice (1 check) and dave (1 check) guess right, bob (2
+	// checks) guesses right, carol keeps playing
+	for _, name := range []string{"alice", "bob", "dave"} {
+		if solved, err := match.Guess(name, code); err != nil || !solved {
+			t.Fatalf("Guess(...) = (%v, %v) for %s, expected a right guess", solved, err, name)
+		}
+	}
+	if _, err := match.Query("carol", code, 0); err != nil {
+		t.Fatalf("Query failed for carol: %v", err)
+	}
+	if err := match.EndTurn("carol"); err != nil {
+		t.Fatalf("EndTurn failed for carol: %v", err)
+	}
+	if !match.Finished() {
+		t.Fatal("Expected the match to be finished")
+	}
+
+	expected := []game.Standing{
+		{Player: "alice", Rank: 1, Won: true, Solved: true, RoundsPlayed: 1, Checks: 1},
+		{Player: "dave", Rank: 1, Won: true, Solved: true, RoundsPlayed: 1, Checks: 1},
+		{Player: "bob", Rank: 3, Solved: true, RoundsPlayed: 1, Checks: 2},
+		{Player: "carol", Rank: 4, RoundsPlayed: 2, Checks: 4},
+		{Player: "erin", Rank: 5, Eliminated: true},
+	}
+	standings := match.Standings()
+	if len(standings) != len(expected) {
+		t.Fatalf("Expected %d standings, got %d", len(expected), len(standings))
+	}
+	for i := range expected {
+		if standings[i] != expected[i] {
+			t.Errorf("Standing %d is %+v, expected %+v", i, standings[i], expected[i])
+		}
+	}
+}
+
+func TestMatchAllEliminated(t *testing.T) {
+	t.Parallel()
+
+	match, code := newTestMatch(t, "alice", "bob")
+	for _, name := range []string{"alice", "bob"} {
+		if _, err := match.Guess(name, wrongCode(code)); err != nil {
+			t.Fatalf("Guess failed for %s: %v", name, err)
+		}
+	}
+	if !match.Finished() {
+		t.Fatal("Expected the match to be finished when all players are eliminated")
+	}
+	for _, standing := range match.Standings() {
+		if standing.Won || !standing.Eliminated || standing.Rank != 1 {
+			t.Errorf("Unexpected standing %+v", standing)
+		}
+	}
+
+	if _, err := game.NewMatch(match.Game(), "alice", "alice"); !errors.Is(err, game.ErrMatchDuplicatePlayer) {
+		t.Errorf("Expected ErrMatchDuplicatePlayer, got %v", err)
+	}
+	if _, err := game.NewMatch(match.Game()); !errors.Is(err, game.ErrMatchNoPlayers) {
+		t.Errorf("Expected ErrMatchNoPlayers, got %v", err)
+	}
+	if _, err := game.NewMatch(game.Game{}, "alice"); !errors.Is(err, game.ErrGameNoUniqueSolution) {
+		t.Errorf("Expected ErrGameNoUniqueSolution, got %v", err)
+	}
+}
+
+func TestMatchForfeit(t *testing.T) {
+	t.Parallel()
+
+	match, code := newTestMatch(t, "alice", "bob", "carol")
+	if _, err := match.Query("alice", code, 0); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if err := match.EndTurn("alice"); err != nil {
+		t.Fatalf("EndTurn failed: %v", err)
+	}
+	if _, err := match.Guess("carol", wrongCode(code)); err != nil {
+		t.Fatalf("Guess failed: %v", err)
+	}
+
+	// Bob leaves instead of ending his turn, which ends the round
+	if err := match.Forfeit("bob"); err != nil || match.Round() != 2 {
+		t.Fatalf("Forfeit(bob) = %v in round %d, expected the round to end", err, match.Round())
+	}
+	testCases := []struct {
+		name     string
+		expected error
+	}{
+		{"bob", game.ErrMatchPlayerEliminated},
+		{"carol", game.ErrMatchPlayerEliminated},
+		{"dave", game.ErrMatchUnknownPlayer},
+	}
+	for _, testCase := range testCases {
+		if err := match.Forfeit(testCase.name); !errors.Is(err, testCase.expected) {
+			t.Errorf("Forfeit(%s) = %v, expected %v", testCase.name, err, testCase.expected)
+		}
+	}
+	if _, err := match.Query("bob", code, 1); !errors.Is(err, game.ErrMatchPlayerEliminated) {
+		t.Errorf("Expected ErrMatchPlayerEliminated for a forfeited player, got %v", err)
+	}
+	if _, err := game.RestoreMatch(match.Snapshot()); err != nil {
+		t.Errorf("Failed to restore a match with a forfeit: %v", err)
+	}
+
+	// The last player still in the game wins, the forfeit is ranked last
+	if solved, err := match.Guess("alice", code); err != nil || !solved || !match.Finished() {
+		t.Fatalf("Guess(...) = (%v, %v), expected the match to be won", solved, err)
+	}
+	standings := match.Standings()
+	if standings[0].Player != "alice" || !standings[0].Won || !standings[2].Eliminated || standings[2].Rank != 2 {
+		t.Errorf("Unexpected standings %+v", standings)
+	}
+	if err := match.Forfeit("alice"); !errors.Is(err, game.ErrMatchFinished) {
+		t.Errorf("Expected ErrMatchFinished, got %v", err)
+	}
+}
+
+func TestMatchSnapshot(t *testing.T) {
+	t.Parallel()
+
+	match, code := newTestMatch(t, "alice", "bob")
+	if _, err := match.Query("alice", code, 0); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if _, err := match.Guess("bob", wrongCode(code)); err != nil {
+		t.Fatalf("Guess failed: %v", err)
+	}
+
+	restored, err := game.RestoreMatch(match.Snapshot())
+	if err != nil {
+		t.Fatalf("Failed to restore match: %v", err)
+	}
+	if restored.Game() != match.Game() || restored.Round() != 1 || len(restored.Players()) != 2 {
+		t.Fatalf("Unexpected restored match %+v", restored.Snapshot())
+	}
+	// The restored match carries on from the snapshot
+	if _, err = restored.Query("alice", code, 0); !errors.Is(err, game.ErrRoundRepeatedQuery) {
+		t.Errorf("Expected ErrRoundRepeatedQuery, got %v", err)
+	}
+	if solved, err := restored.Guess("alice", code); err != nil || !solved || !restored.Finished() {
+		t.Errorf("Guess(...) = (%v, %v), expected the match to be won", solved, err)
+	}
+
+	// Tampered snapshots are rejected
+	tampered := match.Snapshot()
+	tampered.Players[0].Rounds[0].Queries[0].Result = !tampered.Players[0].Rounds[0].Queries[0].Result
+	if _, err = game.RestoreMatch(tampered); !errors.Is(err, game.ErrMatchInvalidSnapshot) {
+		t.Errorf("Expected ErrMatchInvalidSnapshot for a wrong result, got %v", err)
+	}
+	tampered = match.Snapshot()
+	tampered.Players[1].Solved = true
+	if _, err = game.RestoreMatch(tampered); !errors.Is(err, game.ErrMatchInvalidSnapshot) {
+		t.Errorf("Expected ErrMatchInvalidSnapshot for a wrong guess, got %v", err)
+	}
+	tampered = match.Snapshot()
+	tampered.Round = 2
+	if _, err = game.RestoreMatch(tampered); !errors.Is(err, game.ErrMatchInvalidSnapshot) {
+		t.Errorf("Expected ErrMatchInvalidSnapshot for missing rounds, got %v", err)
+	}
+	tampered = match.Snapshot()
+	tampered.Players = nil
+	if _, err = game.RestoreMatch(tampered); !errors.Is(err, game.ErrMatchNoPlayers) {
+		t.Errorf("Expected ErrMatchNoPlayers, got %v", err)
+	}
+}
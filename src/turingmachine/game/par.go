@@ -0,0 +1,439 @@
+package game
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/bits"
+	"slices"
+)
+
+const (
+	// The name of the bot in its match
+	parPlayer = "par"
+	// The cost of a round in the par search, more than the checks of any play
+	// so that rounds are compared first
+	parRoundCost = 1 << 10
+)
+
+var (
+	ErrParUnsolvable = errors.New("the par bot could not solve the game")
+)
+
+// The rounds an optimal bot needs to solve a game, the par for the players
+// of the same game.
+//
+// The bot plays like a player that does not know the laws (see Candidates).
+// It follows the strategy that solves every combination of laws left in the
+// fewest rounds, then the fewest checks, in the worst case: each round it
+// picks a proposal, then each verifier knowing the results of the previous
+// ones, and it guesses once all the combinations left agree on the solution.
+// A player can still do better on a given game by being lucky, not by
+// playing better.
+type Par struct {
+	// The rounds played by the bot, the last one is the round of the guess
+	Rounds []Round
+	Guess  Code
+}
+
+// Returns the number of rounds in which the bot queried a verifier
+func (par Par) RoundsPlayed() int {
+	return Player{Rounds: par.Rounds}.RoundsPlayed()
+}
+
+// Returns the total number of verifiers queried by the bot
+func (par Par) Checks() int {
+	return Player{Rounds: par.Rounds}.Checks()
+}
+
+// Returns the first round (starting from 1) in which the given rounds
+// differ from the bot's, either by proposal or by verifiers queried (in any
+// order), or 0 if they never do. Rounds without queries are skipped.
+func (par Par) Diverged(rounds []Round) int {
+	played := func(rounds []Round) []Round {
+		return slices.DeleteFunc(slices.Clone(rounds), func(round Round) bool {
+			return round.Checks() == 0
+		})
+	}
+	bot, player := played(par.Rounds), played(rounds)
+	for i := range max(len(bot), len(player)) {
+		if i >= len(bot) || i >= len(player) || !sameRound(bot[i], player[i]) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Returns the par of a game, played by the bot through a Match.
+// The game must have a unique solution.
+func PlayPar(game Game) (Par, error) {
+	match, err := NewMatch(game, parPlayer)
+	if err != nil {
+		return Par{}, err
+	}
+	solver := newParSolver(NewCandidates(game))
+	left := solver.all()
+	for !match.Finished() {
+		if left.empty() {
+			return Par{}, ErrParUnsolvable
+		}
+		if solution, ok := solver.solution(left); ok {
+			if _, err = match.Guess(parPlayer, solution); err != nil {
+				return Par{}, err
+			}
+			break
+		}
+		cost, proposal := solver.solve(left)
+		if proposal < 0 {
+			return Par{}, ErrParUnsolvable
+		}
+		for queries := MaxQueriesPerRound; queries > 0; queries-- {
+			// The cost is exact below the cost of the strategy so far
+			var verifier int
+			if cost, verifier = solver.roundCost(left, proposal, queries, cost+1); verifier < 0 {
+				break
+			}
+			cost--
+			result, err := match.Query(parPlayer, solver.proposals[proposal], verifier)
+			if err != nil {
+				return Par{}, err
+			}
+			if result {
+				left = left.and(solver.passes[proposal][verifier])
+			} else {
+				left = left.andNot(solver.passes[proposal][verifier])
+			}
+		}
+		if _, ok := solver.solution(left); !ok {
+			if err = match.EndTurn(parPlayer); err != nil {
+				return Par{}, err
+			}
+		}
+	}
+	player, err := match.Player(parPlayer)
+	if err != nil {
+		return Par{}, err
+	}
+	return Par{
+		Rounds: player.Rounds,
+		Guess:  player.Guess,
+	}, nil
+}
+
+/*
+* Helpers
+**/
+
+// A set of combinations of a parSolver, one bit per combination
+type combinationSet []uint64
+
+// Returns the combinations in both sets
+func (set combinationSet) and(other combinationSet) combinationSet {
+	result := make(combinationSet, len(set))
+	for i := range set {
+		result[i] = set[i] & other[i]
+	}
+	return result
+}
+
+// Returns the combinations in the set but not in the other
+func (set combinationSet) andNot(other combinationSet) combinationSet {
+	result := make(combinationSet, len(set))
+	for i := range set {
+		result[i] = set[i] &^ other[i]
+	}
+	return result
+}
+
+// Returns true if there are no combinations in the set
+func (set combinationSet) empty() bool {
+	for _, word := range set {
+		if word != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns the number of combinations in the set
+func (set combinationSet) len() int {
+	count := 0
+	for _, word := range set {
+		count += bits.OnesCount64(word)
+	}
+	return count
+}
+
+// Returns a map key for the set
+func (set combinationSet) key() string {
+	key := make([]byte, 0, 8*len(set))
+	for _, word := range set {
+		key = binary.LittleEndian.AppendUint64(key, word)
+	}
+	return string(key)
+}
+
+// The exhaustive search of the par strategy over the candidates of a game.
+// The worst case cost of a set of combinations is memoized, the candidates of
+// a game are few enough for the search to be exact.
+type parSolver struct {
+	combinations []combination
+	// The solution of each combination
+	solutions []CodeMask
+	proposals []Code
+	// The combinations whose law passes each proposal, by proposal then by
+	// verifier
+	passes [][]combinationSet
+	// The cost and best proposal of the sets of combinations searched so far
+	costs map[string]parCost
+}
+
+// The worst case cost of a set of combinations (see parSolver.cost), or a
+// lower bound of it if not exact
+type parCost struct {
+	cost     int
+	proposal int
+	exact    bool
+	// The proposals worth testing (see orderProposals), once searched
+	proposals []int
+}
+
+// Returns a solver for the candidates of a game
+func newParSolver(candidates Candidates) *parSolver {
+	solver := &parSolver{
+		combinations: candidates.combinations,
+		proposals:    BaseMask.GetAllCodes(),
+		costs:        map[string]parCost{},
+	}
+	for _, combination := range solver.combinations {
+		solver.solutions = append(solver.solutions, CodeMask{}.or(combination.solution))
+	}
+	words := (len(solver.combinations) + 63) / 64
+	solver.passes = make([][]combinationSet, len(solver.proposals))
+	for i, proposal := range solver.proposals {
+		solver.passes[i] = make([]combinationSet, candidates.verifiers)
+		for verifier := range candidates.verifiers {
+			pass := make(combinationSet, words)
+			for j, combination := range solver.combinations {
+				if combination.choices[verifier].Mask().Check(proposal) {
+					pass[j/64] |= 1 << (j % 64)
+				}
+			}
+			solver.passes[i][verifier] = pass
+		}
+	}
+	return solver
+}
+
+// Returns the set of all the combinations
+func (solver *parSolver) all() combinationSet {
+	set := make(combinationSet, (len(solver.combinations)+63)/64)
+	for j := range solver.combinations {
+		set[j/64] |= 1 << (j % 64)
+	}
+	return set
+}
+
+// Returns the distinct solutions of a set of combinations
+func (solver *parSolver) solutionsOf(set combinationSet) CodeMask {
+	solutions := CodeMask{}
+	for i, word := range set {
+		for ; word != 0; word &= word - 1 {
+			solution := solver.solutions[i*64+bits.TrailingZeros64(word)]
+			solutions.hi |= solution.hi
+			solutions.lo |= solution.lo
+		}
+	}
+	return solutions
+}
+
+// Returns the solution of a set of combinations if they agree on one
+func (solver *parSolver) solution(set combinationSet) (Code, bool) {
+	solutions := solver.solutionsOf(set)
+	if solutions.Available() != 1 {
+		return 0, false
+	}
+	return solutions.GetCode(), true
+}
+
+// Returns a lower bound of the cost of a set of combinations: each round
+// splits the solutions in at most 2^MaxQueriesPerRound groups, each check in
+// at most 2
+func (solver *parSolver) lowerBound(set combinationSet) int {
+	return parLowerBound(int(solver.solutionsOf(set).Available()), 0)
+}
+
+// Returns a lower bound of the cost of telling some solutions apart, not
+// counting the current round which can still split them in 2^queries groups
+// at most
+func parLowerBound(solutions int, queries int) int {
+	rounds, checks := 0, 0
+	for groups := 1 << queries; groups < solutions; groups <<= MaxQueriesPerRound {
+		rounds++
+	}
+	for groups := 1; groups < solutions; groups <<= 1 {
+		checks++
+	}
+	return rounds*parRoundCost + checks
+}
+
+// Returns the worst case cost of a set of combinations and the proposal to
+// test first (see cost), searching for the fewest rounds first, as bounding
+// the search prunes most of it. Each round rules out at least a combination,
+// so there are at most as many rounds as combinations.
+func (solver *parSolver) solve(set combinationSet) (int, int) {
+	for rounds := solver.lowerBound(set) / parRoundCost; rounds <= set.len(); rounds++ {
+		bound := (rounds + 1) * parRoundCost
+		if cost, proposal := solver.cost(set, bound); cost < bound {
+			return cost, proposal
+		}
+	}
+	return math.MaxInt, -1
+}
+
+// Returns the worst case cost (rounds then checks) to solve a set of
+// combinations, and the proposal to test first. The cost is exact if it's
+// below the bound, otherwise it's only known not to be (the proposal is -1).
+// The cost is math.MaxInt if the set can't be solved.
+func (solver *parSolver) cost(set combinationSet, bound int) (int, int) {
+	if _, ok := solver.solution(set); ok {
+		return 0, -1
+	}
+	key := set.key()
+	known, ok := solver.costs[key]
+	if ok && (known.exact || known.cost >= bound) {
+		return known.cost, known.proposal
+	}
+	lowerBound := max(solver.lowerBound(set), known.cost)
+	if lowerBound >= bound {
+		solver.costs[key] = parCost{cost: lowerBound, proposal: -1, proposals: known.proposals}
+		return lowerBound, -1
+	}
+	if known.proposals == nil {
+		known.proposals = solver.orderProposals(set)
+	}
+	best, bestProposal := bound, -1
+	for _, proposal := range known.proposals {
+		if cost, _ := solver.roundCost(set, proposal, MaxQueriesPerRound, best); cost < best {
+			best, bestProposal = cost, proposal
+			if best <= lowerBound {
+				break
+			}
+		}
+	}
+	solver.costs[key] = parCost{
+		cost:      best,
+		proposal:  bestProposal,
+		exact:     bestProposal >= 0 || best == math.MaxInt,
+		proposals: known.proposals,
+	}
+	return best, bestProposal
+}
+
+// Returns the worst case cost to solve a set of combinations from a round
+// testing a proposal with some queries left, and the verifier to query next
+// (-1 to end the round). The cost is exact if it's below the bound, otherwise
+// it's only known not to be.
+func (solver *parSolver) roundCost(set combinationSet, proposal int, queries int, bound int) (int, int) {
+	// Each query left splits the solutions in 2 groups at most
+	solutions := int(solver.solutionsOf(set).Available())
+	if lowerBound := parRoundCost + parLowerBound(solutions, queries); lowerBound >= bound {
+		return lowerBound, -1
+	}
+	best, bestVerifier := math.MaxInt, -1
+	// End the round, after at least a query
+	if queries < MaxQueriesPerRound {
+		if cost, _ := solver.cost(set, bound-parRoundCost); cost == math.MaxInt {
+			best = math.MaxInt
+		} else {
+			best = parRoundCost + cost
+		}
+		if solutions == 1 || queries == 0 {
+			return best, bestVerifier
+		}
+	}
+	// The most balanced queries first, as they are more likely to be the best
+	type split struct {
+		verifier       int
+		passed, failed combinationSet
+		balance        int
+	}
+	splits := make([]split, 0, len(solver.passes[proposal]))
+	for verifier, pass := range solver.passes[proposal] {
+		passed, failed := set.and(pass), set.andNot(pass)
+		if passed.empty() || failed.empty() {
+			continue
+		}
+		// The larger group first, as it's more likely to exceed the bound
+		if passed.len() < failed.len() {
+			passed, failed = failed, passed
+		}
+		splits = append(splits, split{verifier, passed, failed, passed.len() - failed.len()})
+	}
+	slices.SortStableFunc(splits, func(a, b split) int {
+		return a.balance - b.balance
+	})
+	for _, split := range splits {
+		worst := 0
+		limit := min(best, bound)
+		for _, next := range []combinationSet{split.passed, split.failed} {
+			cost, _ := solver.roundCost(next, proposal, queries-1, limit-1)
+			if cost < math.MaxInt {
+				cost++
+			}
+			if worst = max(worst, cost); worst >= limit {
+				break
+			}
+		}
+		if worst < best {
+			best, bestVerifier = worst, split.verifier
+		}
+	}
+	return best, bestVerifier
+}
+
+// Returns the proposals worth testing on a set of combinations, the ones
+// splitting the most combinations first. Proposals splitting the combinations
+// the same way are equivalent, only the first one is kept.
+func (solver *parSolver) orderProposals(set combinationSet) []int {
+	proposals, splits := make([]int, 0, len(solver.proposals)), make([]int, len(solver.proposals))
+	tested := map[string]bool{}
+	signature := make([]byte, 0, 8*len(set)*MaxNumberOfChoicesPerGame)
+	for proposal := range solver.proposals {
+		signature = signature[:0]
+		for _, pass := range solver.passes[proposal] {
+			passed, failed := 0, 0
+			for i, word := range set {
+				signature = binary.LittleEndian.AppendUint64(signature, word&pass[i])
+				passed += bits.OnesCount64(word & pass[i])
+				failed += bits.OnesCount64(word &^ pass[i])
+			}
+			splits[proposal] += passed * failed
+		}
+		if splits[proposal] == 0 || tested[string(signature)] {
+			continue
+		}
+		tested[string(signature)] = true
+		proposals = append(proposals, proposal)
+	}
+	slices.SortStableFunc(proposals, func(a, b int) int {
+		return splits[b] - splits[a]
+	})
+	return proposals
+}
+
+// Returns true if two rounds test the same proposal against the same set of
+// verifiers, in any order
+func sameRound(a, b Round) bool {
+	verifiers := func(round Round) []int {
+		verifiers := make([]int, len(round.Queries))
+		for i, query := range round.Queries {
+			verifiers[i] = query.Verifier
+		}
+		slices.Sort(verifiers)
+		return verifiers
+	}
+	aProposal, aOk := a.Proposal()
+	bProposal, bOk := b.Proposal()
+	return aOk == bOk && aProposal == bProposal && slices.Equal(verifiers(a), verifiers(b))
+}
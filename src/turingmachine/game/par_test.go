@@ -0,0 +1,71 @@
+package game_test
+
+import (
+	"testing"
+
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
+)
+
+func TestPlayPar(t *testing.T) {
+	t.Parallel()
+
+	for _, choices := range []int{4, 5, 6} {
+		for _, difficulty := range []game.Difficulty{game.EasyDifficulty, game.StandardDifficulty, game.HardDifficulty} {
+			g, err := game.RandomSolvableGame(choices, difficulty)
+			if err != nil {
+				t.Fatalf("Failed to generate random game: %v", err)
+			}
+			par, err := game.PlayPar(g)
+			if err != nil {
+				t.Fatalf("PlayPar(%s) failed: %v", g.String(), err)
+			}
+			code, _ := g.Solve()
+			if par.Guess != code || par.Checks() > par.RoundsPlayed()*game.MaxQueriesPerRound {
+				t.Fatalf("PlayPar(%s) = %+v, expected a valid play guessing %s", g.String(), par, code.String())
+			}
+			// The par follows the rules
+			match, _ := game.NewMatch(g, "alice")
+			for _, round := range par.Rounds {
+				for _, query := range round.Queries {
+					if result, err := match.Query("alice", query.Proposal, query.Verifier); err != nil || result != query.Result {
+						t.Fatalf("Replaying %+v returned (%v, %v)", query, result, err)
+					}
+				}
+				if !match.Finished() {
+					_ = match.EndTurn("alice")
+				}
+			}
+			if diverged := par.Diverged(par.Rounds); diverged != 0 {
+				t.Fatalf("Expected the par not to diverge from itself, got round %d", diverged)
+			}
+		}
+	}
+}
+
+func TestParDiverged(t *testing.T) {
+	t.Parallel()
+
+	proposal, other := game.CodeFromNumbers(1, 2, 3), game.CodeFromNumbers(3, 2, 1)
+	par := game.Par{Rounds: []game.Round{
+		{Queries: []game.Query{{Proposal: proposal, Verifier: 0}, {Proposal: proposal, Verifier: 2}}},
+		{Queries: []game.Query{{Proposal: other, Verifier: 1}}},
+	}}
+	testCases := []struct {
+		rounds   []game.Round
+		expected int
+	}{
+		// Empty rounds are skipped
+		{append([]game.Round{{}}, par.Rounds...), 0},
+		{par.Rounds[:1], 2},
+		// The verifiers of a round are compared in any order
+		{[]game.Round{{Queries: []game.Query{{Proposal: proposal, Verifier: 2}, {Proposal: proposal, Verifier: 0}}}}, 2},
+		{[]game.Round{{Queries: []game.Query{{Proposal: proposal, Verifier: 0}}}}, 1},
+		{[]game.Round{par.Rounds[0], {Queries: []game.Query{{Proposal: proposal, Verifier: 1}}}}, 2},
+		{append(par.Rounds, game.Round{Queries: []game.Query{{Proposal: proposal, Verifier: 1}}}), 3},
+	}
+	for i, testCase := range testCases {
+		if diverged := par.Diverged(testCase.rounds); diverged != testCase.expected {
+			t.Errorf("Case %d: Diverged() = %d, expected %d", i, diverged, testCase.expected)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package game
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"unicode"
+)
+
+const (
+	// The number of words in a game phrase
+	PhraseLength = 5
+	// Each word encodes 10 bits, the phrase wordlist has 1024 words
+	phraseWordBits = 10
+	// The phrase encodes the 45 bits of a game uid followed by a 5 bits
+	// checksum: the top bits of the SHA-256 of the uid (as 8 big-endian bytes)
+	phraseChecksumBits = PhraseLength*phraseWordBits - uidBits
+)
+
+var (
+	// Error returned when a phrase doesn't have PhraseLength words
+	ErrGamePhraseLength = errors.New("a game phrase is 5 words long")
+	// Error returned for words not in the wordlist
+	ErrGamePhraseWord = errors.New("unknown word in the game phrase")
+	// Error returned when the checksum of a phrase does not match
+	ErrGamePhraseChecksum = errors.New("the game phrase checksum does not match")
+)
+
+var (
+	//go:embed phraseWords.txt
+	phraseWordList string
+	// The phrase words - populated on init.
+	phraseWords []string
+	// The index of each phrase word - populated on init.
+	phraseIndex map[string]uint64
+)
+
+func init() {
+	phraseWords = strings.Fields(phraseWordList)
+	if len(phraseWords) != 1<<phraseWordBits {
+		panic("the phrase wordlist must have 1024 words")
+	}
+	phraseIndex = make(map[string]uint64, len(phraseWords))
+	for i, word := range phraseWords {
+		phraseIndex[word] = uint64(i)
+	}
+}
+
+// Returns a phrase of words separated by dashes for this game (see
+// GameFromPhrase), meant to be read aloud.
+func (game Game) Phrase() string {
+	return encodePhrase(game.uid() ^ encoderScramble)
+}
+
+// Derives a Game from a game phrase.
+// Words are case-insensitive and can be separated by dashes or spaces, an
+// *IdError is returned for unknown words.
+// Note: the game might not be valid.
+func GameFromPhrase(phrase string) (Game, error) {
+	value, err := decodePhrase(phrase)
+	if err != nil {
+		return Game{}, err
+	}
+	return gameFromUid(value ^ encoderScramble), nil
+}
+
+// Returns the phrase of a game, keyed unless the cipher is nil.
+// Keyed phrases cannot be told apart from legacy ones, so a cipher only
+// decodes the phrases it produced.
+// Note: the game MUST be valid.
+func (cipher *IdCipher) Phrase(game Game) string {
+	if cipher == nil {
+		return game.Phrase()
+	}
+	return encodePhrase(cipher.permute(game.uid(), false))
+}
+
+// Returns the game of a phrase, keyed unless the cipher is nil
+func (cipher *IdCipher) DecodePhrase(phrase string) (Game, error) {
+	if cipher == nil {
+		return GameFromPhrase(phrase)
+	}
+	value, err := decodePhrase(phrase)
+	if err != nil {
+		return Game{}, err
+	}
+	uid := cipher.permute(value, true)
+	if uid >= gameExp5*gameExp1 {
+		// Outside of the uid range, not a phrase this key produced
+		return Game{}, ErrGameInvalidId
+	}
+	return gameFromUid(uid), nil
+}
+
+/*
+* Helpers
+**/
+
+// Encodes a 45 bits value and its checksum as a phrase
+func encodePhrase(value uint64) string {
+	value = value<<phraseChecksumBits | phraseChecksum(value)
+	words := make([]string, PhraseLength)
+	for i := PhraseLength - 1; i >= 0; i-- {
+		words[i] = phraseWords[value&(1<<phraseWordBits-1)]
+		value >>= phraseWordBits
+	}
+	return strings.Join(words, "-")
+}
+
+// Decodes a 45 bits value from a phrase, verifying its checksum
+func decodePhrase(phrase string) (uint64, error) {
+	isSeparator := func(r rune) bool {
+		return r == '-' || unicode.IsSpace(r)
+	}
+	words := strings.FieldsFunc(phrase, isSeparator)
+	if len(words) != PhraseLength {
+		return 0, ErrGamePhraseLength
+	}
+	value := uint64(0)
+	position := 0
+	for _, word := range words {
+		// Words are in order, find the offset of this one
+		position += strings.Index(phrase[position:], word)
+		index, ok := phraseIndex[strings.ToLower(word)]
+		if !ok {
+			return 0, &IdError{Position: position, Err: ErrGamePhraseWord}
+		}
+		position += len(word)
+		value = value<<phraseWordBits | index
+	}
+	checksum := value & (1<<phraseChecksumBits - 1)
+	value >>= phraseChecksumBits
+	if phraseChecksum(value) != checksum {
+		return 0, ErrGamePhraseChecksum
+	}
+	return value, nil
+}
+
+// Returns the checksum of a phrase value
+func phraseChecksum(value uint64) uint64 {
+	var input [8]byte
+	binary.BigEndian.PutUint64(input[:], value)
+	sum := sha256.Sum256(input[:])
+	return uint64(sum[0] >> (8 - phraseChecksumBits))
+}
@@ -0,0 +1,70 @@
+package game_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
+)
+
+func TestGamePhrase(t *testing.T) {
+	t.Parallel()
+
+	cipher := game.NewIdCipher([]byte("secret"))
+	games := []game.Game{
+		{1},
+		{game.MaxChoice - 5, game.MaxChoice - 4, game.MaxChoice - 3, game.MaxChoice - 2, game.MaxChoice - 1, game.MaxChoice},
+	}
+	for range 500 {
+		g, err := game.RandomSolvableGame(4+len(games)%3, game.HardDifficulty)
+		if err != nil {
+			t.Fatalf("Failed to generate random game: %v", err)
+		}
+		games = append(games, g)
+	}
+	for _, g := range games {
+		phrase := g.Phrase()
+		if words := strings.Split(phrase, "-"); len(words) != game.PhraseLength {
+			t.Fatalf("Expected %d words, got %q", game.PhraseLength, phrase)
+		}
+		for _, variant := range []string{phrase, strings.ToUpper(phrase), strings.ReplaceAll(phrase, "-", " ")} {
+			if decoded, err := game.GameFromPhrase(variant); err != nil || decoded != g {
+				t.Fatalf("GameFromPhrase(%q) = (%s, %v), expected %s", variant, decoded.Debug(), err, g.Debug())
+			}
+		}
+		keyed := cipher.Phrase(g)
+		if decoded, err := cipher.DecodePhrase(keyed); err != nil || decoded != g {
+			t.Fatalf("DecodePhrase(%q) = (%s, %v), expected %s", keyed, decoded.Debug(), err, g.Debug())
+		}
+		if keyed == phrase {
+			t.Fatalf("Expected the keyed phrase to differ from %q", phrase)
+		}
+	}
+
+	phrase := games[0].Phrase()
+	words := strings.Split(phrase, "-")
+	testCases := []struct {
+		phrase string
+		err    error
+	}{
+		{strings.Join(words[:4], "-"), game.ErrGamePhraseLength},
+		{phrase + "-amber", game.ErrGamePhraseLength},
+		{"", game.ErrGamePhraseLength},
+		{strings.Join(append([]string{"quokka"}, words[1:]...), "-"), game.ErrGamePhraseWord},
+		{strings.Join([]string{words[1], words[0], words[2], words[3], words[4]}, "-"), game.ErrGamePhraseChecksum},
+	}
+	for _, testCase := range testCases {
+		if words[0] == words[1] && testCase.err == game.ErrGamePhraseChecksum {
+			continue
+		}
+		if _, err := game.GameFromPhrase(testCase.phrase); !errors.Is(err, testCase.err) {
+			t.Errorf("GameFromPhrase(%q) returned %v, expected %v", testCase.phrase, err, testCase.err)
+		}
+	}
+	var idErr *game.IdError
+	unknown := strings.Join(append(words[:2:2], "quokka", words[3], words[4]), " ")
+	if _, err := game.GameFromPhrase(unknown); !errors.As(err, &idErr) || idErr.Position != len(words[0])+len(words[1])+2 {
+		t.Errorf("GameFromPhrase(%q) returned %v, expected an error at the third word", unknown, err)
+	}
+}
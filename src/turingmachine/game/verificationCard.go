@@ -26,19 +26,27 @@ const (
 // A verification card
 type VerificationCard int8
 
+// The symbols verification cards can be represented with
+var VerificationSymbols = [4]string{LozengeSymbol, PoundSymbol, SlashSymbol, CurrencySymbol}
+
 // Returns a string representation of each card with a random symbol picked
 func getRandomVerificationSymbol(cards []VerificationCard) []string {
+	return getVerificationSymbol(cards, VerificationSymbols[rand.Intn(len(VerificationSymbols))])
+}
+
+// Returns a string representation of each card with a given symbol (one of
+// VerificationSymbols)
+func getVerificationSymbol(cards []VerificationCard, symbol string) []string {
 	res := make([]string, len(cards))
-	r := rand.Intn(4)
 	for i := range len(cards) {
-		switch r {
-		case 0:
+		switch symbol {
+		case LozengeSymbol:
 			res[i] = cards[i].LozengeString()
-		case 1:
+		case PoundSymbol:
 			res[i] = cards[i].PoundString()
-		case 2:
+		case SlashSymbol:
 			res[i] = cards[i].SlashString()
-		case 3:
+		case CurrencySymbol:
 			res[i] = cards[i].CurrencyString()
 		}
 	}
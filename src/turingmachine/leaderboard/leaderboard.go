@@ -0,0 +1,405 @@
+package leaderboard
+
+import (
+	"cmp"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/stefanovazzocell/TuringMachine/src/kv"
+)
+
+const (
+	// The maximum length of a nickname, in characters
+	MaxNicknameLength = 24
+	// The number of recent results in player stats
+	RecentResults = 10
+	// The number of random bytes in a player id
+	idBytes = 8
+	// The number of random bytes in a device token
+	deviceTokenBytes = 32
+
+	playerPrefix  = "player/"
+	resultPrefix  = "result/"
+	historyPrefix = "history/"
+	sourcePrefix  = "source/"
+	claimPrefix   = "claim/"
+)
+
+var (
+	// Error returned for empty, too long or unprintable nicknames
+	ErrInvalidNickname = errors.New("the nickname is not valid")
+	// Error returned when a player does not exist
+	ErrPlayerNotFound = errors.New("player not found")
+	// Error returned when the device token does not match the player
+	ErrInvalidDeviceToken = errors.New("the device token is not valid")
+	// Error returned when the player already has a result for the puzzle
+	ErrAlreadyRecorded = errors.New("the player already has a result for this puzzle")
+	// Error returned when the source of a result (a session or a token) was
+	// already used
+	ErrSourceUsed = errors.New("the result source was already used")
+	// Error returned when a source was already claimed (see Claim)
+	ErrSourceClaimed = errors.New("the result source was already claimed")
+)
+
+// An anonymous player
+type Player struct {
+	Id       string `json:"id"`
+	Nickname string `json:"nickname"`
+	// The SHA-256 of the device token
+	TokenHash []byte    `json:"token_hash"`
+	Created   time.Time `json:"created"`
+}
+
+// The result of a player on a puzzle
+type Result struct {
+	Player string `json:"player"`
+	// The id of the game (see game.Game.String)
+	Puzzle string `json:"puzzle"`
+	Score
+	Time time.Time `json:"time"`
+}
+
+// An entry of a puzzle leaderboard
+type Entry struct {
+	// Starting from 1, tied players share a rank
+	Rank     int
+	Player   string
+	Nickname string
+	Rounds   int
+	Checks   int
+	Time     time.Time
+}
+
+// An entry of the overall (daily or all-time) rankings
+type Standing struct {
+	// Starting from 1, tied players share a rank
+	Rank     int
+	Player   string
+	Nickname string
+	// The number of puzzles solved
+	Solved int
+	// The total rounds and checks for the puzzles solved
+	Rounds int
+	Checks int
+}
+
+// The statistics of a player
+type Stats struct {
+	Player Player
+	Played int
+	Solved int
+	// Averages over the puzzles solved
+	AverageRounds float64
+	AverageChecks float64
+	// The most recent results, newest first
+	Recent []Result
+}
+
+// Records the results of players on puzzles in a backend, safe for concurrent
+// use.
+type Board struct {
+	backend kv.Backend
+	// Held while recording, so a player has a single result per puzzle
+	lock sync.Mutex
+}
+
+// Returns a leaderboard stored in a backend
+func New(backend kv.Backend) *Board {
+	return &Board{backend: backend}
+}
+
+// Creates a player, returns it with its device token.
+// The device token is only stored hashed, it's needed to record results.
+func (board *Board) Register(nickname string) (Player, string, error) {
+	nickname = strings.TrimSpace(nickname)
+	if !validNickname(nickname) {
+		return Player{}, "", ErrInvalidNickname
+	}
+	id := make([]byte, idBytes)
+	token := make([]byte, deviceTokenBytes)
+	if _, err := rand.Read(id); err != nil {
+		return Player{}, "", err
+	}
+	if _, err := rand.Read(token); err != nil {
+		return Player{}, "", err
+	}
+	deviceToken := base64.RawURLEncoding.EncodeToString(token)
+	hash := sha256.Sum256([]byte(deviceToken))
+	player := Player{
+		Id:        hex.EncodeToString(id),
+		Nickname:  nickname,
+		TokenHash: hash[:],
+		Created:   time.Now(),
+	}
+	if err := board.put(playerPrefix+player.Id, player); err != nil {
+		return Player{}, "", err
+	}
+	return player, deviceToken, nil
+}
+
+// Returns a player by id
+func (board *Board) Player(id string) (Player, error) {
+	player := Player{}
+	err := board.get(playerPrefix+id, &player)
+	if errors.Is(err, kv.ErrNotFound) {
+		return player, ErrPlayerNotFound
+	}
+	return player, err
+}
+
+// Returns a player by id if the device token matches
+func (board *Board) Authenticate(id, deviceToken string) (Player, error) {
+	player, err := board.Player(id)
+	if err != nil {
+		return player, err
+	}
+	hash := sha256.Sum256([]byte(deviceToken))
+	if subtle.ConstantTimeCompare(hash[:], player.TokenHash) != 1 {
+		return Player{}, ErrInvalidDeviceToken
+	}
+	return player, nil
+}
+
+// Records a result, the time defaults to now.
+// A player has a single result per puzzle, and a source (e.g. a session id)
+// can only be used once; an empty source is not checked.
+func (board *Board) Record(result Result, source string) (Result, error) {
+	if result.Time.IsZero() {
+		result.Time = time.Now()
+	}
+	board.lock.Lock()
+	defer board.lock.Unlock()
+	if _, err := board.Player(result.Player); err != nil {
+		return result, err
+	}
+	key := resultPrefix + result.Puzzle + "/" + result.Player
+	if _, err := board.backend.Get(key); err == nil {
+		return result, ErrAlreadyRecorded
+	} else if !errors.Is(err, kv.ErrNotFound) {
+		return result, err
+	}
+	if source != "" {
+		if _, err := board.backend.Get(sourcePrefix + source); err == nil {
+			return result, ErrSourceUsed
+		} else if !errors.Is(err, kv.ErrNotFound) {
+			return result, err
+		}
+		if err := board.put(sourcePrefix+source, result.Player); err != nil {
+			return result, err
+		}
+	}
+	if err := board.put(historyPrefix+result.Player+"/"+result.Puzzle, result); err != nil {
+		return result, err
+	}
+	return result, board.put(key, result)
+}
+
+// Marks a source as used without a result (e.g. a token the player gave up
+// on), no result can be recorded from it afterwards
+func (board *Board) Forfeit(source string) error {
+	board.lock.Lock()
+	defer board.lock.Unlock()
+	if _, err := board.backend.Get(sourcePrefix + source); err == nil {
+		return nil
+	} else if !errors.Is(err, kv.ErrNotFound) {
+		return err
+	}
+	return board.put(sourcePrefix+source, "")
+}
+
+// Claims a source to be played only once (e.g. a token played in a session),
+// so its puzzle cannot be explored in another play before the recorded one
+func (board *Board) Claim(source string) error {
+	board.lock.Lock()
+	defer board.lock.Unlock()
+	if _, err := board.backend.Get(claimPrefix + source); err == nil {
+		return ErrSourceClaimed
+	} else if !errors.Is(err, kv.ErrNotFound) {
+		return err
+	}
+	return board.put(claimPrefix+source, "")
+}
+
+// Returns the ranking of the players that solved a puzzle, by rounds, checks
+// and then time, up to limit entries
+func (board *Board) Puzzle(puzzle string, limit int) ([]Entry, error) {
+	results, err := board.results(resultPrefix + puzzle + "/")
+	if err != nil {
+		return nil, err
+	}
+	results = slices.DeleteFunc(results, func(result Result) bool {
+		return !result.Solved
+	})
+	slices.SortFunc(results, func(a, b Result) int {
+		return cmp.Or(compareScores(a.Score, b.Score), a.Time.Compare(b.Time), cmp.Compare(a.Player, b.Player))
+	})
+	entries := make([]Entry, 0, min(len(results), limit))
+	for i, result := range results[:min(len(results), limit)] {
+		entry := Entry{
+			Rank:     i + 1,
+			Player:   result.Player,
+			Nickname: board.nickname(result.Player),
+			Rounds:   result.Rounds,
+			Checks:   result.Checks,
+			Time:     result.Time,
+		}
+		if i > 0 && compareScores(results[i-1].Score, result.Score) == 0 {
+			entry.Rank = entries[i-1].Rank
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// Returns the ranking of the players by puzzles solved, and then total rounds
+// and checks, up to limit entries.
+// Only the results recorded on the day of date (in UTC) are counted, or all of
+// them if date is zero.
+func (board *Board) Ranking(date time.Time, limit int) ([]Standing, error) {
+	results, err := board.results(resultPrefix)
+	if err != nil {
+		return nil, err
+	}
+	day := date.UTC().Format(time.DateOnly)
+	byPlayer := map[string]*Standing{}
+	for _, result := range results {
+		if !result.Solved || !date.IsZero() && result.Time.UTC().Format(time.DateOnly) != day {
+			continue
+		}
+		standing, ok := byPlayer[result.Player]
+		if !ok {
+			standing = &Standing{Player: result.Player}
+			byPlayer[result.Player] = standing
+		}
+		standing.Solved++
+		standing.Rounds += result.Rounds
+		standing.Checks += result.Checks
+	}
+	standings := make([]Standing, 0, len(byPlayer))
+	for _, standing := range byPlayer {
+		standings = append(standings, *standing)
+	}
+	slices.SortFunc(standings, func(a, b Standing) int {
+		return cmp.Or(compareStandings(a, b), cmp.Compare(a.Player, b.Player))
+	})
+	standings = standings[:min(len(standings), limit)]
+	for i := range standings {
+		standings[i].Rank = i + 1
+		standings[i].Nickname = board.nickname(standings[i].Player)
+		if i > 0 && compareStandings(standings[i-1], standings[i]) == 0 {
+			standings[i].Rank = standings[i-1].Rank
+		}
+	}
+	return standings, nil
+}
+
+// Returns the statistics of a player
+func (board *Board) Stats(id string) (Stats, error) {
+	player, err := board.Player(id)
+	if err != nil {
+		return Stats{}, err
+	}
+	results, err := board.results(historyPrefix + id + "/")
+	if err != nil {
+		return Stats{}, err
+	}
+	stats := Stats{Player: player, Played: len(results)}
+	for _, result := range results {
+		if result.Solved {
+			stats.Solved++
+			stats.AverageRounds += float64(result.Rounds)
+			stats.AverageChecks += float64(result.Checks)
+		}
+	}
+	if stats.Solved > 0 {
+		stats.AverageRounds /= float64(stats.Solved)
+		stats.AverageChecks /= float64(stats.Solved)
+	}
+	slices.SortFunc(results, func(a, b Result) int {
+		return b.Time.Compare(a.Time)
+	})
+	stats.Recent = results[:min(len(results), RecentResults)]
+	return stats, nil
+}
+
+/*
+* Helpers
+**/
+
+// Returns true if a (trimmed) nickname can be used
+func validNickname(nickname string) bool {
+	if nickname == "" || utf8.RuneCountInString(nickname) > MaxNicknameLength {
+		return false
+	}
+	for _, r := range nickname {
+		if !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns the nickname of a player, empty if unknown
+func (board *Board) nickname(id string) string {
+	player, _ := board.Player(id)
+	return player.Nickname
+}
+
+// Returns the results under a prefix
+func (board *Board) results(prefix string) ([]Result, error) {
+	keys, err := board.backend.Keys(prefix)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]Result, 0, len(keys))
+	for _, key := range keys {
+		result := Result{}
+		if err = board.get(key, &result); errors.Is(err, kv.ErrNotFound) {
+			// Removed in the meantime
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// Stores a value as JSON
+func (board *Board) put(key string, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return board.backend.Put(key, data, 0)
+}
+
+// Reads a JSON value
+func (board *Board) get(key string, value any) error {
+	data, err := board.backend.Get(key)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, value)
+}
+
+// Compares two scores, the best one first
+func compareScores(a, b Score) int {
+	return cmp.Or(cmp.Compare(a.Rounds, b.Rounds), cmp.Compare(a.Checks, b.Checks))
+}
+
+// Compares two standings, the best one first
+func compareStandings(a, b Standing) int {
+	return cmp.Or(cmp.Compare(b.Solved, a.Solved), cmp.Compare(a.Rounds, b.Rounds), cmp.Compare(a.Checks, b.Checks))
+}
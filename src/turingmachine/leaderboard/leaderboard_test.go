@@ -0,0 +1,181 @@
+package leaderboard_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stefanovazzocell/TuringMachine/src/kv"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/leaderboard"
+)
+
+// Registers a player, failing the test on errors
+func register(t *testing.T, board *leaderboard.Board, nickname string) (leaderboard.Player, string) {
+	player, deviceToken, err := board.Register(nickname)
+	if err != nil {
+		t.Fatalf("Register(%q) failed: %v", nickname, err)
+	}
+	return player, deviceToken
+}
+
+func TestBoardPlayers(t *testing.T) {
+	t.Parallel()
+
+	backend, _ := kv.OpenLog("")
+	board := leaderboard.New(backend)
+	for _, nickname := range []string{"", "   ", "a nickname that is way too long", "tab\there"} {
+		if _, _, err := board.Register(nickname); !errors.Is(err, leaderboard.ErrInvalidNickname) {
+			t.Errorf("Register(%q) returned %v, expected ErrInvalidNickname", nickname, err)
+		}
+	}
+	alice, deviceToken := register(t, board, " alice ")
+	if alice.Nickname != "alice" || alice.Id == "" || deviceToken == "" {
+		t.Fatalf("Unexpected player %+v (%q)", alice, deviceToken)
+	}
+	if player, err := board.Authenticate(alice.Id, deviceToken); err != nil || player.Id != alice.Id {
+		t.Fatalf("Authenticate() = (%+v, %v), expected alice", player, err)
+	}
+	if _, err := board.Authenticate(alice.Id, "wrong"); !errors.Is(err, leaderboard.ErrInvalidDeviceToken) {
+		t.Errorf("Expected ErrInvalidDeviceToken, got %v", err)
+	}
+	if _, err := board.Authenticate("unknown", deviceToken); !errors.Is(err, leaderboard.ErrPlayerNotFound) {
+		t.Errorf("Expected ErrPlayerNotFound, got %v", err)
+	}
+}
+
+func TestBoardRankings(t *testing.T) {
+	t.Parallel()
+
+	filename := filepath.Join(t.TempDir(), "leaderboard.log")
+	backend, err := kv.OpenLog(filename)
+	if err != nil {
+		t.Fatalf("OpenLog() failed: %v", err)
+	}
+	board := leaderboard.New(backend)
+	alice, _ := register(t, board, "alice")
+	bob, _ := register(t, board, "bob")
+	carol, _ := register(t, board, "carol")
+
+	day := time.Date(2026, time.March, 2, 12, 0, 0, 0, time.UTC)
+	results := []leaderboard.Result{
+		{Player: alice.Id, Puzzle: "A", Score: leaderboard.Score{Rounds: 3, Checks: 7, Solved: true}, Time: day},
+		{Player: bob.Id, Puzzle: "A", Score: leaderboard.Score{Rounds: 2, Checks: 6, Solved: true}, Time: day.Add(time.Hour)},
+		{Player: carol.Id, Puzzle: "A", Score: leaderboard.Score{Rounds: 2, Checks: 6, Solved: true}, Time: day.Add(2 * time.Hour)},
+		{Player: alice.Id, Puzzle: "B", Score: leaderboard.Score{Rounds: 4, Checks: 9, Solved: true}, Time: day.AddDate(0, 0, 1)},
+		{Player: bob.Id, Puzzle: "B", Score: leaderboard.Score{Rounds: 1, Checks: 1, Solved: false}, Time: day.AddDate(0, 0, 1)},
+	}
+	for i, result := range results {
+		if _, err := board.Record(result, ""); err != nil {
+			t.Fatalf("[%d] Record() failed: %v", i, err)
+		}
+	}
+	if _, err := board.Record(results[0], ""); !errors.Is(err, leaderboard.ErrAlreadyRecorded) {
+		t.Errorf("Expected ErrAlreadyRecorded, got %v", err)
+	}
+	if _, err := board.Record(leaderboard.Result{Player: "unknown", Puzzle: "A"}, ""); !errors.Is(err, leaderboard.ErrPlayerNotFound) {
+		t.Errorf("Expected ErrPlayerNotFound, got %v", err)
+	}
+	if _, err := board.Record(leaderboard.Result{Player: carol.Id, Puzzle: "B"}, "session"); err != nil {
+		t.Fatalf("Record() failed: %v", err)
+	}
+	if _, err := board.Record(leaderboard.Result{Player: carol.Id, Puzzle: "C"}, "session"); !errors.Is(err, leaderboard.ErrSourceUsed) {
+		t.Errorf("Expected ErrSourceUsed, got %v", err)
+	}
+	if err := board.Forfeit("token"); err != nil {
+		t.Fatalf("Forfeit() failed: %v", err)
+	}
+	if _, err := board.Record(leaderboard.Result{Player: carol.Id, Puzzle: "C"}, "token"); !errors.Is(err, leaderboard.ErrSourceUsed) {
+		t.Errorf("Expected ErrSourceUsed for a forfeited source, got %v", err)
+	}
+	if err := board.Claim("token"); err != nil {
+		t.Fatalf("Claim() failed: %v", err)
+	}
+	if err := board.Claim("token"); !errors.Is(err, leaderboard.ErrSourceClaimed) {
+		t.Errorf("Expected ErrSourceClaimed, got %v", err)
+	}
+
+	// Persisted across restarts
+	if err = backend.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	if backend, err = kv.OpenLog(filename); err != nil {
+		t.Fatalf("OpenLog() failed: %v", err)
+	}
+	defer backend.Close()
+	board = leaderboard.New(backend)
+	if err := board.Claim("token"); !errors.Is(err, leaderboard.ErrSourceClaimed) {
+		t.Errorf("Expected the claim to be persisted, got %v", err)
+	}
+
+	entries, err := board.Puzzle("A", 10)
+	if err != nil || len(entries) != 3 {
+		t.Fatalf("Puzzle(A) = (%+v, %v), expected 3 entries", entries, err)
+	}
+	for i, expected := range []struct {
+		rank   int
+		player string
+	}{{1, bob.Id}, {1, carol.Id}, {3, alice.Id}} {
+		if entries[i].Rank != expected.rank || entries[i].Player != expected.player {
+			t.Errorf("Puzzle(A)[%d] = %+v, expected rank %d for %s", i, entries[i], expected.rank, expected.player)
+		}
+	}
+	if entries[0].Nickname != "bob" {
+		t.Errorf("Expected the nickname of bob, got %q", entries[0].Nickname)
+	}
+	if entries, _ = board.Puzzle("B", 10); len(entries) != 1 || entries[0].Player != alice.Id {
+		t.Errorf("Expected only alice to have solved B, got %+v", entries)
+	}
+	if entries, _ = board.Puzzle("A", 1); len(entries) != 1 {
+		t.Errorf("Expected the limit to apply, got %+v", entries)
+	}
+
+	allTime, err := board.Ranking(time.Time{}, 10)
+	if err != nil || len(allTime) != 3 || allTime[0].Player != alice.Id || allTime[0].Solved != 2 ||
+		allTime[0].Rounds != 7 || allTime[1].Rank != 2 || allTime[2].Rank != 2 {
+		t.Fatalf("Unexpected all-time ranking %+v (%v)", allTime, err)
+	}
+	daily, err := board.Ranking(day.AddDate(0, 0, 1), 10)
+	if err != nil || len(daily) != 1 || daily[0].Player != alice.Id || daily[0].Rank != 1 {
+		t.Fatalf("Unexpected daily ranking %+v (%v)", daily, err)
+	}
+
+	stats, err := board.Stats(bob.Id)
+	if err != nil || stats.Played != 2 || stats.Solved != 1 || stats.AverageRounds != 2 ||
+		stats.AverageChecks != 6 || len(stats.Recent) != 2 || stats.Recent[0].Puzzle != "B" {
+		t.Fatalf("Unexpected stats %+v (%v)", stats, err)
+	}
+	if _, err = board.Stats("unknown"); !errors.Is(err, leaderboard.ErrPlayerNotFound) {
+		t.Errorf("Expected ErrPlayerNotFound, got %v", err)
+	}
+}
+
+func TestScoreRounds(t *testing.T) {
+	t.Parallel()
+
+	g, err := game.RandomSolvableGame(5, game.StandardDifficulty)
+	if err != nil {
+		t.Fatalf("Failed to generate random game: %v", err)
+	}
+	solution, _ := g.Solve()
+	match, err := game.NewMatch(g, "player")
+	if err != nil {
+		t.Fatalf("NewMatch() failed: %v", err)
+	}
+	for _, slot := range []int{0, 1, 2} {
+		if _, err = match.Query("player", solution, slot); err != nil {
+			t.Fatalf("Query() failed: %v", err)
+		}
+	}
+	if err = match.EndTurn("player"); err != nil {
+		t.Fatalf("EndTurn() failed: %v", err)
+	}
+	if _, err = match.Query("player", solution, 3); err != nil {
+		t.Fatalf("Query() failed: %v", err)
+	}
+	player, _ := match.Player("player")
+	if score := leaderboard.ScoreRounds(player.Rounds, true); score != (leaderboard.Score{Rounds: 2, Checks: 4, Solved: true}) {
+		t.Errorf("ScoreRounds() = %+v, expected 2 rounds and 4 checks", score)
+	}
+}
@@ -0,0 +1,19 @@
+package leaderboard
+
+import "github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
+
+// How a puzzle was solved
+type Score struct {
+	// The rounds in which a verifier was queried
+	Rounds int `json:"rounds"`
+	// The verifiers queried
+	Checks int `json:"checks"`
+	// True if the guess was correct
+	Solved bool `json:"solved"`
+}
+
+// Returns the score of rounds played on a puzzle
+func ScoreRounds(rounds []game.Round, solved bool) Score {
+	player := game.Player{Rounds: rounds}
+	return Score{Rounds: player.RoundsPlayed(), Checks: player.Checks(), Solved: solved}
+}
@@ -0,0 +1,310 @@
+package matchmaking
+
+import (
+	"cmp"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
+)
+
+const (
+	// The number of random bytes in a ticket id
+	ticketIdBytes = 16
+)
+
+var (
+	// Error returned when a ticket does not exist (matched, left or expired)
+	ErrTicketNotFound = errors.New("ticket not found")
+	// Error returned when a player is already in the queue
+	ErrAlreadyQueued = errors.New("the player is already in the queue")
+	// Error returned for an invalid number of choices
+	ErrInvalidChoices = fmt.Errorf("the number of choices must be between %d and %d",
+		game.MinNumberOfChoicesPerGame, game.MaxNumberOfChoicesPerGame)
+)
+
+// The source of the current time, replaced by a fake clock in tests
+type Clock interface {
+	Now() time.Time
+}
+
+// The clock of the system
+type SystemClock struct{}
+
+// Returns the current time
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// How players are grouped
+type Options struct {
+	// The number of players in a match
+	MinPlayers int
+	MaxPlayers int
+	// A match with fewer than MaxPlayers starts once its longest waiting player
+	// waited this long
+	FillWait time.Duration
+	// The maximum rating difference with the longest waiting player of a match,
+	// growing by SpreadGrowth each second the player waits
+	InitialSpread float64
+	SpreadGrowth  float64
+	// Tickets are dropped after waiting this long
+	MaxWait time.Duration
+}
+
+// Returns the default options: 2 to 4 players within 100 rating points,
+// widening by 2 points a second
+func DefaultOptions() Options {
+	return Options{
+		MinPlayers:    2,
+		MaxPlayers:    4,
+		FillWait:      30 * time.Second,
+		InitialSpread: 100,
+		SpreadGrowth:  2,
+		MaxWait:       10 * time.Minute,
+	}
+}
+
+// A request to play a match
+type Request struct {
+	// The player (account) id, unique in the queue
+	Player string
+	Rating float64
+	// The preferred game, only players with the same preferences are grouped
+	Choices    int
+	Difficulty game.Difficulty
+}
+
+// A request waiting in the queue
+type Ticket struct {
+	Id string
+	Request
+	Joined time.Time
+	// The order of arrival, breaks ties between tickets that joined at once
+	seq uint64
+	// Receives the match of the ticket, closed if it's dropped
+	matched chan Match
+}
+
+// A group of players to start a match
+type Match struct {
+	Choices    int
+	Difficulty game.Difficulty
+	// The longest waiting first
+	Players []Request
+	// Set up by the caller of Tick, e.g. the room the players join
+	Room string
+}
+
+// A queue of players waiting for a match, grouped by Tick.
+// Grouping only depends on the requests, their arrival and the clock: it's
+// deterministic. Safe for concurrent use.
+type Lobby struct {
+	options Options
+	clock   Clock
+
+	lock    sync.Mutex
+	tickets map[string]*Ticket
+	seq     uint64
+	// The recent matches by ticket, for the players waiting on them late
+	matches map[string]matched
+}
+
+// A match of a ticket that left the queue
+type matched struct {
+	match Match
+	at    time.Time
+}
+
+// Returns an empty lobby
+func NewLobby(options Options, clock Clock) *Lobby {
+	return &Lobby{
+		options: options,
+		clock:   clock,
+		tickets: map[string]*Ticket{},
+		matches: map[string]matched{},
+	}
+}
+
+// Queues a request, returns its ticket and a channel receiving its match (or
+// closed if the ticket is dropped)
+func (lobby *Lobby) Join(request Request) (Ticket, <-chan Match, error) {
+	if request.Choices < game.MinNumberOfChoicesPerGame || request.Choices > game.MaxNumberOfChoicesPerGame {
+		return Ticket{}, nil, ErrInvalidChoices
+	}
+	id := make([]byte, ticketIdBytes)
+	if _, err := rand.Read(id); err != nil {
+		return Ticket{}, nil, err
+	}
+	lobby.lock.Lock()
+	defer lobby.lock.Unlock()
+	for _, ticket := range lobby.tickets {
+		if ticket.Player == request.Player {
+			return Ticket{}, nil, ErrAlreadyQueued
+		}
+	}
+	lobby.seq++
+	ticket := &Ticket{
+		Id:      hex.EncodeToString(id),
+		Request: request,
+		Joined:  lobby.clock.Now(),
+		seq:     lobby.seq,
+		matched: make(chan Match, 1),
+	}
+	lobby.tickets[ticket.Id] = ticket
+	return *ticket, ticket.matched, nil
+}
+
+// Removes a ticket from the queue, closing its channel
+func (lobby *Lobby) Leave(id string) error {
+	lobby.lock.Lock()
+	defer lobby.lock.Unlock()
+	ticket, ok := lobby.tickets[id]
+	if !ok {
+		return ErrTicketNotFound
+	}
+	delete(lobby.tickets, id)
+	close(ticket.matched)
+	return nil
+}
+
+// Returns a ticket in the queue
+func (lobby *Lobby) Get(id string) (Ticket, error) {
+	lobby.lock.Lock()
+	defer lobby.lock.Unlock()
+	ticket, ok := lobby.tickets[id]
+	if !ok {
+		return Ticket{}, ErrTicketNotFound
+	}
+	return *ticket, nil
+}
+
+// Returns the channel receiving the match of a ticket (or closed if the ticket
+// is dropped), the tickets matched recently (within MaxWait) are still found
+func (lobby *Lobby) Wait(id string) (<-chan Match, error) {
+	lobby.lock.Lock()
+	defer lobby.lock.Unlock()
+	if ticket, ok := lobby.tickets[id]; ok {
+		return ticket.matched, nil
+	}
+	result, ok := lobby.matches[id]
+	if !ok {
+		return nil, ErrTicketNotFound
+	}
+	replay := make(chan Match, 1)
+	replay <- result.match
+	close(replay)
+	return replay, nil
+}
+
+// Returns the number of tickets in the queue
+func (lobby *Lobby) Len() int {
+	lobby.lock.Lock()
+	defer lobby.lock.Unlock()
+	return len(lobby.tickets)
+}
+
+// Groups the waiting players into matches and drops the expired tickets.
+// Each match is passed to setup (e.g. to create its room): on success its
+// players leave the queue and receive it, on error they keep waiting and the
+// errors are returned. Returns the matches set up, the oldest first.
+func (lobby *Lobby) Tick(setup func(*Match) error) ([]Match, error) {
+	lobby.lock.Lock()
+	defer lobby.lock.Unlock()
+	now := lobby.clock.Now()
+	for id, result := range lobby.matches {
+		if now.Sub(result.at) >= lobby.options.MaxWait {
+			delete(lobby.matches, id)
+		}
+	}
+
+	// The oldest tickets first
+	waiting := make([]*Ticket, 0, len(lobby.tickets))
+	for id, ticket := range lobby.tickets {
+		if now.Sub(ticket.Joined) >= lobby.options.MaxWait {
+			delete(lobby.tickets, id)
+			close(ticket.matched)
+			continue
+		}
+		waiting = append(waiting, ticket)
+	}
+	slices.SortFunc(waiting, compareTickets)
+
+	matches := []Match{}
+	errs := []error{}
+	grouped := map[string]bool{}
+	for _, anchor := range waiting {
+		if grouped[anchor.Id] {
+			continue
+		}
+		group := lobby.group(anchor, waiting, grouped, now)
+		if group == nil {
+			continue
+		}
+		match := Match{Choices: anchor.Choices, Difficulty: anchor.Difficulty}
+		for _, ticket := range group {
+			grouped[ticket.Id] = true
+			match.Players = append(match.Players, ticket.Request)
+		}
+		if err := setup(&match); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, ticket := range group {
+			delete(lobby.tickets, ticket.Id)
+			lobby.matches[ticket.Id] = matched{match: match, at: now}
+			ticket.matched <- match
+			close(ticket.matched)
+		}
+		matches = append(matches, match)
+	}
+	return matches, errors.Join(errs...)
+}
+
+/*
+* Helpers
+**/
+
+// Returns the group of an anchor (the longest waiting player of the group)
+// that can start now, or nil.
+// The other players are the closest in rating with the same preferences,
+// within the spread of the anchor.
+func (lobby *Lobby) group(anchor *Ticket, waiting []*Ticket, grouped map[string]bool, now time.Time) []*Ticket {
+	spread := lobby.spread(anchor, now)
+	candidates := []*Ticket{}
+	for _, ticket := range waiting {
+		if ticket == anchor || grouped[ticket.Id] || compareTickets(ticket, anchor) < 0 ||
+			ticket.Choices != anchor.Choices || ticket.Difficulty != anchor.Difficulty ||
+			math.Abs(ticket.Rating-anchor.Rating) > spread {
+			continue
+		}
+		candidates = append(candidates, ticket)
+	}
+	slices.SortStableFunc(candidates, func(a, b *Ticket) int {
+		return cmp.Compare(math.Abs(a.Rating-anchor.Rating), math.Abs(b.Rating-anchor.Rating))
+	})
+	group := append([]*Ticket{anchor}, candidates[:min(len(candidates), lobby.options.MaxPlayers-1)]...)
+	if len(group) < lobby.options.MinPlayers ||
+		(len(group) < lobby.options.MaxPlayers && now.Sub(anchor.Joined) < lobby.options.FillWait) {
+		return nil
+	}
+	// The longest waiting first
+	slices.SortFunc(group, compareTickets)
+	return group
+}
+
+// Returns the maximum rating difference with a ticket for a match
+func (lobby *Lobby) spread(ticket *Ticket, now time.Time) float64 {
+	return lobby.options.InitialSpread + lobby.options.SpreadGrowth*now.Sub(ticket.Joined).Seconds()
+}
+
+// Compares two tickets by arrival, the oldest first
+func compareTickets(a, b *Ticket) int {
+	return cmp.Or(a.Joined.Compare(b.Joined), cmp.Compare(a.seq, b.seq))
+}
@@ -0,0 +1,164 @@
+package matchmaking_test
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/matchmaking"
+)
+
+// A clock only moving when advanced
+type fakeClock struct {
+	now time.Time
+}
+
+func (clock *fakeClock) Now() time.Time {
+	return clock.now
+}
+
+// Returns a lobby with the default options on a fake clock
+func newLobby() (*matchmaking.Lobby, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2026, time.March, 2, 20, 0, 0, 0, time.UTC)}
+	return matchmaking.NewLobby(matchmaking.DefaultOptions(), clock), clock
+}
+
+// Queues a standard 5 choices request, failing the test on errors
+func join(t *testing.T, lobby *matchmaking.Lobby, player string, rating float64) (matchmaking.Ticket, <-chan matchmaking.Match) {
+	ticket, matched, err := lobby.Join(matchmaking.Request{
+		Player:     player,
+		Rating:     rating,
+		Choices:    5,
+		Difficulty: game.StandardDifficulty,
+	})
+	if err != nil {
+		t.Fatalf("Join(%s) failed: %v", player, err)
+	}
+	return ticket, matched
+}
+
+// Returns the players of a match
+func players(match matchmaking.Match) []string {
+	names := []string{}
+	for _, player := range match.Players {
+		names = append(names, player.Player)
+	}
+	return names
+}
+
+// Returns a setup numbering the rooms
+func numberRooms() func(*matchmaking.Match) error {
+	rooms := 0
+	return func(match *matchmaking.Match) error {
+		rooms++
+		match.Room = fmt.Sprintf("ROOM%d", rooms)
+		return nil
+	}
+}
+
+func TestLobby(t *testing.T) {
+	t.Parallel()
+
+	lobby, clock := newLobby()
+	setup := numberRooms()
+	_, alice := join(t, lobby, "alice", 1500)
+	clock.now = clock.now.Add(time.Second)
+	bob, _ := join(t, lobby, "bob", 1550)
+	join(t, lobby, "carol", 1900)
+	if _, _, err := lobby.Join(matchmaking.Request{Player: "alice", Choices: 5}); !errors.Is(err, matchmaking.ErrAlreadyQueued) {
+		t.Errorf("Expected ErrAlreadyQueued, got %v", err)
+	}
+	if _, _, err := lobby.Join(matchmaking.Request{Player: "dave", Choices: 7}); !errors.Is(err, matchmaking.ErrInvalidChoices) {
+		t.Errorf("Expected ErrInvalidChoices, got %v", err)
+	}
+
+	// Waiting for more players
+	if matches, err := lobby.Tick(setup); err != nil || len(matches) != 0 {
+		t.Fatalf("Tick() = (%+v, %v), expected no matches before the fill wait", matches, err)
+	}
+	// Alice and bob are close enough, carol is too far
+	clock.now = clock.now.Add(matchmaking.DefaultOptions().FillWait)
+	matches, err := lobby.Tick(setup)
+	if err != nil || len(matches) != 1 || !slices.Equal(players(matches[0]), []string{"alice", "bob"}) || matches[0].Room != "ROOM1" {
+		t.Fatalf("Tick() = (%+v, %v), expected alice and bob in ROOM1", matches, err)
+	}
+	select {
+	case match := <-alice:
+		if match.Room != "ROOM1" || match.Choices != 5 || match.Difficulty != game.StandardDifficulty {
+			t.Fatalf("Unexpected match for alice %+v", match)
+		}
+	default:
+		t.Fatalf("Expected alice to be notified")
+	}
+	if lobby.Len() != 1 {
+		t.Fatalf("Expected only carol to be waiting, %d tickets", lobby.Len())
+	}
+	// Bob waits on his ticket after the match
+	waiting, err := lobby.Wait(bob.Id)
+	if err != nil {
+		t.Fatalf("Wait() failed: %v", err)
+	}
+	if match, ok := <-waiting; !ok || match.Room != "ROOM1" {
+		t.Fatalf("Expected bob to get the match, got %+v", match)
+	}
+
+	// The spread widens while carol waits
+	join(t, lobby, "dave", 1500)
+	clock.now = clock.now.Add(time.Minute)
+	if matches, _ = lobby.Tick(setup); len(matches) != 0 {
+		t.Fatalf("Expected carol and dave to be too far apart, got %+v", matches)
+	}
+	clock.now = clock.now.Add(90 * time.Second)
+	if matches, _ = lobby.Tick(setup); len(matches) != 1 || !slices.Equal(players(matches[0]), []string{"carol", "dave"}) {
+		t.Fatalf("Expected carol and dave to be matched, got %+v", matches)
+	}
+}
+
+func TestLobbyFullMatches(t *testing.T) {
+	t.Parallel()
+
+	lobby, clock := newLobby()
+	setup := numberRooms()
+	// Full matches start right away, with the closest ratings
+	for i, rating := range []float64{1500, 1590, 1510, 1520, 1530, 1400} {
+		join(t, lobby, fmt.Sprintf("p%d", i), rating)
+		clock.now = clock.now.Add(time.Millisecond)
+	}
+	other, _, err := lobby.Join(matchmaking.Request{Player: "hard", Rating: 1500, Choices: 5, Difficulty: game.HardDifficulty})
+	if err != nil {
+		t.Fatalf("Join failed: %v", err)
+	}
+	matches, err := lobby.Tick(setup)
+	if err != nil || len(matches) != 1 || !slices.Equal(players(matches[0]), []string{"p0", "p2", "p3", "p4"}) {
+		t.Fatalf("Tick() = (%+v, %v), expected p0, p2, p3 and p4", matches, err)
+	}
+
+	// Failed setups keep the players waiting
+	clock.now = clock.now.Add(time.Minute)
+	failure := errors.New("no room")
+	if matches, err = lobby.Tick(func(*matchmaking.Match) error { return failure }); !errors.Is(err, failure) || len(matches) != 0 || lobby.Len() != 3 {
+		t.Fatalf("Tick() = (%+v, %v), expected the setup error", matches, err)
+	}
+	if matches, _ = lobby.Tick(setup); len(matches) != 1 || !slices.Equal(players(matches[0]), []string{"p1", "p5"}) {
+		t.Fatalf("Expected p1 and p5 to be matched, got %+v", matches)
+	}
+
+	// Different preferences are never grouped, tickets expire
+	if _, err = lobby.Get(other.Id); err != nil {
+		t.Fatalf("Expected the hard ticket to be waiting, got %v", err)
+	}
+	_, matched := join(t, lobby, "late", 1500)
+	clock.now = clock.now.Add(matchmaking.DefaultOptions().MaxWait)
+	if matches, _ = lobby.Tick(setup); len(matches) != 0 || lobby.Len() != 0 {
+		t.Fatalf("Expected the tickets to expire, got %+v and %d tickets", matches, lobby.Len())
+	}
+	if _, ok := <-matched; ok {
+		t.Errorf("Expected the channel of an expired ticket to be closed")
+	}
+	if err = lobby.Leave(other.Id); !errors.Is(err, matchmaking.ErrTicketNotFound) {
+		t.Errorf("Expected ErrTicketNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,274 @@
+package rating
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stefanovazzocell/TuringMachine/src/kv"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
+)
+
+const (
+	// The rating of new players
+	InitialRating = 1500.0
+	// A player rated Scale points above another is expected to place ahead
+	// 10 times out of 11
+	Scale = 400.0
+	// The maximum change of a rating in a match
+	KFactor = 32.0
+	// Players with fewer matches have their rating change twice as fast
+	ProvisionalMatches = 10
+
+	ratingPrefix  = "rating/player/"
+	historyPrefix = "rating/history/"
+	matchPrefix   = "rating/match/"
+)
+
+var (
+	// Error returned when a match has fewer than two players to rate
+	ErrNotEnoughPlayers = errors.New("at least two players are needed to rate a match")
+	// Error returned when a match is rated twice
+	ErrAlreadyRated = errors.New("the match was already rated")
+	// Error returned when a player appears twice in a match
+	ErrDuplicatePlayer = errors.New("the player is listed twice in the match")
+)
+
+// The skill rating of a player
+type Rating struct {
+	Player  string    `json:"player"`
+	Rating  float64   `json:"rating"`
+	Matches int       `json:"matches"`
+	Peak    float64   `json:"peak"`
+	Updated time.Time `json:"updated"`
+}
+
+// Returns true while the rating is based on few matches
+func (rating Rating) Provisional() bool {
+	return rating.Matches < ProvisionalMatches
+}
+
+// The finishing position of a player in a match
+type Placement struct {
+	Player string
+	// Starting from 1, tied players share a rank
+	Rank int
+	// True if the player solved the puzzle
+	Solved bool
+}
+
+// A change of rating after a match
+type Change struct {
+	Match  string    `json:"match"`
+	Player string    `json:"player"`
+	Time   time.Time `json:"time"`
+	Before float64   `json:"before"`
+	After  float64   `json:"after"`
+	Rank   int       `json:"rank"`
+	// The number of players rated in the match
+	Players int `json:"players"`
+	// The rating of the puzzle (see PuzzleRating)
+	Puzzle float64 `json:"puzzle"`
+}
+
+// Returns the rating of a puzzle: standard 5 choices games are rated like a
+// new player, each difficulty level and each choice adds (or removes) 100.
+func PuzzleRating(g game.Game) float64 {
+	difficulty := float64(g.Difficulty()) - float64(game.StandardDifficulty)
+	return InitialRating + 100*difficulty + 100*float64(g.NumberOfChoices()-5)
+}
+
+// The ratings of the players, stored in a backend. Safe for concurrent use.
+//
+// Matches are rated with a multiplayer Elo: each player is compared to every
+// other one (winning, losing or drawing by rank) and to the puzzle itself
+// (winning if they solved it). The expected and actual scores are averaged
+// over these comparisons, so the rating moves by up to KFactor per match
+// regardless of the number of players, and solving a hard puzzle is worth
+// more than solving an easy one.
+type Ratings struct {
+	backend kv.Backend
+	// Held while rating, so concurrent matches don't lose updates
+	lock sync.Mutex
+}
+
+// Returns the ratings stored in a backend
+func New(backend kv.Backend) *Ratings {
+	return &Ratings{backend: backend}
+}
+
+// Returns the rating of a player, InitialRating if it never played a rated
+// match
+func (ratings *Ratings) Get(player string) (Rating, error) {
+	rating := Rating{}
+	err := ratings.get(ratingPrefix+player, &rating)
+	if errors.Is(err, kv.ErrNotFound) {
+		return Rating{Player: player, Rating: InitialRating, Peak: InitialRating}, nil
+	}
+	return rating, err
+}
+
+// Returns the ratings of several players (e.g. for matchmaking), by player
+func (ratings *Ratings) GetAll(players ...string) (map[string]Rating, error) {
+	all := make(map[string]Rating, len(players))
+	for _, player := range players {
+		rating, err := ratings.Get(player)
+		if err != nil {
+			return nil, err
+		}
+		all[player] = rating
+	}
+	return all, nil
+}
+
+// Returns the rating changes of a player since a time (all if zero), newest
+// first, up to limit
+func (ratings *Ratings) History(player string, since time.Time, limit int) ([]Change, error) {
+	keys, err := ratings.backend.Keys(historyPrefix + player + "/")
+	if err != nil {
+		return nil, err
+	}
+	// The keys sort by time
+	slices.Sort(keys)
+	slices.Reverse(keys)
+	changes := []Change{}
+	for _, key := range keys {
+		if len(changes) >= limit {
+			break
+		}
+		change := Change{}
+		if err = ratings.get(key, &change); errors.Is(err, kv.ErrNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if change.Time.Before(since) {
+			break
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// Rates a match (by unique id) on a game from the finishing order of the
+// players, returns the rating changes in the order of the placements
+func (ratings *Ratings) Rate(match string, g game.Game, placements []Placement, at time.Time) ([]Change, error) {
+	if len(placements) < 2 {
+		return nil, ErrNotEnoughPlayers
+	}
+	for i, placement := range placements {
+		for _, other := range placements[:i] {
+			if other.Player == placement.Player {
+				return nil, ErrDuplicatePlayer
+			}
+		}
+	}
+	ratings.lock.Lock()
+	defer ratings.lock.Unlock()
+	if _, err := ratings.backend.Get(matchPrefix + match); err == nil {
+		return nil, ErrAlreadyRated
+	} else if !errors.Is(err, kv.ErrNotFound) {
+		return nil, err
+	}
+
+	before := make([]Rating, len(placements))
+	for i, placement := range placements {
+		var err error
+		if before[i], err = ratings.Get(placement.Player); err != nil {
+			return nil, err
+		}
+	}
+	puzzle := PuzzleRating(g)
+	changes := make([]Change, len(placements))
+	for i, placement := range placements {
+		expected, actual := 0.0, 0.0
+		for j, other := range placements {
+			if i == j {
+				continue
+			}
+			expected += expectedScore(before[i].Rating, before[j].Rating)
+			switch {
+			case placement.Rank < other.Rank:
+				actual++
+			case placement.Rank == other.Rank:
+				actual += 0.5
+			}
+		}
+		expected += expectedScore(before[i].Rating, puzzle)
+		if placement.Solved {
+			actual++
+		}
+		k := KFactor
+		if before[i].Provisional() {
+			k *= 2
+		}
+		// Compared to the other players and the puzzle
+		comparisons := float64(len(placements))
+		changes[i] = Change{
+			Match:   match,
+			Player:  placement.Player,
+			Time:    at,
+			Before:  before[i].Rating,
+			After:   before[i].Rating + k*(actual-expected)/comparisons,
+			Rank:    placement.Rank,
+			Players: len(placements),
+			Puzzle:  puzzle,
+		}
+	}
+
+	if err := ratings.put(matchPrefix+match, at); err != nil {
+		return nil, err
+	}
+	for i, change := range changes {
+		rating := before[i]
+		rating.Rating = change.After
+		rating.Matches++
+		rating.Peak = max(rating.Peak, rating.Rating)
+		rating.Updated = at
+		if err := ratings.put(historyKey(change), change); err != nil {
+			return nil, err
+		}
+		if err := ratings.put(ratingPrefix+change.Player, rating); err != nil {
+			return nil, err
+		}
+	}
+	return changes, nil
+}
+
+/*
+* Helpers
+**/
+
+// Returns the expected score of a player against another, from 0 to 1
+func expectedScore(rating, other float64) float64 {
+	return 1 / (1 + math.Pow(10, (other-rating)/Scale))
+}
+
+// Returns the key of a change in the history of a player, sorting by time
+func historyKey(change Change) string {
+	match := strings.ReplaceAll(change.Match, "/", "_")
+	return fmt.Sprintf("%s%s/%020d-%s", historyPrefix, change.Player, change.Time.UnixNano(), match)
+}
+
+// Stores a value as JSON
+func (ratings *Ratings) put(key string, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return ratings.backend.Put(key, data, 0)
+}
+
+// Reads a JSON value
+func (ratings *Ratings) get(key string, value any) error {
+	data, err := ratings.backend.Get(key)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, value)
+}
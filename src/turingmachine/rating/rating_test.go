@@ -0,0 +1,107 @@
+package rating_test
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stefanovazzocell/TuringMachine/src/kv"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/rating"
+)
+
+// Returns a random game with a difficulty
+func randomGame(t *testing.T, difficulty game.Difficulty) game.Game {
+	g, err := game.RandomSolvableGame(5, difficulty)
+	if err != nil {
+		t.Fatalf("Failed to generate random game: %v", err)
+	}
+	return g
+}
+
+func TestRate(t *testing.T) {
+	t.Parallel()
+
+	backend, _ := kv.OpenLog("")
+	ratings := rating.New(backend)
+	g := randomGame(t, game.StandardDifficulty)
+	start := time.Date(2026, time.March, 2, 20, 0, 0, 0, time.UTC)
+
+	changes, err := ratings.Rate("match-1", g, []rating.Placement{
+		{Player: "alice", Rank: 1, Solved: true},
+		{Player: "bob", Rank: 2, Solved: true},
+		{Player: "carol", Rank: 3},
+	}, start)
+	if err != nil || len(changes) != 3 {
+		t.Fatalf("Rate() = (%+v, %v), expected 3 changes", changes, err)
+	}
+	if changes[0].After <= rating.InitialRating || changes[2].After >= rating.InitialRating ||
+		changes[0].After <= changes[1].After || changes[1].After <= changes[2].After {
+		t.Fatalf("Expected the ratings to follow the finishing order, got %+v", changes)
+	}
+	if changes[0].After-changes[0].Before > 2*rating.KFactor {
+		t.Errorf("Expected a change of at most %v, got %+v", 2*rating.KFactor, changes[0])
+	}
+	if _, err = ratings.Rate("match-1", g, []rating.Placement{{Player: "alice", Rank: 1}, {Player: "bob", Rank: 2}}, start); !errors.Is(err, rating.ErrAlreadyRated) {
+		t.Errorf("Expected ErrAlreadyRated, got %v", err)
+	}
+	if _, err = ratings.Rate("match-2", g, []rating.Placement{{Player: "alice", Rank: 1}}, start); !errors.Is(err, rating.ErrNotEnoughPlayers) {
+		t.Errorf("Expected ErrNotEnoughPlayers, got %v", err)
+	}
+	if _, err = ratings.Rate("match-2", g, []rating.Placement{{Player: "alice", Rank: 1}, {Player: "alice", Rank: 2}}, start); !errors.Is(err, rating.ErrDuplicatePlayer) {
+		t.Errorf("Expected ErrDuplicatePlayer, got %v", err)
+	}
+
+	// A week later, alice and bob tie
+	if _, err = ratings.Rate("match-2", g, []rating.Placement{
+		{Player: "alice", Rank: 1, Solved: true},
+		{Player: "bob", Rank: 1, Solved: true},
+	}, start.AddDate(0, 0, 7)); err != nil {
+		t.Fatalf("Rate() failed: %v", err)
+	}
+	alice, err := ratings.Get("alice")
+	if err != nil || alice.Matches != 2 || alice.Peak < alice.Rating || !alice.Provisional() {
+		t.Fatalf("Unexpected rating for alice %+v (%v)", alice, err)
+	}
+	history, err := ratings.History("alice", time.Time{}, 10)
+	if err != nil || len(history) != 2 || history[0].Match != "match-2" || history[0].After != alice.Rating ||
+		history[1].After != history[0].Before {
+		t.Fatalf("Unexpected history %+v (%v)", history, err)
+	}
+	if history, _ = ratings.History("alice", start.AddDate(0, 0, 1), 10); len(history) != 1 {
+		t.Errorf("Expected a single change in the last week, got %+v", history)
+	}
+	if history, _ = ratings.History("alice", time.Time{}, 1); len(history) != 1 {
+		t.Errorf("Expected the limit to apply, got %+v", history)
+	}
+
+	all, err := ratings.GetAll("alice", "dave")
+	if err != nil || all["alice"] != alice || all["dave"].Rating != rating.InitialRating || all["dave"].Matches != 0 {
+		t.Fatalf("Unexpected ratings %+v (%v)", all, err)
+	}
+}
+
+func TestRateDifficulty(t *testing.T) {
+	t.Parallel()
+
+	easy, hard := randomGame(t, game.EasyDifficulty), randomGame(t, game.HardDifficulty)
+	if rating.PuzzleRating(easy) >= rating.PuzzleRating(hard) {
+		t.Fatalf("Expected hard puzzles to be rated higher than easy ones")
+	}
+	// The same finishing order is worth more on a harder puzzle
+	delta := func(g game.Game) float64 {
+		backend, _ := kv.OpenLog("")
+		changes, err := rating.New(backend).Rate("match", g, []rating.Placement{
+			{Player: "alice", Rank: 1, Solved: true},
+			{Player: "bob", Rank: 2},
+		}, time.Now())
+		if err != nil {
+			t.Fatalf("Rate() failed: %v", err)
+		}
+		return changes[0].After - changes[0].Before
+	}
+	if easyDelta, hardDelta := delta(easy), delta(hard); hardDelta <= easyDelta || math.IsNaN(hardDelta) {
+		t.Fatalf("Expected solving a hard puzzle to be worth more, got %v (easy) and %v (hard)", easyDelta, hardDelta)
+	}
+}
@@ -0,0 +1,140 @@
+package replay
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/bot"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
+)
+
+const (
+	// The maximum number of rounds analyzed, finding the best queries of a
+	// round is expensive
+	MaxAnalyzedRounds = 50
+)
+
+var (
+	// Error returned when analyzing a replay with more than MaxAnalyzedRounds
+	ErrTooManyRounds = errors.New("the replay has too many rounds to analyze")
+)
+
+// How a replay compares with the most informative queries, round by round.
+// Information is measured in bits over the combinations of laws the player
+// could not rule out.
+type Analysis struct {
+	// The rounds with queries
+	Rounds []RoundAnalysis
+	// The round after which the code was uniquely determined (0 if it was
+	// from the start), -1 if it never was
+	Determined int
+	// The number of queries with a result already known
+	Wasted int
+}
+
+// The analysis of a round
+type RoundAnalysis struct {
+	// The round, starting from 1
+	Round    int
+	Proposal game.Code
+	// The verifiers queried, in order
+	Verifiers []int
+	// The combinations of laws and the codes still possible before and after
+	// the round
+	CombinationsBefore int
+	CombinationsAfter  int
+	SolutionsBefore    int
+	SolutionsAfter     int
+	// The information the round gave
+	Gained float64
+	// The information the round was expected to give, before the results
+	Expected float64
+	// The most informative queries available in this round (see bot.Greedy)
+	// and their expected information, nothing if the code was already
+	// determined. This is not an optimal plan for the rest of the game.
+	Best          float64
+	BestProposal  game.Code
+	BestVerifiers []int
+	// The verifiers queried whose result was already known
+	Wasted []int
+}
+
+// Returns the analysis of a replay of a game.
+// The replay should be validated first.
+func (replay Replay) Analyze(g game.Game) (Analysis, error) {
+	if len(replay.Rounds) > MaxAnalyzedRounds {
+		return Analysis{}, ErrTooManyRounds
+	}
+	candidates := game.NewCandidates(g)
+	analysis := Analysis{Rounds: []RoundAnalysis{}, Determined: -1}
+	if _, ok := candidates.Solution(); ok {
+		analysis.Determined = 0
+	}
+	for i, round := range replay.Rounds {
+		if len(round.Queries) == 0 {
+			continue
+		}
+		proposal, err := roundProposal(round)
+		if err != nil {
+			return Analysis{}, fmt.Errorf("%w: round %d: %w", ErrInvalidRound, i+1, err)
+		}
+		current := RoundAnalysis{
+			Round:              i + 1,
+			Proposal:           proposal,
+			Verifiers:          []int{},
+			CombinationsBefore: candidates.Len(),
+			SolutionsBefore:    len(candidates.Solutions()),
+			Wasted:             []int{},
+		}
+		for _, query := range round.Queries {
+			if query.Verifier < 0 || query.Verifier >= candidates.Verifiers() {
+				return Analysis{}, fmt.Errorf("%w: round %d: %w", ErrInvalidRound, i+1, game.ErrRoundInvalidVerifier)
+			}
+			current.Verifiers = append(current.Verifiers, query.Verifier)
+		}
+		current.Expected = information(candidates, proposal, current.Verifiers)
+		if _, ok := candidates.Solution(); !ok {
+			current.BestProposal, current.BestVerifiers = bot.Greedy{}.Choose(candidates)
+			current.Best = information(candidates, current.BestProposal, current.BestVerifiers)
+		}
+
+		for _, query := range round.Queries {
+			if !candidates.Splits(proposal, query.Verifier) {
+				current.Wasted = append(current.Wasted, query.Verifier)
+			}
+			candidates = candidates.Filter(game.Query{
+				Proposal: proposal,
+				Verifier: query.Verifier,
+				Result:   query.Result,
+			})
+		}
+		current.CombinationsAfter = candidates.Len()
+		current.SolutionsAfter = len(candidates.Solutions())
+		if current.CombinationsAfter > 0 {
+			current.Gained = math.Log2(float64(current.CombinationsBefore) / float64(current.CombinationsAfter))
+		}
+		analysis.Wasted += len(current.Wasted)
+		if _, ok := candidates.Solution(); ok && analysis.Determined == -1 {
+			analysis.Determined = i + 1
+		}
+		analysis.Rounds = append(analysis.Rounds, current)
+	}
+	return analysis, nil
+}
+
+/*
+* Helpers
+**/
+
+// Returns the expected information of testing a proposal against some
+// verifiers: the entropy of the results
+func information(candidates game.Candidates, proposal game.Code, verifiers []int) float64 {
+	total := float64(candidates.Len())
+	entropy := 0.0
+	for _, outcome := range candidates.Partition(proposal, verifiers) {
+		p := float64(outcome.Combinations) / total
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
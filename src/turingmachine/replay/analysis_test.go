@@ -0,0 +1,73 @@
+package replay_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/replay"
+)
+
+func TestAnalyze(t *testing.T) {
+	t.Parallel()
+
+	g, r := playedReplay(t)
+	analysis, err := r.Analyze(g)
+	if err != nil {
+		t.Fatalf("Failed to analyze replay: %v", err)
+	}
+	if len(analysis.Rounds) == 0 || analysis.Determined < 1 || analysis.Determined > len(r.Rounds) {
+		t.Fatalf("Unexpected analysis %+v", analysis)
+	}
+	last := analysis.Rounds[len(analysis.Rounds)-1]
+	if last.SolutionsAfter != 1 {
+		t.Fatalf("Expected the code to be determined after the last round, got %+v", last)
+	}
+	for i, round := range analysis.Rounds {
+		if round.Gained < 0 || round.CombinationsAfter > round.CombinationsBefore ||
+			round.SolutionsAfter > round.SolutionsBefore || round.Expected > round.Best+1e-9 ||
+			len(round.Verifiers) == 0 || len(round.BestVerifiers) == 0 {
+			t.Fatalf("Unexpected round %+v", round)
+		}
+		if i > 0 && round.CombinationsBefore != analysis.Rounds[i-1].CombinationsAfter {
+			t.Fatalf("Expected round %d to start with %d combinations, got %d",
+				round.Round, analysis.Rounds[i-1].CombinationsAfter, round.CombinationsBefore)
+		}
+	}
+
+	// Repeating a query in another round is wasted
+	first := r.Rounds[0]
+	proposal, err := game.CodeFromString(first.Proposal)
+	if err != nil {
+		t.Fatalf("Failed to parse proposal: %v", err)
+	}
+	repeated, err := replay.New(g, "XXXXX", replay.ModeSession, game.PoundSymbol, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to create replay: %v", err)
+	}
+	repeated.Query(proposal, first.Queries[0].Verifier, first.Queries[0].Result, time.Now())
+	repeated.NextRound(time.Now())
+	repeated.NextRound(time.Now())
+	repeated.Query(proposal, first.Queries[0].Verifier, first.Queries[0].Result, time.Now())
+	if _, err = repeated.Validate(g); err != nil {
+		t.Fatalf("Failed to validate replay: %v", err)
+	}
+	analysis, err = repeated.Analyze(g)
+	if err != nil {
+		t.Fatalf("Failed to analyze replay: %v", err)
+	}
+	if len(analysis.Rounds) != 2 || analysis.Rounds[1].Round != 3 || analysis.Wasted != 1 ||
+		!slices.Equal(analysis.Rounds[1].Wasted, []int{first.Queries[0].Verifier}) ||
+		analysis.Rounds[1].Gained != 0 || (analysis.Determined == 1) != (analysis.Rounds[0].SolutionsAfter == 1) {
+		t.Fatalf("Unexpected analysis %+v", analysis)
+	}
+
+	for range replay.MaxAnalyzedRounds {
+		repeated.NextRound(time.Now())
+	}
+	if _, err = repeated.Analyze(g); !errors.Is(err, replay.ErrTooManyRounds) {
+		t.Fatalf("Expected ErrTooManyRounds, got %v", err)
+	}
+}
@@ -0,0 +1,275 @@
+package replay
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
+)
+
+const (
+	// The version of the replay format
+	Version = 1
+
+	// The modes a game can be played in
+	ModeSession = "session"
+	ModeRoom    = "room"
+
+	// The name of the player when replaying
+	player = "replay"
+)
+
+var (
+	ErrUnsupportedVersion = errors.New("unsupported replay version")
+	ErrInvalidMode        = errors.New("invalid replay mode")
+	ErrInvalidSymbol      = errors.New("invalid verification card symbol")
+	// Error returned when the cards of a replay don't match the game
+	ErrWrongGame = errors.New("the replay cards do not match the game")
+	// Error returned when a replay breaks the rules of the game
+	ErrInvalidRound = errors.New("the replay breaks the rules of the game")
+	// Error returned when a recorded result (or guess) does not match the
+	// laws of the game
+	ErrTampered = errors.New("the replay results do not match the game")
+	// Error returned when the timestamps of a replay go back in time
+	ErrInvalidTime = errors.New("the replay timestamps are not in order")
+	// Error returned when reconstructing a step that does not exist
+	ErrInvalidStep = errors.New("the replay step does not exist")
+)
+
+// A game played by a player, with the queries in the order they were made.
+// Replays are JSON encoded, see Validate to check one against its game.
+type Replay struct {
+	Version int    `json:"version"`
+	GameId  string `json:"game_id"`
+	// One of ModeSession or ModeRoom
+	Mode string `json:"mode"`
+	// The symbol the verification cards are shown with (one of
+	// game.VerificationSymbols)
+	Symbol    string   `json:"symbol"`
+	Criterias []int    `json:"criterias"`
+	Verifiers []string `json:"verifiers"`
+	Rounds    []Round  `json:"rounds"`
+	// Nil until the player guesses
+	Guess   *Guess    `json:"guess,omitempty"`
+	Started time.Time `json:"started"`
+}
+
+// The queries of a round, all testing the same proposal
+type Round struct {
+	// Empty if no query was made
+	Proposal string    `json:"proposal"`
+	Queries  []Query   `json:"queries"`
+	Started  time.Time `json:"started"`
+}
+
+// A verifier asked in a round, and its result
+type Query struct {
+	// The index of the verifier in Replay.Verifiers
+	Verifier int       `json:"verifier"`
+	Result   bool      `json:"result"`
+	Time     time.Time `json:"time"`
+}
+
+// The final guess of the player
+type Guess struct {
+	Code    string    `json:"code"`
+	Correct bool      `json:"correct"`
+	Time    time.Time `json:"time"`
+}
+
+// What the player could deduce after some queries of a replay
+type Step struct {
+	// The number of queries made, starting from 0 before the first one
+	Step int
+	// The round of the last query, starting from 1
+	Round int
+	// The last query, nil for step 0
+	Proposal game.Code
+	Query    *Query
+	// The combinations of laws the player could not rule out yet
+	Candidates game.Candidates
+}
+
+// Returns an empty replay for a game, starting with the first round
+func New(g game.Game, gameId string, mode string, symbol string, started time.Time) (Replay, error) {
+	if mode != ModeSession && mode != ModeRoom {
+		return Replay{}, ErrInvalidMode
+	}
+	if !slices.Contains(game.VerificationSymbols[:], symbol) {
+		return Replay{}, ErrInvalidSymbol
+	}
+	criterias, verifiers, _ := g.GetCardsWithSymbol(symbol)
+	return Replay{
+		Version:   Version,
+		GameId:    gameId,
+		Mode:      mode,
+		Symbol:    symbol,
+		Criterias: criterias,
+		Verifiers: verifiers,
+		Rounds:    []Round{{Queries: []Query{}, Started: started}},
+		Started:   started,
+	}, nil
+}
+
+// Records a query in the current round
+func (replay *Replay) Query(proposal game.Code, verifier int, result bool, at time.Time) {
+	round := &replay.Rounds[len(replay.Rounds)-1]
+	round.Proposal = proposal.String()
+	round.Queries = append(round.Queries, Query{
+		Verifier: verifier,
+		Result:   result,
+		Time:     at,
+	})
+}
+
+// Records the start of a new round
+func (replay *Replay) NextRound(at time.Time) {
+	replay.Rounds = append(replay.Rounds, Round{Queries: []Query{}, Started: at})
+}
+
+// Records the guess of the player, in the current round
+func (replay *Replay) MakeGuess(code game.Code, correct bool, at time.Time) {
+	replay.Guess = &Guess{
+		Code:    code.String(),
+		Correct: correct,
+		Time:    at,
+	}
+}
+
+// Returns the number of queries in the replay
+func (replay Replay) Queries() int {
+	queries := 0
+	for _, round := range replay.Rounds {
+		queries += len(round.Queries)
+	}
+	return queries
+}
+
+// Checks a replay against its game: the cards, the rules, the results of the
+// queries and the guess, and the order of the timestamps.
+// Returns the player at the end of the replay.
+func (replay Replay) Validate(g game.Game) (game.Player, error) {
+	if replay.Version != Version {
+		return game.Player{}, ErrUnsupportedVersion
+	}
+	if replay.Mode != ModeSession && replay.Mode != ModeRoom {
+		return game.Player{}, ErrInvalidMode
+	}
+	if !slices.Contains(game.VerificationSymbols[:], replay.Symbol) {
+		return game.Player{}, ErrInvalidSymbol
+	}
+	criterias, verifiers, _ := g.GetCardsWithSymbol(replay.Symbol)
+	if !slices.Equal(criterias, replay.Criterias) || !slices.Equal(verifiers, replay.Verifiers) {
+		return game.Player{}, ErrWrongGame
+	}
+	if len(replay.Rounds) == 0 {
+		return game.Player{}, fmt.Errorf("%w: no rounds", ErrInvalidRound)
+	}
+
+	match, err := game.NewMatch(g, player)
+	if err != nil {
+		return game.Player{}, err
+	}
+	last := replay.Started
+	inOrder := func(at time.Time) error {
+		if at.Before(last) {
+			return ErrInvalidTime
+		}
+		last = at
+		return nil
+	}
+	for i, round := range replay.Rounds {
+		if i > 0 {
+			if err = match.EndTurn(player); err != nil {
+				return game.Player{}, fmt.Errorf("%w: round %d: %w", ErrInvalidRound, i+1, err)
+			}
+		}
+		if err = inOrder(round.Started); err != nil {
+			return game.Player{}, err
+		}
+		proposal, err := roundProposal(round)
+		if err != nil {
+			return game.Player{}, fmt.Errorf("%w: round %d: %w", ErrInvalidRound, i+1, err)
+		}
+		for _, query := range round.Queries {
+			result, err := match.Query(player, proposal, query.Verifier)
+			if err != nil {
+				return game.Player{}, fmt.Errorf("%w: round %d: %w", ErrInvalidRound, i+1, err)
+			}
+			if result != query.Result {
+				return game.Player{}, fmt.Errorf("%w: round %d, verifier %d", ErrTampered, i+1, query.Verifier)
+			}
+			if err = inOrder(query.Time); err != nil {
+				return game.Player{}, err
+			}
+		}
+	}
+	if replay.Guess != nil {
+		code, err := game.CodeFromString(replay.Guess.Code)
+		if err != nil {
+			return game.Player{}, fmt.Errorf("%w: guess: %w", ErrInvalidRound, err)
+		}
+		correct, err := match.Guess(player, code)
+		if err != nil {
+			return game.Player{}, fmt.Errorf("%w: guess: %w", ErrInvalidRound, err)
+		}
+		if correct != replay.Guess.Correct {
+			return game.Player{}, fmt.Errorf("%w: guess", ErrTampered)
+		}
+		if err = inOrder(replay.Guess.Time); err != nil {
+			return game.Player{}, err
+		}
+	}
+	return match.Player(player)
+}
+
+// Returns what the player could deduce after a number of queries (from 0 to
+// Queries()), knowing only the criteria cards of the game and the results.
+// The replay should be validated first.
+func (replay Replay) Step(g game.Game, step int) (Step, error) {
+	if step < 0 || step > replay.Queries() {
+		return Step{}, ErrInvalidStep
+	}
+	state := Step{Round: 1, Candidates: game.NewCandidates(g)}
+	for i, round := range replay.Rounds {
+		if state.Step == step {
+			break
+		}
+		proposal, err := roundProposal(round)
+		if err != nil {
+			return Step{}, fmt.Errorf("%w: round %d: %w", ErrInvalidRound, i+1, err)
+		}
+		for _, query := range round.Queries {
+			if state.Step == step {
+				break
+			}
+			if query.Verifier < 0 || query.Verifier >= g.NumberOfChoices() {
+				return Step{}, fmt.Errorf("%w: round %d: %w", ErrInvalidRound, i+1, game.ErrRoundInvalidVerifier)
+			}
+			state.Step++
+			state.Round = i + 1
+			state.Proposal = proposal
+			state.Query = &query
+			state.Candidates = state.Candidates.Filter(game.Query{
+				Proposal: proposal,
+				Verifier: query.Verifier,
+				Result:   query.Result,
+			})
+		}
+	}
+	return state, nil
+}
+
+/*
+* Helpers
+**/
+
+// Returns the proposal of a round, or an invalid code if it has no queries
+func roundProposal(round Round) (game.Code, error) {
+	if len(round.Queries) == 0 {
+		return 0, nil
+	}
+	return game.CodeFromString(round.Proposal)
+}
@@ -0,0 +1,144 @@
+package replay_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/bot"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/replay"
+)
+
+// Returns a random game and the replay of a bot solving it with some queries
+func playedReplay(t *testing.T) (game.Game, replay.Replay) {
+	var g game.Game
+	player := game.Player{}
+	for player.Checks() == 0 {
+		var err error
+		g, err = game.RandomSolvableGame(5, game.StandardDifficulty)
+		if err != nil {
+			t.Fatalf("Failed to generate random game: %v", err)
+		}
+		player, err = bot.Play(g, bot.Greedy{})
+		if err != nil {
+			t.Fatalf("Failed to play game: %v", err)
+		}
+	}
+	now := time.Now()
+	r, err := replay.New(g, "XXXXX", replay.ModeSession, game.PoundSymbol, now)
+	if err != nil {
+		t.Fatalf("Failed to create replay: %v", err)
+	}
+	for i, round := range player.Rounds {
+		if i > 0 {
+			r.NextRound(now)
+		}
+		for _, query := range round.Queries {
+			now = now.Add(time.Second)
+			r.Query(query.Proposal, query.Verifier, query.Result, now)
+		}
+	}
+	r.MakeGuess(player.Guess, player.Solved, now.Add(time.Second))
+	return g, r
+}
+
+// Returns a deep copy of a replay
+func cloneReplay(t *testing.T, r replay.Replay) replay.Replay {
+	raw, err := json.Marshal(r)
+	if err != nil {
+		t.Fatalf("Failed to encode replay: %v", err)
+	}
+	clone := replay.Replay{}
+	if err = json.Unmarshal(raw, &clone); err != nil {
+		t.Fatalf("Failed to decode replay: %v", err)
+	}
+	return clone
+}
+
+func TestReplay(t *testing.T) {
+	t.Parallel()
+
+	g, r := playedReplay(t)
+	r = cloneReplay(t, r)
+	player, err := r.Validate(g)
+	if err != nil {
+		t.Fatalf("Failed to validate replay: %v", err)
+	}
+	if !player.Solved || player.Checks() != r.Queries() || player.RoundsPlayed() != len(r.Rounds) {
+		t.Fatalf("Unexpected player %+v for replay %+v", player, r)
+	}
+
+	// The deduction state at each step
+	solution, _ := g.Solve()
+	previous := 0
+	for step := 0; step <= r.Queries(); step++ {
+		state, err := r.Step(g, step)
+		if err != nil {
+			t.Fatalf("Failed to get step %d: %v", step, err)
+		}
+		if state.Step != step || (step == 0) != (state.Query == nil) || state.Candidates.Len() == 0 {
+			t.Fatalf("Unexpected state %+v at step %d", state, step)
+		}
+		if step > 0 && state.Candidates.Len() > previous {
+			t.Fatalf("Expected at most %d combinations at step %d, got %d", previous, step, state.Candidates.Len())
+		}
+		previous = state.Candidates.Len()
+	}
+	if state, _ := r.Step(g, r.Queries()); state.Round != len(r.Rounds) {
+		t.Fatalf("Expected the last step in round %d, got %d", len(r.Rounds), state.Round)
+	} else if code, ok := state.Candidates.Solution(); !ok || code != solution {
+		t.Fatalf("Expected solution %s at the last step, got %s (%v)", solution, code, ok)
+	}
+	if _, err = r.Step(g, r.Queries()+1); !errors.Is(err, replay.ErrInvalidStep) {
+		t.Fatalf("Expected ErrInvalidStep, got %v", err)
+	}
+}
+
+func TestReplayTampered(t *testing.T) {
+	t.Parallel()
+
+	g, r := playedReplay(t)
+	other, err := game.RandomSolvableGame(5, game.StandardDifficulty)
+	if err != nil {
+		t.Fatalf("Failed to generate random game: %v", err)
+	}
+	// An empty replay of the same game is valid
+	empty, err := replay.New(g, "XXXXX", replay.ModeRoom, game.SlashSymbol, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to create replay: %v", err)
+	}
+	if _, err = empty.Validate(g); err != nil {
+		t.Fatalf("Failed to validate empty replay: %v", err)
+	}
+
+	tests := map[string]struct {
+		tamper   func(r *replay.Replay)
+		expected error
+	}{
+		"version":  {func(r *replay.Replay) { r.Version = 2 }, replay.ErrUnsupportedVersion},
+		"mode":     {func(r *replay.Replay) { r.Mode = "solo" }, replay.ErrInvalidMode},
+		"symbol":   {func(r *replay.Replay) { r.Symbol = "?" }, replay.ErrInvalidSymbol},
+		"verifier": {func(r *replay.Replay) { r.Verifiers[0] = "#1" }, replay.ErrWrongGame},
+		"result": {func(r *replay.Replay) {
+			r.Rounds[0].Queries[0].Result = !r.Rounds[0].Queries[0].Result
+		}, replay.ErrTampered},
+		"guess":    {func(r *replay.Replay) { r.Guess.Correct = false }, replay.ErrTampered},
+		"proposal": {func(r *replay.Replay) { r.Rounds[0].Proposal = "999" }, replay.ErrInvalidRound},
+		"repeated": {func(r *replay.Replay) {
+			r.Rounds[0].Queries = append(r.Rounds[0].Queries, r.Rounds[0].Queries[0])
+		}, replay.ErrInvalidRound},
+		"time": {func(r *replay.Replay) { r.Guess.Time = r.Started.Add(-time.Second) }, replay.ErrInvalidTime},
+	}
+	for name, test := range tests {
+		tampered := cloneReplay(t, r)
+		test.tamper(&tampered)
+		if _, err := tampered.Validate(g); !errors.Is(err, test.expected) {
+			t.Errorf("Expected %v for a tampered %s, got %v", test.expected, name, err)
+		}
+	}
+	if _, err := r.Validate(other); !errors.Is(err, replay.ErrWrongGame) {
+		t.Fatalf("Expected ErrWrongGame for another game, got %v", err)
+	}
+}
@@ -0,0 +1,142 @@
+package session
+
+import (
+	"time"
+
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
+)
+
+// The type of a session event
+type EventType string
+
+const (
+	// A new round started
+	EventRoundStarted EventType = "round_started"
+	// A proposal was tested against a verifier
+	EventQuery EventType = "query"
+	// The result of the last query
+	EventResult EventType = "result"
+	// The player made a guess
+	EventGuess EventType = "guess"
+	// The session is finished
+	EventGameOver EventType = "game_over"
+)
+
+// Something that happened in a session
+type Event struct {
+	// Sequential, starting from 1
+	Id   int
+	Type EventType
+	// The round the event happened in
+	Round int
+	// For EventQuery and EventResult
+	Proposal game.Code
+	Slot     int
+	// For EventResult
+	Check bool
+	// For EventGuess
+	Guess game.Code
+	// For EventGuess and EventGameOver
+	Won  bool
+	Time time.Time
+}
+
+// Returns the events of a session after a given event id, and true if the
+// session is finished (no more events will follow)
+func (manager *Manager) Events(id string, after int) ([]Event, bool, error) {
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+	s, err := manager.get(id)
+	if err != nil {
+		return nil, false, err
+	}
+	after = min(max(after, 0), len(s.events))
+	events := make([]Event, len(s.events)-after)
+	copy(events, s.events[after:])
+	return events, s.match.Finished(), nil
+}
+
+// Adds a read-only spectator to a session, returns its spectator id.
+// The spectator id only gives access to the session events (see Spectate),
+// without the results until the end if hideResults is set.
+func (manager *Manager) AddSpectator(id string, hideResults bool) (string, error) {
+	spectator, err := newId()
+	if err != nil {
+		return "", err
+	}
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+	s, err := manager.get(id)
+	if err != nil {
+		return "", err
+	}
+	s.spectators[spectator] = hideResults
+	manager.spectators[spectator] = id
+	return spectator, nil
+}
+
+// Returns the id of the session a spectator id was added to (see
+// AddSpectator), and true if the results are hidden from the spectator
+func (manager *Manager) Spectate(spectator string) (string, bool, error) {
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+	id, ok := manager.spectators[spectator]
+	if !ok {
+		return "", false, ErrNotFound
+	}
+	s, err := manager.get(id)
+	if err != nil {
+		return "", false, err
+	}
+	return id, s.spectators[spectator], nil
+}
+
+// Returns a channel notified whenever a session has new events, and a
+// function to unsubscribe
+func (manager *Manager) Subscribe(id string) (<-chan struct{}, func(), error) {
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+	s, err := manager.get(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	updates := make(chan struct{}, 1)
+	s.subscribers[updates] = struct{}{}
+	return updates, func() {
+		manager.lock.Lock()
+		defer manager.lock.Unlock()
+		delete(s.subscribers, updates)
+	}, nil
+}
+
+/*
+* Helpers
+**/
+
+// Records an event and notifies the subscribers, must hold the manager lock
+func (s *state) record(event Event) {
+	event.Id = len(s.events) + 1
+	event.Time = s.updated
+	s.events = append(s.events, event)
+	for updates := range s.subscribers {
+		// Notifications are coalesced, a pending one is enough
+		select {
+		case updates <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Records the end of a round (and the start of the next one, or the end of
+// the game) if the match moved on from a given round, must hold the manager
+// lock
+func (s *state) recordRoundChange(round int) {
+	if s.match.Finished() {
+		p, _ := s.match.Player(player)
+		s.record(Event{Type: EventGameOver, Round: round, Won: p.Solved})
+		return
+	}
+	if s.match.Round() != round {
+		s.record(Event{Type: EventRoundStarted, Round: s.match.Round()})
+	}
+}
@@ -0,0 +1,101 @@
+package session_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/session"
+)
+
+func TestEvents(t *testing.T) {
+	t.Parallel()
+
+	manager := session.NewManager(session.DefaultTTL)
+	g := randomGame(t)
+	code, _ := g.Solve()
+	created, err := manager.Create(g)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	updates, unsubscribe, err := manager.Subscribe(created.Id)
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+	defer unsubscribe()
+
+	// A new proposal starts a new round
+	if _, err = manager.Query(created.Id, otherCode(code), 0); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	expectUpdate(t, updates)
+	if _, err = manager.Query(created.Id, code, 1); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if _, err = manager.Guess(created.Id, code); err != nil {
+		t.Fatalf("Guess failed: %v", err)
+	}
+	expectUpdate(t, updates)
+
+	events, finished, err := manager.Events(created.Id, 0)
+	if err != nil || !finished {
+		t.Fatalf("Events(...) = (%v, %v, %v), expected a finished session", events, finished, err)
+	}
+	expected := []session.EventType{
+		session.EventRoundStarted,
+		session.EventQuery, session.EventResult,
+		session.EventRoundStarted,
+		session.EventQuery, session.EventResult,
+		session.EventGuess, session.EventGameOver,
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("Expected %d events, got %+v", len(expected), events)
+	}
+	for i, event := range events {
+		if event.Id != i+1 || event.Type != expected[i] {
+			t.Errorf("Event %d is %+v, expected %q", i, event, expected[i])
+		}
+	}
+	if last := events[len(events)-1]; last.Round != 2 || !last.Won {
+		t.Errorf("Unexpected game over event %+v", last)
+	}
+
+	// Resuming after an event
+	if events, _, _ = manager.Events(created.Id, 6); len(events) != 2 || events[0].Id != 7 {
+		t.Errorf("Expected the last 2 events, got %+v", events)
+	}
+	if events, _, _ = manager.Events(created.Id, 100); len(events) != 0 {
+		t.Errorf("Expected no events, got %+v", events)
+	}
+	if _, _, err = manager.Subscribe("missing"); !errors.Is(err, session.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestSpectators(t *testing.T) {
+	t.Parallel()
+
+	manager := session.NewManager(session.DefaultTTL)
+	created, err := manager.Create(randomGame(t))
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	for _, hide := range []bool{false, true} {
+		spectator, err := manager.AddSpectator(created.Id, hide)
+		if err != nil || spectator == "" || spectator == created.Id {
+			t.Fatalf("AddSpectator(%v) = (%q, %v)", hide, spectator, err)
+		}
+		if id, hidden, err := manager.Spectate(spectator); err != nil || id != created.Id || hidden != hide {
+			t.Errorf("Spectate(%q) = (%q, %v, %v), expected (%q, %v)", spectator, id, hidden, err, created.Id, hide)
+		}
+		// The spectator id does not give access to the session
+		if _, err = manager.Get(spectator); !errors.Is(err, session.ErrNotFound) {
+			t.Errorf("Expected ErrNotFound for a spectator id, got %v", err)
+		}
+	}
+	if _, _, err = manager.Spectate(created.Id); !errors.Is(err, session.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound to spectate with the session id, got %v", err)
+	}
+	if _, err = manager.AddSpectator("missing", false); !errors.Is(err, session.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,292 @@
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/stefanovazzocell/TuringMachine/src/kv"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/bot"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
+)
+
+const (
+	// The prefix of the backend keys holding sessions
+	sessionKeyPrefix = "session/"
+	// The prefix of the backend keys holding rooms
+	roomKeyPrefix = "room/"
+)
+
+// The persisted state of a session
+type sessionSnapshot struct {
+	Id        string
+	Criterias []int
+	Verifiers []string
+	Match     game.MatchSnapshot
+	Events    []Event
+	// The spectator ids, and whether results are hidden from them
+	Spectators map[string]bool
+	Origin     string
+	Created    time.Time
+	Updated    time.Time
+}
+
+// The persisted state of a room
+type roomSnapshot struct {
+	Code      string
+	Criterias []int
+	Verifiers []string
+	Game      game.Game
+	// Nil until the first player joins
+	Match *game.MatchSnapshot
+	// The secrets of the players, by name
+	Secrets  map[string]string
+	Accounts map[string]string
+	// The strategies of the bots, by name
+	Bots map[string]string
+	// The accounts of a rated room, nil if it's not rated
+	Rated        []string
+	OutcomeTaken bool
+	Updated      time.Time
+}
+
+// Writes all the sessions to a backend, each expiring when it would have in
+// memory. Returns the number of sessions written.
+func (manager *Manager) Save(backend kv.Backend) (int, error) {
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+	now := time.Now()
+	manager.expire(now)
+	saved := 0
+	for id, s := range manager.sessions {
+		data, err := json.Marshal(sessionSnapshot{
+			Id:         s.id,
+			Criterias:  s.criterias,
+			Verifiers:  s.verifiers,
+			Match:      s.match.Snapshot(),
+			Events:     s.events,
+			Spectators: maps.Clone(s.spectators),
+			Origin:     s.origin,
+			Created:    s.created,
+			Updated:    s.updated,
+		})
+		if err != nil {
+			return saved, err
+		}
+		if err = backend.Put(sessionKeyPrefix+id, data, remaining(manager.ttl, s.updated, now)); err != nil {
+			return saved, err
+		}
+		saved++
+	}
+	return saved, nil
+}
+
+// Loads the sessions saved to a backend (see Save), replacing the ones in
+// memory with the same id. Invalid sessions are skipped and reported in the
+// error. Returns the number of sessions loaded.
+func (manager *Manager) Restore(backend kv.Backend) (int, error) {
+	keys, err := backend.Keys(sessionKeyPrefix)
+	if err != nil {
+		return 0, err
+	}
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+	now := time.Now()
+	restored := 0
+	errs := []error{}
+	for _, key := range keys {
+		snapshot := sessionSnapshot{}
+		if err = load(backend, key, &snapshot); errors.Is(err, kv.ErrNotFound) {
+			continue
+		} else if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if now.Sub(snapshot.Updated) > manager.ttl {
+			continue
+		}
+		match, err := game.RestoreMatch(snapshot.Match)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+			continue
+		}
+		s := &state{
+			id:          snapshot.Id,
+			criterias:   snapshot.Criterias,
+			verifiers:   snapshot.Verifiers,
+			match:       match,
+			origin:      snapshot.Origin,
+			created:     snapshot.Created,
+			updated:     snapshot.Updated,
+			events:      snapshot.Events,
+			subscribers: map[chan struct{}]struct{}{},
+			spectators:  snapshot.Spectators,
+		}
+		if s.spectators == nil {
+			s.spectators = map[string]bool{}
+		}
+		manager.drop(s.id)
+		manager.sessions[s.id] = s
+		for spectator := range s.spectators {
+			manager.spectators[spectator] = s.id
+		}
+		restored++
+	}
+	return restored, errors.Join(errs...)
+}
+
+// Writes all the rooms to a backend, each expiring when it would have in
+// memory once everybody disconnected. Returns the number of rooms written.
+func (manager *RoomManager) Save(backend kv.Backend) (int, error) {
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+	now := time.Now()
+	manager.expire(now)
+	saved := 0
+	for code, room := range manager.rooms {
+		room.lock.Lock()
+		snapshot := roomSnapshot{
+			Code:         room.code,
+			Criterias:    room.criterias,
+			Verifiers:    room.verifiers,
+			Game:         room.game,
+			Secrets:      maps.Clone(room.secrets),
+			Accounts:     maps.Clone(room.accounts),
+			Bots:         map[string]string{},
+			Rated:        room.rated,
+			OutcomeTaken: room.outcomeTaken,
+			Updated:      room.updated,
+		}
+		for name, b := range room.bots {
+			snapshot.Bots[name] = b.Strategy.Name()
+		}
+		if room.match != nil {
+			match := room.match.Snapshot()
+			snapshot.Match = &match
+		}
+		// Rooms with players connected are kept for a full ttl
+		if len(room.subscribers) > 0 {
+			snapshot.Updated = now
+		}
+		room.lock.Unlock()
+		data, err := json.Marshal(snapshot)
+		if err != nil {
+			return saved, err
+		}
+		if err = backend.Put(roomKeyPrefix+code, data, remaining(manager.ttl, snapshot.Updated, now)); err != nil {
+			return saved, err
+		}
+		saved++
+	}
+	return saved, nil
+}
+
+// Loads the rooms saved to a backend (see Save), replacing the ones in memory
+// with the same code. Players reconnect by joining with the same name and
+// secret, or forfeit (see Room.Leave).
+// Invalid rooms are skipped and reported in the error. Returns the number of
+// rooms loaded.
+func (manager *RoomManager) Restore(backend kv.Backend) (int, error) {
+	keys, err := backend.Keys(roomKeyPrefix)
+	if err != nil {
+		return 0, err
+	}
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+	now := time.Now()
+	restored := 0
+	errs := []error{}
+	for _, key := range keys {
+		snapshot := roomSnapshot{}
+		if err = load(backend, key, &snapshot); errors.Is(err, kv.ErrNotFound) {
+			continue
+		} else if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		room := &Room{
+			code:         snapshot.Code,
+			criterias:    snapshot.Criterias,
+			verifiers:    snapshot.Verifiers,
+			game:         snapshot.Game,
+			subscribers:  map[string]chan struct{}{},
+			disconnected: map[string]time.Time{},
+			forfeitAfter: manager.forfeitAfter,
+			secrets:      snapshot.Secrets,
+			accounts:     snapshot.Accounts,
+			bots:         map[string]bot.Bot{},
+			rated:        snapshot.Rated,
+			onRated:      manager.onRated,
+			outcomeTaken: snapshot.OutcomeTaken,
+			updated:      snapshot.Updated,
+		}
+		if room.secrets == nil {
+			room.secrets = map[string]string{}
+		}
+		if room.accounts == nil {
+			room.accounts = map[string]string{}
+		}
+		if room.expired(now, manager.ttl) {
+			continue
+		}
+		// The bots rebuild what they know from their rounds, only their
+		// strategy is persisted
+		for name, strategy := range snapshot.Bots {
+			var botStrategy bot.Strategy
+			if botStrategy, err = bot.New(strategy); err != nil {
+				break
+			}
+			room.bots[name] = bot.Bot{Name: name, Strategy: botStrategy}
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+			continue
+		}
+		if snapshot.Match != nil {
+			if room.match, err = game.RestoreMatch(*snapshot.Match); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", key, err))
+				continue
+			}
+			room.game = room.match.Game()
+			// Nobody is connected after a restart, the players forfeit
+			// unless they reconnect in time
+			for _, player := range room.match.Players() {
+				if _, isBot := room.bots[player.Name]; !isBot && player.Playing() {
+					room.disconnect(player.Name)
+				}
+			}
+			// In case the server stopped before reporting it
+			room.reportOutcome()
+		} else if !room.game.HasUniqueSolution() {
+			errs = append(errs, fmt.Errorf("%s: %w", key, ErrNoSolution))
+			continue
+		}
+		manager.rooms[room.code] = room
+		restored++
+	}
+	return restored, errors.Join(errs...)
+}
+
+/*
+* Helpers
+**/
+
+// Returns the time left before something last updated at a given time
+// expires, at least a millisecond since a ttl of 0 never expires
+func remaining(ttl time.Duration, updated time.Time, now time.Time) time.Duration {
+	return max(ttl-now.Sub(updated), time.Millisecond)
+}
+
+// Reads and decodes a JSON value from a backend
+func load(backend kv.Backend, key string, v any) error {
+	data, err := backend.Get(key)
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	return nil
+}
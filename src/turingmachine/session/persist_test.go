@@ -0,0 +1,172 @@
+package session_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stefanovazzocell/TuringMachine/src/kv"
+	"github.com/stefanovazzocell/TuringMachine/src/kv/redistest"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/bot"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/session"
+)
+
+func TestPersistence(t *testing.T) {
+	t.Parallel()
+
+	redis := redistest.NewServer("")
+	t.Cleanup(redis.Close)
+	backends := map[string]string{
+		"file":  filepath.Join(t.TempDir(), "sessions.kv"),
+		"redis": redis.URL,
+	}
+	for name, location := range backends {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			// Play a bit, then save
+			manager := session.NewManager(session.DefaultTTL)
+			rooms := session.NewRoomManager(session.DefaultTTL, session.DefaultForfeitAfter)
+			g := randomGame(t)
+			code, _ := g.Solve()
+			created, err := manager.CreateFrom(g, "token/signature")
+			if err != nil || created.Origin != "token/signature" {
+				t.Fatalf("CreateFrom() = (%+v, %v)", created, err)
+			}
+			if _, err = manager.Query(created.Id, code, 0); err != nil {
+				t.Fatalf("Query failed: %v", err)
+			}
+			spectator, err := manager.AddSpectator(created.Id, true)
+			if err != nil {
+				t.Fatalf("Failed to add a spectator: %v", err)
+			}
+			room, err := rooms.Create(g)
+			if err != nil {
+				t.Fatalf("Failed to create room: %v", err)
+			}
+			_, secret, err := room.JoinAs("alice", "", "account-alice")
+			if err != nil {
+				t.Fatalf("Failed to join: %v", err)
+			}
+			if err = room.AddBot("bot", bot.Greedy{}); err != nil {
+				t.Fatalf("Failed to add a bot: %v", err)
+			}
+			if _, err = room.Query("alice", code, 1); err != nil {
+				t.Fatalf("Query failed: %v", err)
+			}
+			rated, err := rooms.CreateRated(g, []string{"account-alice"})
+			if err != nil {
+				t.Fatalf("Failed to create rated room: %v", err)
+			}
+
+			backend, err := kv.Open(location)
+			if err != nil {
+				t.Fatalf("Failed to open %s: %v", location, err)
+			}
+			if saved, err := manager.Save(backend); err != nil || saved != 1 {
+				t.Fatalf("Save() = (%d, %v), expected (1, nil)", saved, err)
+			}
+			if saved, err := rooms.Save(backend); err != nil || saved != 2 {
+				t.Fatalf("Save() = (%d, %v), expected (2, nil)", saved, err)
+			}
+			if err = backend.Close(); err != nil {
+				t.Fatalf("Close failed: %v", err)
+			}
+
+			// Restore after a "restart"
+			if backend, err = kv.Open(location); err != nil {
+				t.Fatalf("Failed to reopen %s: %v", location, err)
+			}
+			defer backend.Close()
+			manager = session.NewManager(session.DefaultTTL)
+			rooms = session.NewRoomManager(session.DefaultTTL, session.DefaultForfeitAfter)
+			if restored, err := manager.Restore(backend); err != nil || restored != 1 {
+				t.Fatalf("Restore() = (%d, %v), expected (1, nil)", restored, err)
+			}
+			if restored, err := rooms.Restore(backend); err != nil || restored != 2 {
+				t.Fatalf("Restore() = (%d, %v), expected (2, nil)", restored, err)
+			}
+
+			s, err := manager.Get(created.Id)
+			if err != nil || s.Game != g || s.Origin != created.Origin || len(s.Rounds[0].Queries) != 1 {
+				t.Fatalf("Get(...) = (%+v, %v) after restoring", s, err)
+			}
+			if events, _, _ := manager.Events(created.Id, 0); len(events) != 3 {
+				t.Errorf("Expected the events to be restored, got %+v", events)
+			}
+			if id, hide, err := manager.Spectate(spectator); err != nil || id != created.Id || !hide {
+				t.Errorf("Spectate(...) = (%q, %v, %v) after restoring", id, hide, err)
+			}
+			if s, err = manager.Guess(created.Id, code); err != nil || !s.Won {
+				t.Errorf("Guess(...) = (%+v, %v) after restoring", s, err)
+			}
+			if room, err = rooms.Get(room.Code()); err != nil {
+				t.Fatalf("Failed to get room after restoring: %v", err)
+			}
+			if _, _, err = room.Join("alice", ""); !errors.Is(err, session.ErrWrongSecret) {
+				t.Errorf("Expected the secret to be restored, got %v", err)
+			}
+			if _, _, err = room.JoinAs("alice", secret, "account-other"); !errors.Is(err, session.ErrAccountMismatch) {
+				t.Errorf("Expected the account link to be restored, got %v", err)
+			}
+			if _, _, err = room.Join("alice", secret); !errors.Is(err, session.ErrAccountMismatch) {
+				t.Errorf("Expected the account to be required, got %v", err)
+			}
+			if _, _, err = room.JoinAs("alice", secret, "account-alice"); err != nil {
+				t.Fatalf("Failed to reconnect: %v", err)
+			}
+			if view := room.View("alice"); len(view.Rounds[0].Queries) != 1 || view.Players[0].Checks != 1 || !view.Players[1].Bot {
+				t.Errorf("Unexpected view after restoring %+v", view)
+			}
+			if rated, err = rooms.Get(rated.Code()); err != nil {
+				t.Fatalf("Failed to get rated room after restoring: %v", err)
+			}
+			if _, _, err = rated.JoinAs("bob", "", "account-bob"); !errors.Is(err, session.ErrRatedRoom) {
+				t.Errorf("Expected the rated accounts to be restored, got %v", err)
+			}
+			// The bot still plays
+			if err = room.EndTurn("alice"); err != nil {
+				t.Fatalf("EndTurn failed: %v", err)
+			}
+			if view := room.View("alice"); view.Round != 2 && !view.Finished {
+				t.Errorf("Expected the bot to play its round, got %+v", view)
+			}
+		})
+	}
+}
+
+func TestPersistenceExpiry(t *testing.T) {
+	t.Parallel()
+
+	backend, err := kv.OpenFile(filepath.Join(t.TempDir(), "sessions.kv"))
+	if err != nil {
+		t.Fatalf("Failed to open backend: %v", err)
+	}
+	defer backend.Close()
+	manager := session.NewManager(20 * time.Millisecond)
+	created, err := manager.Create(randomGame(t))
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	if _, err = manager.Save(backend); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	manager = session.NewManager(session.DefaultTTL)
+	if restored, err := manager.Restore(backend); err != nil || restored != 0 {
+		t.Errorf("Restore() = (%d, %v), expected the session to have expired", restored, err)
+	}
+	if _, err = manager.Get(created.Id); !errors.Is(err, session.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	// Invalid sessions are reported and skipped
+	invalid := `{"Id":"invalid","Updated":"` + time.Now().Format(time.RFC3339Nano) + `"}`
+	if err = backend.Put("session/invalid", []byte(invalid), 0); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if restored, err := manager.Restore(backend); err == nil || restored != 0 {
+		t.Errorf("Restore() = (%d, %v), expected an error", restored, err)
+	}
+}
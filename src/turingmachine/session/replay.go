@@ -0,0 +1,51 @@
+package session
+
+import (
+	"strings"
+
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/replay"
+)
+
+// Returns the replay of a session so far, built from its events.
+// The replay has no game id: it depends on how the game is shared.
+func (manager *Manager) Replay(id string) (replay.Replay, error) {
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+	s, err := manager.get(id)
+	if err != nil {
+		return replay.Replay{}, err
+	}
+	r, err := replay.New(s.match.Game(), "", replay.ModeSession, verifiersSymbol(s.verifiers), s.created)
+	if err != nil {
+		return replay.Replay{}, err
+	}
+	for _, event := range s.events {
+		switch event.Type {
+		case EventRoundStarted:
+			// The first round starts with the replay
+			if event.Round > 1 {
+				r.NextRound(event.Time)
+			}
+		case EventResult:
+			r.Query(event.Proposal, event.Slot, event.Check, event.Time)
+		case EventGuess:
+			r.MakeGuess(event.Guess, event.Won, event.Time)
+		}
+	}
+	return r, nil
+}
+
+/*
+* Helpers
+**/
+
+// Returns the symbol the verification cards are shown with
+func verifiersSymbol(verifiers []string) string {
+	for _, symbol := range game.VerificationSymbols {
+		if len(verifiers) > 0 && strings.HasPrefix(verifiers[0], symbol) {
+			return symbol
+		}
+	}
+	return ""
+}
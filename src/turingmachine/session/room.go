@@ -0,0 +1,577 @@
+package session
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/bot"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
+)
+
+const (
+	// The number of characters in a room code
+	roomCodeLength = 6
+	// The characters used for room codes (Crockford's base32)
+	roomCodeAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+	// The maximum length of a player name
+	MaxPlayerNameLength = 24
+	// The number of random bytes in a player secret
+	playerSecretBytes = 16
+	// The default time after which a disconnected player forfeits, so they
+	// cannot hold the room up
+	DefaultForfeitAfter = 5 * time.Minute
+)
+
+var (
+	// Error returned when a room does not exist (or has expired)
+	ErrRoomNotFound = errors.New("room not found")
+	// Error returned when joining a room with a name already connected
+	ErrPlayerConnected = errors.New("the player is already connected")
+	// Error returned when reconnecting to a room without the player secret
+	ErrWrongSecret = errors.New("wrong secret for the player")
+	// Error returned when joining a room with an invalid name
+	ErrInvalidName = errors.New("invalid player name")
+	// Error returned when joining a room with a name linked to another account
+	ErrAccountMismatch = errors.New("the player name is linked to another account")
+	// Error returned when adding a bot with the name of a player in the room
+	ErrNameTaken = errors.New("the name is taken by another player")
+	// Error returned when joining a rated room without one of its accounts,
+	// or adding a bot to it
+	ErrRatedRoom = errors.New("the room is rated, only its matched accounts can play")
+)
+
+// A player in a room as seen by the other players
+type RoomPlayer struct {
+	Name string
+	// Always true for bots
+	Connected bool
+	Bot       bool
+	// The number of verifiers queried in the current round
+	Checks int
+	// True once the player is done with the current round
+	Done bool
+	// True if the player made a guess (but not whether it was right)
+	Guessed bool
+	// True if the player forfeited by staying disconnected
+	Forfeited bool
+}
+
+// A room as seen by one of its players.
+// The results of the other players are hidden until the match is finished.
+type RoomView struct {
+	Code      string
+	Criterias []int
+	Verifiers []string
+	// The current round, starting from 1
+	Round   int
+	Players []RoomPlayer
+	// The rounds of the viewing player, with their results
+	Rounds   []game.Round
+	Finished bool
+	// Only set once the match is finished
+	Standings []game.Standing
+	// Only set once the match is finished
+	Game game.Game
+}
+
+// A multiplayer room, several players join with the room code and play the
+// same game (see game.Match).
+// Safe for concurrent use.
+type Room struct {
+	code      string
+	criterias []int
+	verifiers []string
+	game      game.Game
+
+	lock sync.Mutex
+	// Created when the first player joins
+	match *game.Match
+	// The channels notified on changes, by connected player
+	subscribers map[string]chan struct{}
+	// When the players that are not connected left, by name
+	disconnected map[string]time.Time
+	// The time after which a disconnected player forfeits, 0 if never
+	forfeitAfter time.Duration
+	// The secrets the players reconnect with, by name
+	secrets map[string]string
+	// The accounts (e.g. leaderboard players) the players are linked to
+	accounts map[string]string
+	// The bots playing in the room, by name
+	bots map[string]bot.Bot
+	// The accounts that can play in a rated room (see
+	// RoomManager.CreateRated), nil if the room is not rated
+	rated []string
+	// Called with the outcome of a rated match once it's finished
+	onRated func(code string, outcome RoomOutcome)
+	// True once the outcome of the rated match was reported
+	outcomeTaken bool
+	updated      time.Time
+}
+
+// The outcome of a finished match in a rated room
+type RoomOutcome struct {
+	Game      game.Game
+	Standings []game.Standing
+	// The accounts the players are linked to, by player name
+	Accounts map[string]string
+}
+
+// Returns the code of this room
+func (room *Room) Code() string {
+	return room.code
+}
+
+// Joins the room (or reconnects to it) as a player, returns a channel
+// notified whenever the room changes and the secret of the player.
+// New players can only join before the first query is made, and get a new
+// secret: reconnecting takes the secret returned by the first join.
+// A player that does not reconnect in time forfeits (see Leave).
+func (room *Room) Join(name string, secret string) (<-chan struct{}, string, error) {
+	return room.JoinAs(name, secret, "")
+}
+
+// Joins the room like Join, linking the player to an account (if not empty).
+// A player stays linked to the first account it joined with, and can only
+// reconnect with it.
+// Only the accounts of a rated room can join it, with a single player each.
+func (room *Room) JoinAs(name string, secret string, account string) (<-chan struct{}, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > MaxPlayerNameLength {
+		return nil, "", ErrInvalidName
+	}
+	room.lock.Lock()
+	defer room.lock.Unlock()
+	if _, ok := room.subscribers[name]; ok {
+		return nil, "", ErrPlayerConnected
+	}
+	if _, ok := room.bots[name]; ok {
+		return nil, "", ErrNameTaken
+	}
+	// Players restored without a secret get one on their next join
+	expected, seated := room.secrets[name]
+	if seated && subtle.ConstantTimeCompare([]byte(secret), []byte(expected)) != 1 {
+		return nil, "", ErrWrongSecret
+	}
+	if linked, ok := room.accounts[name]; ok && linked != account {
+		return nil, "", ErrAccountMismatch
+	}
+	if room.rated != nil {
+		if !slices.Contains(room.rated, account) {
+			return nil, "", ErrRatedRoom
+		}
+		for other, linked := range room.accounts {
+			if linked == account && other != name {
+				return nil, "", ErrAccountMismatch
+			}
+		}
+	}
+	if !seated {
+		var err error
+		if expected, err = newPlayerSecret(); err != nil {
+			return nil, "", err
+		}
+	}
+	if room.match == nil {
+		match, err := game.NewMatch(room.game, name)
+		if err != nil {
+			return nil, "", err
+		}
+		room.match = match
+	} else if _, err := room.match.Player(name); err != nil {
+		if err = room.match.AddPlayer(name); err != nil {
+			return nil, "", err
+		}
+	}
+	room.secrets[name] = expected
+	if _, ok := room.accounts[name]; !ok && account != "" {
+		room.accounts[name] = account
+	}
+	updates := make(chan struct{}, 1)
+	room.subscribers[name] = updates
+	delete(room.disconnected, name)
+	room.changed()
+	return updates, expected, nil
+}
+
+// Adds a bot following a strategy to the room, it plays each round once all
+// the other players are done with it.
+// Bots can only be added before the first query is made, and not to rated
+// rooms.
+func (room *Room) AddBot(name string, strategy bot.Strategy) error {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > MaxPlayerNameLength {
+		return ErrInvalidName
+	}
+	room.lock.Lock()
+	defer room.lock.Unlock()
+	if room.rated != nil {
+		return ErrRatedRoom
+	}
+	if room.match == nil {
+		match, err := game.NewMatch(room.game, name)
+		if err != nil {
+			return err
+		}
+		room.match = match
+	} else if err := room.match.AddPlayer(name); errors.Is(err, game.ErrMatchDuplicatePlayer) {
+		return ErrNameTaken
+	} else if err != nil {
+		return err
+	}
+	room.bots[name] = bot.Bot{Name: name, Strategy: strategy}
+	room.changed()
+	return nil
+}
+
+// Disconnects a player from the room, the player stays in the match.
+// The player forfeits if they do not reconnect before the forfeit delay of
+// the room, so the other players are not held up.
+func (room *Room) Leave(name string) {
+	name = strings.TrimSpace(name)
+	room.lock.Lock()
+	defer room.lock.Unlock()
+	if _, ok := room.subscribers[name]; !ok {
+		return
+	}
+	delete(room.subscribers, name)
+	room.disconnect(name)
+	room.changed()
+}
+
+// Tests a proposal against a verifier for a player, see game.Match.Query
+func (room *Room) Query(name string, proposal game.Code, slot int) (bool, error) {
+	room.lock.Lock()
+	defer room.lock.Unlock()
+	if room.match == nil {
+		return false, game.ErrMatchUnknownPlayer
+	}
+	check, err := room.match.Query(name, proposal, slot)
+	if err == nil {
+		room.changed()
+	}
+	return check, err
+}
+
+// Ends the turn of a player, see game.Match.EndTurn
+func (room *Room) EndTurn(name string) error {
+	room.lock.Lock()
+	defer room.lock.Unlock()
+	if room.match == nil {
+		return game.ErrMatchUnknownPlayer
+	}
+	err := room.match.EndTurn(name)
+	if err == nil {
+		room.playBots()
+		room.changed()
+	}
+	return err
+}
+
+// Makes the guess of a player, see game.Match.Guess
+func (room *Room) Guess(name string, code game.Code) (bool, error) {
+	room.lock.Lock()
+	defer room.lock.Unlock()
+	if room.match == nil {
+		return false, game.ErrMatchUnknownPlayer
+	}
+	solved, err := room.match.Guess(name, code)
+	if err == nil {
+		room.playBots()
+		room.changed()
+	}
+	return solved, err
+}
+
+// Returns the room as seen by a player
+func (room *Room) View(name string) RoomView {
+	room.lock.Lock()
+	defer room.lock.Unlock()
+	view := RoomView{
+		Code:      room.code,
+		Criterias: slices.Clone(room.criterias),
+		Verifiers: slices.Clone(room.verifiers),
+		Round:     1,
+		Players:   []RoomPlayer{},
+	}
+	if room.match == nil {
+		return view
+	}
+	view.Round = room.match.Round()
+	view.Finished = room.match.Finished()
+	for _, player := range room.match.Players() {
+		_, connected := room.subscribers[player.Name]
+		_, isBot := room.bots[player.Name]
+		current := player.Rounds[len(player.Rounds)-1]
+		view.Players = append(view.Players, RoomPlayer{
+			Name:      player.Name,
+			Connected: connected || isBot,
+			Bot:       isBot,
+			Checks:    current.Checks(),
+			Done:      player.Done,
+			Guessed:   player.Guessed,
+			Forfeited: player.Forfeited,
+		})
+		if player.Name == name {
+			view.Rounds = player.Rounds
+		}
+	}
+	if view.Finished {
+		view.Standings = room.match.Standings()
+		view.Game = room.match.Game()
+	}
+	return view
+}
+
+/*
+* Helpers
+**/
+
+// Notifies all subscribers of a change, and reports the outcome of a rated
+// match once it's finished. Must hold the lock.
+func (room *Room) changed() {
+	room.updated = time.Now()
+	room.reportOutcome()
+	for _, updates := range room.subscribers {
+		// Notifications are coalesced, a pending one is enough
+		select {
+		case updates <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Reports the outcome of a rated match once it's finished, only once.
+// Must hold the lock.
+func (room *Room) reportOutcome() {
+	if room.rated == nil || room.onRated == nil || room.outcomeTaken || room.match == nil || !room.match.Finished() {
+		return
+	}
+	room.outcomeTaken = true
+	room.onRated(room.code, RoomOutcome{
+		Game:      room.match.Game(),
+		Standings: room.match.Standings(),
+		Accounts:  maps.Clone(room.accounts),
+	})
+}
+
+// Plays the round of the bots once all the other players still in the game
+// are done, until the match is finished or another player can play.
+// Must hold the lock.
+func (room *Room) playBots() {
+	for len(room.bots) > 0 && !room.match.Finished() {
+		players := room.match.Players()
+		humans := 0
+		for _, player := range players {
+			if _, ok := room.bots[player.Name]; ok {
+				continue
+			}
+			humans++
+			if player.Playing() && !player.Done {
+				return
+			}
+		}
+		if humans == 0 {
+			return
+		}
+		round := room.match.Round()
+		for _, player := range players {
+			b, ok := room.bots[player.Name]
+			if !ok || !player.Playing() || player.Done {
+				continue
+			}
+			if err := b.PlayRound(room.match); err != nil {
+				// The bot skips its turn rather than holding the room up
+				_ = room.match.EndTurn(b.Name)
+			}
+		}
+		if room.match.Round() == round {
+			return
+		}
+	}
+}
+
+// Marks a player as disconnected from now, and forfeits them once the
+// forfeit delay passes. Must hold the lock.
+func (room *Room) disconnect(name string) {
+	if room.forfeitAfter <= 0 {
+		return
+	}
+	room.disconnected[name] = time.Now()
+	time.AfterFunc(room.forfeitAfter, room.forfeitDisconnected)
+}
+
+// Forfeits the players disconnected for longer than the forfeit delay, and
+// lets the bots play if they were waiting on them
+func (room *Room) forfeitDisconnected() {
+	room.lock.Lock()
+	defer room.lock.Unlock()
+	if room.match == nil || room.match.Finished() {
+		return
+	}
+	forfeited := false
+	for name, since := range room.disconnected {
+		if time.Since(since) < room.forfeitAfter {
+			continue
+		}
+		delete(room.disconnected, name)
+		if err := room.match.Forfeit(name); err == nil {
+			forfeited = true
+		}
+	}
+	if forfeited {
+		room.playBots()
+		room.changed()
+	}
+}
+
+// Returns true if the room was idle for longer than ttl with nobody connected
+func (room *Room) expired(now time.Time, ttl time.Duration) bool {
+	room.lock.Lock()
+	defer room.lock.Unlock()
+	return len(room.subscribers) == 0 && now.Sub(room.updated) > ttl
+}
+
+// Holds the rooms in memory, safe for concurrent use
+type RoomManager struct {
+	lock  sync.Mutex
+	rooms map[string]*Room
+	ttl   time.Duration
+	// The time after which a disconnected player forfeits, 0 if never
+	forfeitAfter time.Duration
+	// Called with the outcome of each rated match once it's finished
+	onRated func(code string, outcome RoomOutcome)
+}
+
+// Returns a room manager that drops rooms idle for longer than ttl, where
+// players disconnected for longer than forfeitAfter forfeit (never if 0)
+func NewRoomManager(ttl time.Duration, forfeitAfter time.Duration) *RoomManager {
+	return &RoomManager{
+		rooms:        map[string]*Room{},
+		ttl:          ttl,
+		forfeitAfter: forfeitAfter,
+	}
+}
+
+// Sets the function called with the outcome of each rated match once it's
+// finished, before any room is created or restored.
+// It's called with the room locked, so it must not use the room.
+func (manager *RoomManager) OnRated(fn func(code string, outcome RoomOutcome)) {
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+	manager.onRated = fn
+}
+
+// Creates a new room for a game
+func (manager *RoomManager) Create(g game.Game) (*Room, error) {
+	return manager.create(g, nil)
+}
+
+// Creates a new rated room for a game, only the given accounts can play in it
+// (one player each, see Room.JoinAs) and its outcome is reported once it's
+// finished (see OnRated)
+func (manager *RoomManager) CreateRated(g game.Game, accounts []string) (*Room, error) {
+	return manager.create(g, append([]string{}, accounts...))
+}
+
+/*
+* Helpers
+**/
+
+// Creates a new room for a game, rated for the given accounts if not nil
+func (manager *RoomManager) create(g game.Game, rated []string) (*Room, error) {
+	if !g.HasUniqueSolution() {
+		return nil, ErrNoSolution
+	}
+	criterias, verifiers, _ := g.GetCards()
+	room := &Room{
+		criterias:    criterias,
+		verifiers:    verifiers,
+		game:         g,
+		subscribers:  map[string]chan struct{}{},
+		disconnected: map[string]time.Time{},
+		forfeitAfter: manager.forfeitAfter,
+		secrets:      map[string]string{},
+		accounts:     map[string]string{},
+		bots:         map[string]bot.Bot{},
+		rated:        rated,
+		updated:      time.Now(),
+	}
+
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+	room.onRated = manager.onRated
+	manager.expire(room.updated)
+	for {
+		code, err := newRoomCode()
+		if err != nil {
+			return nil, err
+		}
+		if _, taken := manager.rooms[code]; !taken {
+			room.code = code
+			break
+		}
+	}
+	manager.rooms[room.code] = room
+	return room, nil
+}
+
+// Returns a room by code (case insensitive)
+func (manager *RoomManager) Get(code string) (*Room, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+	room, ok := manager.rooms[code]
+	if !ok {
+		return nil, ErrRoomNotFound
+	}
+	if room.expired(time.Now(), manager.ttl) {
+		delete(manager.rooms, code)
+		return nil, ErrRoomNotFound
+	}
+	return room, nil
+}
+
+// Returns the number of rooms held
+func (manager *RoomManager) Len() int {
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+	return len(manager.rooms)
+}
+
+// Drops all expired rooms, must hold the lock
+func (manager *RoomManager) expire(now time.Time) {
+	for code, room := range manager.rooms {
+		if room.expired(now, manager.ttl) {
+			delete(manager.rooms, code)
+		}
+	}
+}
+
+// Returns a new random room code
+func newRoomCode() (string, error) {
+	raw := make([]byte, roomCodeLength)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	for i, b := range raw {
+		raw[i] = roomCodeAlphabet[int(b)%len(roomCodeAlphabet)]
+	}
+	return string(raw), nil
+}
+
+// Returns a new random player secret
+func newPlayerSecret() (string, error) {
+	raw := make([]byte, playerSecretBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
@@ -0,0 +1,309 @@
+package session_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/bot"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/session"
+)
+
+// Fails the test if no update is pending on a channel
+func expectUpdate(t *testing.T, updates <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-updates:
+	default:
+		t.Fatal("Expected an update")
+	}
+}
+
+func TestRoom(t *testing.T) {
+	t.Parallel()
+
+	manager := session.NewRoomManager(session.DefaultTTL, session.DefaultForfeitAfter)
+	g := randomGame(t)
+	code, _ := g.Solve()
+	room, err := manager.Create(g)
+	if err != nil {
+		t.Fatalf("Failed to create room: %v", err)
+	}
+	if found, err := manager.Get(strings.ToLower(room.Code())); err != nil || found != room {
+		t.Fatalf("Get(%q) = (%v, %v), expected the room", room.Code(), found, err)
+	}
+
+	alice, aliceSecret, err := room.JoinAs("alice", "", "account-alice")
+	if err != nil {
+		t.Fatalf("Failed to join: %v", err)
+	}
+	bob, bobSecret, err := room.Join("bob", "")
+	if err != nil || bobSecret == "" || bobSecret == aliceSecret {
+		t.Fatalf("Join(bob) = (%v, %q, %v), expected a new secret", bob, bobSecret, err)
+	}
+	expectUpdate(t, alice)
+	expectUpdate(t, bob)
+	if _, _, err = room.Join("alice", aliceSecret); !errors.Is(err, session.ErrPlayerConnected) {
+		t.Errorf("Expected ErrPlayerConnected, got %v", err)
+	}
+	if _, _, err = room.Join("  ", ""); !errors.Is(err, session.ErrInvalidName) {
+		t.Errorf("Expected ErrInvalidName, got %v", err)
+	}
+
+	// Alice's queries are private, but her progress is shared
+	if check, err := room.Query("alice", code, 0); err != nil || !check {
+		t.Fatalf("Query(...) = (%v, %v), expected (true, nil)", check, err)
+	}
+	expectUpdate(t, bob)
+	view := room.View("bob")
+	if len(view.Players) != 2 || view.Players[0].Checks != 1 || len(view.Rounds[0].Queries) != 0 {
+		t.Fatalf("Unexpected view for bob %+v", view)
+	}
+	if view = room.View("alice"); len(view.Rounds[0].Queries) != 1 {
+		t.Fatalf("Expected alice to see her query, got %+v", view)
+	}
+	if _, _, err = room.Join("carol", ""); !errors.Is(err, game.ErrMatchStarted) {
+		t.Errorf("Expected ErrMatchStarted, got %v", err)
+	}
+
+	// Bob leaves and reconnects
+	room.Leave("bob")
+	if view = room.View("alice"); view.Players[1].Connected {
+		t.Errorf("Expected bob to be disconnected")
+	}
+	// Only with the secret of the first join
+	for _, secret := range []string{"", "secret", aliceSecret} {
+		if _, _, err = room.Join("bob", secret); !errors.Is(err, session.ErrWrongSecret) {
+			t.Errorf("Join(bob, %q) returned %v, expected ErrWrongSecret", secret, err)
+		}
+	}
+	secret := ""
+	if bob, secret, err = room.JoinAs("bob", bobSecret, "account-bob"); err != nil || secret != bobSecret {
+		t.Fatalf("JoinAs(bob) = (%v, %q, %v), expected to reconnect with the same secret", bob, secret, err)
+	}
+	room.Leave("bob")
+	if _, _, err = room.JoinAs("bob", bobSecret, "account-other"); !errors.Is(err, session.ErrAccountMismatch) {
+		t.Errorf("Expected ErrAccountMismatch, got %v", err)
+	}
+	// A linked seat can't be taken back without the account
+	if _, _, err = room.Join("bob", bobSecret); !errors.Is(err, session.ErrAccountMismatch) {
+		t.Errorf("Expected ErrAccountMismatch without an account, got %v", err)
+	}
+	if bob, _, err = room.JoinAs("bob", bobSecret, "account-bob"); err != nil {
+		t.Fatalf("Failed to reconnect: %v", err)
+	}
+
+	// Bob guesses right, alice ends her turn: the match is over
+	if _, err = room.Guess("bob", code); err != nil {
+		t.Fatalf("Guess failed: %v", err)
+	}
+	if view = room.View("alice"); view.Finished || !view.Players[1].Guessed || view.Standings != nil {
+		t.Fatalf("Unexpected view before the end of the round %+v", view)
+	}
+	if err = room.EndTurn("alice"); err != nil {
+		t.Fatalf("EndTurn failed: %v", err)
+	}
+	view = room.View("alice")
+	if !view.Finished || view.Game != g || len(view.Standings) != 2 ||
+		view.Standings[0].Player != "bob" || !view.Standings[0].Won {
+		t.Fatalf("Unexpected view after the end of the match %+v", view)
+	}
+}
+
+func TestRoomRated(t *testing.T) {
+	t.Parallel()
+
+	manager := session.NewRoomManager(session.DefaultTTL, session.DefaultForfeitAfter)
+	outcomes := []session.RoomOutcome{}
+	manager.OnRated(func(code string, outcome session.RoomOutcome) {
+		outcomes = append(outcomes, outcome)
+	})
+	g := randomGame(t)
+	code, _ := g.Solve()
+	room, err := manager.CreateRated(g, []string{"account-alice", "account-bob"})
+	if err != nil {
+		t.Fatalf("Failed to create room: %v", err)
+	}
+
+	// Only the rated accounts can play, with a single player each
+	if _, _, err = room.Join("carol", ""); !errors.Is(err, session.ErrRatedRoom) {
+		t.Errorf("Expected ErrRatedRoom without an account, got %v", err)
+	}
+	if _, _, err = room.JoinAs("carol", "", "account-carol"); !errors.Is(err, session.ErrRatedRoom) {
+		t.Errorf("Expected ErrRatedRoom for another account, got %v", err)
+	}
+	if err = room.AddBot("minimax", bot.Minimax{}); !errors.Is(err, session.ErrRatedRoom) {
+		t.Errorf("Expected ErrRatedRoom for a bot, got %v", err)
+	}
+	if _, _, err = room.JoinAs("alice", "", "account-alice"); err != nil {
+		t.Fatalf("Failed to join: %v", err)
+	}
+	if _, _, err = room.JoinAs("alice2", "", "account-alice"); !errors.Is(err, session.ErrAccountMismatch) {
+		t.Errorf("Expected ErrAccountMismatch for a second seat, got %v", err)
+	}
+	if _, _, err = room.JoinAs("bob", "", "account-bob"); err != nil {
+		t.Fatalf("Failed to join: %v", err)
+	}
+
+	// The outcome is reported once, as soon as the match is over
+	if _, err = room.Guess("bob", code); err != nil {
+		t.Fatalf("Guess failed: %v", err)
+	}
+	if len(outcomes) != 0 {
+		t.Fatalf("Expected no outcome before the end of the match, got %+v", outcomes)
+	}
+	if err = room.EndTurn("alice"); err != nil {
+		t.Fatalf("EndTurn failed: %v", err)
+	}
+	if len(outcomes) != 1 || outcomes[0].Game != g || len(outcomes[0].Standings) != 2 ||
+		outcomes[0].Accounts["alice"] != "account-alice" || outcomes[0].Accounts["bob"] != "account-bob" {
+		t.Fatalf("Expected the outcome with accounts, got %+v", outcomes)
+	}
+	room.Leave("alice")
+	if len(outcomes) != 1 {
+		t.Errorf("Expected the outcome to be reported once, got %+v", outcomes)
+	}
+
+	// Unrated rooms are not reported
+	unrated, err := manager.Create(g)
+	if err != nil {
+		t.Fatalf("Failed to create room: %v", err)
+	}
+	if _, _, err = unrated.JoinAs("alice", "", "account-alice"); err != nil {
+		t.Fatalf("Failed to join: %v", err)
+	}
+	if _, err = unrated.Guess("alice", code); err != nil || !unrated.View("alice").Finished {
+		t.Fatalf("Guess failed: %v", err)
+	}
+	if len(outcomes) != 1 {
+		t.Errorf("Expected no outcome for an unrated room, got %+v", outcomes)
+	}
+}
+
+func TestRoomBots(t *testing.T) {
+	t.Parallel()
+
+	manager := session.NewRoomManager(session.DefaultTTL, session.DefaultForfeitAfter)
+	g := randomGame(t)
+	room, err := manager.Create(g)
+	if err != nil {
+		t.Fatalf("Failed to create room: %v", err)
+	}
+	if err = room.AddBot("minimax", bot.Minimax{}); err != nil {
+		t.Fatalf("AddBot failed: %v", err)
+	}
+	alice, _, err := room.Join("alice", "")
+	if err != nil {
+		t.Fatalf("Failed to join: %v", err)
+	}
+	if err = room.AddBot("alice", bot.Greedy{}); !errors.Is(err, session.ErrNameTaken) {
+		t.Errorf("Expected ErrNameTaken for a player name, got %v", err)
+	}
+	if _, _, err = room.Join("minimax", ""); !errors.Is(err, session.ErrNameTaken) {
+		t.Errorf("Expected ErrNameTaken for a bot name, got %v", err)
+	}
+	view := room.View("alice")
+	if len(view.Players) != 2 || !view.Players[0].Bot || !view.Players[0].Connected || view.Players[1].Bot {
+		t.Fatalf("Unexpected view with a bot %+v", view)
+	}
+
+	// The bot plays once alice is done, until it solves the game
+	for round := 1; !room.View("alice").Finished; round++ {
+		if view = room.View("alice"); view.Round != round || view.Players[0].Done {
+			t.Fatalf("Expected the bot to wait for alice in round %d, got %+v", round, view)
+		}
+		if err = room.EndTurn("alice"); err != nil {
+			t.Fatalf("EndTurn failed: %v", err)
+		}
+		expectUpdate(t, alice)
+	}
+	if view = room.View("alice"); view.Standings[0].Player != "minimax" || !view.Standings[0].Won {
+		t.Fatalf("Expected the bot to win, got %+v", view.Standings)
+	}
+	if err = room.AddBot("greedy", bot.Greedy{}); !errors.Is(err, game.ErrMatchFinished) {
+		t.Errorf("Expected ErrMatchFinished, got %v", err)
+	}
+}
+
+func TestRoomExpiry(t *testing.T) {
+	t.Parallel()
+
+	manager := session.NewRoomManager(10*time.Millisecond, session.DefaultForfeitAfter)
+	room, err := manager.Create(randomGame(t))
+	if err != nil {
+		t.Fatalf("Failed to create room: %v", err)
+	}
+	// Rooms with players connected do not expire
+	if _, _, err = room.Join("alice", ""); err != nil {
+		t.Fatalf("Failed to join: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if _, err = manager.Get(room.Code()); err != nil {
+		t.Fatalf("Expected the room to be kept while players are connected, got %v", err)
+	}
+	room.Leave("alice")
+	time.Sleep(20 * time.Millisecond)
+	if _, err = manager.Get(room.Code()); !errors.Is(err, session.ErrRoomNotFound) {
+		t.Errorf("Expected ErrRoomNotFound, got %v", err)
+	}
+	if _, err = manager.Create(game.Game{}); !errors.Is(err, session.ErrNoSolution) {
+		t.Errorf("Expected ErrNoSolution, got %v", err)
+	}
+}
+
+func TestRoomForfeit(t *testing.T) {
+	t.Parallel()
+
+	forfeitAfter := 50 * time.Millisecond
+	manager := session.NewRoomManager(session.DefaultTTL, forfeitAfter)
+	g := randomGame(t)
+	code, _ := g.Solve()
+	room, err := manager.Create(g)
+	if err != nil {
+		t.Fatalf("Failed to create room: %v", err)
+	}
+	alice, _, err := room.Join("alice", "")
+	if err != nil {
+		t.Fatalf("Failed to join: %v", err)
+	}
+	_, bobSecret, err := room.Join("bob", "")
+	if err != nil {
+		t.Fatalf("Failed to join: %v", err)
+	}
+	if _, err = room.Query("alice", code, 0); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if err = room.EndTurn("alice"); err != nil {
+		t.Fatalf("EndTurn failed: %v", err)
+	}
+
+	// Reconnecting in time keeps the seat
+	room.Leave("bob")
+	if _, _, err = room.Join("bob", bobSecret); err != nil {
+		t.Fatalf("Failed to reconnect: %v", err)
+	}
+	time.Sleep(2 * forfeitAfter)
+	if view := room.View("alice"); view.Round != 1 || view.Players[1].Forfeited {
+		t.Fatalf("Expected bob to keep playing, got %+v", view)
+	}
+
+	// Staying away forfeits, which ends the round bob was holding up
+	room.Leave("bob")
+	view := room.View("alice")
+	for !view.Players[1].Forfeited {
+		select {
+		case <-alice:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected bob to forfeit, got %+v", view)
+		}
+		view = room.View("alice")
+	}
+	if view.Round != 2 || view.Finished {
+		t.Errorf("Expected the next round to start, got %+v", view)
+	}
+	if _, err = room.Query("bob", code, 1); !errors.Is(err, game.ErrMatchPlayerEliminated) {
+		t.Errorf("Expected ErrMatchPlayerEliminated after forfeiting, got %v", err)
+	}
+}
@@ -0,0 +1,288 @@
+package session
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
+)
+
+const (
+	// The default time after which an idle session is dropped
+	DefaultTTL = 24 * time.Hour
+	// The number of random bytes in a session id
+	idBytes = 16
+	// The name of the player in a session match
+	player = "player"
+)
+
+var (
+	// Error returned when a session does not exist (or has expired)
+	ErrNotFound = errors.New("session not found")
+	// Error returned when creating a session for a game without a unique
+	// solution
+	ErrNoSolution = errors.New("the game does not have a unique solution")
+)
+
+// A play session for a single player, following the rules of game.Match.
+// The game (and therefore the code) must not be shared with the player until
+// the session is finished.
+type Session struct {
+	// An opaque random id
+	Id   string
+	Game game.Game
+	// The criteria cards, one per verifier slot
+	Criterias []int
+	// The verification cards with the symbol picked for this session
+	Verifiers []string
+	// The rounds played so far, the last one is the current round
+	Rounds []game.Round
+	// True once the player has made their guess
+	Finished bool
+	// True if the guess was correct
+	Won bool
+	// Where the game came from, as set by the creator (see CreateFrom)
+	Origin string
+
+	Created time.Time
+	Updated time.Time
+}
+
+// Returns the solution of this session game
+func (session Session) Code() game.Code {
+	code, _ := session.Game.Solve()
+	return code
+}
+
+// Holds the sessions in memory, safe for concurrent use
+type Manager struct {
+	lock     sync.Mutex
+	sessions map[string]*state
+	// The session of each spectator id
+	spectators map[string]string
+	ttl        time.Duration
+}
+
+// Returns a manager that drops sessions idle for longer than ttl
+func NewManager(ttl time.Duration) *Manager {
+	return &Manager{
+		sessions:   map[string]*state{},
+		spectators: map[string]string{},
+		ttl:        ttl,
+	}
+}
+
+// Creates a new session for a game
+func (manager *Manager) Create(g game.Game) (Session, error) {
+	return manager.CreateFrom(g, "")
+}
+
+// Creates a new session for a game, noting where the game came from (e.g. the
+// token it was requested with)
+func (manager *Manager) CreateFrom(g game.Game, origin string) (Session, error) {
+	match, err := game.NewMatch(g, player)
+	if errors.Is(err, game.ErrGameNoUniqueSolution) {
+		return Session{}, ErrNoSolution
+	} else if err != nil {
+		return Session{}, err
+	}
+	id, err := newId()
+	if err != nil {
+		return Session{}, err
+	}
+	criterias, verifiers, _ := g.GetCards()
+	now := time.Now()
+	s := &state{
+		id:        id,
+		criterias: criterias,
+		verifiers: verifiers,
+		match:     match,
+		origin:    origin,
+		created:   now,
+		updated:   now,
+
+		subscribers: map[chan struct{}]struct{}{},
+		spectators:  map[string]bool{},
+	}
+	s.record(Event{Type: EventRoundStarted, Round: 1})
+
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+	manager.expire(now)
+	manager.sessions[id] = s
+	return s.session(), nil
+}
+
+// Returns a session by id
+func (manager *Manager) Get(id string) (Session, error) {
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+	s, err := manager.get(id)
+	if err != nil {
+		return Session{}, err
+	}
+	return s.session(), nil
+}
+
+// Checks a proposal against the verifier in a given slot, returns the result.
+// Testing a new proposal ends the current round (see game.Match).
+func (manager *Manager) Query(id string, proposal game.Code, slot int) (bool, error) {
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+	s, err := manager.get(id)
+	if err != nil {
+		return false, err
+	}
+	if slot < 0 || slot >= s.match.Game().NumberOfChoices() {
+		return false, game.ErrRoundInvalidVerifier
+	}
+	p, err := s.match.Player(player)
+	if err != nil {
+		return false, err
+	}
+	if current, ok := p.Rounds[len(p.Rounds)-1].Proposal(); ok && current != proposal {
+		round := s.match.Round()
+		if err = s.match.EndTurn(player); err != nil {
+			return false, err
+		}
+		s.updated = time.Now()
+		s.recordRoundChange(round)
+	}
+	check, err := s.match.Query(player, proposal, slot)
+	if err != nil {
+		return false, err
+	}
+	s.updated = time.Now()
+	round := s.match.Round()
+	s.record(Event{Type: EventQuery, Round: round, Proposal: proposal, Slot: slot})
+	s.record(Event{Type: EventResult, Round: round, Proposal: proposal, Slot: slot, Check: check})
+	return check, nil
+}
+
+// Ends the current round of a session, allowing the same proposal to be
+// tested against more verifiers
+func (manager *Manager) EndRound(id string) (Session, error) {
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+	s, err := manager.get(id)
+	if err != nil {
+		return Session{}, err
+	}
+	round := s.match.Round()
+	if err = s.match.EndTurn(player); err != nil {
+		return Session{}, err
+	}
+	s.updated = time.Now()
+	s.recordRoundChange(round)
+	return s.session(), nil
+}
+
+// Makes the final guess for a session, returns the finished session
+func (manager *Manager) Guess(id string, code game.Code) (Session, error) {
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+	s, err := manager.get(id)
+	if err != nil {
+		return Session{}, err
+	}
+	round := s.match.Round()
+	solved, err := s.match.Guess(player, code)
+	if err != nil {
+		return Session{}, err
+	}
+	s.updated = time.Now()
+	s.record(Event{Type: EventGuess, Round: round, Guess: code, Won: solved})
+	s.recordRoundChange(round)
+	return s.session(), nil
+}
+
+// Returns the number of sessions held
+func (manager *Manager) Len() int {
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+	return len(manager.sessions)
+}
+
+/*
+* Helpers
+**/
+
+// The state of a session
+type state struct {
+	id        string
+	criterias []int
+	verifiers []string
+	match     *game.Match
+	origin    string
+	created   time.Time
+	updated   time.Time
+
+	// The events so far, see Manager.Events
+	events []Event
+	// The channels notified on new events
+	subscribers map[chan struct{}]struct{}
+	// The spectator ids, and whether results are hidden from them
+	spectators map[string]bool
+}
+
+// Returns a snapshot of the session that does not share memory with it
+func (s *state) session() Session {
+	p, _ := s.match.Player(player)
+	return Session{
+		Id:        s.id,
+		Game:      s.match.Game(),
+		Criterias: slices.Clone(s.criterias),
+		Verifiers: slices.Clone(s.verifiers),
+		Rounds:    p.Rounds,
+		Finished:  s.match.Finished(),
+		Won:       p.Solved,
+		Origin:    s.origin,
+		Created:   s.created,
+		Updated:   s.updated,
+	}
+}
+
+// Returns a session that has not expired, must hold the lock
+func (manager *Manager) get(id string) (*state, error) {
+	s, ok := manager.sessions[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if time.Since(s.updated) > manager.ttl {
+		manager.drop(id)
+		return nil, ErrNotFound
+	}
+	return s, nil
+}
+
+// Drops all expired sessions, must hold the lock
+func (manager *Manager) expire(now time.Time) {
+	for id, s := range manager.sessions {
+		if now.Sub(s.updated) > manager.ttl {
+			manager.drop(id)
+		}
+	}
+}
+
+// Drops a session and its spectators, must hold the lock
+func (manager *Manager) drop(id string) {
+	if s, ok := manager.sessions[id]; ok {
+		for spectator := range s.spectators {
+			delete(manager.spectators, spectator)
+		}
+		delete(manager.sessions, id)
+	}
+}
+
+// Returns a new random session id
+func newId() (string, error) {
+	raw := make([]byte, idBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
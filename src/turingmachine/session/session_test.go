@@ -0,0 +1,175 @@
+package session_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/replay"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/session"
+)
+
+// Returns a random game with a unique solution
+func randomGame(t testing.TB) game.Game {
+	g, err := game.RandomSolvableGame(5, game.StandardDifficulty)
+	if err != nil {
+		t.Fatalf("Failed to generate random game: %v", err)
+	}
+	return g
+}
+
+// Returns a code different from code
+func otherCode(code game.Code) game.Code {
+	if code == game.MaxCode {
+		return game.CodeFromNumbers(1, 1, 1)
+	}
+	return code.Incr()
+}
+
+func TestSession(t *testing.T) {
+	t.Parallel()
+
+	manager := session.NewManager(session.DefaultTTL)
+	g := randomGame(t)
+	created, err := manager.Create(g)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	if len(created.Id) != 32 || len(created.Criterias) != 5 || len(created.Verifiers) != 5 {
+		t.Fatalf("Unexpected session %+v", created)
+	}
+
+	// The solution passes all verifiers, at most 3 per round
+	code, _ := g.Solve()
+	for slot := range g.NumberOfChoices() {
+		if slot == game.MaxQueriesPerRound {
+			if _, err = manager.Query(created.Id, code, slot); !errors.Is(err, game.ErrRoundTooManyQueries) {
+				t.Fatalf("Expected ErrRoundTooManyQueries, got %v", err)
+			}
+			if _, err = manager.EndRound(created.Id); err != nil {
+				t.Fatalf("Failed to end the round: %v", err)
+			}
+		}
+		check, err := manager.Query(created.Id, code, slot)
+		if err != nil || !check {
+			t.Fatalf("Query(%s, %d) = (%v, %v) for %s, expected (true, nil)",
+				code, slot, check, err, g.Debug())
+		}
+	}
+	if _, err = manager.Query(created.Id, code, 5); !errors.Is(err, game.ErrRoundInvalidVerifier) {
+		t.Errorf("Expected ErrRoundInvalidVerifier, got %v", err)
+	}
+	if _, err = manager.Query("unknown", code, 0); !errors.Is(err, session.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	// A new proposal starts a new round
+	if _, err = manager.Query(created.Id, otherCode(code), 0); err != nil {
+		t.Fatalf("Query with a new proposal failed: %v", err)
+	}
+	current, err := manager.Get(created.Id)
+	if err != nil || len(current.Rounds) != 3 || current.Finished {
+		t.Fatalf("Get(...) = (%+v, %v), expected 3 rounds", current, err)
+	}
+	if proposal, _ := current.Rounds[2].Proposal(); proposal != otherCode(code) {
+		t.Errorf("Expected the last round to test %s, got %s", otherCode(code), proposal)
+	}
+
+	finished, err := manager.Guess(created.Id, code)
+	if err != nil || !finished.Finished || !finished.Won || finished.Code() != code {
+		t.Fatalf("Guess(%s) = (%+v, %v), expected a win", code, finished, err)
+	}
+	if _, err = manager.Query(created.Id, code, 0); !errors.Is(err, game.ErrMatchFinished) {
+		t.Errorf("Expected ErrMatchFinished for a query, got %v", err)
+	}
+	if _, err = manager.Guess(created.Id, code); !errors.Is(err, game.ErrMatchFinished) {
+		t.Errorf("Expected ErrMatchFinished for a second guess, got %v", err)
+	}
+}
+
+func TestSessionWrongGuess(t *testing.T) {
+	t.Parallel()
+
+	manager := session.NewManager(session.DefaultTTL)
+	g := randomGame(t)
+	created, err := manager.Create(g)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	code, _ := g.Solve()
+	finished, err := manager.Guess(created.Id, otherCode(code))
+	if err != nil || !finished.Finished || finished.Won {
+		t.Fatalf("Guess(%s) = (%+v, %v), expected a loss", otherCode(code), finished, err)
+	}
+}
+
+func TestSessionExpiry(t *testing.T) {
+	t.Parallel()
+
+	manager := session.NewManager(10 * time.Millisecond)
+	created, err := manager.Create(randomGame(t))
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if _, err = manager.Get(created.Id); !errors.Is(err, session.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for an expired session, got %v", err)
+	}
+	if _, err = manager.Create(randomGame(t)); err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if _, err = manager.Create(randomGame(t)); err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	if manager.Len() != 1 {
+		t.Errorf("Expected expired sessions to be dropped, got %d sessions", manager.Len())
+	}
+	if _, err = manager.Create(game.Game{}); !errors.Is(err, session.ErrNoSolution) {
+		t.Errorf("Expected ErrNoSolution, got %v", err)
+	}
+}
+
+func TestSessionReplay(t *testing.T) {
+	t.Parallel()
+
+	manager := session.NewManager(session.DefaultTTL)
+	g := randomGame(t)
+	created, err := manager.Create(g)
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	code, _ := g.Solve()
+	queries := []struct {
+		proposal game.Code
+		slot     int
+	}{{code, 0}, {code, 1}, {otherCode(code), 2}, {otherCode(code), 3}}
+	for _, query := range queries {
+		if _, err = manager.Query(created.Id, query.proposal, query.slot); err != nil {
+			t.Fatalf("Query(%s, %d) failed: %v", query.proposal, query.slot, err)
+		}
+	}
+	if _, err = manager.EndRound(created.Id); err != nil {
+		t.Fatalf("Failed to end the round: %v", err)
+	}
+	if _, err = manager.Guess(created.Id, code); err != nil {
+		t.Fatalf("Guess(%s) failed: %v", code, err)
+	}
+
+	r, err := manager.Replay(created.Id)
+	if err != nil {
+		t.Fatalf("Failed to get the replay: %v", err)
+	}
+	if r.Mode != replay.ModeSession || !slices.Equal(r.Verifiers, created.Verifiers) ||
+		len(r.Rounds) != 3 || r.Queries() != len(queries) || r.Guess == nil || !r.Guess.Correct {
+		t.Fatalf("Unexpected replay %+v", r)
+	}
+	if _, err = r.Validate(g); err != nil {
+		t.Fatalf("Failed to validate the replay: %v", err)
+	}
+	if _, err = manager.Replay("unknown"); !errors.Is(err, session.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,296 @@
+package store
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
+)
+
+const (
+	// Exports games as one JSON object per line
+	FormatJSONL Format = iota
+	// Exports games as comma separated values with a header row, lists are
+	// space separated
+	FormatCSV
+)
+
+var (
+	// Error returned when an export format is not known
+	ErrUnknownFormat = errors.New("unknown export format")
+	// Error returned when an imported record does not match its game id
+	ErrRecordMismatch = errors.New("the record does not match the game id")
+	// Error returned when an imported file does not contain any game
+	ErrNoRecords = errors.New("no records to import")
+)
+
+var (
+	// The header row for FormatCSV
+	csvHeader = []string{"id", "criterias", "verifiers", "laws", "code", "difficulty"}
+)
+
+// An export format for the store
+type Format uint8
+
+// Returns the format matching a name ("jsonl" or "csv")
+func FormatFromString(name string) (Format, error) {
+	switch strings.ToLower(name) {
+	case "jsonl":
+		return FormatJSONL, nil
+	case "csv":
+		return FormatCSV, nil
+	}
+	return 0, ErrUnknownFormat
+}
+
+// Returns the name of this format
+func (format Format) String() string {
+	switch format {
+	case FormatJSONL:
+		return "jsonl"
+	case FormatCSV:
+		return "csv"
+	}
+	return "unknown"
+}
+
+// A human readable description of a game in the store.
+// Verifiers are reported with their lozenge (◊) number.
+type Record struct {
+	Id         string `json:"id"`
+	Criterias  []int  `json:"criterias"`
+	Verifiers  []int  `json:"verifiers"`
+	Laws       []int  `json:"laws"`
+	Code       string `json:"code"`
+	Difficulty string `json:"difficulty"`
+}
+
+// Returns the record for a given game.
+// The game must be valid.
+func RecordFromGame(g game.Game) Record {
+	n := g.NumberOfChoices()
+	record := Record{
+		Id:         g.String(),
+		Criterias:  make([]int, n),
+		Verifiers:  make([]int, n),
+		Laws:       make([]int, n),
+		Difficulty: g.Difficulty().String(),
+	}
+	for i := range n {
+		record.Criterias[i] = int(g[i].Criteria().Id)
+		record.Verifiers[i] = int(g[i].Law().VerificationCard.Lozenge())
+		record.Laws[i] = int(g[i].Law().Id)
+	}
+	if code, ok := g.Solve(); ok {
+		record.Code = code.String()
+	}
+	return record
+}
+
+// Returns the game described by this record.
+// The game must pass strict validation and all the other fields of the record
+// must match the game id.
+func (record Record) Game() (game.Game, error) {
+	g, err := game.GameFromString(record.Id)
+	if err != nil {
+		return g, err
+	}
+	if err = g.ValidateStrict(); err != nil {
+		return g, err
+	}
+	expected := RecordFromGame(g)
+	if !slices.Equal(expected.Criterias, record.Criterias) ||
+		!slices.Equal(expected.Verifiers, record.Verifiers) ||
+		!slices.Equal(expected.Laws, record.Laws) ||
+		expected.Code != record.Code ||
+		expected.Difficulty != record.Difficulty {
+		return g, ErrRecordMismatch
+	}
+	return g, nil
+}
+
+// Writes the games in the range [start, end) to w in the given format
+func (store *Store) Export(w io.Writer, format Format, start, end int64) error {
+	buf := bufio.NewWriter(w)
+	var write func(record Record) error
+	var flush func() error
+	switch format {
+	case FormatJSONL:
+		encoder := json.NewEncoder(buf)
+		encoder.SetEscapeHTML(false)
+		write = func(record Record) error {
+			return encoder.Encode(record)
+		}
+		flush = buf.Flush
+	case FormatCSV:
+		writer := csv.NewWriter(buf)
+		if err := writer.Write(csvHeader); err != nil {
+			return err
+		}
+		write = func(record Record) error {
+			return writer.Write([]string{
+				record.Id,
+				joinInts(record.Criterias),
+				joinInts(record.Verifiers),
+				joinInts(record.Laws),
+				record.Code,
+				record.Difficulty,
+			})
+		}
+		flush = func() error {
+			writer.Flush()
+			if err := writer.Error(); err != nil {
+				return err
+			}
+			return buf.Flush()
+		}
+	default:
+		return ErrUnknownFormat
+	}
+
+	err := store.scan(context.Background(), start, end, func(_ int64, g game.Game) error {
+		return write(RecordFromGame(g))
+	})
+	if err != nil {
+		return err
+	}
+	return flush()
+}
+
+// Reads games in the given format from r, validates them, and writes them to
+// a new sorted store in filename (overwriting it).
+// Duplicate games are only written once.
+func Import(r io.Reader, format Format, filename string) (*Store, error) {
+	games, err := readRecords(r, format)
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(games, func(a, b game.Game) int {
+		return a.Value() - b.Value()
+	})
+	games = slices.Compact(games)
+	if err = writeGames(filename, games); err != nil {
+		return nil, err
+	}
+	return OpenStore(filename)
+}
+
+/*
+* Helpers
+**/
+
+// Reads and validates all the records from r in the given format
+func readRecords(r io.Reader, format Format) (games solution, err error) {
+	switch format {
+	case FormatJSONL:
+		games, err = importJSONL(r)
+	case FormatCSV:
+		games, err = importCSV(r)
+	default:
+		err = ErrUnknownFormat
+	}
+	if err == nil && len(games) == 0 {
+		err = ErrNoRecords
+	}
+	return
+}
+
+// Reads all the records from a JSONL reader
+func importJSONL(r io.Reader) (solution, error) {
+	games := solution{}
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		record := Record{}
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		g, err := record.Game()
+		if err != nil {
+			return nil, fmt.Errorf("line %d (%s): %w", line, record.Id, err)
+		}
+		games = append(games, g)
+	}
+	return games, scanner.Err()
+}
+
+// Reads all the records from a CSV reader
+func importCSV(r io.Reader) (solution, error) {
+	games := solution{}
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = len(csvHeader)
+	reader.ReuseRecord = true
+	line := 0
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			return nil, err
+		}
+		if line == 1 && slices.Equal(row, csvHeader) {
+			continue
+		}
+		record := Record{
+			Id:         row[0],
+			Code:       row[4],
+			Difficulty: row[5],
+		}
+		if record.Criterias, err = splitInts(row[1]); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if record.Verifiers, err = splitInts(row[2]); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if record.Laws, err = splitInts(row[3]); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		g, err := record.Game()
+		if err != nil {
+			return nil, fmt.Errorf("line %d (%s): %w", line, record.Id, err)
+		}
+		games = append(games, g)
+	}
+	return games, nil
+}
+
+// Joins a list of ints with spaces
+func joinInts(ints []int) string {
+	sb := strings.Builder{}
+	for i, n := range ints {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(strconv.Itoa(n))
+	}
+	return sb.String()
+}
+
+// Splits a list of space separated ints
+func splitInts(str string) ([]int, error) {
+	fields := strings.Fields(str)
+	ints := make([]int, len(fields))
+	var err error
+	for i, field := range fields {
+		ints[i], err = strconv.Atoi(field)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ints, nil
+}
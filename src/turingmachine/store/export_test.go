@@ -0,0 +1,235 @@
+package store_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/store"
+)
+
+// Returns n random valid games with 4 to 6 choices
+func randomGames(t testing.TB, n int) []game.Game {
+	games := make([]game.Game, n)
+	difficulties := []game.Difficulty{game.EasyDifficulty, game.StandardDifficulty, game.HardDifficulty}
+	var err error
+	for i := range n {
+		games[i], err = game.RandomSolvableGame(4+i%3, difficulties[i%len(difficulties)])
+		if err != nil {
+			t.Fatalf("Failed to generate random game: %v", err)
+		}
+	}
+	return games
+}
+
+// Returns a small store with the given games, and a cleanup function
+func smallStore(t testing.TB, games []game.Game) (*store.Store, func()) {
+	filename := smallStoreFile(t, games)
+	s, err := store.OpenStore(filename)
+	if err != nil {
+		rmFile(filename)
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	return s, func() {
+		_ = s.Close()
+		rmFile(filename)
+	}
+}
+
+// Returns the file name of a small store with the given games
+func smallStoreFile(t testing.TB, games []game.Game) string {
+	buf := &bytes.Buffer{}
+	encoder := json.NewEncoder(buf)
+	for _, g := range games {
+		if err := encoder.Encode(store.RecordFromGame(g)); err != nil {
+			t.Fatalf("Failed to encode game %s: %v", g.Debug(), err)
+		}
+	}
+	filename := tmpFile()
+	s, err := store.Import(buf, store.FormatJSONL, filename)
+	if err != nil {
+		rmFile(filename)
+		t.Fatalf("Failed to import games: %v", err)
+	}
+	if err = s.Close(); err != nil {
+		t.Fatalf("Failed to close store: %v", err)
+	}
+	return filename
+}
+
+// Returns all the games in a store
+func allGames(t testing.TB, s *store.Store) []game.Game {
+	games := make([]game.Game, s.NumberOfGames())
+	var err error
+	for i := range s.NumberOfGames() {
+		games[i], err = s.GetGame(i)
+		if err != nil {
+			t.Fatalf("Failed to read game %d: %v", i, err)
+		}
+	}
+	return games
+}
+
+func TestImport(t *testing.T) {
+	t.Parallel()
+
+	games := randomGames(t, 300)
+	// Duplicate some games to check that those are removed
+	games = append(games, games[:10]...)
+	s, cleanup := smallStore(t, games)
+	defer cleanup()
+
+	stored := allGames(t, s)
+	if !slices.IsSortedFunc(stored, func(a, b game.Game) int { return a.Value() - b.Value() }) {
+		t.Fatal("The imported store is not sorted")
+	}
+	if len(slices.Compact(slices.Clone(stored))) != len(stored) {
+		t.Fatal("The imported store has duplicates")
+	}
+	for _, g := range games {
+		found, err := s.HasGame(g)
+		if err != nil || !found {
+			t.Fatalf("HasGame(%s) = (%v, %v), expected to find it", g.Debug(), found, err)
+		}
+	}
+	for choices := 1; choices <= game.MaxNumberOfChoicesPerGame; choices++ {
+		start, end := s.GameRangeByChoices(choices)
+		for i := start; i < end; i++ {
+			if stored[i].NumberOfChoices() != choices {
+				t.Fatalf("Game %d (%s) is in the range for %d choices",
+					i, stored[i].Debug(), choices)
+			}
+		}
+	}
+}
+
+func TestImportSingleChoiceCount(t *testing.T) {
+	t.Parallel()
+
+	games := make([]game.Game, 50)
+	var err error
+	for i := range games {
+		games[i], err = game.RandomSolvableGame(4, game.HardDifficulty)
+		if err != nil {
+			t.Fatalf("Failed to generate random game: %v", err)
+		}
+	}
+	s, cleanup := smallStore(t, games)
+	defer cleanup()
+
+	for choices := 1; choices <= game.MaxNumberOfChoicesPerGame; choices++ {
+		start, end := s.GameRangeByChoices(choices)
+		if choices == 4 && (start != 0 || end != s.NumberOfGames()) {
+			t.Errorf("Expected all games to have 4 choices, got range [%d, %d)", start, end)
+		}
+		if choices != 4 && start != end {
+			t.Errorf("Expected no games with %d choices, got range [%d, %d)", choices, start, end)
+		}
+	}
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	s, cleanup := smallStore(t, randomGames(t, 200))
+	defer cleanup()
+	expected := allGames(t, s)
+
+	for _, format := range []store.Format{store.FormatJSONL, store.FormatCSV} {
+		t.Run(format.String(), func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			if err := s.Export(buf, format, 0, s.NumberOfGames()); err != nil {
+				t.Fatalf("Failed to export: %v", err)
+			}
+			filename := tmpFile()
+			defer rmFile(filename)
+			imported, err := store.Import(buf, format, filename)
+			if err != nil {
+				t.Fatalf("Failed to import: %v", err)
+			}
+			defer imported.Close()
+			if actual := allGames(t, imported); !slices.Equal(actual, expected) {
+				t.Fatalf("Expected %d games after round trip, got %d different ones",
+					len(expected), len(actual))
+			}
+		})
+	}
+}
+
+func TestExportRange(t *testing.T) {
+	t.Parallel()
+
+	s, cleanup := smallStore(t, randomGames(t, 30))
+	defer cleanup()
+	start, end := s.GameRangeByChoices(5)
+
+	buf := &bytes.Buffer{}
+	if err := s.Export(buf, store.FormatCSV, start, end); err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if int64(len(lines)) != end-start+1 {
+		t.Fatalf("Expected %d lines (with header), got %d", end-start+1, len(lines))
+	}
+	if lines[0] != "id,criterias,verifiers,laws,code,difficulty" {
+		t.Errorf("Unexpected header %q", lines[0])
+	}
+
+	if err := s.Export(buf, store.FormatCSV, 0, s.NumberOfGames()+1); !errors.Is(err, store.ErrInvalidRange) {
+		t.Errorf("Expected ErrInvalidRange for range past the end, got %v", err)
+	}
+	if err := s.Export(buf, store.Format(42), 0, 1); !errors.Is(err, store.ErrUnknownFormat) {
+		t.Errorf("Expected ErrUnknownFormat, got %v", err)
+	}
+}
+
+func TestImportInvalid(t *testing.T) {
+	t.Parallel()
+
+	g, err := game.RandomSolvableGame(5, game.HardDifficulty)
+	if err != nil {
+		t.Fatalf("Failed to generate random game: %v", err)
+	}
+	record := store.RecordFromGame(g)
+	tampered := record
+	tampered.Code = "111"
+	if tampered.Code == record.Code {
+		tampered.Code = "555"
+	}
+
+	testCases := map[string]struct {
+		format   store.Format
+		input    string
+		expected error
+	}{
+		"mismatch": {store.FormatJSONL, mustJSON(t, tampered), store.ErrRecordMismatch},
+		"invalid": {store.FormatCSV, "id,criterias,verifiers,laws,code,difficulty\n" +
+			game.Game{}.String() + ",,,,,easy\n", game.ErrGameEmpty},
+		"empty":   {store.FormatCSV, "id,criterias,verifiers,laws,code,difficulty\n", store.ErrNoRecords},
+		"unknown": {store.Format(42), mustJSON(t, record), store.ErrUnknownFormat},
+	}
+	for name, testCase := range testCases {
+		filename := tmpFile()
+		s, err := store.Import(strings.NewReader(testCase.input), testCase.format, filename)
+		if err == nil {
+			s.Close()
+		}
+		rmFile(filename)
+		if !errors.Is(err, testCase.expected) {
+			t.Errorf("[%s] Import(...) returned %v, expected %v", name, err, testCase.expected)
+		}
+	}
+}
+
+// Returns v as a JSON string
+func mustJSON(t testing.TB, v any) string {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("Failed to marshal %v: %v", v, err)
+	}
+	return string(raw)
+}
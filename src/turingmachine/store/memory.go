@@ -0,0 +1,116 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
+)
+
+// A game store held in memory, useful for tests and small deployments
+type MemoryStore struct {
+	games solution
+	step  choiceSteps
+}
+
+// Returns a memory store with a copy of the given games.
+// The games are sorted and deduplicated, and must pass strict validation.
+func NewMemoryStore(games []game.Game) (*MemoryStore, error) {
+	store := &MemoryStore{
+		games: slices.Clone(games),
+	}
+	for _, g := range store.games {
+		if err := g.ValidateStrict(); err != nil {
+			return nil, fmt.Errorf("game %s: %w", g.Debug(), err)
+		}
+	}
+	slices.SortFunc(store.games, func(a, b game.Game) int {
+		return a.Value() - b.Value()
+	})
+	store.games = slices.Compact(store.games)
+
+	var err error
+	store.step, err = newChoiceSteps(store, int64(len(store.games)))
+	return store, err
+}
+
+// Returns a memory store with the games read from r in the given format (see
+// Import)
+func LoadMemoryStore(r io.Reader, format Format) (*MemoryStore, error) {
+	games, err := readRecords(r, format)
+	if err != nil {
+		return nil, err
+	}
+	return NewMemoryStore(games)
+}
+
+// Returns true if the store contains a given game
+func (store *MemoryStore) HasGame(g game.Game) (bool, error) {
+	_, found, err := indexOf(store, g)
+	return found, err
+}
+
+// Returns the index of a given game and true if the store contains it
+func (store *MemoryStore) IndexOf(g game.Game) (int64, bool, error) {
+	return indexOf(store, g)
+}
+
+// Returns the game at a given index
+func (store *MemoryStore) GetGame(idx int64) (game.Game, error) {
+	if idx < 0 || idx >= int64(len(store.games)) {
+		return game.Game{}, io.EOF
+	}
+	return store.games[idx], nil
+}
+
+// Returns the range [start, end) of game indexes that have a given number of
+// choices.
+// Returns [0, 0] if an invalid number of choices was passed
+func (store *MemoryStore) GameRangeByChoices(choices int) (start, end int64) {
+	return store.step.rangeByChoices(choices)
+}
+
+// Returns the total number of games in this store
+func (store *MemoryStore) NumberOfGames() int64 {
+	return int64(len(store.games))
+}
+
+// Returns a random game in a given range [start, end)
+func (store *MemoryStore) GetRandomGameInRange(start, end int64) (game.Game, error) {
+	return randomGameInRange(store, start, end)
+}
+
+// Returns a random game in a range with a given difficulty
+func (store *MemoryStore) GetRandomGameInRangeWithDifficulty(start, end int64, difficulty game.Difficulty) (game.Game, error) {
+	return randomGameInRangeWithDifficulty(store, start, end, difficulty)
+}
+
+// Does nothing, present to implement GameSource
+func (store *MemoryStore) Close() error {
+	return nil
+}
+
+/*
+* Helpers
+**/
+
+// Calls fn for each game in the range [start, end), stops at the first error
+// returned by fn.
+func (store *MemoryStore) scan(ctx context.Context, start, end int64, fn func(idx int64, g game.Game) error) error {
+	if start < 0 || end > store.NumberOfGames() || start > end {
+		return ErrInvalidRange
+	}
+	for idx := start; idx < end; idx++ {
+		if idx%bufferMultiplier == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		if err := fn(idx, store.games[idx]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package store_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/store"
+)
+
+// Checks that two game sources hold the same games
+func compareGameSources(t *testing.T, expected, actual store.GameSource) {
+	if expected.NumberOfGames() != actual.NumberOfGames() {
+		t.Fatalf("Expected %d games, got %d", expected.NumberOfGames(), actual.NumberOfGames())
+	}
+	for choices := 0; choices <= game.MaxNumberOfChoicesPerGame+1; choices++ {
+		expectedStart, expectedEnd := expected.GameRangeByChoices(choices)
+		actualStart, actualEnd := actual.GameRangeByChoices(choices)
+		if expectedStart != actualStart || expectedEnd != actualEnd {
+			t.Errorf("Expected range [%d, %d) for %d choices, got [%d, %d)",
+				expectedStart, expectedEnd, choices, actualStart, actualEnd)
+		}
+	}
+	for i := range expected.NumberOfGames() {
+		expectedGame, err := expected.GetGame(i)
+		if err != nil {
+			t.Fatalf("Failed to read game %d: %v", i, err)
+		}
+		actualGame, err := actual.GetGame(i)
+		if err != nil || actualGame != expectedGame {
+			t.Fatalf("GetGame(%d) = (%s, %v), expected %s",
+				i, actualGame.Debug(), err, expectedGame.Debug())
+		}
+		found, err := actual.HasGame(expectedGame)
+		if err != nil || !found {
+			t.Fatalf("HasGame(%s) = (%v, %v), expected to find it", expectedGame.Debug(), found, err)
+		}
+		idx, found, err := actual.IndexOf(expectedGame)
+		if err != nil || !found || idx != i {
+			t.Fatalf("IndexOf(%s) = (%d, %v, %v), expected %d", expectedGame.Debug(), idx, found, err, i)
+		}
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	t.Parallel()
+
+	games := randomGames(t, 200)
+	fileStore, cleanup := smallStore(t, games)
+	defer cleanup()
+
+	// Shuffled and duplicated games are fine
+	memoryStore, err := store.NewMemoryStore(append(games[100:], games...))
+	if err != nil {
+		t.Fatalf("Failed to create memory store: %v", err)
+	}
+	compareGameSources(t, fileStore, memoryStore)
+
+	if _, err = memoryStore.GetGame(memoryStore.NumberOfGames()); err == nil {
+		t.Error("Expected an error reading past the end of the store")
+	}
+	start, end := memoryStore.GameRangeByChoices(6)
+	g, err := memoryStore.GetRandomGameInRange(start, end)
+	if err != nil || g.NumberOfChoices() != 6 {
+		t.Errorf("GetRandomGameInRange(%d, %d) = (%s, %v), expected a game with 6 choices",
+			start, end, g.Debug(), err)
+	}
+	g, err = memoryStore.GetRandomGameInRangeWithDifficulty(0, memoryStore.NumberOfGames(), game.EasyDifficulty)
+	if err != nil || g.Difficulty() != game.EasyDifficulty {
+		t.Errorf("GetRandomGameInRangeWithDifficulty(...) = (%s, %v), expected an easy game",
+			g.Debug(), err)
+	}
+	start, end = memoryStore.GameRangeByChoices(2)
+	if _, err = memoryStore.GetRandomGameInRange(start, end); !errors.Is(err, store.ErrEmptyRange) {
+		t.Errorf("Expected ErrEmptyRange for a range without games, got %v", err)
+	}
+
+	// Invalid games are rejected
+	if _, err = store.NewMemoryStore([]game.Game{games[0], {}}); !errors.Is(err, game.ErrGameEmpty) {
+		t.Errorf("Expected ErrGameEmpty, got %v", err)
+	}
+}
+
+func TestLoadMemoryStore(t *testing.T) {
+	t.Parallel()
+
+	fileStore, cleanup := smallStore(t, randomGames(t, 100))
+	defer cleanup()
+
+	buf := &bytes.Buffer{}
+	if err := fileStore.Export(buf, store.FormatCSV, 0, fileStore.NumberOfGames()); err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+	memoryStore, err := store.LoadMemoryStore(buf, store.FormatCSV)
+	if err != nil {
+		t.Fatalf("Failed to load memory store: %v", err)
+	}
+	compareGameSources(t, fileStore, memoryStore)
+}
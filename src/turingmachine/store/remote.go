@@ -0,0 +1,266 @@
+package store
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
+)
+
+const (
+	// The default number of bytes fetched with each range request, a
+	// multiple of the size of a game
+	DefaultRemoteBlockSize = game.MaxNumberOfChoicesPerGame << 13
+	// The default number of blocks kept in memory
+	DefaultRemoteCacheBlocks = 256
+	// The default number of retries for a failed request
+	DefaultRemoteRetries = 3
+	// The default delay before the first retry, doubled for each retry
+	DefaultRemoteRetryDelay = 100 * time.Millisecond
+	// The default timeout for each request
+	DefaultRemoteTimeout = 30 * time.Second
+)
+
+var (
+	// Error returned when the server does not honor range requests
+	ErrRangeNotSupported = errors.New("the server does not support range requests")
+	// Error returned when the server responds with an unexpected status
+	ErrRemoteStatus = errors.New("unexpected response status")
+	// Error returned when the server responds with a malformed Content-Range
+	ErrRemoteContentRange = errors.New("invalid Content-Range in response")
+)
+
+// Options for OpenRemoteStore
+type RemoteOptions struct {
+	// The client used for the requests
+	Client *http.Client
+	// The number of bytes fetched with each range request
+	BlockSize int64
+	// The number of blocks kept in memory
+	CacheBlocks int
+	// The number of retries for a failed request, client errors (4xx) are not
+	// retried
+	Retries int
+	// The delay before the first retry, doubled for each retry
+	RetryDelay time.Duration
+}
+
+// Returns a RemoteOptions with the default values
+func NewRemoteOptions() RemoteOptions {
+	return RemoteOptions{
+		Client:      &http.Client{Timeout: DefaultRemoteTimeout},
+		BlockSize:   DefaultRemoteBlockSize,
+		CacheBlocks: DefaultRemoteCacheBlocks,
+		Retries:     DefaultRemoteRetries,
+		RetryDelay:  DefaultRemoteRetryDelay,
+	}
+}
+
+// Opens a game store served at url, the games are read with HTTP range
+// requests and cached in blocks.
+// The server must support range requests (like most static file hosts).
+func OpenRemoteStore(url string, opts RemoteOptions) (*Store, error) {
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	opts.BlockSize = max(opts.BlockSize, 1)
+	opts.CacheBlocks = max(opts.CacheBlocks, 1)
+	file := &remoteFile{
+		url:    url,
+		opts:   opts,
+		blocks: map[int64]*list.Element{},
+		lru:    list.New(),
+	}
+	size, err := file.fetchSize()
+	if err != nil {
+		return nil, err
+	}
+	file.size = size
+	slog.Info("opened remote store", "url", url, "size", size)
+	return newStore(file, file, size)
+}
+
+/*
+* Helpers
+**/
+
+// A remote file read with HTTP range requests
+type remoteFile struct {
+	url  string
+	opts RemoteOptions
+	size int64
+
+	// Guards blocks and lru
+	lock sync.Mutex
+	// The cached blocks by number
+	blocks map[int64]*list.Element
+	// The cached blocks, the most recently used at the front
+	lru *list.List
+}
+
+// A block of a remote file
+type remoteBlock struct {
+	number int64
+	data   []byte
+}
+
+// Reads len(p) bytes at offset off, see io.ReaderAt
+func (file *remoteFile) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, ErrInvalidRange
+	}
+	for n < len(p) {
+		if off >= file.size {
+			return n, io.EOF
+		}
+		number := off / file.opts.BlockSize
+		data, err := file.block(number)
+		if err != nil {
+			return n, err
+		}
+		copied := copy(p[n:], data[off-number*file.opts.BlockSize:])
+		n += copied
+		off += int64(copied)
+	}
+	return n, nil
+}
+
+// Drops the cache and idle connections
+func (file *remoteFile) Close() error {
+	file.lock.Lock()
+	defer file.lock.Unlock()
+	file.blocks = map[int64]*list.Element{}
+	file.lru.Init()
+	file.opts.Client.CloseIdleConnections()
+	return nil
+}
+
+// Returns a block from the cache or fetches it
+func (file *remoteFile) block(number int64) ([]byte, error) {
+	file.lock.Lock()
+	if element, ok := file.blocks[number]; ok {
+		file.lru.MoveToFront(element)
+		file.lock.Unlock()
+		return element.Value.(*remoteBlock).data, nil
+	}
+	file.lock.Unlock()
+
+	// Concurrent reads of the same block might fetch it twice, which is fine
+	start := number * file.opts.BlockSize
+	end := min(start+file.opts.BlockSize, file.size)
+	data, err := file.fetchRange(start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	file.lock.Lock()
+	defer file.lock.Unlock()
+	if element, ok := file.blocks[number]; ok {
+		file.lru.MoveToFront(element)
+		return element.Value.(*remoteBlock).data, nil
+	}
+	file.blocks[number] = file.lru.PushFront(&remoteBlock{number: number, data: data})
+	for file.lru.Len() > file.opts.CacheBlocks {
+		oldest := file.lru.Remove(file.lru.Back()).(*remoteBlock)
+		delete(file.blocks, oldest.number)
+	}
+	return data, nil
+}
+
+// Returns the bytes in [start, end) of the remote file
+func (file *remoteFile) fetchRange(start, end int64) (data []byte, err error) {
+	err = file.retry(func() (bool, error) {
+		resp, err := file.get(fmt.Sprintf("bytes=%d-%d", start, end-1))
+		if err != nil {
+			return true, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			return false, ErrRangeNotSupported
+		}
+		if resp.StatusCode != http.StatusPartialContent {
+			return retryStatus(resp)
+		}
+		data = make([]byte, end-start)
+		_, err = io.ReadFull(resp.Body, data)
+		return true, err
+	})
+	return
+}
+
+// Returns the size of the remote file
+func (file *remoteFile) fetchSize() (size int64, err error) {
+	err = file.retry(func() (bool, error) {
+		resp, err := file.get("bytes=0-0")
+		if err != nil {
+			return true, err
+		}
+		defer resp.Body.Close()
+		switch resp.StatusCode {
+		case http.StatusOK:
+			return false, ErrRangeNotSupported
+		case http.StatusPartialContent, http.StatusRequestedRangeNotSatisfiable:
+			// An empty file is not satisfiable, but still reports its size
+			size, err = contentRangeSize(resp.Header.Get("Content-Range"))
+			return false, err
+		}
+		return retryStatus(resp)
+	})
+	return
+}
+
+// Sends a GET request for a given range
+func (file *remoteFile) get(byteRange string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, file.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", byteRange)
+	return file.opts.Client.Do(req)
+}
+
+// Calls fn until it succeeds, returns a non-retryable error, or it runs out
+// of retries
+func (file *remoteFile) retry(fn func() (retryable bool, err error)) error {
+	delay := file.opts.RetryDelay
+	for try := 0; ; try++ {
+		retryable, err := fn()
+		if err == nil || !retryable || try >= file.opts.Retries {
+			return err
+		}
+		slog.Warn("remote store request failed, retrying",
+			"url", file.url,
+			"try", try+1,
+			"err", err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// Returns the error for an unexpected response status, only server errors
+// are retryable
+func retryStatus(resp *http.Response) (bool, error) {
+	return resp.StatusCode >= 500, fmt.Errorf("%w: %s", ErrRemoteStatus, resp.Status)
+}
+
+// Returns the complete length from a Content-Range header ("bytes 0-0/42" or
+// "bytes */42")
+func contentRangeSize(contentRange string) (int64, error) {
+	_, size, found := strings.Cut(contentRange, "/")
+	if !found || !strings.HasPrefix(contentRange, "bytes ") {
+		return 0, fmt.Errorf("%w: %q", ErrRemoteContentRange, contentRange)
+	}
+	n, err := strconv.ParseInt(size, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%w: %q", ErrRemoteContentRange, contentRange)
+	}
+	return n, nil
+}
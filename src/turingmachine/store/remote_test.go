@@ -0,0 +1,129 @@
+package store_test
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/store"
+)
+
+// Returns a test server for a store file that fails the first failures
+// requests, and a counter of the requests received
+func remoteStoreServer(t *testing.T, filename string, failures int64) (*httptest.Server, *atomic.Int64) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", filename, err)
+	}
+	requests := &atomic.Int64{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		http.ServeContent(w, r, "games", time.Time{}, bytes.NewReader(data))
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+// Returns remote options for tests, with a small block size and cache
+func testRemoteOptions() store.RemoteOptions {
+	opts := store.NewRemoteOptions()
+	opts.BlockSize = 6 * 16
+	opts.CacheBlocks = 4
+	opts.RetryDelay = time.Millisecond
+	return opts
+}
+
+func TestRemoteStore(t *testing.T) {
+	t.Parallel()
+
+	filename := smallStoreFile(t, randomGames(t, 200))
+	defer rmFile(filename)
+	fileStore, err := store.OpenStore(filename)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer fileStore.Close()
+	server, requests := remoteStoreServer(t, filename, 0)
+
+	remoteStore, err := store.OpenRemoteStore(server.URL, testRemoteOptions())
+	if err != nil {
+		t.Fatalf("Failed to open remote store: %v", err)
+	}
+	defer remoteStore.Close()
+	compareGameSources(t, fileStore, remoteStore)
+
+	// Cached blocks are not fetched again
+	if _, err = remoteStore.GetGame(0); err != nil {
+		t.Fatalf("Failed to read game 0: %v", err)
+	}
+	before := requests.Load()
+	for range 10 {
+		if _, err = remoteStore.GetGame(1); err != nil {
+			t.Fatalf("Failed to read game 1: %v", err)
+		}
+	}
+	if after := requests.Load(); after != before {
+		t.Errorf("Expected cached reads, got %d new requests", after-before)
+	}
+	if _, err = remoteStore.GetGame(remoteStore.NumberOfGames()); err == nil {
+		t.Error("Expected an error reading past the end of the store")
+	}
+}
+
+func TestRemoteStoreRetries(t *testing.T) {
+	t.Parallel()
+
+	games := randomGames(t, 20)
+	filename := smallStoreFile(t, games)
+	defer rmFile(filename)
+
+	// Fails less than the number of retries
+	server, _ := remoteStoreServer(t, filename, store.DefaultRemoteRetries)
+	remoteStore, err := store.OpenRemoteStore(server.URL, testRemoteOptions())
+	if err != nil {
+		t.Fatalf("Expected the store to open after retries, got %v", err)
+	}
+	remoteStore.Close()
+
+	// Fails more than the number of retries
+	server, _ = remoteStoreServer(t, filename, store.DefaultRemoteRetries+1)
+	if _, err = store.OpenRemoteStore(server.URL, testRemoteOptions()); !errors.Is(err, store.ErrRemoteStatus) {
+		t.Errorf("Expected ErrRemoteStatus, got %v", err)
+	}
+}
+
+func TestRemoteStoreInvalid(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		handler  http.HandlerFunc
+		expected error
+	}{
+		"no range": {func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write(make([]byte, 60))
+		}, store.ErrRangeNotSupported},
+		"not found": {http.NotFound, store.ErrRemoteStatus},
+		"invalid size": {func(w http.ResponseWriter, r *http.Request) {
+			http.ServeContent(w, r, "games", time.Time{}, bytes.NewReader(make([]byte, 61)))
+		}, store.ErrInvalidFile},
+	}
+	for name, testCase := range testCases {
+		server := httptest.NewServer(testCase.handler)
+		s, err := store.OpenRemoteStore(server.URL, testRemoteOptions())
+		if err == nil {
+			s.Close()
+		}
+		server.Close()
+		if !errors.Is(err, testCase.expected) {
+			t.Errorf("[%s] OpenRemoteStore(...) returned %v, expected %v", name, err, testCase.expected)
+		}
+	}
+}
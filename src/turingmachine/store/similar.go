@@ -0,0 +1,181 @@
+package store
+
+import (
+	"container/heap"
+	"context"
+	"log/slog"
+	"math/bits"
+	"slices"
+	"time"
+
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
+)
+
+const (
+	// The bits of an index entry used for the criteria id mask
+	indexCriteriaMask = 1<<game.NumberOfCriterias - 1
+	// The shift for the code index in an index entry
+	indexCodeShift = game.NumberOfCriterias
+	// Code index used for games without a unique solution
+	indexNoCode = 0x7F
+)
+
+// An in-memory index of the criterias and solution of every game in a source.
+// Used to find games similar to a given one.
+type CriteriaIndex struct {
+	source GameSource
+	// For each game in the source: the criteria id mask in the lower bits and
+	// the index of the solution code from bit indexCodeShift.
+	entries []uint64
+}
+
+// A game similar to another one
+type Similar struct {
+	// The index of the game in the source
+	Index int64
+	Game  game.Game
+	// The number of criteria cards shared with the original game
+	SharedCriterias int
+	// True if the game has the same solution code as the original game
+	SameCode bool
+	// True if the game is a single choice swap away from the original game
+	Swap bool
+}
+
+// Builds a criteria index for all the games in a source
+func NewCriteriaIndex(ctx context.Context, source GameSource) (*CriteriaIndex, error) {
+	start := time.Now()
+	index := &CriteriaIndex{
+		source:  source,
+		entries: make([]uint64, source.NumberOfGames()),
+	}
+	err := scan(ctx, source, 0, source.NumberOfGames(), func(idx int64, g game.Game) error {
+		index.entries[idx] = indexEntry(g)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("criteria index initialized",
+		"games", len(index.entries),
+		"duration", time.Since(start).String())
+	return index, nil
+}
+
+// Returns up to limit games in the source similar to g, excluding g itself.
+// A game is similar if it shares at least minShared criteria cards with g,
+// has the same solution code, or is a single choice swap away (see
+// game.Game.Neighbours).
+// The results are ranked by the number of shared criterias, then swaps first,
+// then same code first, and finally by index.
+func (index *CriteriaIndex) Similar(g game.Game, minShared int, limit int) ([]Similar, error) {
+	if limit <= 0 {
+		return []Similar{}, nil
+	}
+	target := indexEntry(g)
+	targetMask := target & indexCriteriaMask
+	targetCode := target >> indexCodeShift
+
+	// Find g and its swaps in this source
+	self, hasSelf, err := indexOf(index.source, g)
+	if err != nil {
+		return nil, err
+	}
+	swaps := []int64{}
+	for _, neighbour := range g.Neighbours() {
+		idx, found, err := indexOf(index.source, neighbour)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			swaps = append(swaps, idx)
+		}
+	}
+	slices.Sort(swaps)
+
+	// Keep the best candidates
+	candidates := &similarHeap{}
+	for i, entry := range index.entries {
+		idx := int64(i)
+		if hasSelf && idx == self {
+			continue
+		}
+		candidate := Similar{
+			Index:           idx,
+			SharedCriterias: bits.OnesCount64(entry & targetMask),
+			SameCode:        targetCode != indexNoCode && entry>>indexCodeShift == targetCode,
+		}
+		// Swaps are sorted, so we only need to check the next one
+		if len(swaps) > 0 && swaps[0] == idx {
+			candidate.Swap = true
+			swaps = swaps[1:]
+		}
+		if candidate.SharedCriterias < minShared && !candidate.SameCode && !candidate.Swap {
+			continue
+		}
+		if candidates.Len() == limit {
+			if !candidate.better((*candidates)[0]) {
+				continue
+			}
+			heap.Pop(candidates)
+		}
+		heap.Push(candidates, candidate)
+	}
+
+	// Sort and load the results
+	similar := slices.Clone(*candidates)
+	slices.SortFunc(similar, func(a, b Similar) int {
+		if a.better(b) {
+			return -1
+		}
+		return 1
+	})
+	for i := range similar {
+		similar[i].Game, err = index.source.GetGame(similar[i].Index)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return similar, nil
+}
+
+/*
+* Helpers
+**/
+
+// Returns the index entry for a given game
+func indexEntry(g game.Game) uint64 {
+	var code uint64 = indexNoCode
+	if solution, ok := g.Solve(); ok {
+		code = uint64(solution.GetIndex())
+	}
+	return g.CriteriaIdMask() | code<<indexCodeShift
+}
+
+// Returns true if this similar game should rank before other
+func (similar Similar) better(other Similar) bool {
+	if similar.SharedCriterias != other.SharedCriterias {
+		return similar.SharedCriterias > other.SharedCriterias
+	}
+	if similar.Swap != other.Swap {
+		return similar.Swap
+	}
+	if similar.SameCode != other.SameCode {
+		return similar.SameCode
+	}
+	return similar.Index < other.Index
+}
+
+// A min-heap of similar games, the worst one at the top
+type similarHeap []Similar
+
+func (h similarHeap) Len() int           { return len(h) }
+func (h similarHeap) Less(i, j int) bool { return h[j].better(h[i]) }
+func (h similarHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *similarHeap) Push(x any)        { *h = append(*h, x.(Similar)) }
+func (h *similarHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
@@ -0,0 +1,81 @@
+package store_test
+
+import (
+	"context"
+	"math/bits"
+	"testing"
+
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/store"
+)
+
+func TestCriteriaIndexSimilar(t *testing.T) {
+	t.Parallel()
+
+	games := randomGames(t, 300)
+	target := games[0]
+	// Make sure some swaps are in the store
+	neighbours := target.Neighbours()
+	if len(neighbours) < 3 {
+		t.Fatalf("Expected %s to have neighbours", target.Debug())
+	}
+	games = append(games, neighbours[:3]...)
+	s, cleanup := smallStore(t, games)
+	defer cleanup()
+
+	index, err := store.NewCriteriaIndex(context.Background(), s)
+	if err != nil {
+		t.Fatalf("Failed to build index: %v", err)
+	}
+
+	minShared := 2
+	similar, err := index.Similar(target, minShared, 20)
+	if err != nil {
+		t.Fatalf("Similar returned error: %v", err)
+	}
+	if len(similar) == 0 || len(similar) > 20 {
+		t.Fatalf("Expected between 1 and 20 similar games, got %d", len(similar))
+	}
+	targetCode, _ := target.Solve()
+	swaps := 0
+	for i, result := range similar {
+		if result.Game == target {
+			t.Fatalf("Similar returned the target game")
+		}
+		if g, _ := s.GetGame(result.Index); g != result.Game {
+			t.Fatalf("Result %d has index %d but game %s", i, result.Index, result.Game.Debug())
+		}
+		shared := bits.OnesCount64(result.Game.CriteriaIdMask() & target.CriteriaIdMask())
+		code, _ := result.Game.Solve()
+		if shared != result.SharedCriterias || (code == targetCode) != result.SameCode {
+			t.Fatalf("Result %d (%s) reported shared=%d sameCode=%v, expected %d and %v",
+				i, result.Game.Debug(), result.SharedCriterias, result.SameCode, shared, code == targetCode)
+		}
+		if result.SharedCriterias < minShared && !result.SameCode && !result.Swap {
+			t.Fatalf("Result %d (%s) is not similar", i, result.Game.Debug())
+		}
+		if i > 0 && similar[i-1].SharedCriterias < result.SharedCriterias {
+			t.Fatalf("Results are not ranked by shared criterias: %d < %d",
+				similar[i-1].SharedCriterias, result.SharedCriterias)
+		}
+		if result.Swap {
+			swaps++
+		}
+	}
+	// The random games may include more neighbours of the target
+	if swaps < 3 {
+		t.Errorf("Expected at least 3 swaps, got %d", swaps)
+	}
+
+	if similar, err = index.Similar(target, minShared, 0); err != nil || len(similar) != 0 {
+		t.Errorf("Similar(..., 0) = (%v, %v), expected no results", similar, err)
+	}
+	// Games not in the store can be used too
+	other, err := game.RandomSolvableGame(6, game.HardDifficulty)
+	if err != nil {
+		t.Fatalf("Failed to generate random game: %v", err)
+	}
+	if _, err = index.Similar(other, 1, 5); err != nil {
+		t.Errorf("Similar returned error for a game outside the store: %v", err)
+	}
+}
@@ -20,15 +20,11 @@ const (
 
 // solves for all the possible games and writes the solutions to a file
 func solve(filename string) (err error) {
-	// Delete any existing file (if present)
-	err = os.Remove(filename)
-	if err != nil && !errors.Is(err, os.ErrNotExist) {
-		slog.Error("failed to delete existing file",
-			"filename", filename,
-			"err", err)
-		return
-	}
+	return writeGames(filename, generate())
+}
 
+// Generates all the possible games, sorted by Game.Value
+func generate() solution {
 	// Start a thread for each initial choice/move. This way we can scale with
 	// the number of available CPUs while allocating only a constant amount of
 	// RAM.
@@ -61,18 +57,30 @@ func solve(filename string) (err error) {
 	slog.Info("sorted solutions",
 		"solutions", len(result),
 		"duration", time.Since(start).String())
+	return result
+}
+
+// Writes a set of (sorted) games to a file, replacing any existing file
+func writeGames(filename string, games solution) (err error) {
+	// Delete any existing file (if present)
+	err = os.Remove(filename)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		slog.Error("failed to delete existing file",
+			"filename", filename,
+			"err", err)
+		return
+	}
 
 	// Store solutions
-	start = time.Now()
+	start := time.Now()
 	file, err := os.OpenFile(filename, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
 	if err != nil {
 		return err
 	}
 	buf := make([]byte, writeBufferSize)
 	bufIdx := 0
-	for i := range len(result) {
-		// err = game.Write(file)
-		result[i].WriteTo(buf, bufIdx*game.MaxNumberOfChoicesPerGame)
+	for i := range len(games) {
+		games[i].WriteTo(buf, bufIdx*game.MaxNumberOfChoicesPerGame)
 		bufIdx += 1
 		if bufIdx == bufferMultiplier {
 			// Write buffer
@@ -81,6 +89,7 @@ func solve(filename string) (err error) {
 				slog.Error("got error while writing game to file",
 					"err", err,
 					"duration", time.Since(start).String())
+				_ = file.Close()
 				return err
 			}
 			bufIdx = 0
@@ -92,10 +101,12 @@ func solve(filename string) (err error) {
 		slog.Error("got error while writing game to file",
 			"err", err,
 			"duration", time.Since(start).String())
+		_ = file.Close()
 		return err
 	}
 	if err := file.Sync(); err != nil {
 		slog.Error("got error while syncing file", "err", err)
+		_ = file.Close()
 		return err
 	}
 	if err := file.Close(); err != nil {
@@ -0,0 +1,167 @@
+package store
+
+import (
+	"context"
+	"math/rand"
+
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
+)
+
+// A source of valid games sorted by Game.Value (and therefore grouped by
+// number of choices).
+type GameSource interface {
+	// Returns the total number of games
+	NumberOfGames() int64
+	// Returns the game at a given index
+	GetGame(idx int64) (game.Game, error)
+	// Returns true if the source contains a given game
+	HasGame(g game.Game) (bool, error)
+	// Returns the index of a given game and true if the source contains it
+	IndexOf(g game.Game) (int64, bool, error)
+	// Returns the range [start, end) of game indexes that have a given number
+	// of choices
+	GameRangeByChoices(choices int) (start, end int64)
+	// Returns a random game in a given range [start, end)
+	GetRandomGameInRange(start, end int64) (game.Game, error)
+	// Returns a random game in a range with a given difficulty
+	GetRandomGameInRangeWithDifficulty(start, end int64, difficulty game.Difficulty) (game.Game, error)
+	// Releases any resource held by the source
+	Close() error
+}
+
+// The minimum needed to read games by index
+type gameReader interface {
+	NumberOfGames() int64
+	GetGame(idx int64) (game.Game, error)
+}
+
+// Implemented by game sources that can read a range of games faster than
+// calling GetGame for each one
+type rangeScanner interface {
+	scan(ctx context.Context, start, end int64, fn func(idx int64, g game.Game) error) error
+}
+
+// step[i] is the count of all games with [0, (i+1)] choices.
+// ex: step[2] = how many games are there with 1 + 2 + 3 choices
+type choiceSteps [game.MaxNumberOfChoicesPerGame]int64
+
+// Computes the steps for a source with nGames sorted games
+func newChoiceSteps(src gameReader, nGames int64) (step choiceSteps, err error) {
+	step[5] = nGames
+	for i := int64(4); i >= 0; i-- {
+		step[i], err = firstGameWithChoices(src, int(i+2), 0, step[i+1])
+		if err != nil {
+			break
+		}
+	}
+	return
+}
+
+// Returns the range [start, end) of game indexes that have a given number of
+// choices.
+// Returns [0, 0] if an invalid number of choices was passed
+func (step choiceSteps) rangeByChoices(choices int) (start, end int64) {
+	if choices <= 0 || choices > game.MaxNumberOfChoicesPerGame {
+		return
+	}
+	if choices > 1 {
+		start = step[choices-2]
+	}
+	end = step[choices-1]
+	return
+}
+
+// Returns the total number of games
+func (step choiceSteps) total() int64 {
+	return step[game.MaxNumberOfChoicesPerGame-1]
+}
+
+// Returns the index of the first game to have at least target choices within
+// the range [start, end), or end if there is no such game.
+func firstGameWithChoices(src gameReader, target int, start, end int64) (int64, error) {
+	for start < end {
+		mid := (start + end) >> 1
+		game, err := src.GetGame(mid)
+		if err != nil {
+			return -1, err
+		}
+		if game.NumberOfChoices() < target {
+			start = mid + 1
+			continue
+		}
+		end = mid
+	}
+	return start, nil
+}
+
+// Returns the index of a given game and true if the source contains it
+func indexOf(src gameReader, g game.Game) (int64, bool, error) {
+	start, end := int64(0), src.NumberOfGames()
+	for start < end {
+		mid := (start + end) >> 1
+		sg, err := src.GetGame(mid)
+		if err != nil {
+			return -1, false, err
+		}
+		if sg == g {
+			return mid, true, nil
+		}
+		if sg.Value() < g.Value() {
+			start = mid + 1
+			continue
+		}
+		end = mid
+	}
+	return -1, false, nil
+}
+
+// Returns a random game in a given range [start, end)
+func randomGameInRange(src gameReader, start, end int64) (game.Game, error) {
+	if end <= start {
+		return game.Game{}, ErrEmptyRange
+	}
+	return src.GetGame(start + rand.Int63n(end-start))
+}
+
+// Returns a random game in a range with a given difficulty
+func randomGameInRangeWithDifficulty(src gameReader, start, end int64, difficulty game.Difficulty) (game.Game, error) {
+	if end <= start {
+		return game.Game{}, ErrEmptyRange
+	}
+	game, err := src.GetGame(start + rand.Int63n(end-start))
+	maxTries := RandomGameMaxRetries
+	for err == nil && game.Difficulty() != difficulty && maxTries > 0 {
+		game, err = src.GetGame(start + rand.Int63n(end-start))
+		maxTries--
+	}
+	if maxTries == 0 {
+		err = ErrMaxRetries
+	}
+	return game, err
+}
+
+// Calls fn for each game in the range [start, end) of a source, stops at the
+// first error returned by fn.
+func scan(ctx context.Context, src GameSource, start, end int64, fn func(idx int64, g game.Game) error) error {
+	if scanner, ok := src.(rangeScanner); ok {
+		return scanner.scan(ctx, start, end, fn)
+	}
+	if start < 0 || end > src.NumberOfGames() || start > end {
+		return ErrInvalidRange
+	}
+	for idx := start; idx < end; idx++ {
+		if idx%bufferMultiplier == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		g, err := src.GetGame(idx)
+		if err != nil {
+			return err
+		}
+		if err = fn(idx, g); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,10 +1,12 @@
 package store
 
 import (
+	"bufio"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
-	"math/rand"
 	"os"
 	"strings"
 	"time"
@@ -24,31 +26,37 @@ var (
 	// Error returned when we exceeded the threashold of retries for a random
 	// game search
 	ErrMaxRetries = errors.New("too many failed tries")
+	// Error returned when a range of games is outside of the store
+	ErrInvalidRange = errors.New("the range is outside of the store")
+	// Error returned when picking a random game in a range without games
+	ErrEmptyRange = errors.New("the range does not contain any game")
 )
 
 // A database for valid games
 type Store struct {
-	file *os.File
-	// step indicates the count of all games with [0, (i+1)] choices.
-	// ex: step[2] = how many games are there with 1 + 2 + 3 choices
-	step [game.MaxNumberOfChoicesPerGame]int64
+	// The raw games, usually a file
+	data io.ReaderAt
+	// Closes data
+	closer io.Closer
+	// The size of data in bytes
+	size int64
+	step choiceSteps
 }
 
 // Opens a game store
 func OpenStore(filename string) (*Store, error) {
 	// 1. Open the file
-	store := &Store{}
-	var err error
-	store.file, err = os.Open(filename)
+	file, err := os.Open(filename)
 	if err != nil {
 		return nil, err
 	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
 	// 2. Populate step
-	start := time.Now()
-	defer func() {
-		slog.Info("store initialized", "duration", time.Since(start))
-	}()
-	return store, store.init()
+	return newStore(file, file, info.Size())
 }
 
 // Creates (or overwrites) a game store
@@ -75,150 +83,114 @@ func (store *Store) Debug() string {
 
 // Returns true if the store contains a given game
 func (store *Store) HasGame(g game.Game) (bool, error) {
-	start, end := int64(0), store.NumberOfGames()
-
-	var (
-		sg  game.Game
-		err error
-	)
-
-	for start < end {
-		mid := (start + end) >> 1
-		sg, err = store.GetGame(mid)
-		if err != nil {
-			return false, err
-		}
-		if sg == g {
-			return true, nil
-		}
-		if sg.Value() < g.Value() {
-			start = mid + 1
-			continue
-		}
-		end = mid
-	}
-	sg, err = store.GetGame(start)
-	return sg == g, err
+	_, found, err := indexOf(store, g)
+	return found, err
+}
+
+// Returns the index of a given game and true if the store contains it
+func (store *Store) IndexOf(g game.Game) (int64, bool, error) {
+	return indexOf(store, g)
 }
 
 // Returns the game at a given index
 func (store *Store) GetGame(idx int64) (game.Game, error) {
-	return game.GameFromReader(store.file, idx)
+	return game.GameFromReader(store.data, idx)
 }
 
 // Returns the range [start, end) of game indexes that have a given number of
 // choices.
 // Returns [0, 0] if an invalid number of choices was passed
 func (store *Store) GameRangeByChoices(choices int) (start, end int64) {
-	if choices == 0 || choices > game.MaxNumberOfChoicesPerGame {
-		return
-	}
-	if choices > 1 {
-		start = store.step[choices-2]
-	}
-	end = store.step[choices-1]
-	return
+	return store.step.rangeByChoices(choices)
 }
 
 // Returns the total number of games in this store
 func (store *Store) NumberOfGames() int64 {
-	return store.step[game.MaxNumberOfChoicesPerGame-1]
+	return store.step.total()
 }
 
 // Returns a random game in a given range [start, end)
 func (store *Store) GetRandomGameInRange(start, end int64) (game.Game, error) {
-	return store.GetGame(start + rand.Int63n(end-start))
+	return randomGameInRange(store, start, end)
 }
 
 // Returns a random game in a range with a given difficulty
 func (store *Store) GetRandomGameInRangeWithDifficulty(start, end int64, difficulty game.Difficulty) (game.Game, error) {
-	game, err := store.GetGame(start + rand.Int63n(end-start))
-	maxTries := RandomGameMaxRetries
-	for err == nil && game.Difficulty() != difficulty && maxTries > 0 {
-		game, err = store.GetGame(start + rand.Int63n(end-start))
-		maxTries--
-	}
-	if maxTries == 0 {
-		err = ErrMaxRetries
-	}
-	return game, err
+	return randomGameInRangeWithDifficulty(store, start, end, difficulty)
 }
 
 // Closes the store underlying file
 func (store *Store) Close() error {
-	return store.file.Close()
+	return store.closer.Close()
 }
 
 /*
 * Helpers
 **/
 
+// Returns a store reading size bytes of games from data, closer is closed
+// if the store cannot be initialized.
+func newStore(data io.ReaderAt, closer io.Closer, size int64) (*Store, error) {
+	start := time.Now()
+	store := &Store{
+		data:   data,
+		closer: closer,
+		size:   size,
+	}
+	if err := store.init(); err != nil {
+		closer.Close()
+		return nil, err
+	}
+	slog.Info("store initialized", "duration", time.Since(start))
+	return store, nil
+}
+
 // Helper function to be called on create.
 func (store *Store) init() error {
 	nGames, err := store.numberOfGames()
 	if err != nil {
 		return err
 	}
-	store.step[5] = nGames
-	for i := int64(4); i >= 0; i-- {
-		store.step[i], err = store.firstGameInRangeWithChoices(int(i+2), 0, store.step[i+1])
-		if err != nil {
-			break
-		}
-		if store.step[i] == -1 {
-			store.step[i] = 0
-		}
-	}
+	store.step, err = newChoiceSteps(store, nGames)
 	return err
 }
 
-// Returns the index of the first game to have target choices within the range
-// [start, end].
-// If not found returns -1. On error getting a game, it returns -1 & the error.
-func (store *Store) firstGameInRangeWithChoices(target int, start, end int64) (idx int64, err error) {
-	var (
-		mid     int64
-		game    game.Game
-		choices int
-	)
-	for start <= end {
-		mid = (start + end) >> 1
-		game, err = store.GetGame(mid)
-		if err != nil {
-			return -1, err
-		}
-		choices = game.NumberOfChoices()
-		if choices == target {
-			if mid == 0 {
-				return mid, nil
-			}
-			// Check if the previous game is different
-			game, err = store.GetGame(mid - 1)
-			if err != nil {
-				return -1, err
-			}
-			choices = game.NumberOfChoices()
-			if choices != target {
-				return mid, nil
+// Calls fn for each game in the range [start, end) reading the file
+// sequentially, stops at the first error returned by fn.
+func (store *Store) scan(ctx context.Context, start, end int64, fn func(idx int64, g game.Game) error) error {
+	if start < 0 || end > store.NumberOfGames() || start > end {
+		return ErrInvalidRange
+	}
+	reader := bufio.NewReaderSize(io.NewSectionReader(store.data,
+		start*game.MaxNumberOfChoicesPerGame,
+		(end-start)*game.MaxNumberOfChoicesPerGame), writeBufferSize)
+	gameRaw := [game.MaxNumberOfChoicesPerGame]byte{}
+	for idx := start; idx < end; idx++ {
+		// Checking the context for every game is too expensive
+		if idx%bufferMultiplier == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
 			}
-		} else if choices < target {
-			start = mid + 1
-			continue
 		}
-		end = mid - 1
+		if _, err := io.ReadFull(reader, gameRaw[:]); err != nil {
+			return err
+		}
+		g := game.Game{
+			game.Choice(gameRaw[0]), game.Choice(gameRaw[1]),
+			game.Choice(gameRaw[2]), game.Choice(gameRaw[3]),
+			game.Choice(gameRaw[4]), game.Choice(gameRaw[5]),
+		}
+		if err := fn(idx, g); err != nil {
+			return err
+		}
 	}
-	return -1, nil
+	return nil
 }
 
 // Returns the total number of games
 func (store *Store) numberOfGames() (int64, error) {
-	info, err := store.file.Stat()
-	if err != nil {
-		return 0, err
-	}
-	size := info.Size()
-	if size%game.MaxNumberOfChoicesPerGame != 0 {
+	if store.size%game.MaxNumberOfChoicesPerGame != 0 {
 		return 0, ErrInvalidFile
 	}
-	return size / game.MaxNumberOfChoicesPerGame, nil
+	return store.size / game.MaxNumberOfChoicesPerGame, nil
 }
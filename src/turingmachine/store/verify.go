@@ -0,0 +1,136 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
+)
+
+const (
+	// The default maximum number of issues reported by Store.Verify
+	DefaultVerifyMaxIssues = 10
+)
+
+var (
+	// Issue reported when a game is not sorted by Game.Value
+	ErrUnsorted = errors.New("the game is not sorted after the previous one")
+	// Issue reported when a game is equal to the previous one
+	ErrDuplicate = errors.New("the game is a duplicate of the previous one")
+	// Issue reported when a game is outside of the range for its choices
+	ErrChoicesRange = errors.New("the game is outside the range for its number of choices")
+	// Issue reported when a game does not match the regenerated one
+	ErrRegenerationMismatch = errors.New("the game does not match the regenerated store")
+	// Issue reported when the store has a different number of games than
+	// the regenerated one
+	ErrRegenerationLength = errors.New("the store has a different number of games than the regenerated one")
+
+	// Used internally to stop a scan early
+	errStopScan = errors.New("stop scan")
+)
+
+// Options for Store.Verify
+type VerifyOptions struct {
+	// The maximum number of issues to report before stopping, 0 reports all
+	MaxIssues int
+	// If set, the store is also compared with a freshly generated one.
+	// Requires a 64-bit build and a lot of memory.
+	Regenerate bool
+}
+
+// Returns a VerifyOptions with the default values
+func NewVerifyOptions() VerifyOptions {
+	return VerifyOptions{
+		MaxIssues:  DefaultVerifyMaxIssues,
+		Regenerate: false,
+	}
+}
+
+// An issue found by Store.Verify
+type Issue struct {
+	// The index of the offending game
+	Index int64
+	// The offending game (if it could be read)
+	Game game.Game
+	// Why this game is an issue
+	Err error
+}
+
+// Returns a human readable description of the issue
+func (issue Issue) String() string {
+	return fmt.Sprintf("game %d (%s): %v", issue.Index, issue.Game.Debug(), issue.Err)
+}
+
+// Checks the integrity of the store, returns the issues found (if any).
+// The following is checked:
+// - all games are sorted by Game.Value with no duplicates
+// - all games pass Game.ValidateStrict
+// - all games are within the range of their number of choices
+// - optionally, that all games match a freshly generated store
+//
+// The error is only set if the store could not be read or ctx is done.
+func (store *Store) Verify(ctx context.Context, opts VerifyOptions) ([]Issue, error) {
+	start := time.Now()
+	issues := []Issue{}
+	report := func(idx int64, g game.Game, err error) error {
+		issues = append(issues, Issue{Index: idx, Game: g, Err: err})
+		if opts.MaxIssues > 0 && len(issues) >= opts.MaxIssues {
+			return errStopScan
+		}
+		return nil
+	}
+
+	var expected solution
+	if opts.Regenerate {
+		expected = generate()
+		if int64(len(expected)) != store.NumberOfGames() {
+			idx := min(int64(len(expected)), store.NumberOfGames())
+			if err := report(idx, game.Game{}, ErrRegenerationLength); err != nil {
+				return issues, nil
+			}
+		}
+	}
+
+	var previous game.Game
+	err := store.scan(ctx, 0, store.NumberOfGames(), func(idx int64, g game.Game) error {
+		if idx > 0 {
+			if g == previous {
+				if err := report(idx, g, ErrDuplicate); err != nil {
+					return err
+				}
+			} else if g.Value() < previous.Value() {
+				if err := report(idx, g, ErrUnsorted); err != nil {
+					return err
+				}
+			}
+		}
+		previous = g
+
+		if err := g.ValidateStrict(); err != nil {
+			if err := report(idx, g, err); err != nil {
+				return err
+			}
+		}
+		if first, last := store.GameRangeByChoices(g.NumberOfChoices()); idx < first || idx >= last {
+			if err := report(idx, g, ErrChoicesRange); err != nil {
+				return err
+			}
+		}
+		if expected != nil && idx < int64(len(expected)) && expected[idx] != g {
+			if err := report(idx, g, ErrRegenerationMismatch); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errStopScan) {
+		err = nil
+	}
+	slog.Info("store verified",
+		"issues", len(issues),
+		"duration", time.Since(start).String())
+	return issues, err
+}
@@ -0,0 +1,119 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/game"
+	"github.com/stefanovazzocell/TuringMachine/src/turingmachine/store"
+)
+
+// Overwrites the game at index idx in a store file
+func overwriteGame(t *testing.T, filename string, idx int64, g game.Game) {
+	file, err := os.OpenFile(filename, os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("Failed to open %q: %v", filename, err)
+	}
+	defer file.Close()
+	raw := make([]byte, game.MaxNumberOfChoicesPerGame)
+	g.WriteTo(raw, 0)
+	if _, err = file.WriteAt(raw, idx*game.MaxNumberOfChoicesPerGame); err != nil {
+		t.Fatalf("Failed to write game %d: %v", idx, err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	t.Parallel()
+
+	games := randomGames(t, 100)
+	s, cleanup := smallStore(t, games)
+	defer cleanup()
+
+	issues, err := s.Verify(context.Background(), store.NewVerifyOptions())
+	if err != nil {
+		t.Fatalf("Verify returned error: %v", err)
+	}
+	if len(issues) != 0 {
+		t.Fatalf("Expected no issues, got %v", issues)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = s.Verify(ctx, store.NewVerifyOptions()); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestVerifyCorrupted(t *testing.T) {
+	t.Parallel()
+
+	filename := smallStoreFile(t, randomGames(t, 100))
+	defer rmFile(filename)
+	s, err := store.OpenStore(filename)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	first, err := s.GetGame(10)
+	if err != nil {
+		t.Fatalf("Failed to read game: %v", err)
+	}
+	second, err := s.GetGame(11)
+	if err != nil {
+		t.Fatalf("Failed to read game: %v", err)
+	}
+	last, err := s.GetGame(s.NumberOfGames() - 1)
+	if err != nil {
+		t.Fatalf("Failed to read game: %v", err)
+	}
+	_ = s.Close()
+
+	// Swap two games, duplicate one, and break the last one
+	overwriteGame(t, filename, 10, second)
+	overwriteGame(t, filename, 11, first)
+	overwriteGame(t, filename, 21, first)
+	overwriteGame(t, filename, 20, first)
+	last[0] = last[1]
+	overwriteGame(t, filename, 99, last)
+
+	s, err = store.OpenStore(filename)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer s.Close()
+
+	issues, err := s.Verify(context.Background(), store.VerifyOptions{})
+	if err != nil {
+		t.Fatalf("Verify returned error: %v", err)
+	}
+	expected := []struct {
+		idx int64
+		err error
+	}{
+		{11, store.ErrUnsorted},
+		{20, store.ErrUnsorted},
+		{21, store.ErrDuplicate},
+		{99, game.ErrGameChoiceOrderCriterias},
+	}
+	for _, e := range expected {
+		found := false
+		for _, issue := range issues {
+			if issue.Index == e.idx && errors.Is(issue.Err, e.err) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("Expected issue %v at index %d, got %v", e.err, e.idx, issues)
+		}
+	}
+
+	issues, err = s.Verify(context.Background(), store.VerifyOptions{MaxIssues: 2})
+	if err != nil {
+		t.Fatalf("Verify returned error: %v", err)
+	}
+	if len(issues) != 2 || issues[0].Index != 11 {
+		t.Errorf("Expected the first 2 issues starting at 11, got %v", issues)
+	}
+}
@@ -0,0 +1,369 @@
+package websocket
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// The maximum size of a message, larger messages close the connection
+	MaxMessageSize = 1 << 16
+	// The GUID used to compute Sec-WebSocket-Accept (RFC 6455, section 1.3)
+	acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	// How long to wait for the other end to acknowledge a close
+	closeTimeout = time.Second
+)
+
+// Frame opcodes (RFC 6455, section 5.2)
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+var (
+	// Error returned when the opening handshake is not valid
+	ErrBadHandshake = errors.New("invalid websocket handshake")
+	// Error returned when a message is larger than MaxMessageSize
+	ErrMessageTooLarge = errors.New("websocket message too large")
+	// Error returned when the other end does not follow the protocol
+	ErrProtocol = errors.New("websocket protocol error")
+)
+
+// A minimal websocket connection (RFC 6455) exchanging text messages.
+// Reads must happen from a single goroutine, writes are safe for concurrent
+// use.
+type Conn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	// True for the client side, which must mask the frames it sends
+	client bool
+
+	// If set, each frame must be received within this time
+	idleTimeout time.Duration
+
+	writeLock sync.Mutex
+	closed    bool
+}
+
+// Upgrades an HTTP request to a websocket connection.
+// On error, a response has already been written.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != http.MethodGet ||
+		!headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") ||
+		r.Header.Get("Sec-WebSocket-Version") != "13" || key == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return nil, ErrBadHandshake
+	}
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, err
+	}
+	// Clear the deadlines of the HTTP server, the caller manages those now
+	if err = conn.SetDeadline(time.Time{}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	_, err = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n")
+	if err == nil {
+		err = rw.Flush()
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &Conn{conn: conn, reader: rw.Reader}, nil
+}
+
+// Opens a client websocket connection to a ws:// or http:// URL (TLS is not
+// supported)
+func Dial(rawURL string) (*Conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	var conn net.Conn
+	switch u.Scheme {
+	case "ws", "http":
+		conn, err = net.Dial("tcp", hostPort(u, "80"))
+	default:
+		return nil, fmt.Errorf("%w: unsupported scheme %q", ErrBadHandshake, u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	raw := make([]byte, 16)
+	if _, err = rand.Read(raw); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(raw)
+	req := &http.Request{
+		Method: http.MethodGet,
+		URL:    &url.URL{Path: u.EscapedPath(), RawQuery: u.RawQuery},
+		Host:   u.Host,
+		Header: http.Header{
+			"Upgrade":               {"websocket"},
+			"Connection":            {"Upgrade"},
+			"Sec-WebSocket-Key":     {key},
+			"Sec-WebSocket-Version": {"13"},
+		},
+	}
+	if req.URL.Path == "" {
+		req.URL.Path = "/"
+	}
+	if err = req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols ||
+		resp.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		conn.Close()
+		return nil, fmt.Errorf("%w: %s", ErrBadHandshake, resp.Status)
+	}
+	return &Conn{conn: conn, reader: reader, client: true}, nil
+}
+
+// Reads the next data message, answering pings along the way.
+// Returns io.EOF once the connection was closed by the other end.
+func (c *Conn) ReadMessage() ([]byte, error) {
+	var message []byte
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+		switch opcode {
+		case opPing:
+			if err = c.writeFrame(opPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			c.Close()
+			return nil, io.EOF
+		case opText, opBinary:
+			if message != nil {
+				return nil, ErrProtocol
+			}
+			message = payload
+		case opContinuation:
+			if message == nil {
+				return nil, ErrProtocol
+			}
+			if len(message)+len(payload) > MaxMessageSize {
+				return nil, ErrMessageTooLarge
+			}
+			message = append(message, payload...)
+		default:
+			return nil, ErrProtocol
+		}
+		if fin {
+			return message, nil
+		}
+	}
+}
+
+// Reads the next message as JSON into v
+func (c *Conn) ReadJSON(v any) error {
+	message, err := c.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(message, v)
+}
+
+// Writes a text message
+func (c *Conn) WriteMessage(data []byte) error {
+	return c.writeFrame(opText, data)
+}
+
+// Writes v as a JSON text message
+func (c *Conn) WriteJSON(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.WriteMessage(data)
+}
+
+// Sends a ping, the other end answers with a pong which ReadMessage skips
+func (c *Conn) Ping() error {
+	return c.writeFrame(opPing, nil)
+}
+
+// Sets the time within which each frame (including pongs) must be received,
+// 0 disables it.
+// Sending pings more often than this keeps a healthy connection open.
+func (c *Conn) SetIdleTimeout(timeout time.Duration) {
+	c.idleTimeout = timeout
+}
+
+// Sends a close frame and closes the connection
+func (c *Conn) Close() error {
+	_ = c.conn.SetWriteDeadline(time.Now().Add(closeTimeout))
+	_ = c.writeFrame(opClose, []byte{0x03, 0xE8}) // 1000: normal closure
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	c.closed = true
+	return c.conn.Close()
+}
+
+/*
+* Helpers
+**/
+
+// Reads a single frame
+func (c *Conn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	if c.idleTimeout > 0 {
+		if err = c.conn.SetReadDeadline(time.Now().Add(c.idleTimeout)); err != nil {
+			return
+		}
+	}
+	header := [2]byte{}
+	if _, err = io.ReadFull(c.reader, header[:]); err != nil {
+		return
+	}
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0F
+	masked := header[1]&0x80 != 0
+	// Clients must mask their frames, servers must not
+	if masked == c.client || header[0]&0x70 != 0 {
+		err = ErrProtocol
+		return
+	}
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		ext := [2]byte{}
+		if _, err = io.ReadFull(c.reader, ext[:]); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		ext := [8]byte{}
+		if _, err = io.ReadFull(c.reader, ext[:]); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if length > MaxMessageSize {
+		err = ErrMessageTooLarge
+		return
+	}
+	mask := [4]byte{}
+	if masked {
+		if _, err = io.ReadFull(c.reader, mask[:]); err != nil {
+			return
+		}
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.reader, payload); err != nil {
+		return
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return
+}
+
+// Writes a single final frame
+func (c *Conn) writeFrame(opcode byte, payload []byte) error {
+	frame := make([]byte, 0, 14+len(payload))
+	frame = append(frame, 0x80|opcode)
+	maskBit := byte(0)
+	if c.client {
+		maskBit = 0x80
+	}
+	switch {
+	case len(payload) < 126:
+		frame = append(frame, maskBit|byte(len(payload)))
+	case len(payload) <= 0xFFFF:
+		frame = append(frame, maskBit|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	default:
+		frame = append(frame, maskBit|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(len(payload)))
+	}
+	if c.client {
+		mask := [4]byte{}
+		if _, err := rand.Read(mask[:]); err != nil {
+			return err
+		}
+		frame = append(frame, mask[:]...)
+		for i, b := range payload {
+			frame = append(frame, b^mask[i%4])
+		}
+	} else {
+		frame = append(frame, payload...)
+	}
+
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	if c.closed {
+		return net.ErrClosed
+	}
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// Returns the Sec-WebSocket-Accept value for a Sec-WebSocket-Key
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// Returns true if a comma separated header contains a token (case
+// insensitive)
+func headerContains(header http.Header, name string, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, field := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(field), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Returns the host:port of a URL, with a default port
+func hostPort(u *url.URL, defaultPort string) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	return net.JoinHostPort(u.Hostname(), defaultPort)
+}
@@ -0,0 +1,150 @@
+package websocket_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stefanovazzocell/TuringMachine/src/websocket"
+)
+
+// Returns a test server echoing every message back
+func echoServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err = conn.WriteMessage(message); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestEcho(t *testing.T) {
+	t.Parallel()
+
+	server := echoServer(t)
+	conn, err := websocket.Dial(server.URL + "/echo?x=1")
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	// Messages of every length encoding
+	for _, size := range []int{0, 1, 125, 126, 1000, 1 << 16} {
+		message := bytes.Repeat([]byte("x"), size)
+		if err = conn.WriteMessage(message); err != nil {
+			t.Fatalf("Failed to write %d bytes: %v", size, err)
+		}
+		if err = conn.Ping(); err != nil {
+			t.Fatalf("Failed to ping: %v", err)
+		}
+		echo, err := conn.ReadMessage()
+		if err != nil || !bytes.Equal(echo, message) {
+			t.Fatalf("Expected an echo of %d bytes, got %d bytes (%v)", size, len(echo), err)
+		}
+	}
+
+	type payload struct {
+		Type string `json:"type"`
+	}
+	if err = conn.WriteJSON(payload{Type: "hello"}); err != nil {
+		t.Fatalf("Failed to write JSON: %v", err)
+	}
+	echo := payload{}
+	if err = conn.ReadJSON(&echo); err != nil || echo.Type != "hello" {
+		t.Fatalf("ReadJSON(...) = (%+v, %v), expected hello", echo, err)
+	}
+}
+
+func TestMessageTooLarge(t *testing.T) {
+	t.Parallel()
+
+	server := echoServer(t)
+	conn, err := websocket.Dial(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+	if err = conn.WriteMessage(make([]byte, websocket.MaxMessageSize+1)); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	// The server drops the connection
+	if _, err = conn.ReadMessage(); err == nil {
+		t.Fatal("Expected an error after sending a message too large")
+	}
+}
+
+func TestServerClose(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		_ = conn.WriteMessage([]byte("bye"))
+		conn.Close()
+	}))
+	defer server.Close()
+
+	conn, err := websocket.Dial(strings.Replace(server.URL, "http://", "ws://", 1))
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+	if message, err := conn.ReadMessage(); err != nil || string(message) != "bye" {
+		t.Fatalf("ReadMessage() = (%q, %v), expected bye", message, err)
+	}
+	if _, err = conn.ReadMessage(); !errors.Is(err, io.EOF) {
+		t.Fatalf("Expected io.EOF after the server closed, got %v", err)
+	}
+}
+
+func TestIdleTimeout(t *testing.T) {
+	t.Parallel()
+
+	server := echoServer(t)
+	conn, err := websocket.Dial(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+	conn.SetIdleTimeout(10 * time.Millisecond)
+	if _, err = conn.ReadMessage(); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("Expected a timeout without messages, got %v", err)
+	}
+}
+
+func TestBadHandshake(t *testing.T) {
+	t.Parallel()
+
+	server := echoServer(t)
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected %d for a plain request, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+	if _, err = websocket.Dial("ftp://example.com"); !errors.Is(err, websocket.ErrBadHandshake) {
+		t.Errorf("Expected ErrBadHandshake for an unsupported scheme, got %v", err)
+	}
+}